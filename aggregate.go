@@ -0,0 +1,224 @@
+package kapacitor
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/influxdata/kapacitor/edge"
+	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/pipeline"
+	"github.com/influxdata/kapacitor/sketch"
+)
+
+// The functions computed if neither functions nor quantiles are specified.
+var defaultAggregates = []string{
+	pipeline.AggregateCount,
+	pipeline.AggregateSum,
+	pipeline.AggregateMean,
+	pipeline.AggregateMin,
+	pipeline.AggregateMax,
+}
+
+type AggregateNode struct {
+	node
+	a *pipeline.AggregateNode
+
+	functions []string
+	// Names of the quantile fields in the order of the quantiles.
+	quantileNames []string
+}
+
+// Create a new AggregateNode, which computes running aggregates per group.
+func newAggregateNode(et *ExecutingTask, n *pipeline.AggregateNode, l *log.Logger) (*AggregateNode, error) {
+	an := &AggregateNode{
+		node:      node{Node: n, et: et, logger: l},
+		a:         n,
+		functions: n.Aggregates,
+	}
+	if len(an.functions) == 0 && len(n.QuantileList) == 0 {
+		an.functions = defaultAggregates
+	}
+	if len(n.QuantileList) > 0 {
+		// Validate the sketch configuration up front.
+		if _, err := sketch.NewDDSketch(n.Accuracy, sketch.DefaultMaxBins); err != nil {
+			return nil, err
+		}
+	}
+	for _, q := range n.QuantileList {
+		an.quantileNames = append(an.quantileNames, "p"+strconv.FormatFloat(q*100, 'g', 10, 64))
+	}
+	an.node.runF = an.runAggregate
+	return an, nil
+}
+
+func (n *AggregateNode) runAggregate([]byte) error {
+	consumer := n.newGroupedConsumer(n)
+	return consumer.Consume()
+}
+
+func (n *AggregateNode) NewGroup(group edge.GroupInfo, first edge.PointMeta) (edge.Receiver, error) {
+	g := &aggregateGroup{
+		n:     n,
+		name:  first.Name(),
+		group: group,
+	}
+	if len(n.a.QuantileList) > 0 {
+		g.sketch, _ = sketch.NewDDSketch(n.a.Accuracy, sketch.DefaultMaxBins)
+	}
+	g.reset()
+	return edge.NewReceiverFromForwardReceiverWithStats(
+		n.outs,
+		edge.NewTimedForwardReceiver(n.timer, g),
+	), nil
+}
+
+type aggregateGroup struct {
+	n     *AggregateNode
+	name  string
+	group edge.GroupInfo
+
+	count int64
+	sum   float64
+	mean  float64
+	// Sum of squares of differences from the mean.
+	m2  float64
+	min float64
+	max float64
+
+	sketch *sketch.DDSketch
+
+	// Time of the next emit if emitting on an interval.
+	nextEmit time.Time
+	// Time of the current batch.
+	batchTime time.Time
+}
+
+func (g *aggregateGroup) BeginBatch(begin edge.BeginBatchMessage) (edge.Message, error) {
+	g.batchTime = begin.Time()
+	return nil, nil
+}
+
+func (g *aggregateGroup) BatchPoint(bp edge.BatchPointMessage) (edge.Message, error) {
+	msg := g.tick(bp.Time())
+	g.add(bp.Fields())
+	return msg, nil
+}
+
+func (g *aggregateGroup) EndBatch(end edge.EndBatchMessage) (edge.Message, error) {
+	if g.n.a.Every != 0 {
+		return nil, nil
+	}
+	return g.emit(g.batchTime), nil
+}
+
+func (g *aggregateGroup) Point(p edge.PointMessage) (edge.Message, error) {
+	msg := g.tick(p.Time())
+	g.add(p.Fields())
+	if g.n.a.Every == 0 {
+		msg = g.emit(p.Time())
+	}
+	return msg, nil
+}
+
+func (g *aggregateGroup) Barrier(b edge.BarrierMessage) (edge.Message, error) {
+	return b, nil
+}
+
+func (g *aggregateGroup) DeleteGroup(d edge.DeleteGroupMessage) (edge.Message, error) {
+	return d, nil
+}
+
+// tick emits the aggregates if the interval has elapsed at time t.
+func (g *aggregateGroup) tick(t time.Time) edge.Message {
+	every := g.n.a.Every
+	if every == 0 {
+		return nil
+	}
+	if g.nextEmit.IsZero() {
+		g.nextEmit = t.Truncate(every).Add(every)
+		return nil
+	}
+	if t.Before(g.nextEmit) {
+		return nil
+	}
+	msg := g.emit(g.nextEmit)
+	g.nextEmit = t.Truncate(every).Add(every)
+	return msg
+}
+
+func (g *aggregateGroup) add(fields models.Fields) {
+	v, ok := numToFloat(fields[g.n.a.Field])
+	if !ok {
+		return
+	}
+	g.count++
+	g.sum += v
+	delta := v - g.mean
+	g.mean += delta / float64(g.count)
+	g.m2 += delta * (v - g.mean)
+	if v < g.min {
+		g.min = v
+	}
+	if v > g.max {
+		g.max = v
+	}
+	if g.sketch != nil {
+		g.sketch.Add(v)
+	}
+}
+
+// emit returns the current aggregates as a point, or nil if no data was aggregated.
+func (g *aggregateGroup) emit(t time.Time) edge.Message {
+	if g.count == 0 {
+		return nil
+	}
+	fields := make(models.Fields, len(g.n.functions)+len(g.n.quantileNames))
+	for _, f := range g.n.functions {
+		switch f {
+		case pipeline.AggregateCount:
+			fields[f] = g.count
+		case pipeline.AggregateSum:
+			fields[f] = g.sum
+		case pipeline.AggregateMean:
+			fields[f] = g.mean
+		case pipeline.AggregateMin:
+			fields[f] = g.min
+		case pipeline.AggregateMax:
+			fields[f] = g.max
+		case pipeline.AggregateStddev:
+			stddev := 0.0
+			if g.count > 1 {
+				stddev = math.Sqrt(g.m2 / float64(g.count-1))
+			}
+			fields[f] = stddev
+		}
+	}
+	for i, q := range g.n.a.QuantileList {
+		fields[g.n.quantileNames[i]] = g.sketch.Quantile(q)
+	}
+	p := edge.NewPointMessage(
+		g.name, "", "",
+		g.group.Dimensions,
+		fields,
+		g.group.Tags,
+		t,
+	)
+	if g.n.a.ResetFlag {
+		g.reset()
+	}
+	return p
+}
+
+func (g *aggregateGroup) reset() {
+	g.count = 0
+	g.sum = 0
+	g.mean = 0
+	g.m2 = 0
+	g.min = math.Inf(1)
+	g.max = math.Inf(-1)
+	if g.sketch != nil {
+		g.sketch.Reset()
+	}
+}
@@ -2,6 +2,7 @@ package kapacitor
 
 import (
 	"bytes"
+	"encoding/gob"
 	"encoding/json"
 	"fmt"
 	html "html/template"
@@ -20,6 +21,7 @@ import (
 	"github.com/influxdata/kapacitor/services/hipchat"
 	"github.com/influxdata/kapacitor/services/httppost"
 	"github.com/influxdata/kapacitor/services/mqtt"
+	"github.com/influxdata/kapacitor/services/namespace"
 	"github.com/influxdata/kapacitor/services/opsgenie"
 	"github.com/influxdata/kapacitor/services/pagerduty"
 	"github.com/influxdata/kapacitor/services/pushover"
@@ -29,7 +31,6 @@ import (
 	"github.com/influxdata/kapacitor/services/snmptrap"
 	"github.com/influxdata/kapacitor/services/telegram"
 	"github.com/influxdata/kapacitor/services/victorops"
-	"github.com/influxdata/kapacitor/tick/ast"
 	"github.com/influxdata/kapacitor/tick/stateful"
 	"github.com/pkg/errors"
 )
@@ -72,6 +73,25 @@ type AlertNode struct {
 
 	levelResets  []stateful.Expression
 	lrScopePools []stateful.ScopePool
+
+	mu     sync.Mutex
+	states map[models.GroupID]*alertState
+	// State of groups restored from a snapshot that have not received data yet.
+	restored map[models.GroupID]alertStateSnapshot
+}
+
+type alertSnapshot struct {
+	Groups map[models.GroupID]alertStateSnapshot
+}
+
+type alertStateSnapshot struct {
+	History        []alert.Level
+	Idx            int
+	Flapping       bool
+	Changed        bool
+	FirstTriggered time.Time
+	LastTriggered  time.Time
+	Expired        bool
 }
 
 // Create a new  AlertNode which caches the most recent item and exposes it over the HTTP API.
@@ -82,9 +102,16 @@ func newAlertNode(et *ExecutingTask, n *pipeline.AlertNode, l *log.Logger) (an *
 	}
 	an.node.runF = an.runAlert
 
-	an.topic = n.Topic
+	// Topics of a task within a namespace belong to the namespace.
+	ns, taskID := namespace.Split(et.Task.ID)
+	if n.Topic != "" {
+		if namespace.Qualified(n.Topic) {
+			return nil, fmt.Errorf("topic %q must not contain %q", n.Topic, namespace.Separator)
+		}
+		an.topic = namespace.Qualify(ns, n.Topic)
+	}
 	// Create anonymous topic name
-	an.anonTopic = fmt.Sprintf("%s:%s:%s", et.tm.ID(), et.Task.ID, an.Name())
+	an.anonTopic = namespace.Qualify(ns, fmt.Sprintf("%s:%s:%s", et.tm.ID(), taskID, an.Name()))
 
 	// Create buffer pool for the templates
 	an.bufPool = sync.Pool{
@@ -403,14 +430,14 @@ func newAlertNode(et *ExecutingTask, n *pipeline.AlertNode, l *log.Logger) (an *
 		}
 
 		an.levels[alert.Info] = statefulExpression
-		an.scopePools[alert.Info] = stateful.NewScopePool(ast.FindReferenceVariables(n.Info.Expression))
+		an.scopePools[alert.Info] = stateful.NewScopePool(stateful.FindReferenceVariables(n.Info.Expression))
 		if n.InfoReset != nil {
 			lstatefulExpression, lexpressionCompileError := stateful.NewExpression(n.InfoReset.Expression)
 			if lexpressionCompileError != nil {
 				return nil, fmt.Errorf("Failed to compile stateful expression for infoReset: %s", lexpressionCompileError)
 			}
 			an.levelResets[alert.Info] = lstatefulExpression
-			an.lrScopePools[alert.Info] = stateful.NewScopePool(ast.FindReferenceVariables(n.InfoReset.Expression))
+			an.lrScopePools[alert.Info] = stateful.NewScopePool(stateful.FindReferenceVariables(n.InfoReset.Expression))
 		}
 	}
 
@@ -420,14 +447,14 @@ func newAlertNode(et *ExecutingTask, n *pipeline.AlertNode, l *log.Logger) (an *
 			return nil, fmt.Errorf("Failed to compile stateful expression for warn: %s", expressionCompileError)
 		}
 		an.levels[alert.Warning] = statefulExpression
-		an.scopePools[alert.Warning] = stateful.NewScopePool(ast.FindReferenceVariables(n.Warn.Expression))
+		an.scopePools[alert.Warning] = stateful.NewScopePool(stateful.FindReferenceVariables(n.Warn.Expression))
 		if n.WarnReset != nil {
 			lstatefulExpression, lexpressionCompileError := stateful.NewExpression(n.WarnReset.Expression)
 			if lexpressionCompileError != nil {
 				return nil, fmt.Errorf("Failed to compile stateful expression for warnReset: %s", lexpressionCompileError)
 			}
 			an.levelResets[alert.Warning] = lstatefulExpression
-			an.lrScopePools[alert.Warning] = stateful.NewScopePool(ast.FindReferenceVariables(n.WarnReset.Expression))
+			an.lrScopePools[alert.Warning] = stateful.NewScopePool(stateful.FindReferenceVariables(n.WarnReset.Expression))
 		}
 	}
 
@@ -437,14 +464,14 @@ func newAlertNode(et *ExecutingTask, n *pipeline.AlertNode, l *log.Logger) (an *
 			return nil, fmt.Errorf("Failed to compile stateful expression for crit: %s", expressionCompileError)
 		}
 		an.levels[alert.Critical] = statefulExpression
-		an.scopePools[alert.Critical] = stateful.NewScopePool(ast.FindReferenceVariables(n.Crit.Expression))
+		an.scopePools[alert.Critical] = stateful.NewScopePool(stateful.FindReferenceVariables(n.Crit.Expression))
 		if n.CritReset != nil {
 			lstatefulExpression, lexpressionCompileError := stateful.NewExpression(n.CritReset.Expression)
 			if lexpressionCompileError != nil {
 				return nil, fmt.Errorf("Failed to compile stateful expression for critReset: %s", lexpressionCompileError)
 			}
 			an.levelResets[alert.Critical] = lstatefulExpression
-			an.lrScopePools[alert.Critical] = stateful.NewScopePool(ast.FindReferenceVariables(n.CritReset.Expression))
+			an.lrScopePools[alert.Critical] = stateful.NewScopePool(stateful.FindReferenceVariables(n.CritReset.Expression))
 		}
 	}
 
@@ -463,9 +490,19 @@ func newAlertNode(et *ExecutingTask, n *pipeline.AlertNode, l *log.Logger) (an *
 	return
 }
 
-func (n *AlertNode) runAlert([]byte) error {
+func (n *AlertNode) runAlert(snapshot []byte) error {
+	n.states = make(map[models.GroupID]*alertState)
+	n.restored = make(map[models.GroupID]alertStateSnapshot)
+	if len(snapshot) > 0 {
+		if err := n.restore(snapshot); err != nil {
+			n.incrementErrorCount()
+			n.logger.Println("E! failed to restore alert state, restoring from topics instead:", err)
+		}
+	}
+
 	// Register delete hook
-	if n.hasAnonTopic() {
+	// Shadow tasks capture events instead of handling them so no handlers are registered.
+	if n.hasAnonTopic() && n.et.shadow == nil {
 		n.et.tm.registerDeleteHookForTask(n.et.Task.ID, deleteAlertHook(n.anonTopic))
 
 		// Register Handlers on topic
@@ -496,16 +533,16 @@ func (n *AlertNode) runAlert([]byte) error {
 	n.statMap.Set(statsCritsTriggered, n.critsTriggered)
 
 	// Setup consumer
-	consumer := edge.NewGroupedConsumer(
-		n.ins[0],
-		n,
-	)
-	n.statMap.Set(statCardinalityGauge, consumer.CardinalityVar())
+	consumer := n.newGroupedConsumer(n)
 
 	if err := consumer.Consume(); err != nil {
 		return err
 	}
 
+	if n.et.shadow != nil {
+		return nil
+	}
+
 	// Close the anonymous topic.
 	n.et.tm.AlertService.CloseTopic(n.anonTopic)
 
@@ -523,7 +560,21 @@ func (n *AlertNode) NewGroup(group edge.GroupInfo, first edge.PointMeta) (edge.R
 	}
 	t := first.Time()
 
-	state := n.restoreEventState(id, t)
+	n.mu.Lock()
+	snapshot, ok := n.restored[group.ID]
+	delete(n.restored, group.ID)
+	n.mu.Unlock()
+
+	var state *alertState
+	if ok {
+		state = n.restoreSnapshotState(id, t, snapshot)
+	} else {
+		state = n.restoreEventState(id, t)
+	}
+
+	n.mu.Lock()
+	n.states[group.ID] = state
+	n.mu.Unlock()
 
 	return edge.NewReceiverFromForwardReceiverWithStats(
 		n.outs,
@@ -546,6 +597,66 @@ func (n *AlertNode) restoreEventState(id string, t time.Time) *alertState {
 	return state
 }
 
+// restoreSnapshotState restores the state of an alert from a snapshot.
+// The state of the topics takes precedence if it disagrees with the snapshot.
+func (n *AlertNode) restoreSnapshotState(id string, t time.Time, s alertStateSnapshot) *alertState {
+	currentLevel, _ := n.restoreEvent(id)
+	if len(s.History) != int(n.a.History) || s.Idx >= len(s.History) || s.History[s.Idx] != currentLevel {
+		return n.restoreEventState(id, t)
+	}
+	return &alertState{
+		n:              n,
+		buffer:         new(edge.BatchBuffer),
+		history:        s.History,
+		idx:            s.Idx,
+		flapping:       s.Flapping,
+		changed:        s.Changed,
+		firstTriggered: s.FirstTriggered,
+		lastTriggered:  s.LastTriggered,
+		expired:        s.Expired,
+	}
+}
+
+// EvictGroup releases the state of an evicted group.
+func (n *AlertNode) EvictGroup(group models.GroupID) {
+	n.mu.Lock()
+	delete(n.states, group)
+	n.mu.Unlock()
+}
+
+func (n *AlertNode) snapshot() ([]byte, error) {
+	n.mu.Lock()
+	s := alertSnapshot{
+		Groups: make(map[models.GroupID]alertStateSnapshot, len(n.states)+len(n.restored)),
+	}
+	for id, as := range n.restored {
+		s.Groups[id] = as
+	}
+	for id, state := range n.states {
+		s.Groups[id] = state.snapshot()
+	}
+	n.mu.Unlock()
+	if len(s.Groups) == 0 {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (n *AlertNode) restore(data []byte) error {
+	var s alertSnapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return err
+	}
+	n.mu.Lock()
+	n.restored = s.Groups
+	n.mu.Unlock()
+	return nil
+}
+
 func (n *AlertNode) newAlertState() *alertState {
 	return &alertState{
 		history: make([]alert.Level, n.a.History),
@@ -577,7 +688,8 @@ func (n *AlertNode) restoreEvent(id string) (alert.Level, time.Time) {
 			topicFound = true
 		}
 	}
-	if topicState.Level != anonTopicState.Level {
+	// Shadow tasks must not modify the state of the topics.
+	if topicState.Level != anonTopicState.Level && n.et.shadow == nil {
 		if anonFound && topicFound {
 			// Anon topic takes precedence
 			if err := n.et.tm.AlertService.UpdateEvent(n.topic, anonTopicState); err != nil {
@@ -625,6 +737,19 @@ func (n *AlertNode) handleEvent(event alert.Event) {
 	}
 	n.logger.Printf("D! %v alert triggered id:%s msg:%s data:%v", event.State.Level, event.State.ID, event.State.Message, event.Data.Result.Series[0])
 
+	if n.et.shadow != nil {
+		n.et.shadow.record(n.Name(), ShadowAlert, event.State.Time, shadowAlertData{
+			Topic:    n.topic,
+			ID:       event.State.ID,
+			Message:  event.State.Message,
+			Details:  event.State.Details,
+			Level:    event.State.Level.String(),
+			Duration: event.State.Duration,
+			Handlers: len(n.handlers),
+		})
+		return
+	}
+
 	// If we have anon handlers, emit event to the anonTopic
 	if n.hasAnonTopic() {
 		event.Topic = n.anonTopic
@@ -648,6 +773,18 @@ func (n *AlertNode) handleEvent(event alert.Event) {
 	}
 }
 
+// An alert event captured by a shadow task.
+type shadowAlertData struct {
+	Topic    string        `json:"topic,omitempty"`
+	ID       string        `json:"id"`
+	Message  string        `json:"message"`
+	Details  string        `json:"details,omitempty"`
+	Level    string        `json:"level"`
+	Duration time.Duration `json:"duration"`
+	// Number of handlers that would have handled the event.
+	Handlers int `json:"handlers"`
+}
+
 func (n *AlertNode) determineLevel(p edge.FieldsTagsTimeGetter, currentLevel alert.Level) alert.Level {
 	if higherLevel, found := n.findFirstMatchLevel(alert.Critical, currentLevel-1, p); found {
 		return higherLevel
@@ -739,6 +876,25 @@ type alertState struct {
 	// Note: Alerts are not triggered for every event.
 	lastTriggered time.Time
 	expired       bool
+
+	// mu guards the state while it is being snapshot.
+	mu sync.Mutex
+}
+
+func (a *alertState) snapshot() alertStateSnapshot {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	history := make([]alert.Level, len(a.history))
+	copy(history, a.history)
+	return alertStateSnapshot{
+		History:        history,
+		Idx:            a.idx,
+		Flapping:       a.flapping,
+		Changed:        a.changed,
+		FirstTriggered: a.firstTriggered,
+		LastTriggered:  a.lastTriggered,
+		Expired:        a.expired,
+	}
 }
 
 func (a *alertState) BeginBatch(begin edge.BeginBatchMessage) (edge.Message, error) {
@@ -754,6 +910,8 @@ func (a *alertState) EndBatch(end edge.EndBatchMessage) (edge.Message, error) {
 }
 
 func (a *alertState) BufferedBatch(b edge.BufferedBatchMessage) (edge.Message, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	begin := b.Begin()
 	id, err := a.n.renderID(begin.Name(), begin.GroupID(), begin.Tags())
 	if err != nil {
@@ -846,6 +1004,8 @@ func (a *alertState) BufferedBatch(b edge.BufferedBatchMessage) (edge.Message, e
 }
 
 func (a *alertState) Point(p edge.PointMessage) (edge.Message, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	id, err := a.n.renderID(p.Name(), p.GroupID(), p.Tags())
 	if err != nil {
 		return nil, err
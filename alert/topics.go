@@ -21,6 +21,10 @@ type Topics struct {
 
 	topics map[string]*Topic
 
+	// AllowHandle reports whether a handler of the topic may handle another event, if set.
+	// It is called once for each handler an event is delivered to.
+	AllowHandle func(topic string) bool
+
 	logger *log.Logger
 }
 
@@ -103,7 +107,7 @@ func (s *Topics) Collect(event Event) error {
 		s.mu.Unlock()
 	}
 
-	return topic.collect(event)
+	return topic.collect(event, s.AllowHandle)
 }
 
 func (s *Topics) DeleteTopic(topic string) {
@@ -312,23 +316,26 @@ func (t *Topic) close() {
 	vars.DeleteStatistic(t.statsKey)
 }
 
-func (t *Topic) collect(event Event) error {
+func (t *Topic) collect(event Event, allow func(topic string) bool) error {
 	prev, ok := t.updateEvent(event.State)
 	if ok {
 		event.previousState = prev
 	}
 
 	t.collected.Add(1)
-	return t.handleEvent(event)
+	return t.handleEvent(event, allow)
 }
 
-func (t *Topic) handleEvent(event Event) error {
+func (t *Topic) handleEvent(event Event, allow func(topic string) bool) error {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 
 	// Handle event
 	var errs multiError
 	for _, h := range t.handlers {
+		if allow != nil && !allow(t.id) {
+			continue
+		}
 		err := h.Handle(event)
 		if err != nil {
 			errs = append(errs, err)
@@ -11,6 +11,7 @@ type Interface interface {
 	Authenticate(username, password string) (User, error)
 	User(username string) (User, error)
 	SubscriptionUser(token string) (User, error)
+	TokenUser(token string) (User, error)
 	GrantSubscriptionAccess(token, db, rp string) error
 	ListSubscriptionTokens() ([]string, error)
 	RevokeSubscriptionAccess(token string) error
@@ -54,6 +55,16 @@ func (p Privilege) String() string {
 	}
 }
 
+// ParsePrivilege returns the privilege with the given name.
+func ParsePrivilege(s string) (Privilege, error) {
+	for _, p := range PrivilegeList {
+		if p.String() == s {
+			return p, nil
+		}
+	}
+	return NoPrivileges, fmt.Errorf("unknown privilege %q", s)
+}
+
 type Action struct {
 	Resource  string
 	Privilege Privilege
@@ -135,6 +146,7 @@ func (u User) AuthorizeAction(action Action) error {
 	// Find a matching resource of the form /path/to/resource
 	// where if the resource is /a/b/c and the user has permision to /a/b
 	// then it is considered valid.
+	// Otherwise a resource pattern such as /a/*/c or /a/b* may match the resource or one of its parents.
 	if !path.IsAbs(action.Resource) {
 		return fmt.Errorf("invalid action resource: %q, must be an absolute path", action.Resource)
 	}
@@ -157,6 +169,9 @@ func (u User) AuthorizeAction(action Action) error {
 			// Pop off the last piece of the resource and try again
 			resource = path.Dir(resource)
 		}
+		if u.authorizedByPattern(path.Clean(action.Resource), action.Privilege) {
+			return nil
+		}
 	}
 	return authError{
 		username: u.name,
@@ -164,6 +179,30 @@ func (u User) AuthorizeAction(action Action) error {
 	}
 }
 
+// authorizedByPattern reports whether a resource pattern of the user matches
+// the resource or one of its parents and grants the privilege.
+// Patterns are resources containing shell/glob characters, see https://golang.org/pkg/path/#Match
+func (u User) authorizedByPattern(resource string, privilege Privilege) bool {
+	for pattern, p := range u.privileges {
+		if !isPattern(pattern) || (p&privilege == 0 && p != AllPrivileges) {
+			continue
+		}
+		for r := resource; ; r = path.Dir(r) {
+			if match, _ := path.Match(pattern, r); match {
+				return true
+			}
+			if r == "/" {
+				break
+			}
+		}
+	}
+	return false
+}
+
+func isPattern(resource string) bool {
+	return strings.ContainsAny(resource, "*?[")
+}
+
 // All auth errors are of this type.
 type authError struct {
 	username string
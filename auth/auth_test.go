@@ -45,6 +45,21 @@ func Test_Privilege_String(t *testing.T) {
 	}
 }
 
+func Test_ParsePrivilege(t *testing.T) {
+	for _, p := range auth.PrivilegeList {
+		got, err := auth.ParsePrivilege(p.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != p {
+			t.Errorf("unexpected privilege: got %v exp %v", got, p)
+		}
+	}
+	if _, err := auth.ParsePrivilege("unknown"); err == nil {
+		t.Error("expected error parsing unknown privilege")
+	}
+}
+
 func Test_NewUser(t *testing.T) {
 	privs := map[string][]auth.Privilege{
 		"/simple/path/":               []auth.Privilege{auth.ReadPrivilege, auth.WritePrivilege},
@@ -306,6 +321,42 @@ func Test_User_AuthorizeAction(t *testing.T) {
 			authorized: false,
 			err:        errors.New(`user natalie does not have "write" privilege for resource "/a/b/c"`),
 		},
+		{
+			username: "patrick",
+			privileges: map[string][]auth.Privilege{
+				"/api/tasks/*": []auth.Privilege{auth.ReadPrivilege},
+			},
+			action: auth.Action{
+				Resource:  "/api/tasks/cpu/extra",
+				Privilege: auth.ReadPrivilege,
+			},
+			authorized: true,
+			err:        nil,
+		},
+		{
+			username: "paula",
+			privileges: map[string][]auth.Privilege{
+				"/api/topics/team-x*": []auth.Privilege{auth.WritePrivilege},
+			},
+			action: auth.Action{
+				Resource:  "/api/topics/team-y",
+				Privilege: auth.WritePrivilege,
+			},
+			authorized: false,
+			err:        errors.New(`user paula does not have "write" privilege for resource "/api/topics/team-y"`),
+		},
+		{
+			username: "peter",
+			privileges: map[string][]auth.Privilege{
+				"/api/topics/team-x*": []auth.Privilege{auth.ReadPrivilege},
+			},
+			action: auth.Action{
+				Resource:  "/api/topics/team-x1",
+				Privilege: auth.WritePrivilege,
+			},
+			authorized: false,
+			err:        errors.New(`user peter does not have "write" privilege for resource "/api/topics/team-x1"`),
+		},
 		{
 			username: "katherine",
 			privileges: map[string][]auth.Privilege{
@@ -89,7 +89,7 @@ func newAutoscaleNode(
 	if err != nil {
 		return nil, errors.Wrap(err, "invalid replicas expression")
 	}
-	replicasScopePool := stateful.NewScopePool(ast.FindReferenceVariables(replicas.Expression))
+	replicasScopePool := stateful.NewScopePool(stateful.FindReferenceVariables(replicas.Expression))
 	kn := &AutoscaleNode{
 		node:              node{Node: n, et: et, logger: l},
 		resourceStates:    make(map[string]resourceState),
@@ -115,11 +115,7 @@ func (n *AutoscaleNode) runAutoscale([]byte) error {
 	n.statMap.Set(statsAutoscaleDecreaseEventsCount, n.decreaseCount)
 	n.statMap.Set(statsAutoscaleCooldownDropsCount, n.cooldownDropsCount)
 
-	consumer := edge.NewGroupedConsumer(
-		n.ins[0],
-		n,
-	)
-	n.statMap.Set(statCardinalityGauge, consumer.CardinalityVar())
+	consumer := n.newGroupedConsumer(n)
 	return consumer.Consume()
 }
 
@@ -250,7 +246,7 @@ func (n *AutoscaleNode) handlePoint(streamName string, dims models.Dimensions, p
 	}
 
 	// We have a valid event to apply
-	if err := n.applyEvent(e); err != nil {
+	if err := n.applyEvent(t, e); err != nil {
 		return nil, errors.Wrap(err, "failed to apply scaling event")
 	}
 
@@ -283,12 +279,27 @@ func (n *AutoscaleNode) handlePoint(streamName string, dims models.Dimensions, p
 	), nil
 }
 
-func (n *AutoscaleNode) applyEvent(e event) error {
+func (n *AutoscaleNode) applyEvent(t time.Time, e event) error {
+	if n.et.shadow != nil {
+		n.et.shadow.record(n.Name(), ShadowAutoscale, t, shadowScaleData{
+			Resource: e.ID.ID(),
+			Old:      e.Old,
+			New:      e.New,
+		})
+		return nil
+	}
 	n.logger.Printf("D! setting replicas to %d was %d for %q", e.New, e.Old, e.ID)
 	err := n.a.SetReplicas(e.ID, e.New)
 	return errors.Wrapf(err, "failed to set new replica count for %q", e.ID)
 }
 
+// A scaling event captured by a shadow task.
+type shadowScaleData struct {
+	Resource string `json:"resource"`
+	Old      int    `json:"old"`
+	New      int    `json:"new"`
+}
+
 func (n *AutoscaleNode) evalExpr(
 	current int,
 	expr stateful.Expression,
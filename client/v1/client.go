@@ -12,6 +12,7 @@ import (
 	"net/url"
 	"path"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/influxdata/influxdb/influxql"
@@ -50,6 +51,11 @@ const (
 	storagePath       = basePath + "/storage"
 	storesPath        = storagePath + "/stores"
 	backupPath        = storagePath + "/backup"
+	usersPath         = basePath + "/users"
+	tokensPath        = basePath + "/tokens"
+	auditPath         = basePath + "/audit"
+	secretsPath       = basePath + "/secrets"
+	namespacesPath    = basePath + "/namespaces"
 )
 
 // HTTP configuration for connecting to Kapacitor
@@ -73,6 +79,10 @@ type Config struct {
 
 	// Optional credentials for authenticating with the server.
 	Credentials *Credentials
+
+	// Optional namespace of the tasks, templates and alert topics managed by the client.
+	// Defaults to the global namespace.
+	Namespace string
 }
 
 // AuthenticationMethod defines the type of authentication used.
@@ -83,6 +93,7 @@ const (
 	_ AuthenticationMethod = iota
 	UserAuthentication
 	BearerAuthentication
+	TokenAuthentication
 )
 
 // Set of credentials depending on the authentication method
@@ -94,7 +105,7 @@ type Credentials struct {
 	Username string
 	Password string
 
-	// BearerAuthentication fields
+	// BearerAuthentication and TokenAuthentication fields
 
 	Token string
 }
@@ -108,7 +119,7 @@ func (c Credentials) Validate() error {
 		if c.Password == "" {
 			return errors.New("missing password")
 		}
-	case BearerAuthentication:
+	case BearerAuthentication, TokenAuthentication:
 		if c.Token == "" {
 			return errors.New("missing token")
 		}
@@ -124,6 +135,7 @@ type Client struct {
 	userAgent   string
 	httpClient  *http.Client
 	credentials *Credentials
+	namespace   string
 }
 
 // Create a new client.
@@ -165,9 +177,25 @@ func New(conf Config) (*Client, error) {
 			Transport: tr,
 		},
 		credentials: conf.Credentials,
+		namespace:   conf.Namespace,
 	}, nil
 }
 
+// namespaced returns the path p of a namespaced resource within the namespace of the client.
+// All namespaced resources are served below the namespaces path, including the preview alert topics.
+func (c *Client) namespaced(p string) string {
+	if c.namespace == "" {
+		return p
+	}
+	// The preview path has the base path as prefix, so check it first.
+	for _, base := range []string{basePreviewPath, basePath} {
+		if strings.HasPrefix(p, base+"/") {
+			return path.Join(namespacesPath, c.namespace, p[len(base):])
+		}
+	}
+	return p
+}
+
 type Relation string
 
 const (
@@ -198,6 +226,15 @@ type ExecutionStats struct {
 	TaskStats map[string]interface{} `json:"task-stats"`
 	// Stats for each node in the task
 	NodeStats map[string]map[string]interface{} `json:"node-stats"`
+	// Groups with the most points for each node that keeps state per group
+	TopGroups map[string][]GroupVolume `json:"top-groups,omitempty"`
+}
+
+// The number of points a node has received for a single group.
+type GroupVolume struct {
+	Group  string            `json:"group"`
+	Tags   map[string]string `json:"tags"`
+	Points int64             `json:"points"`
 }
 
 type TaskType int
@@ -535,6 +572,21 @@ type Task struct {
 	Created        time.Time      `json:"created"`
 	Modified       time.Time      `json:"modified"`
 	LastEnabled    time.Time      `json:"last-enabled,omitempty"`
+	ShadowOf       string         `json:"shadow-of"`
+	Limits         TaskLimits     `json:"limits"`
+}
+
+// Resource limits of a task.
+// A zero value uses the default limit configured on the server.
+type TaskLimits struct {
+	// Size of the input queue of a stream task.
+	QueueSize int `json:"queue-size,omitempty"`
+	// Policy applied when the input queue is full, one of block, drop-oldest or drop-newest.
+	QueuePolicy string `json:"queue-policy,omitempty"`
+	// Maximum number of groups per node.
+	MaxGroups int `json:"max-groups,omitempty"`
+	// Maximum number of points buffered by each group of a window node.
+	MaxWindowPoints int `json:"max-window-points,omitempty"`
 }
 
 // A Template plus its read-only attributes.
@@ -601,6 +653,8 @@ func (c *Client) prepRequest(req *http.Request) error {
 			req.SetBasicAuth(c.credentials.Username, c.credentials.Password)
 		case BearerAuthentication:
 			req.Header.Set("Authorization", "Bearer "+c.credentials.Token)
+		case TokenAuthentication:
+			req.Header.Set("Authorization", "Token "+c.credentials.Token)
 		default:
 			return errors.New("unknown authentication method set")
 		}
@@ -680,11 +734,11 @@ func (c *Client) Ping() (time.Duration, string, error) {
 }
 
 func (c *Client) TaskLink(id string) Link {
-	return Link{Relation: Self, Href: path.Join(tasksPath, id)}
+	return Link{Relation: Self, Href: c.namespaced(path.Join(tasksPath, id))}
 }
 
 func (c *Client) TemplateLink(id string) Link {
-	return Link{Relation: Self, Href: path.Join(templatesPath, id)}
+	return Link{Relation: Self, Href: c.namespaced(path.Join(templatesPath, id))}
 }
 
 func (c *Client) ConfigSectionLink(section string) Link {
@@ -704,34 +758,48 @@ func (c *Client) ServiceTestLink(service string) Link {
 }
 
 func (c *Client) TopicLink(id string) Link {
-	return Link{Relation: Self, Href: path.Join(topicsPath, id)}
+	return Link{Relation: Self, Href: c.namespaced(path.Join(topicsPath, id))}
 }
 
 func (c *Client) TopicEventsLink(topic string) Link {
-	return Link{Relation: Self, Href: path.Join(topicsPath, topic, topicEventsPath)}
+	return Link{Relation: Self, Href: c.namespaced(path.Join(topicsPath, topic, topicEventsPath))}
 }
 func (c *Client) TopicEventLink(topic, event string) Link {
-	return Link{Relation: Self, Href: path.Join(topicsPath, topic, topicEventsPath, event)}
+	return Link{Relation: Self, Href: c.namespaced(path.Join(topicsPath, topic, topicEventsPath, event))}
 }
 
 func (c *Client) TopicHandlersLink(topic string) Link {
-	return Link{Relation: Self, Href: path.Join(topicsPath, topic, topicHandlersPath)}
+	return Link{Relation: Self, Href: c.namespaced(path.Join(topicsPath, topic, topicHandlersPath))}
 }
 func (c *Client) TopicHandlerLink(topic, id string) Link {
-	return Link{Relation: Self, Href: path.Join(topicsPath, topic, topicHandlersPath, id)}
+	return Link{Relation: Self, Href: c.namespaced(path.Join(topicsPath, topic, topicHandlersPath, id))}
 }
 func (c *Client) StorageLink(name string) Link {
 	return Link{Relation: Self, Href: path.Join(storesPath, name)}
 }
+func (c *Client) UserLink(name string) Link {
+	return Link{Relation: Self, Href: path.Join(usersPath, name)}
+}
+func (c *Client) TokenLink(name string) Link {
+	return Link{Relation: Self, Href: path.Join(tokensPath, name)}
+}
+func (c *Client) SecretLink(name string) Link {
+	return Link{Relation: Self, Href: path.Join(secretsPath, name)}
+}
+func (c *Client) NamespaceLink(id string) Link {
+	return Link{Relation: Self, Href: path.Join(namespacesPath, id)}
+}
 
 type CreateTaskOptions struct {
-	ID         string     `json:"id,omitempty"`
-	TemplateID string     `json:"template-id,omitempty"`
-	Type       TaskType   `json:"type,omitempty"`
-	DBRPs      []DBRP     `json:"dbrps,omitempty"`
-	TICKscript string     `json:"script,omitempty"`
-	Status     TaskStatus `json:"status,omitempty"`
-	Vars       Vars       `json:"vars,omitempty"`
+	ID         string      `json:"id,omitempty"`
+	TemplateID string      `json:"template-id,omitempty"`
+	Type       TaskType    `json:"type,omitempty"`
+	DBRPs      []DBRP      `json:"dbrps,omitempty"`
+	TICKscript string      `json:"script,omitempty"`
+	Status     TaskStatus  `json:"status,omitempty"`
+	Vars       Vars        `json:"vars,omitempty"`
+	ShadowOf   string      `json:"shadow-of,omitempty"`
+	Limits     *TaskLimits `json:"limits,omitempty"`
 }
 
 // Create a new task.
@@ -745,7 +813,7 @@ func (c *Client) CreateTask(opt CreateTaskOptions) (Task, error) {
 	}
 
 	u := *c.url
-	u.Path = tasksPath
+	u.Path = c.namespaced(tasksPath)
 
 	req, err := http.NewRequest("POST", u.String(), &buf)
 	if err != nil {
@@ -759,13 +827,20 @@ func (c *Client) CreateTask(opt CreateTaskOptions) (Task, error) {
 }
 
 type UpdateTaskOptions struct {
-	ID         string     `json:"id,omitempty"`
-	TemplateID string     `json:"template-id,omitempty"`
-	Type       TaskType   `json:"type,omitempty"`
-	DBRPs      []DBRP     `json:"dbrps,omitempty"`
-	TICKscript string     `json:"script,omitempty"`
-	Status     TaskStatus `json:"status,omitempty"`
-	Vars       Vars       `json:"vars,omitempty"`
+	ID         string      `json:"id,omitempty"`
+	TemplateID string      `json:"template-id,omitempty"`
+	Type       TaskType    `json:"type,omitempty"`
+	DBRPs      []DBRP      `json:"dbrps,omitempty"`
+	TICKscript string      `json:"script,omitempty"`
+	Status     TaskStatus  `json:"status,omitempty"`
+	Vars       Vars        `json:"vars,omitempty"`
+	ShadowOf   string      `json:"shadow-of,omitempty"`
+	Limits     *TaskLimits `json:"limits,omitempty"`
+	// Promote a shadow task to a live task, its side effects are executed from then on.
+	Promote bool `json:"promote,omitempty"`
+	// Reload the task if it is enabled.
+	// Nodes that are unchanged by the update keep their state.
+	Reload bool `json:"reload,omitempty"`
 }
 
 // Update an existing task.
@@ -905,7 +980,7 @@ func (c *Client) ListTasks(opt *ListTasksOptions) ([]Task, error) {
 	opt.Default()
 
 	u := *c.url
-	u.Path = tasksPath
+	u.Path = c.namespaced(tasksPath)
 	u.RawQuery = opt.Values().Encode()
 
 	req, err := http.NewRequest("GET", u.String(), nil)
@@ -943,6 +1018,50 @@ func (c *Client) TaskOutput(link Link, name string) (*influxql.Result, error) {
 	return r, nil
 }
 
+// The captured side effects of a shadow task.
+type TaskShadow struct {
+	TaskID   string `json:"task"`
+	ShadowOf string `json:"shadow-of"`
+	// Total number of captured effects, including those no longer retained.
+	Captured  int64                      `json:"captured"`
+	Effects   []ShadowEffect             `json:"effects"`
+	NodeStats map[string]ShadowNodeStats `json:"node-stats"`
+}
+
+// A side effect of a shadow task that was captured instead of being executed.
+type ShadowEffect struct {
+	Node string                 `json:"node"`
+	Kind string                 `json:"kind"`
+	Time time.Time              `json:"time"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// Stats of a node of a shadow task and the node with the same name of the live task.
+type ShadowNodeStats struct {
+	Shadow map[string]interface{} `json:"shadow"`
+	Live   map[string]interface{} `json:"live"`
+}
+
+// Get the captured side effects of a shadow task.
+func (c *Client) TaskShadow(link Link) (TaskShadow, error) {
+	shadow := TaskShadow{}
+	if link.Href == "" {
+		return shadow, fmt.Errorf("invalid link %v", link)
+	}
+	u := *c.url
+	u.Path = path.Join(link.Href, "shadow")
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return shadow, err
+	}
+	_, err = c.Do(req, &shadow, http.StatusOK)
+	if err != nil {
+		return shadow, err
+	}
+	return shadow, nil
+}
+
 type CreateTemplateOptions struct {
 	ID         string   `json:"id,omitempty"`
 	Type       TaskType `json:"type,omitempty"`
@@ -960,7 +1079,7 @@ func (c *Client) CreateTemplate(opt CreateTemplateOptions) (Template, error) {
 	}
 
 	u := *c.url
-	u.Path = templatesPath
+	u.Path = c.namespaced(templatesPath)
 
 	req, err := http.NewRequest("POST", u.String(), &buf)
 	if err != nil {
@@ -1108,7 +1227,7 @@ func (c *Client) ListTemplates(opt *ListTemplatesOptions) ([]Template, error) {
 	opt.Default()
 
 	u := *c.url
-	u.Path = templatesPath
+	u.Path = c.namespaced(templatesPath)
 	u.RawQuery = opt.Values().Encode()
 
 	req, err := http.NewRequest("GET", u.String(), nil)
@@ -1799,7 +1918,7 @@ func (c *Client) ListTopics(opt *ListTopicsOptions) (Topics, error) {
 	opt.Default()
 
 	u := *c.url
-	u.Path = topicsPath
+	u.Path = c.namespaced(topicsPath)
 	u.RawQuery = opt.Values().Encode()
 
 	req, err := http.NewRequest("GET", u.String(), nil)
@@ -2207,6 +2326,645 @@ func (c *Client) Backup() (int64, io.ReadCloser, error) {
 	return resp.ContentLength, resp.Body, nil
 }
 
+type Users struct {
+	Link  Link   `json:"link"`
+	Users []User `json:"users"`
+}
+
+type User struct {
+	Link  Link   `json:"link"`
+	Name  string `json:"name"`
+	Admin bool   `json:"admin"`
+	// Map of resource to the names of the privileges the user has on the resource.
+	Privileges map[string][]string `json:"privileges"`
+}
+
+type CreateUserOptions struct {
+	Name       string              `json:"name"`
+	Password   string              `json:"password"`
+	Admin      bool                `json:"admin"`
+	Privileges map[string][]string `json:"privileges"`
+}
+
+// Create a new user.
+// Errors if the user already exists.
+func (c *Client) CreateUser(opt CreateUserOptions) (User, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	err := enc.Encode(opt)
+	if err != nil {
+		return User{}, err
+	}
+
+	u := *c.url
+	u.Path = usersPath
+
+	req, err := http.NewRequest("POST", u.String(), &buf)
+	if err != nil {
+		return User{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	user := User{}
+	_, err = c.Do(req, &user, http.StatusOK)
+	return user, err
+}
+
+// Options for updating a user, only set options are updated.
+// An empty non nil Privileges map removes all privileges.
+type UpdateUserOptions struct {
+	Password   string              `json:"password,omitempty"`
+	Admin      *bool               `json:"admin,omitempty"`
+	Privileges map[string][]string `json:"privileges"`
+}
+
+// Update an existing user.
+func (c *Client) UpdateUser(link Link, opt UpdateUserOptions) (User, error) {
+	user := User{}
+	if link.Href == "" {
+		return user, fmt.Errorf("invalid link %v", link)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	err := enc.Encode(opt)
+	if err != nil {
+		return user, err
+	}
+
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("PATCH", u.String(), &buf)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	_, err = c.Do(req, &user, http.StatusOK)
+	return user, err
+}
+
+// Get information about a user.
+// Errors if the user does not exist.
+func (c *Client) User(link Link) (User, error) {
+	user := User{}
+	if link.Href == "" {
+		return user, fmt.Errorf("invalid link %v", link)
+	}
+
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return user, err
+	}
+
+	_, err = c.Do(req, &user, http.StatusOK)
+	return user, err
+}
+
+// Delete a user.
+func (c *Client) DeleteUser(link Link) error {
+	if link.Href == "" {
+		return fmt.Errorf("invalid link %v", link)
+	}
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("DELETE", u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Do(req, nil, http.StatusNoContent)
+	return err
+}
+
+type ListUsersOptions struct {
+	Pattern string
+	Offset  int
+	Limit   int
+}
+
+func (o *ListUsersOptions) Default() {
+	if o.Limit == 0 {
+		o.Limit = 100
+	}
+}
+
+func (o *ListUsersOptions) Values() *url.Values {
+	v := &url.Values{}
+	v.Set("pattern", o.Pattern)
+	v.Set("offset", strconv.FormatInt(int64(o.Offset), 10))
+	v.Set("limit", strconv.FormatInt(int64(o.Limit), 10))
+	return v
+}
+
+// Get users.
+func (c *Client) ListUsers(opt *ListUsersOptions) (Users, error) {
+	users := Users{}
+	if opt == nil {
+		opt = new(ListUsersOptions)
+	}
+	opt.Default()
+
+	u := *c.url
+	u.Path = usersPath
+	u.RawQuery = opt.Values().Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return users, err
+	}
+
+	_, err = c.Do(req, &users, http.StatusOK)
+	return users, err
+}
+
+type Tokens struct {
+	Link   Link    `json:"link"`
+	Tokens []Token `json:"tokens"`
+}
+
+type Token struct {
+	Link Link   `json:"link"`
+	Name string `json:"name"`
+	// Map of resource or resource pattern to the names of the privileges the token grants.
+	Privileges map[string][]string `json:"privileges"`
+	// The user that owns the token, only the owner and admins may get or delete it.
+	Owner   string    `json:"owner"`
+	Created time.Time `json:"created"`
+	// The time the token expires, the token does not expire if zero.
+	Expires  time.Time `json:"expires"`
+	LastUsed time.Time `json:"last-used"`
+	// The secret token, only returned when the token is created.
+	// Use it with the TokenAuthentication method.
+	Token string `json:"token,omitempty"`
+}
+
+type CreateTokenOptions struct {
+	Name       string              `json:"name"`
+	Privileges map[string][]string `json:"privileges"`
+	// The time the token expires, the token does not expire if zero.
+	// A token created with another token expires no later than that token, and with it if zero.
+	Expires time.Time `json:"expires"`
+}
+
+// Create a new API token.
+// The returned token contains the secret token, which cannot be retrieved later.
+// Errors if the token already exists.
+func (c *Client) CreateToken(opt CreateTokenOptions) (Token, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	err := enc.Encode(opt)
+	if err != nil {
+		return Token{}, err
+	}
+
+	u := *c.url
+	u.Path = tokensPath
+
+	req, err := http.NewRequest("POST", u.String(), &buf)
+	if err != nil {
+		return Token{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	t := Token{}
+	_, err = c.Do(req, &t, http.StatusOK)
+	return t, err
+}
+
+// Get information about an API token.
+// Errors if the token does not exist.
+func (c *Client) Token(link Link) (Token, error) {
+	t := Token{}
+	if link.Href == "" {
+		return t, fmt.Errorf("invalid link %v", link)
+	}
+
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return t, err
+	}
+
+	_, err = c.Do(req, &t, http.StatusOK)
+	return t, err
+}
+
+// Delete an API token, revoking it.
+func (c *Client) DeleteToken(link Link) error {
+	if link.Href == "" {
+		return fmt.Errorf("invalid link %v", link)
+	}
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("DELETE", u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Do(req, nil, http.StatusNoContent)
+	return err
+}
+
+type ListTokensOptions struct {
+	Pattern string
+	Offset  int
+	Limit   int
+}
+
+func (o *ListTokensOptions) Default() {
+	if o.Limit == 0 {
+		o.Limit = 100
+	}
+}
+
+func (o *ListTokensOptions) Values() *url.Values {
+	v := &url.Values{}
+	v.Set("pattern", o.Pattern)
+	v.Set("offset", strconv.FormatInt(int64(o.Offset), 10))
+	v.Set("limit", strconv.FormatInt(int64(o.Limit), 10))
+	return v
+}
+
+// Get API tokens.
+func (c *Client) ListTokens(opt *ListTokensOptions) (Tokens, error) {
+	tokens := Tokens{}
+	if opt == nil {
+		opt = new(ListTokensOptions)
+	}
+	opt.Default()
+
+	u := *c.url
+	u.Path = tokensPath
+	u.RawQuery = opt.Values().Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return tokens, err
+	}
+
+	_, err = c.Do(req, &tokens, http.StatusOK)
+	return tokens, err
+}
+
+type AuditRecords struct {
+	Link    Link          `json:"link"`
+	Records []AuditRecord `json:"records"`
+}
+
+// AuditRecord describes a mutating API call.
+type AuditRecord struct {
+	Time time.Time `json:"time"`
+	User string    `json:"user"`
+	// The HTTP method and the privilege it requires, i.e. write or delete.
+	Method string `json:"method"`
+	Action string `json:"action"`
+	// The API resource, i.e. /api/tasks/cpu
+	Resource string `json:"resource"`
+	// Hex encoded SHA-256 digest of the request body.
+	BodyDigest string `json:"body-digest"`
+	Status     int    `json:"status"`
+	// One of success, denied or failure.
+	Result string `json:"result"`
+}
+
+type ListAuditRecordsOptions struct {
+	User string
+	// Only return records of the resource or its children.
+	Resource string
+	// Only return records within the time range, ignored if zero.
+	Start time.Time
+	Stop  time.Time
+	// Records are returned newest first.
+	Offset int
+	Limit  int
+}
+
+func (o *ListAuditRecordsOptions) Default() {
+	if o.Limit == 0 {
+		o.Limit = 100
+	}
+}
+
+func (o *ListAuditRecordsOptions) Values() *url.Values {
+	v := &url.Values{}
+	if o.User != "" {
+		v.Set("user", o.User)
+	}
+	if o.Resource != "" {
+		v.Set("resource", o.Resource)
+	}
+	if !o.Start.IsZero() {
+		v.Set("start", o.Start.Format(time.RFC3339Nano))
+	}
+	if !o.Stop.IsZero() {
+		v.Set("stop", o.Stop.Format(time.RFC3339Nano))
+	}
+	v.Set("offset", strconv.FormatInt(int64(o.Offset), 10))
+	v.Set("limit", strconv.FormatInt(int64(o.Limit), 10))
+	return v
+}
+
+// ListAuditRecords returns the recorded mutating API calls, newest first.
+func (c *Client) ListAuditRecords(opt *ListAuditRecordsOptions) (AuditRecords, error) {
+	records := AuditRecords{}
+	if opt == nil {
+		opt = new(ListAuditRecordsOptions)
+	}
+	opt.Default()
+
+	u := *c.url
+	u.Path = auditPath
+	u.RawQuery = opt.Values().Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return records, err
+	}
+
+	_, err = c.Do(req, &records, http.StatusOK)
+	return records, err
+}
+
+type Secrets struct {
+	Link    Link     `json:"link"`
+	Secrets []Secret `json:"secrets"`
+}
+
+// Secret describes a secret of the secrets store, its value is never returned.
+type Secret struct {
+	Link    Link      `json:"link"`
+	Name    string    `json:"name"`
+	Updated time.Time `json:"updated"`
+}
+
+type SetSecretOptions struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Set creates or replaces a secret of the secrets store.
+// Configuration values and handler options referencing the secret as secret://<name> are updated.
+func (c *Client) SetSecret(opt SetSecretOptions) (Secret, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	err := enc.Encode(opt)
+	if err != nil {
+		return Secret{}, err
+	}
+
+	u := *c.url
+	u.Path = secretsPath
+
+	req, err := http.NewRequest("POST", u.String(), &buf)
+	if err != nil {
+		return Secret{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	s := Secret{}
+	_, err = c.Do(req, &s, http.StatusOK)
+	return s, err
+}
+
+// Get information about a secret.
+// Errors if the secret does not exist.
+func (c *Client) Secret(link Link) (Secret, error) {
+	s := Secret{}
+	if link.Href == "" {
+		return s, fmt.Errorf("invalid link %v", link)
+	}
+
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return s, err
+	}
+
+	_, err = c.Do(req, &s, http.StatusOK)
+	return s, err
+}
+
+// Delete a secret.
+func (c *Client) DeleteSecret(link Link) error {
+	if link.Href == "" {
+		return fmt.Errorf("invalid link %v", link)
+	}
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("DELETE", u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Do(req, nil, http.StatusNoContent)
+	return err
+}
+
+// Get the secrets of the secrets store, sorted by name.
+func (c *Client) ListSecrets() (Secrets, error) {
+	secrets := Secrets{}
+
+	u := *c.url
+	u.Path = secretsPath
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return secrets, err
+	}
+
+	_, err = c.Do(req, &secrets, http.StatusOK)
+	return secrets, err
+}
+
+type Namespaces struct {
+	Link       Link        `json:"link"`
+	Namespaces []Namespace `json:"namespaces"`
+}
+
+type Namespace struct {
+	Link Link   `json:"link"`
+	ID   string `json:"id"`
+	// The database and retention policy pairs tasks of the namespace may use.
+	// An empty retention policy allows all retention policies of the database,
+	// tasks may use all DBRPs if empty.
+	DBRPs    []DBRP          `json:"dbrps"`
+	Quotas   NamespaceQuotas `json:"quotas"`
+	Created  time.Time       `json:"created"`
+	Modified time.Time       `json:"modified"`
+}
+
+// Quotas limit the resources used by the tasks and alert topics of a namespace.
+// A zero limit is unlimited.
+type NamespaceQuotas struct {
+	MaxTasks                       int   `json:"max-tasks"`
+	MaxPointsPerSecond             int64 `json:"max-points-per-second"`
+	MaxAlertEventsPerMinute        int64 `json:"max-alert-events-per-minute"`
+	MaxHandlerInvocationsPerMinute int64 `json:"max-handler-invocations-per-minute"`
+}
+
+type CreateNamespaceOptions struct {
+	ID     string          `json:"id"`
+	DBRPs  []DBRP          `json:"dbrps,omitempty"`
+	Quotas NamespaceQuotas `json:"quotas"`
+}
+
+// Create a new namespace.
+// Errors if the namespace already exists.
+func (c *Client) CreateNamespace(opt CreateNamespaceOptions) (Namespace, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	err := enc.Encode(opt)
+	if err != nil {
+		return Namespace{}, err
+	}
+
+	u := *c.url
+	u.Path = namespacesPath
+
+	req, err := http.NewRequest("POST", u.String(), &buf)
+	if err != nil {
+		return Namespace{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	n := Namespace{}
+	_, err = c.Do(req, &n, http.StatusOK)
+	return n, err
+}
+
+// Options for updating a namespace, only set options are updated.
+// An empty non nil DBRPs list allows all DBRPs.
+type UpdateNamespaceOptions struct {
+	DBRPs  []DBRP                 `json:"dbrps"`
+	Quotas *UpdateNamespaceQuotas `json:"quotas,omitempty"`
+}
+
+// Quotas for updating a namespace, only set quotas are updated.
+type UpdateNamespaceQuotas struct {
+	MaxTasks                       *int   `json:"max-tasks,omitempty"`
+	MaxPointsPerSecond             *int64 `json:"max-points-per-second,omitempty"`
+	MaxAlertEventsPerMinute        *int64 `json:"max-alert-events-per-minute,omitempty"`
+	MaxHandlerInvocationsPerMinute *int64 `json:"max-handler-invocations-per-minute,omitempty"`
+}
+
+// Update an existing namespace.
+func (c *Client) UpdateNamespace(link Link, opt UpdateNamespaceOptions) (Namespace, error) {
+	n := Namespace{}
+	if link.Href == "" {
+		return n, fmt.Errorf("invalid link %v", link)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	err := enc.Encode(opt)
+	if err != nil {
+		return n, err
+	}
+
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("PATCH", u.String(), &buf)
+	if err != nil {
+		return n, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	_, err = c.Do(req, &n, http.StatusOK)
+	return n, err
+}
+
+// Get information about a namespace.
+// Errors if the namespace does not exist.
+func (c *Client) Namespace(link Link) (Namespace, error) {
+	n := Namespace{}
+	if link.Href == "" {
+		return n, fmt.Errorf("invalid link %v", link)
+	}
+
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return n, err
+	}
+
+	_, err = c.Do(req, &n, http.StatusOK)
+	return n, err
+}
+
+// Delete a namespace.
+// The resources of the namespace are not deleted.
+func (c *Client) DeleteNamespace(link Link) error {
+	if link.Href == "" {
+		return fmt.Errorf("invalid link %v", link)
+	}
+	u := *c.url
+	u.Path = link.Href
+
+	req, err := http.NewRequest("DELETE", u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Do(req, nil, http.StatusNoContent)
+	return err
+}
+
+type ListNamespacesOptions struct {
+	Pattern string
+	Offset  int
+	Limit   int
+}
+
+func (o *ListNamespacesOptions) Default() {
+	if o.Limit == 0 {
+		o.Limit = 100
+	}
+}
+
+func (o *ListNamespacesOptions) Values() *url.Values {
+	v := &url.Values{}
+	v.Set("pattern", o.Pattern)
+	v.Set("offset", strconv.FormatInt(int64(o.Offset), 10))
+	v.Set("limit", strconv.FormatInt(int64(o.Limit), 10))
+	return v
+}
+
+// Get namespaces.
+func (c *Client) ListNamespaces(opt *ListNamespacesOptions) (Namespaces, error) {
+	namespaces := Namespaces{}
+	if opt == nil {
+		opt = new(ListNamespacesOptions)
+	}
+	opt.Default()
+
+	u := *c.url
+	u.Path = namespacesPath
+	u.RawQuery = opt.Values().Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return namespaces, err
+	}
+
+	_, err = c.Do(req, &namespaces, http.StatusOK)
+	return namespaces, err
+}
+
 type LogLevelOptions struct {
 	Level string `json:"level"`
 }
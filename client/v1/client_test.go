@@ -3006,6 +3006,128 @@ func Test_DeleteTopicHandler(t *testing.T) {
 	}
 }
 
+func Test_CreateUser(t *testing.T) {
+	s, c, err := newClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		options := client.CreateUserOptions{}
+		json.NewDecoder(r.Body).Decode(&options)
+		expOptions := client.CreateUserOptions{
+			Name:     "bob",
+			Password: "secret",
+			Privileges: map[string][]string{
+				"/api/tasks": {"read"},
+			},
+		}
+		if r.URL.String() == "/kapacitor/v1/users" &&
+			r.Method == "POST" &&
+			reflect.DeepEqual(expOptions, options) {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, `{
+	"link":{"rel":"self","href":"/kapacitor/v1/users/bob"},
+	"name": "bob",
+	"admin": false,
+	"privileges": {
+		"/api/tasks": ["read"]
+	}
+}`)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "request: %v", r)
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	u, err := c.CreateUser(client.CreateUserOptions{
+		Name:     "bob",
+		Password: "secret",
+		Privileges: map[string][]string{
+			"/api/tasks": {"read"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := client.User{
+		Link: client.Link{Relation: client.Self, Href: "/kapacitor/v1/users/bob"},
+		Name: "bob",
+		Privileges: map[string][]string{
+			"/api/tasks": {"read"},
+		},
+	}
+	if !reflect.DeepEqual(exp, u) {
+		t.Errorf("unexpected create user result:\ngot:\n%v\nexp:\n%v", u, exp)
+	}
+}
+
+func Test_UpdateUser(t *testing.T) {
+	s, c, err := newClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		expBody := map[string]interface{}{
+			"admin":      true,
+			"privileges": nil,
+		}
+		if r.URL.String() == "/kapacitor/v1/users/bob" &&
+			r.Method == "PATCH" &&
+			reflect.DeepEqual(expBody, body) {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, `{
+	"link":{"rel":"self","href":"/kapacitor/v1/users/bob"},
+	"name": "bob",
+	"admin": true,
+	"privileges": {}
+}`)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "request: %v", r)
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	admin := true
+	u, err := c.UpdateUser(c.UserLink("bob"), client.UpdateUserOptions{
+		Admin: &admin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := client.User{
+		Link:       client.Link{Relation: client.Self, Href: "/kapacitor/v1/users/bob"},
+		Name:       "bob",
+		Admin:      true,
+		Privileges: map[string][]string{},
+	}
+	if !reflect.DeepEqual(exp, u) {
+		t.Errorf("unexpected update user result:\ngot:\n%v\nexp:\n%v", u, exp)
+	}
+}
+
+func Test_DeleteUser(t *testing.T) {
+	s, c, err := newClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.String() == "/kapacitor/v1/users/bob" &&
+			r.Method == "DELETE" {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "request: %v", r)
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	err = c.DeleteUser(c.UserLink("bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func Test_LogLevel(t *testing.T) {
 	s, c, err := newClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var opts client.LogLevelOptions
@@ -3134,3 +3256,30 @@ func Test_BearerAuthentication(t *testing.T) {
 		t.Errorf("unexpected version: got: %s exp: %s", got, exp)
 	}
 }
+
+func Test_TokenAuthentication(t *testing.T) {
+	s, c, err := newClientWithConfig(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if r.URL.Path == "/kapacitor/v1/ping" && r.Method == "GET" &&
+			auth == "Token deploy:secret" {
+			w.Header().Set("X-Kapacitor-Version", "versionStr")
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "request: %v", r)
+		}
+	}), client.Config{
+		Credentials: &client.Credentials{
+			Method: client.TokenAuthentication,
+			Token:  "deploy:secret",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if _, _, err := c.Ping(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -20,6 +22,9 @@ import (
 	"github.com/ghodss/yaml"
 	"github.com/influxdata/influxdb/influxql"
 	"github.com/influxdata/kapacitor/client/v1"
+	"github.com/influxdata/kapacitor/tasktest"
+	"github.com/influxdata/kapacitor/tlsconfig"
+	"github.com/influxdata/wlog"
 	"github.com/pkg/errors"
 )
 
@@ -36,6 +41,10 @@ var defaultSkipVerify = false
 var mainFlags = flag.NewFlagSet("main", flag.ExitOnError)
 var kapacitordURL = mainFlags.String("url", "", "The URL http(s)://host:port of the kapacitord server. Defaults to the KAPACITOR_URL environment variable or "+defaultURL+" if not set.")
 var skipVerify = mainFlags.Bool("skipVerify", false, "Disable SSL verification (note, this is insecure). Defaults to the KAPACITOR_UNSAFE_SSL environment variable or "+strconv.FormatBool(defaultSkipVerify)+" if not set.")
+var sslCA = mainFlags.String("ssl-ca", "", "Path to the PEM encoded CA used to verify the server certificate. Defaults to the KAPACITOR_SSL_CA environment variable.")
+var sslCert = mainFlags.String("ssl-cert", "", "Path to the PEM encoded client certificate, needed if the server requires client certificates. Defaults to the KAPACITOR_SSL_CERT environment variable.")
+var sslKey = mainFlags.String("ssl-key", "", "Path to the PEM encoded private key of the client certificate. Defaults to the KAPACITOR_SSL_KEY environment variable.")
+var namespaceFlag = mainFlags.String("namespace", "", "The namespace of the tasks, templates and topics to manage. Defaults to the KAPACITOR_NAMESPACE environment variable or the global namespace if not set.")
 
 var l = log.New(os.Stderr, "[run] ", log.LstdFlags)
 
@@ -63,11 +72,14 @@ Commands:
 	show-topic-handler    Display detailed information about an alert handler for a topic.
 	show-topic            Display detailed information about an alert topic.
 	backup                Backup the Kapacitor database.
+	user                  Manage the users of the built-in auth service.
+	namespace             Manage the namespaces of tasks, templates and topics.
 	level                 Sets the logging level on the kapacitord server.
 	stats                 Display various stats about Kapacitor.
 	version               Displays the Kapacitor version info.
 	vars                  Print debug vars in JSON format.
 	service-tests         Test a service.
+	test                  Run TICKscript unit tests offline.
 	help                  Prints help for a command.
 
 Options:
@@ -109,9 +121,37 @@ func main() {
 		result := strings.Split(basicAuth, ":")
 		creds = &client.Credentials{Method: client.UserAuthentication, Username: result[0], Password: result[1]}
 	}
+	if token := os.Getenv("KAPACITOR_TOKEN"); token != "" {
+		creds = &client.Credentials{Method: client.TokenAuthentication, Token: token}
+	}
+
+	ca, cert, key := os.Getenv("KAPACITOR_SSL_CA"), os.Getenv("KAPACITOR_SSL_CERT"), os.Getenv("KAPACITOR_SSL_KEY")
+	if *sslCA != "" {
+		ca = *sslCA
+	}
+	if *sslCert != "" {
+		cert = *sslCert
+	}
+	if *sslKey != "" {
+		key = *sslKey
+	}
+	var tlsConfig *tls.Config
+	if ca != "" || cert != "" || key != "" {
+		var err error
+		tlsConfig, err = tlsconfig.Create(ca, cert, key, skipSSL)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	namespace := os.Getenv("KAPACITOR_NAMESPACE")
+	if *namespaceFlag != "" {
+		namespace = *namespaceFlag
+	}
 
 	var err error
-	cli, err = connect(url, skipSSL, creds)
+	cli, err = connect(url, skipSSL, tlsConfig, creds, namespace)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(4)
@@ -190,6 +230,12 @@ func main() {
 	case "backup":
 		commandArgs = args
 		commandF = doBackup
+	case "user":
+		commandArgs = args
+		commandF = doUser
+	case "namespace":
+		commandArgs = args
+		commandF = doNamespace
 	case "level":
 		commandArgs = args
 		commandF = doLevel
@@ -205,6 +251,10 @@ func main() {
 	case "service-tests":
 		commandArgs = args
 		commandF = doServiceTest
+	case "test":
+		testFlags.Parse(args)
+		commandArgs = testFlags.Args()
+		commandF = doTest
 	default:
 		fmt.Fprintln(os.Stderr, "Unknown command", command)
 		usage()
@@ -223,6 +273,7 @@ func init() {
 	defineFlags.Usage = defineUsage
 	defineTemplateFlags.Usage = defineTemplateUsage
 	showFlags.Usage = showUsage
+	testFlags.Usage = testUsage
 
 	recordStreamFlags.Usage = recordStreamUsage
 	recordBatchFlags.Usage = recordBatchUsage
@@ -242,11 +293,13 @@ func (e responseError) Error() string {
 	return e.Err
 }
 
-func connect(url string, skipSSL bool, creds *client.Credentials) (*client.Client, error) {
+func connect(url string, skipSSL bool, tlsConfig *tls.Config, creds *client.Credentials, namespace string) (*client.Client, error) {
 	return client.New(client.Config{
 		URL:                url,
 		InsecureSkipVerify: skipSSL,
+		TLSConfig:          tlsConfig,
 		Credentials:        creds,
+		Namespace:          namespace,
 	})
 }
 
@@ -291,6 +344,10 @@ func doHelp(args []string) error {
 			showTopicUsage()
 		case "backup":
 			backupUsage()
+		case "user":
+			userUsage()
+		case "namespace":
+			namespaceUsage()
 		case "level":
 			levelUsage()
 		case "help":
@@ -303,6 +360,8 @@ func doHelp(args []string) error {
 			varsUsage()
 		case "service-tests":
 			varsUsage()
+		case "test":
+			testUsage()
 		default:
 			fmt.Fprintln(os.Stderr, "Unknown command", command)
 			usage()
@@ -550,6 +609,12 @@ var (
 	dtemplate   = defineFlags.String("template", "", "Optional template ID")
 	dvars       = defineFlags.String("vars", "", "Optional path to a JSON vars file")
 	dnoReload   = defineFlags.Bool("no-reload", false, "Do not reload the task even if it is enabled")
+	dshadowOf   = defineFlags.String("shadow-of", "", "Optional ID of a live task. If set the task runs in shadow mode, its side effects are captured instead of executed.")
+	dpromote    = defineFlags.Bool("promote", false, "Promote an existing shadow task to a live task, its side effects are executed from then on.")
+	dqueueSize  = defineFlags.Int("queue-size", 0, "Optional size of the input queue of a stream task, 0 uses the server default.")
+	dqueuePol   = defineFlags.String("queue-policy", "", "Optional policy applied when the input queue is full (block|drop-oldest|drop-newest).")
+	dmaxGroups  = defineFlags.Int("max-groups", 0, "Optional maximum number of groups per node, 0 uses the server default.")
+	dmaxWindow  = defineFlags.Int("max-window-points", 0, "Optional maximum number of points buffered by each group of a window, 0 uses the server default.")
 	ddbrp       = make(dbrps, 0)
 )
 
@@ -625,6 +690,7 @@ func defineUsage() {
 	If an option is absent it will be left unmodified.
 
 	If the task is enabled then it will be reloaded unless -no-reload is specified.
+	Nodes that are unchanged keep their state, see 'kapacitor help reload'.
 
 For example:
 
@@ -643,6 +709,21 @@ For example:
 
 	NOTE: you must specify all 'dbrp' flags you desire if you wish to modify them.
 
+	A new version of a task can be evaluated against live data in shadow mode.
+	Alerts, writes, HTTP posts, autoscaling and loopback points of a shadow task
+	are captured and can be inspected with 'kapacitor show'.
+
+		$ kapacitor define my_task_v2 -tick path/to/TICKscript -type stream -dbrp mydb.myrp -shadow-of my_task
+
+	Once the shadow task behaves as expected it can be promoted to a live task.
+
+		$ kapacitor define my_task_v2 -promote
+
+	Resource limits override the defaults of the server for a single task.
+	A limit of 0 uses the server default.
+
+		$ kapacitor define my_task -queue-size 10000 -queue-policy drop-oldest -max-groups 1000
+
 Options:
 
 `
@@ -696,6 +777,29 @@ func doDefine(args []string) error {
 
 	l := cli.TaskLink(id)
 	task, _ := cli.Task(l, nil)
+
+	// Only send limits if any of the limit flags are set, limits without a flag are left unmodified.
+	var limits *client.TaskLimits
+	defineFlags.Visit(func(f *flag.Flag) {
+		l := task.Limits
+		if limits != nil {
+			l = *limits
+		}
+		switch f.Name {
+		case "queue-size":
+			l.QueueSize = *dqueueSize
+		case "queue-policy":
+			l.QueuePolicy = *dqueuePol
+		case "max-groups":
+			l.MaxGroups = *dmaxGroups
+		case "max-window-points":
+			l.MaxWindowPoints = *dmaxWindow
+		default:
+			return
+		}
+		limits = &l
+	})
+
 	var err error
 	if task.ID == "" {
 		_, err = cli.CreateTask(client.CreateTaskOptions{
@@ -706,6 +810,8 @@ func doDefine(args []string) error {
 			TICKscript: script,
 			Vars:       vars,
 			Status:     client.Disabled,
+			ShadowOf:   *dshadowOf,
+			Limits:     limits,
 		})
 	} else {
 		_, err = cli.UpdateTask(
@@ -716,6 +822,10 @@ func doDefine(args []string) error {
 				DBRPs:      ddbrp,
 				TICKscript: script,
 				Vars:       vars,
+				ShadowOf:   *dshadowOf,
+				Promote:    *dpromote,
+				Limits:     limits,
+				Reload:     !*dnoReload,
 			},
 		)
 	}
@@ -723,16 +833,6 @@ func doDefine(args []string) error {
 		return err
 	}
 
-	if !*dnoReload && task.Status == client.Enabled {
-		_, err := cli.UpdateTask(l, client.UpdateTaskOptions{Status: client.Disabled})
-		if err != nil {
-			return err
-		}
-		_, err = cli.UpdateTask(l, client.UpdateTaskOptions{Status: client.Enabled})
-		if err != nil {
-			return err
-		}
-	}
 	return nil
 }
 
@@ -1172,9 +1272,13 @@ func doEnable(args []string) error {
 		enableUsage()
 		os.Exit(2)
 	}
+	return updateMatchingTasks(args, client.UpdateTaskOptions{Status: client.Enabled}, "enabling")
+}
 
+// updateMatchingTasks updates all tasks matching any of the patterns with opt.
+func updateMatchingTasks(patterns []string, opt client.UpdateTaskOptions, action string) error {
 	limit := 100
-	for _, pattern := range args {
+	for _, pattern := range patterns {
 		offset := 0
 		for {
 			tasks, err := cli.ListTasks(&client.ListTasksOptions{
@@ -1187,12 +1291,9 @@ func doEnable(args []string) error {
 				return errors.Wrap(err, "listing tasks")
 			}
 			for _, task := range tasks {
-				_, err := cli.UpdateTask(
-					task.Link,
-					client.UpdateTaskOptions{Status: client.Enabled},
-				)
+				_, err := cli.UpdateTask(task.Link, opt)
 				if err != nil {
-					return errors.Wrapf(err, "enabling task %s", task.ID)
+					return errors.Wrapf(err, "%s task %s", action, task.ID)
 				}
 			}
 			if len(tasks) != limit {
@@ -1267,7 +1368,13 @@ func doDisable(args []string) error {
 func reloadUsage() {
 	var u = `Usage: kapacitor reload [task ID...]
 
-	Disable then enable a running task.
+	Reload a task, enabling it if it is disabled.
+
+	Nodes of an enabled task that are unchanged since the task was started keep their state,
+	i.e. the contents of windows and the levels of alerts.
+	Changed nodes start without state.
+	If the task type changed or no node is unchanged the task is fully restarted.
+	Disable then enable the task to always restart it without state.
 
 For example:
 
@@ -1288,12 +1395,7 @@ func doReload(args []string) error {
 		reloadUsage()
 		os.Exit(2)
 	}
-	err := doDisable(args)
-	if err != nil {
-		return err
-	}
-
-	return doEnable(args)
+	return updateMatchingTasks(args, client.UpdateTaskOptions{Status: client.Enabled, Reload: true}, "reloading")
 }
 
 // Show
@@ -1338,6 +1440,17 @@ func doShow(args []string) error {
 	fmt.Println("Modified:", t.Modified.Format(time.RFC822))
 	fmt.Println("LastEnabled:", t.LastEnabled.Format(time.RFC822))
 	fmt.Println("Databases Retention Policies:", t.DBRPs)
+	if t.ShadowOf != "" {
+		fmt.Println("Shadow Of:", t.ShadowOf)
+	}
+	if t.Limits != (client.TaskLimits{}) {
+		fmt.Printf("Limits: queue-size=%d queue-policy=%s max-groups=%d max-window-points=%d\n",
+			t.Limits.QueueSize,
+			t.Limits.QueuePolicy,
+			t.Limits.MaxGroups,
+			t.Limits.MaxWindowPoints,
+		)
+	}
 	fmt.Printf("TICKscript:\n%s\n", t.TICKscript)
 	if len(t.Vars) > 0 {
 		fmt.Println("Vars:")
@@ -1363,9 +1476,96 @@ func doShow(args []string) error {
 	}
 	fmt.Printf("DOT:\n%s\n", t.Dot)
 
+	if len(t.ExecutionStats.TopGroups) > 0 {
+		printTopGroups(t.ExecutionStats)
+	}
+
+	if t.ShadowOf != "" && t.Executing {
+		shadow, err := cli.TaskShadow(t.Link)
+		if err != nil {
+			return err
+		}
+		printShadowEffects(shadow)
+	}
 	return nil
 }
 
+// Maximum number of nodes displayed in the cardinality section of show.
+const maxShownCardinalityNodes = 5
+
+type nodeCardinality struct {
+	node        string
+	cardinality int64
+}
+
+// nodeCardinalityList sorts by cardinality descending and then by node name.
+type nodeCardinalityList []nodeCardinality
+
+func (l nodeCardinalityList) Len() int { return len(l) }
+func (l nodeCardinalityList) Less(i, j int) bool {
+	if ci, cj := l[i].cardinality, l[j].cardinality; ci != cj {
+		return ci > cj
+	}
+	return l[i].node < l[j].node
+}
+func (l nodeCardinalityList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+
+// printTopGroups prints the nodes with the highest group cardinality
+// and for each node the groups that received the most points.
+func printTopGroups(stats client.ExecutionStats) {
+	nodes := make(nodeCardinalityList, 0, len(stats.TopGroups))
+	for name := range stats.TopGroups {
+		var c int64
+		if f, ok := stats.NodeStats[name]["working_cardinality"].(float64); ok {
+			c = int64(f)
+		}
+		nodes = append(nodes, nodeCardinality{node: name, cardinality: c})
+	}
+	sort.Sort(nodes)
+	if len(nodes) > maxShownCardinalityNodes {
+		nodes = nodes[:maxShownCardinalityNodes]
+	}
+	fmt.Println("Cardinality:")
+	outFmt := "%-20s%-12v%-50s%v\n"
+	fmt.Printf(outFmt, "Node", "Groups", "Top Groups", "Points")
+	for _, n := range nodes {
+		groups := stats.TopGroups[n.node]
+		if len(groups) == 0 {
+			fmt.Printf(outFmt, n.node, n.cardinality, "", "")
+			continue
+		}
+		for i, g := range groups {
+			var node string
+			var cardinality interface{} = ""
+			if i == 0 {
+				node, cardinality = n.node, n.cardinality
+			}
+			group := g.Group
+			if group == "" {
+				group = "<all>"
+			}
+			fmt.Printf(outFmt, node, cardinality, group, g.Points)
+		}
+	}
+}
+
+// Maximum number of shadow effects displayed by show.
+const maxShownShadowEffects = 20
+
+func printShadowEffects(shadow client.TaskShadow) {
+	effects := shadow.Effects
+	if len(effects) > maxShownShadowEffects {
+		effects = effects[len(effects)-maxShownShadowEffects:]
+	}
+	fmt.Printf("Shadow Effects: %d captured, showing the last %d\n", shadow.Captured, len(effects))
+	outFmt := "%-20s%-16s%-32s%s\n"
+	fmt.Printf(outFmt, "Node", "Kind", "Time", "Data")
+	for _, e := range effects {
+		data, _ := json.Marshal(e.Data)
+		fmt.Printf(outFmt, e.Node, e.Kind, e.Time.Format(time.RFC3339Nano), data)
+	}
+}
+
 func varListToStr(list []client.Var) (string, error) {
 	values := make([]string, len(list))
 	for i := range list {
@@ -1857,6 +2057,7 @@ func deleteUsage() {
 	Delete a tasks, templates, recordings, replays, topics or handlers.
 
 	If a task is enabled it will be disabled and then deleted.
+	The shadow tasks of a task are deleted with it.
 
 	Deleting a handler requires that the topic be specified before the pattern.
 
@@ -2220,6 +2421,558 @@ func doServiceTest(args []string) error {
 	return nil
 }
 
+// Test
+var (
+	testFlags   = flag.NewFlagSet("test", flag.ExitOnError)
+	testVerbose = testFlags.Bool("v", false, "Print the logs of the executing tasks.")
+)
+
+func testUsage() {
+	var u = `Usage: kapacitor test [options] <spec file...>
+
+	Run TICKscript unit tests.
+
+	Each spec file is a YAML file that names a TICKscript, a fixture of input data
+	and the expected alerts, httpOut results and influxDBOut writes of the task.
+	The tests run offline, no kapacitord server is required.
+
+	The command exits with a non zero status if any test fails.
+
+Examples:
+
+	$ kapacitor test tests/cpu_alert.yaml
+
+		Runs the task described in tests/cpu_alert.yaml and compares its outputs to the expectations.
+
+	An example spec file:
+
+		tick: cpu_alert.tick
+		type: stream
+		dbrp:
+		  - telegraf.autogen
+		data: cpu.lp
+		precision: s
+		expect:
+		  alerts:
+		    - id: cpu
+		      level: CRITICAL
+		      time: 2017-01-01T00:00:10Z
+
+Options:
+`
+	fmt.Fprintln(os.Stderr, u)
+	testFlags.PrintDefaults()
+}
+
+func doTest(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Must provide at least one spec file.")
+		testUsage()
+		os.Exit(2)
+	}
+	var logs io.Writer
+	if *testVerbose {
+		wlog.SetLevel(wlog.DEBUG)
+		logs = os.Stderr
+	}
+	failed := 0
+	for _, path := range args {
+		s, err := tasktest.LoadSpec(path)
+		if err != nil {
+			return err
+		}
+		start := time.Now()
+		r, err := tasktest.Run(s, logs)
+		elapsed := time.Since(start)
+		switch {
+		case err != nil:
+			failed++
+			fmt.Fprintf(os.Stdout, "ERROR %s (%v): %v\n", s.Name, elapsed, err)
+		case !r.Passed():
+			failed++
+			fmt.Fprintf(os.Stdout, "FAIL %s (%v)\n", s.Name, elapsed)
+			for _, f := range r.Failures {
+				fmt.Fprintln(os.Stdout, f)
+			}
+		default:
+			fmt.Fprintf(os.Stdout, "PASS %s (%v)\n", s.Name, elapsed)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tests failed", failed, len(args))
+	}
+	return nil
+}
+
+// User
+var (
+	userCreateFlags    = flag.NewFlagSet("user-create", flag.ExitOnError)
+	ucPassword         = userCreateFlags.String("password", "", "The password of the user. If not set the password is read from stdin.")
+	ucAdmin            = userCreateFlags.Bool("admin", false, "Whether the user has all privileges on all resources.")
+	ucPrivileges       = make(privilegeList)
+	userUpdateFlags    = flag.NewFlagSet("user-update", flag.ExitOnError)
+	uuPassword         = userUpdateFlags.String("password", "", "The new password of the user.")
+	uuAdmin            = userUpdateFlags.Bool("admin", false, "Whether the user has all privileges on all resources.")
+	uuPrivileges       = make(privilegeList)
+	uuRemovePrivileges = userUpdateFlags.Bool("remove-privileges", false, "Remove all privileges of the user.")
+)
+
+func init() {
+	userCreateFlags.Var(ucPrivileges, "privilege", `A privilege of the user of the form <resource>=<privilege>[,<privilege>...] e.g. "/api/tasks=read,write". May be repeated.`)
+	userUpdateFlags.Var(uuPrivileges, "privilege", `A privilege of the user of the form <resource>=<privilege>[,<privilege>...], replacing all existing privileges. May be repeated.`)
+	userCreateFlags.Usage = userCreateUsage
+	userUpdateFlags.Usage = userUpdateUsage
+}
+
+type privilegeList map[string][]string
+
+func (p privilegeList) String() string {
+	return fmt.Sprint(map[string][]string(p))
+}
+
+func (p privilegeList) Set(value string) error {
+	i := strings.IndexRune(value, '=')
+	if i <= 0 || i == len(value)-1 {
+		return fmt.Errorf("invalid privilege %q, must be of the form <resource>=<privilege>[,<privilege>...]", value)
+	}
+	resource := value[:i]
+	p[resource] = append(p[resource], strings.Split(value[i+1:], ",")...)
+	return nil
+}
+
+func userUsage() {
+	var u = `Usage: kapacitor user [create|update|delete|list|show] [options] [args]
+
+	Manage the users of the built-in auth service.
+	The built-in auth service must be enabled in the [auth] section of the kapacitord configuration.
+
+	Privileges are granted on resources, which are paths of the form:
+
+		/api/<API path>  e.g. /api/tasks for the /kapacitor/v1/tasks API.
+		/database/<name> for writing to a database, see the documentation of the auth package for its encoding.
+
+	A privilege on a resource also applies to all resources below it.
+	The privileges are read, write, delete and all.
+
+Examples:
+
+	$ kapacitor user create -privilege /api/tasks=read -privilege /api/alerts=read,write bob
+
+		Creates the user bob, reading its password from stdin.
+
+	$ kapacitor user update -admin bob
+
+		Makes bob an admin user.
+
+	$ kapacitor user delete bob
+
+		Deletes the user bob.
+
+	$ kapacitor user list 'b*'
+
+		Lists the users whose names start with b.
+
+	$ kapacitor user show bob
+
+		Shows the privileges of bob.
+`
+	fmt.Fprintln(os.Stderr, u)
+}
+
+func userCreateUsage() {
+	var u = `Usage: kapacitor user create [options] <name>
+
+	Create a user.
+
+Options:
+`
+	fmt.Fprintln(os.Stderr, u)
+	userCreateFlags.PrintDefaults()
+}
+
+func userUpdateUsage() {
+	var u = `Usage: kapacitor user update [options] <name>
+
+	Update a user, only the given options are changed.
+
+Options:
+`
+	fmt.Fprintln(os.Stderr, u)
+	userUpdateFlags.PrintDefaults()
+}
+
+func doUser(args []string) error {
+	if len(args) == 0 {
+		userUsage()
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "create":
+		userCreateFlags.Parse(args[1:])
+		if userCreateFlags.NArg() != 1 {
+			userCreateFlags.Usage()
+			return errors.New("must provide exactly one user name")
+		}
+		password := *ucPassword
+		if password == "" {
+			var err error
+			password, err = readPassword()
+			if err != nil {
+				return err
+			}
+		}
+		u, err := cli.CreateUser(client.CreateUserOptions{
+			Name:       userCreateFlags.Arg(0),
+			Password:   password,
+			Admin:      *ucAdmin,
+			Privileges: ucPrivileges,
+		})
+		if err != nil {
+			return err
+		}
+		printUser(u)
+	case "update":
+		userUpdateFlags.Parse(args[1:])
+		if userUpdateFlags.NArg() != 1 {
+			userUpdateFlags.Usage()
+			return errors.New("must provide exactly one user name")
+		}
+		opt := client.UpdateUserOptions{
+			Password: *uuPassword,
+		}
+		userUpdateFlags.Visit(func(f *flag.Flag) {
+			if f.Name == "admin" {
+				opt.Admin = uuAdmin
+			}
+		})
+		if *uuRemovePrivileges {
+			if len(uuPrivileges) > 0 {
+				return errors.New("cannot both set and remove privileges")
+			}
+			opt.Privileges = make(map[string][]string)
+		} else if len(uuPrivileges) > 0 {
+			opt.Privileges = uuPrivileges
+		}
+		u, err := cli.UpdateUser(cli.UserLink(userUpdateFlags.Arg(0)), opt)
+		if err != nil {
+			return err
+		}
+		printUser(u)
+	case "delete":
+		if len(args) < 2 {
+			userUsage()
+			return errors.New("must provide at least one user name")
+		}
+		for _, name := range args[1:] {
+			if err := cli.DeleteUser(cli.UserLink(name)); err != nil {
+				return err
+			}
+		}
+	case "list":
+		patterns := args[1:]
+		if len(patterns) == 0 {
+			patterns = []string{""}
+		}
+		limit := 100
+		outFmt := "%-30s%-7v%s\n"
+		fmt.Printf(outFmt, "Name", "Admin", "Privileges")
+		for _, pattern := range patterns {
+			offset := 0
+			for {
+				users, err := cli.ListUsers(&client.ListUsersOptions{
+					Pattern: pattern,
+					Offset:  offset,
+					Limit:   limit,
+				})
+				if err != nil {
+					return err
+				}
+				for _, u := range users.Users {
+					fmt.Printf(outFmt, u.Name, u.Admin, formatPrivileges(u.Privileges))
+				}
+				if len(users.Users) != limit {
+					break
+				}
+				offset += limit
+			}
+		}
+	case "show":
+		if len(args) != 2 {
+			userUsage()
+			return errors.New("must provide exactly one user name")
+		}
+		u, err := cli.User(cli.UserLink(args[1]))
+		if err != nil {
+			return err
+		}
+		printUser(u)
+	default:
+		userUsage()
+		return fmt.Errorf("unknown user command %q", args[0])
+	}
+	return nil
+}
+
+// readPassword reads a password from the first line of stdin.
+func readPassword() (string, error) {
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", errors.Wrap(err, "failed to read password")
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("must provide a password")
+	}
+	return password, nil
+}
+
+func printUser(u client.User) {
+	fmt.Println("Name:", u.Name)
+	fmt.Println("Admin:", u.Admin)
+	fmt.Println("Privileges:")
+	resources := make([]string, 0, len(u.Privileges))
+	for r := range u.Privileges {
+		resources = append(resources, r)
+	}
+	sort.Strings(resources)
+	for _, r := range resources {
+		fmt.Printf("    %s: %s\n", r, strings.Join(u.Privileges[r], ","))
+	}
+}
+
+func formatPrivileges(privileges map[string][]string) string {
+	list := make([]string, 0, len(privileges))
+	for r, ps := range privileges {
+		list = append(list, r+"="+strings.Join(ps, ","))
+	}
+	sort.Strings(list)
+	return strings.Join(list, " ")
+}
+
+// Namespace
+var (
+	namespaceCreateFlags = flag.NewFlagSet("namespace-create", flag.ExitOnError)
+	ncDBRPs              = make(dbrps, 0)
+	namespaceUpdateFlags = flag.NewFlagSet("namespace-update", flag.ExitOnError)
+	nuDBRPs              = make(dbrps, 0)
+	nuRemoveDBRPs        = namespaceUpdateFlags.Bool("remove-dbrps", false, "Remove all DBRPs of the namespace, allowing its tasks to use all DBRPs.")
+	ncQuotas             client.NamespaceQuotas
+	nuQuotas             client.NamespaceQuotas
+)
+
+// quotaFlags defines the flags of the quotas of a namespace.
+func quotaFlags(fs *flag.FlagSet, q *client.NamespaceQuotas) {
+	fs.IntVar(&q.MaxTasks, "max-tasks", 0, "Maximum number of executing tasks of the namespace, 0 is unlimited.")
+	fs.Int64Var(&q.MaxPointsPerSecond, "max-points-per-second", 0, "Maximum number of points per second written into the tasks of the namespace, 0 is unlimited.")
+	fs.Int64Var(&q.MaxAlertEventsPerMinute, "max-alert-events-per-minute", 0, "Maximum number of alert events per minute collected by the topics of the namespace, 0 is unlimited.")
+	fs.Int64Var(&q.MaxHandlerInvocationsPerMinute, "max-handler-invocations-per-minute", 0, "Maximum number of events per minute handled by the handlers of the namespace, 0 is unlimited.")
+}
+
+func init() {
+	quotaFlags(namespaceCreateFlags, &ncQuotas)
+	quotaFlags(namespaceUpdateFlags, &nuQuotas)
+	namespaceCreateFlags.Var(&ncDBRPs, "dbrp", `A database and retention policy pair tasks of the namespace may use of the form "db"."rp". May be repeated, tasks may use all DBRPs if not set.`)
+	namespaceUpdateFlags.Var(&nuDBRPs, "dbrp", `A database and retention policy pair tasks of the namespace may use of the form "db"."rp", replacing all existing DBRPs. May be repeated.`)
+	namespaceCreateFlags.Usage = namespaceCreateUsage
+	namespaceUpdateFlags.Usage = namespaceUpdateUsage
+}
+
+func namespaceUsage() {
+	var u = `Usage: kapacitor namespace [create|update|delete|list|show] [options] [args]
+
+	Manage the namespaces of tasks, templates and alert topics.
+
+	Use the -namespace option or the KAPACITOR_NAMESPACE environment variable
+	to manage the tasks, templates and topics of a namespace with the other commands.
+	Privileges on a namespace are granted on the resource /api/namespaces/<namespace>.
+
+Examples:
+
+	$ kapacitor namespace create -dbrp telegraf.autogen team-a
+
+		Creates the namespace team-a, whose tasks may only use the telegraf.autogen DBRP.
+
+	$ kapacitor -namespace team-a list tasks
+
+		Lists the tasks of the namespace team-a.
+
+	$ kapacitor namespace delete team-a
+
+		Deletes the namespace team-a, its tasks, templates and topics are not deleted.
+`
+	fmt.Fprintln(os.Stderr, u)
+}
+
+func namespaceCreateUsage() {
+	var u = `Usage: kapacitor namespace create [options] <id>
+
+	Create a namespace.
+
+Options:
+`
+	fmt.Fprintln(os.Stderr, u)
+	namespaceCreateFlags.PrintDefaults()
+}
+
+func namespaceUpdateUsage() {
+	var u = `Usage: kapacitor namespace update [options] <id>
+
+	Update a namespace, only the given options are changed.
+
+Options:
+`
+	fmt.Fprintln(os.Stderr, u)
+	namespaceUpdateFlags.PrintDefaults()
+}
+
+func doNamespace(args []string) error {
+	if len(args) == 0 {
+		namespaceUsage()
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "create":
+		namespaceCreateFlags.Parse(args[1:])
+		if namespaceCreateFlags.NArg() != 1 {
+			namespaceCreateFlags.Usage()
+			return errors.New("must provide exactly one namespace ID")
+		}
+		n, err := cli.CreateNamespace(client.CreateNamespaceOptions{
+			ID:     namespaceCreateFlags.Arg(0),
+			DBRPs:  ncDBRPs,
+			Quotas: ncQuotas,
+		})
+		if err != nil {
+			return err
+		}
+		printNamespace(n)
+	case "update":
+		namespaceUpdateFlags.Parse(args[1:])
+		if namespaceUpdateFlags.NArg() != 1 {
+			namespaceUpdateFlags.Usage()
+			return errors.New("must provide exactly one namespace ID")
+		}
+		opt := client.UpdateNamespaceOptions{}
+		if *nuRemoveDBRPs {
+			if len(nuDBRPs) > 0 {
+				return errors.New("cannot both set and remove DBRPs")
+			}
+			opt.DBRPs = make([]client.DBRP, 0)
+		} else if len(nuDBRPs) > 0 {
+			opt.DBRPs = nuDBRPs
+		}
+		link := cli.NamespaceLink(namespaceUpdateFlags.Arg(0))
+		// Only change the quotas set by flags.
+		quotas := client.UpdateNamespaceQuotas{}
+		quotasSet := false
+		namespaceUpdateFlags.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "max-tasks":
+				quotas.MaxTasks = &nuQuotas.MaxTasks
+			case "max-points-per-second":
+				quotas.MaxPointsPerSecond = &nuQuotas.MaxPointsPerSecond
+			case "max-alert-events-per-minute":
+				quotas.MaxAlertEventsPerMinute = &nuQuotas.MaxAlertEventsPerMinute
+			case "max-handler-invocations-per-minute":
+				quotas.MaxHandlerInvocationsPerMinute = &nuQuotas.MaxHandlerInvocationsPerMinute
+			default:
+				return
+			}
+			quotasSet = true
+		})
+		if quotasSet {
+			opt.Quotas = &quotas
+		}
+		n, err := cli.UpdateNamespace(link, opt)
+		if err != nil {
+			return err
+		}
+		printNamespace(n)
+	case "delete":
+		if len(args) < 2 {
+			namespaceUsage()
+			return errors.New("must provide at least one namespace ID")
+		}
+		for _, id := range args[1:] {
+			if err := cli.DeleteNamespace(cli.NamespaceLink(id)); err != nil {
+				return err
+			}
+		}
+	case "list":
+		patterns := args[1:]
+		if len(patterns) == 0 {
+			patterns = []string{""}
+		}
+		limit := 100
+		outFmt := "%-30s%s\n"
+		fmt.Printf(outFmt, "ID", "DBRPs")
+		for _, pattern := range patterns {
+			offset := 0
+			for {
+				namespaces, err := cli.ListNamespaces(&client.ListNamespacesOptions{
+					Pattern: pattern,
+					Offset:  offset,
+					Limit:   limit,
+				})
+				if err != nil {
+					return err
+				}
+				for _, n := range namespaces.Namespaces {
+					fmt.Printf(outFmt, n.ID, formatDBRPs(n.DBRPs))
+				}
+				if len(namespaces.Namespaces) != limit {
+					break
+				}
+				offset += limit
+			}
+		}
+	case "show":
+		if len(args) != 2 {
+			namespaceUsage()
+			return errors.New("must provide exactly one namespace ID")
+		}
+		n, err := cli.Namespace(cli.NamespaceLink(args[1]))
+		if err != nil {
+			return err
+		}
+		printNamespace(n)
+	default:
+		namespaceUsage()
+		return fmt.Errorf("unknown namespace command %q", args[0])
+	}
+	return nil
+}
+
+func printNamespace(n client.Namespace) {
+	fmt.Println("ID:", n.ID)
+	fmt.Println("DBRPs:", formatDBRPs(n.DBRPs))
+	fmt.Println("Max Tasks:", formatQuota(int64(n.Quotas.MaxTasks)))
+	fmt.Println("Max Points/s:", formatQuota(n.Quotas.MaxPointsPerSecond))
+	fmt.Println("Max Alert Events/min:", formatQuota(n.Quotas.MaxAlertEventsPerMinute))
+	fmt.Println("Max Handler Invocations/min:", formatQuota(n.Quotas.MaxHandlerInvocationsPerMinute))
+	fmt.Println("Created:", n.Created.Format(time.RFC822))
+	fmt.Println("Modified:", n.Modified.Format(time.RFC822))
+}
+
+func formatQuota(limit int64) string {
+	if limit == 0 {
+		return "unlimited"
+	}
+	return strconv.FormatInt(limit, 10)
+}
+
+func formatDBRPs(dbrps []client.DBRP) string {
+	if len(dbrps) == 0 {
+		return "all"
+	}
+	list := make([]string, len(dbrps))
+	for i, dbrp := range dbrps {
+		list[i] = dbrp.String()
+	}
+	return strings.Join(list, " ")
+}
+
 // Backup
 func backupUsage() {
 	var u = `Usage: kapacitor backup <output file>
@@ -8,7 +8,6 @@ import (
 	"github.com/influxdata/kapacitor/edge"
 	"github.com/influxdata/kapacitor/models"
 	"github.com/influxdata/kapacitor/pipeline"
-	"github.com/influxdata/kapacitor/tick/ast"
 	"github.com/influxdata/kapacitor/tick/stateful"
 )
 
@@ -39,18 +38,14 @@ func newCombineNode(et *ExecutingTask, n *pipeline.CombineNode, l *log.Logger) (
 			return nil, fmt.Errorf("Failed to compile %v expression: %v", i, err)
 		}
 		cn.expressions[i] = statefulExpr
-		cn.scopePools[i] = stateful.NewScopePool(ast.FindReferenceVariables(lambda.Expression))
+		cn.scopePools[i] = stateful.NewScopePool(stateful.FindReferenceVariables(lambda.Expression))
 	}
 	cn.node.runF = cn.runCombine
 	return cn, nil
 }
 
 func (n *CombineNode) runCombine([]byte) error {
-	consumer := edge.NewGroupedConsumer(
-		n.ins[0],
-		n,
-	)
-	n.statMap.Set(statCardinalityGauge, consumer.CardinalityVar())
+	consumer := n.newGroupedConsumer(n)
 	return consumer.Consume()
 }
 
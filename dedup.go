@@ -0,0 +1,214 @@
+package kapacitor
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/influxdata/kapacitor/edge"
+	"github.com/influxdata/kapacitor/expvar"
+	"github.com/influxdata/kapacitor/pipeline"
+	"github.com/influxdata/kapacitor/tick/stateful"
+)
+
+const (
+	statsDuplicatesDropped = "duplicates_dropped"
+)
+
+type DedupNode struct {
+	node
+	d *pipeline.DedupNode
+
+	expression stateful.Expression
+	scopePool  stateful.ScopePool
+
+	duplicatesDropped *expvar.Int
+}
+
+// Create a new DedupNode which drops duplicate points.
+func newDedupNode(et *ExecutingTask, n *pipeline.DedupNode, l *log.Logger) (*DedupNode, error) {
+	dn := &DedupNode{
+		node:              node{Node: n, et: et, logger: l},
+		d:                 n,
+		duplicatesDropped: new(expvar.Int),
+	}
+	if n.Key != nil {
+		expr, err := stateful.NewExpression(n.Key.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to compile key expression: %v", err)
+		}
+		dn.expression = expr
+		dn.scopePool = stateful.NewScopePool(stateful.FindReferenceVariables(n.Key.Expression))
+	}
+	dn.node.runF = dn.runDedup
+	return dn, nil
+}
+
+func (n *DedupNode) runDedup([]byte) error {
+	n.statMap.Set(statsDuplicatesDropped, n.duplicatesDropped)
+	consumer := n.newGroupedConsumer(n)
+	return consumer.Consume()
+}
+
+func (n *DedupNode) NewGroup(group edge.GroupInfo, first edge.PointMeta) (edge.Receiver, error) {
+	g := &dedupGroup{
+		n:    n,
+		seen: make(map[string]time.Time),
+	}
+	if n.expression != nil {
+		g.expr = n.expression.CopyReset()
+	}
+	return edge.NewReceiverFromForwardReceiverWithStats(
+		n.outs,
+		edge.NewTimedForwardReceiver(n.timer, g),
+	), nil
+}
+
+type dedupKey struct {
+	key  string
+	time time.Time
+}
+
+// dedupKeys is a min-heap of keys ordered by time,
+// so that keys arriving out of order still expire after the TTL.
+type dedupKeys []dedupKey
+
+func (k dedupKeys) Len() int           { return len(k) }
+func (k dedupKeys) Less(i, j int) bool { return k[i].time.Before(k[j].time) }
+func (k dedupKeys) Swap(i, j int)      { k[i], k[j] = k[j], k[i] }
+
+func (k *dedupKeys) Push(x interface{}) {
+	*k = append(*k, x.(dedupKey))
+}
+
+func (k *dedupKeys) Pop() interface{} {
+	old := *k
+	n := len(old)
+	x := old[n-1]
+	*k = old[:n-1]
+	return x
+}
+
+type dedupGroup struct {
+	n    *DedupNode
+	expr stateful.Expression
+
+	// The time each key was first seen.
+	seen map[string]time.Time
+	// The keys ordered by the time they were seen.
+	order dedupKeys
+	// The latest time seen.
+	latest time.Time
+
+	buf bytes.Buffer
+}
+
+func (g *dedupGroup) BeginBatch(begin edge.BeginBatchMessage) (edge.Message, error) {
+	begin = begin.ShallowCopy()
+	begin.SetSizeHint(0)
+	return begin, nil
+}
+
+func (g *dedupGroup) BatchPoint(bp edge.BatchPointMessage) (edge.Message, error) {
+	return g.dedup(bp)
+}
+
+func (g *dedupGroup) EndBatch(end edge.EndBatchMessage) (edge.Message, error) {
+	return end, nil
+}
+
+func (g *dedupGroup) Point(p edge.PointMessage) (edge.Message, error) {
+	return g.dedup(p)
+}
+
+func (g *dedupGroup) Barrier(b edge.BarrierMessage) (edge.Message, error) {
+	return b, nil
+}
+
+func (g *dedupGroup) DeleteGroup(d edge.DeleteGroupMessage) (edge.Message, error) {
+	return d, nil
+}
+
+func (g *dedupGroup) dedup(p edge.FieldsTagsTimeGetterMessage) (edge.Message, error) {
+	key, err := g.key(p)
+	if err != nil {
+		g.n.incrementErrorCount()
+		g.n.logger.Println("E! failed to compute dedup key:", err)
+		return p, nil
+	}
+	t := p.Time()
+	if t.After(g.latest) {
+		g.latest = t
+	}
+	g.expire()
+	if seen, ok := g.seen[key]; ok && absDuration(t.Sub(seen)) < g.n.d.Ttl {
+		g.n.duplicatesDropped.Add(1)
+		return nil, nil
+	}
+	g.seen[key] = t
+	heap.Push(&g.order, dedupKey{key: key, time: t})
+	if int64(len(g.order)) > g.n.d.MaxKeys {
+		g.forgetOldest()
+	}
+	return p, nil
+}
+
+// expire forgets the keys older than the TTL.
+func (g *dedupGroup) expire() {
+	for len(g.order) > 0 && g.latest.Sub(g.order[0].time) >= g.n.d.Ttl {
+		g.forgetOldest()
+	}
+}
+
+func (g *dedupGroup) forgetOldest() {
+	k := heap.Pop(&g.order).(dedupKey)
+	// The key may have been seen again after it expired.
+	if g.seen[k.key].Equal(k.time) {
+		delete(g.seen, k.key)
+	}
+}
+
+func (g *dedupGroup) key(p edge.FieldsTagsTimeGetter) (string, error) {
+	if g.expr != nil {
+		return evalKey(g.expr, g.n.scopePool, p)
+	}
+
+	g.buf.Reset()
+	g.buf.WriteString(strconv.FormatInt(p.Time().UnixNano(), 10))
+	tags := p.Tags()
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&g.buf, ",%s=%s", name, tags[name])
+	}
+	g.buf.WriteByte(' ')
+	fields := p.Fields()
+	names = g.n.d.FieldList
+	if len(names) == 0 {
+		names = make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	for _, name := range names {
+		if v, ok := fields[name]; ok {
+			fmt.Fprintf(&g.buf, ",%s=%T:%v", name, v, v)
+		}
+	}
+	return g.buf.String(), nil
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
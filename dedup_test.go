@@ -0,0 +1,69 @@
+package kapacitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/kapacitor/edge"
+	"github.com/influxdata/kapacitor/expvar"
+	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/pipeline"
+	"github.com/influxdata/kapacitor/tick/ast"
+	"github.com/influxdata/kapacitor/tick/stateful"
+)
+
+func TestDedupGroup_ExpireOutOfOrder(t *testing.T) {
+	expr, err := stateful.NewExpression(&ast.ReferenceNode{Reference: "host"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &DedupNode{
+		d:                 &pipeline.DedupNode{Ttl: 10 * time.Second, MaxKeys: 100},
+		scopePool:         stateful.NewScopePool([]string{"host"}),
+		duplicatesDropped: new(expvar.Int),
+	}
+	g := &dedupGroup{
+		n:    n,
+		expr: expr,
+		seen: make(map[string]time.Time),
+	}
+
+	t0 := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, p := range []struct {
+		host    string
+		time    time.Time
+		dropped bool
+	}{
+		{host: "A", time: t0.Add(20 * time.Second)},
+		// B arrives out of order.
+		{host: "B", time: t0.Add(5 * time.Second)},
+		{host: "A", time: t0.Add(21 * time.Second), dropped: true},
+		// B has expired by the time of the data, even though A, which arrived before it, has not.
+		{host: "C", time: t0.Add(22 * time.Second)},
+	} {
+		m := edge.NewPointMessage(
+			"cpu", "db", "rp",
+			models.Dimensions{},
+			models.Fields{"value": 1.0},
+			models.Tags{"host": p.host},
+			p.time,
+		)
+		out, err := g.Point(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dropped := out == nil; dropped != p.dropped {
+			t.Errorf("%d: unexpected dropped got %t exp %t", i, dropped, p.dropped)
+		}
+	}
+
+	if _, ok := g.seen["string:B"]; ok {
+		t.Error("expected key of B to have expired")
+	}
+	if got, exp := len(g.seen), 2; got != exp {
+		t.Errorf("unexpected number of keys got %d exp %d", got, exp)
+	}
+	if got, exp := n.duplicatesDropped.IntValue(), int64(1); got != exp {
+		t.Errorf("unexpected duplicates dropped got %d exp %d", got, exp)
+	}
+}
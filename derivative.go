@@ -26,11 +26,7 @@ func newDerivativeNode(et *ExecutingTask, n *pipeline.DerivativeNode, l *log.Log
 }
 
 func (n *DerivativeNode) runDerivative([]byte) error {
-	consumer := edge.NewGroupedConsumer(
-		n.ins[0],
-		n,
-	)
-	n.statMap.Set(statCardinalityGauge, consumer.CardinalityVar())
+	consumer := n.newGroupedConsumer(n)
 	return consumer.Consume()
 }
 
@@ -15,6 +15,7 @@ import (
 const (
 	statCollected = "collected"
 	statEmitted   = "emitted"
+	statDropped   = "dropped"
 
 	defaultEdgeBufferSize = 1000
 )
@@ -33,7 +34,16 @@ type Edge struct {
 }
 
 func newEdge(taskName, parentName, childName string, t pipeline.EdgeType, size int, logService LogService) edge.StatsEdge {
-	e := edge.NewStatsEdge(edge.NewChannelEdge(t, defaultEdgeBufferSize))
+	return newBoundedEdge(taskName, parentName, childName, t, size, edge.BlockPolicy, nil, logService)
+}
+
+// newBoundedEdge creates an edge that applies the overflow policy when full.
+// Dropped messages are counted in dropped, which may be nil.
+func newBoundedEdge(taskName, parentName, childName string, t pipeline.EdgeType, size int, policy edge.OverflowPolicy, dropped *expvar.Int, logService LogService) edge.StatsEdge {
+	if dropped == nil {
+		dropped = new(expvar.Int)
+	}
+	e := edge.NewStatsEdge(edge.NewBoundedChannelEdge(t, size, policy, dropped))
 	tags := map[string]string{
 		"task":   taskName,
 		"parent": parentName,
@@ -43,6 +53,7 @@ func newEdge(taskName, parentName, childName string, t pipeline.EdgeType, size i
 	key, sm := vars.NewStatistic("edges", tags)
 	sm.Set(statCollected, e.CollectedVar())
 	sm.Set(statEmitted, e.EmittedVar())
+	sm.Set(statDropped, dropped)
 	name := fmt.Sprintf("%s|%s->%s", taskName, parentName, childName)
 	return &Edge{
 		StatsEdge: e,
@@ -95,6 +95,12 @@ type MultiReceiver interface {
 	Finish() error
 }
 
+// MultiGroupDeleter is implemented by a MultiReceiver that releases the state of deleted groups.
+// DeleteGroup messages are discarded for other receivers.
+type MultiGroupDeleter interface {
+	DeleteGroup(src int, d DeleteGroupMessage) error
+}
+
 func NewMultiConsumerWithStats(ins []StatsEdge, r MultiReceiver) Consumer {
 	edges := make([]Edge, len(ins))
 	for i := range ins {
@@ -167,6 +173,12 @@ LOOP:
 				if err := c.r.Barrier(m.Src, msg); err != nil {
 					return err
 				}
+			case DeleteGroupMessage:
+				if gd, ok := c.r.(MultiGroupDeleter); ok {
+					if err := gd.DeleteGroup(m.Src, msg); err != nil {
+						return err
+					}
+				}
 			}
 		}
 	}
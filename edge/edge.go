@@ -2,8 +2,10 @@ package edge
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 
+	"github.com/influxdata/kapacitor/expvar"
 	"github.com/influxdata/kapacitor/pipeline"
 )
 
@@ -33,6 +35,46 @@ const (
 	edgeAborted
 )
 
+// OverflowPolicy determines how an edge handles new messages when its buffer is full.
+type OverflowPolicy int
+
+const (
+	// BlockPolicy waits until there is room in the buffer.
+	BlockPolicy OverflowPolicy = iota
+	// DropOldestPolicy discards the oldest buffered message to make room for the new message.
+	DropOldestPolicy
+	// DropNewestPolicy discards the new message.
+	DropNewestPolicy
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case BlockPolicy:
+		return "block"
+	case DropOldestPolicy:
+		return "drop-oldest"
+	case DropNewestPolicy:
+		return "drop-newest"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseOverflowPolicy parses the string representation of a policy.
+// The empty string is the BlockPolicy.
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	switch s {
+	case "", "block":
+		return BlockPolicy, nil
+	case "drop-oldest":
+		return DropOldestPolicy, nil
+	case "drop-newest":
+		return DropNewestPolicy, nil
+	default:
+		return 0, fmt.Errorf("unknown overflow policy %q, must be one of block, drop-oldest or drop-newest", s)
+	}
+}
+
 // channelEdge is an implementation of Edge using channels.
 type channelEdge struct {
 	aborting chan struct{}
@@ -40,21 +82,68 @@ type channelEdge struct {
 
 	typ pipeline.EdgeType
 
+	policy  OverflowPolicy
+	dropped *expvar.Int
+
 	mu    sync.Mutex
 	state edgeState
 }
 
 // NewChannelEdge returns a new edge that uses channels as the underlying transport.
 func NewChannelEdge(typ pipeline.EdgeType, size int) Edge {
+	return NewBoundedChannelEdge(typ, size, BlockPolicy, nil)
+}
+
+// NewBoundedChannelEdge returns a new channel edge that applies the policy when its buffer is full.
+// Messages discarded by the policy are counted in dropped, which may be nil.
+// The drop policies only make sense for stream edges, discarding batch messages produces incomplete batches.
+func NewBoundedChannelEdge(typ pipeline.EdgeType, size int, policy OverflowPolicy, dropped *expvar.Int) Edge {
+	if policy != BlockPolicy && size < 1 {
+		// An unbuffered edge is always full.
+		size = 1
+	}
+	if dropped == nil {
+		dropped = new(expvar.Int)
+	}
 	return &channelEdge{
 		aborting: make(chan struct{}),
 		messages: make(chan Message, size),
 		state:    edgeOpen,
 		typ:      typ,
+		policy:   policy,
+		dropped:  dropped,
 	}
 }
 
 func (e *channelEdge) Collect(m Message) error {
+	switch e.policy {
+	case DropNewestPolicy:
+		select {
+		case e.messages <- m:
+			return nil
+		case <-e.aborting:
+			return ErrAborted
+		default:
+			e.dropped.Add(1)
+			return nil
+		}
+	case DropOldestPolicy:
+		for {
+			select {
+			case e.messages <- m:
+				return nil
+			case <-e.aborting:
+				return ErrAborted
+			default:
+			}
+			// The buffer is full, discard the oldest message and try again.
+			select {
+			case <-e.messages:
+				e.dropped.Add(1)
+			default:
+			}
+		}
+	}
 	select {
 	case e.messages <- m:
 		return nil
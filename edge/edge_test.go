@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/influxdata/kapacitor/edge"
+	"github.com/influxdata/kapacitor/expvar"
 	"github.com/influxdata/kapacitor/models"
 	"github.com/influxdata/kapacitor/pipeline"
 )
@@ -110,6 +111,203 @@ func TestEdge_CollectBatch(t *testing.T) {
 	}
 }
 
+func TestEdge_OverflowPolicy(t *testing.T) {
+	points := make([]edge.PointMessage, 5)
+	for i := range points {
+		points[i] = edge.NewPointMessage(name, db, rp, models.Dimensions{}, models.Fields{"value": int64(i)}, groupTags, now)
+	}
+	testCases := []struct {
+		policy  edge.OverflowPolicy
+		exp     []int64
+		dropped int64
+	}{
+		{
+			policy:  edge.DropOldestPolicy,
+			exp:     []int64{3, 4},
+			dropped: 3,
+		},
+		{
+			policy:  edge.DropNewestPolicy,
+			exp:     []int64{0, 1},
+			dropped: 3,
+		},
+	}
+	for _, tc := range testCases {
+		dropped := new(expvar.Int)
+		e := edge.NewBoundedChannelEdge(pipeline.StreamEdge, 2, tc.policy, dropped)
+		for _, p := range points {
+			if err := e.Collect(p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		e.Close()
+		var got []int64
+		for m, ok := e.Emit(); ok; m, ok = e.Emit() {
+			got = append(got, m.(edge.PointMessage).Fields()["value"].(int64))
+		}
+		if !reflect.DeepEqual(got, tc.exp) {
+			t.Errorf("%v: unexpected points got %v exp %v", tc.policy, got, tc.exp)
+		}
+		if got := dropped.IntValue(); got != tc.dropped {
+			t.Errorf("%v: unexpected dropped count got %d exp %d", tc.policy, got, tc.dropped)
+		}
+	}
+}
+
+func TestParseOverflowPolicy(t *testing.T) {
+	for _, p := range []edge.OverflowPolicy{edge.BlockPolicy, edge.DropOldestPolicy, edge.DropNewestPolicy} {
+		got, err := edge.ParseOverflowPolicy(p.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != p {
+			t.Errorf("unexpected policy got %v exp %v", got, p)
+		}
+	}
+	if _, err := edge.ParseOverflowPolicy("drop"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+type countingGroupedReceiver struct {
+	points  int
+	evicted []models.GroupID
+}
+
+func (r *countingGroupedReceiver) EvictGroup(id models.GroupID) {
+	r.evicted = append(r.evicted, id)
+}
+
+func (r *countingGroupedReceiver) NewGroup(group edge.GroupInfo, first edge.PointMeta) (edge.Receiver, error) {
+	return countingReceiver{r: r}, nil
+}
+
+type countingReceiver struct {
+	noopReceiver
+	r *countingGroupedReceiver
+}
+
+func (r countingReceiver) Point(p edge.PointMessage) error {
+	r.r.points++
+	return nil
+}
+
+func TestGroupedConsumer_MaxGroups(t *testing.T) {
+	e := edge.NewChannelEdge(pipeline.StreamEdge, defaultEdgeBufferSize)
+	r := new(countingGroupedReceiver)
+	consumer := edge.NewGroupedConsumerWithLimit(e, r, 2)
+	for _, host := range []string{"a", "b", "c", "a", "d"} {
+		tags := models.Tags{"host": host}
+		e.Collect(edge.NewPointMessage(name, db, rp, models.Dimensions{TagNames: []string{"host"}}, nil, tags, now))
+	}
+	e.Close()
+	if err := consumer.Consume(); err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := r.points, 3; got != exp {
+		t.Errorf("unexpected points received got %d exp %d", got, exp)
+	}
+	if got, exp := consumer.CardinalityVar().IntValue(), int64(2); got != exp {
+		t.Errorf("unexpected cardinality got %d exp %d", got, exp)
+	}
+	if got, exp := consumer.DroppedVar().IntValue(), int64(2); got != exp {
+		t.Errorf("unexpected dropped count got %d exp %d", got, exp)
+	}
+}
+
+func hostPoint(host string, t time.Time) edge.PointMessage {
+	return edge.NewPointMessage(name, db, rp, models.Dimensions{TagNames: []string{"host"}}, nil, models.Tags{"host": host}, t)
+}
+
+func TestGroupedConsumer_EvictLRU(t *testing.T) {
+	e := edge.NewChannelEdge(pipeline.StreamEdge, defaultEdgeBufferSize)
+	r := new(countingGroupedReceiver)
+	consumer := edge.NewGroupedConsumerWithLimits(e, r, edge.GroupLimits{MaxGroups: 2, Evict: true})
+	for _, host := range []string{"a", "b", "a", "c", "b"} {
+		e.Collect(hostPoint(host, now))
+	}
+	e.Close()
+	if err := consumer.Consume(); err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := r.points, 5; got != exp {
+		t.Errorf("unexpected points received got %d exp %d", got, exp)
+	}
+	if got, exp := consumer.CardinalityVar().IntValue(), int64(2); got != exp {
+		t.Errorf("unexpected cardinality got %d exp %d", got, exp)
+	}
+	if got, exp := consumer.EvictedVar().IntValue(), int64(2); got != exp {
+		t.Errorf("unexpected evicted count got %d exp %d", got, exp)
+	}
+	// b is the least recently used group when c arrives, then a when b returns.
+	exp := []models.GroupID{hostPoint("b", now).GroupID(), hostPoint("a", now).GroupID()}
+	if !reflect.DeepEqual(r.evicted, exp) {
+		t.Errorf("unexpected evicted groups got %v exp %v", r.evicted, exp)
+	}
+}
+
+func TestGroupedConsumer_IdleTimeout(t *testing.T) {
+	e := edge.NewChannelEdge(pipeline.StreamEdge, defaultEdgeBufferSize)
+	r := new(countingGroupedReceiver)
+	consumer := edge.NewGroupedConsumerWithLimits(e, r, edge.GroupLimits{IdleTimeout: 10 * time.Second})
+	e.Collect(hostPoint("a", now))
+	e.Collect(hostPoint("b", now.Add(5*time.Second)))
+	// a has been idle for 12s and expires before it is recreated.
+	e.Collect(hostPoint("a", now.Add(12*time.Second)))
+	// All groups have been idle long enough.
+	e.Collect(edge.NewBarrierMessage(now.Add(30 * time.Second)))
+	e.Close()
+	if err := consumer.Consume(); err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := consumer.CardinalityVar().IntValue(), int64(0); got != exp {
+		t.Errorf("unexpected cardinality got %d exp %d", got, exp)
+	}
+	if got, exp := consumer.ExpiredVar().IntValue(), int64(3); got != exp {
+		t.Errorf("unexpected expired count got %d exp %d", got, exp)
+	}
+	exp := []models.GroupID{
+		hostPoint("a", now).GroupID(),
+		hostPoint("b", now).GroupID(),
+		hostPoint("a", now).GroupID(),
+	}
+	if !reflect.DeepEqual(r.evicted, exp) {
+		t.Errorf("unexpected evicted groups got %v exp %v", r.evicted, exp)
+	}
+}
+
+type deletingMultiReceiver struct {
+	deleted []int
+}
+
+func (r *deletingMultiReceiver) BufferedBatch(int, edge.BufferedBatchMessage) error { return nil }
+func (r *deletingMultiReceiver) Point(int, edge.PointMessage) error                 { return nil }
+func (r *deletingMultiReceiver) Barrier(int, edge.BarrierMessage) error             { return nil }
+func (r *deletingMultiReceiver) Finish() error                                      { return nil }
+func (r *deletingMultiReceiver) DeleteGroup(src int, d edge.DeleteGroupMessage) error {
+	r.deleted = append(r.deleted, src)
+	return nil
+}
+
+func TestMultiConsumer_DeleteGroup(t *testing.T) {
+	ins := []edge.Edge{
+		edge.NewChannelEdge(pipeline.StreamEdge, defaultEdgeBufferSize),
+		edge.NewChannelEdge(pipeline.StreamEdge, defaultEdgeBufferSize),
+	}
+	r := new(deletingMultiReceiver)
+	consumer := edge.NewMultiConsumer(ins, r)
+	ins[1].Collect(edge.NewDeleteGroupMessage(hostPoint("a", now).GroupID()))
+	for _, in := range ins {
+		in.Close()
+	}
+	if err := consumer.Consume(); err != nil {
+		t.Fatal(err)
+	}
+	if exp := []int{1}; !reflect.DeepEqual(r.deleted, exp) {
+		t.Errorf("unexpected deleted sources got %v exp %v", r.deleted, exp)
+	}
+}
+
 var emittedMsg edge.Message
 var emittedOK bool
 
@@ -1,7 +1,9 @@
 package edge
 
 import (
+	"container/list"
 	"errors"
+	"time"
 
 	"github.com/influxdata/kapacitor/expvar"
 	"github.com/influxdata/kapacitor/models"
@@ -12,6 +14,12 @@ type GroupedConsumer interface {
 	Consumer
 	// CardinalityVar is an exported var that indicates the current number of groups being managed.
 	CardinalityVar() expvar.IntVar
+	// DroppedVar is an exported var that counts the messages discarded because the group limit was reached.
+	DroppedVar() expvar.IntVar
+	// EvictedVar is an exported var that counts the groups evicted to make room for new groups.
+	EvictedVar() expvar.IntVar
+	// ExpiredVar is an exported var that counts the groups evicted because they were idle.
+	ExpiredVar() expvar.IntVar
 }
 
 // GroupedReceiver creates and deletes receivers as groups are created and deleted.
@@ -21,6 +29,29 @@ type GroupedReceiver interface {
 	NewGroup(group GroupInfo, first PointMeta) (Receiver, error)
 }
 
+// GroupEvicter is implemented by a GroupedReceiver that needs to release state held outside of
+// the group receiver when a group is evicted by the grouped consumer.
+// Evicted groups are removed locally, no DeleteGroup message is sent to the group receiver or downstream.
+type GroupEvicter interface {
+	EvictGroup(id models.GroupID)
+}
+
+// GroupLimits configures the number of groups managed by a grouped consumer.
+type GroupLimits struct {
+	// MaxGroups is the maximum number of groups, zero means no limit.
+	MaxGroups int
+	// Evict causes the least recently used group to be evicted when a new group arrives at the limit.
+	// Otherwise messages for new groups are discarded.
+	Evict bool
+	// IdleTimeout is the duration, measured in message time, after which a group without messages is evicted.
+	// Zero means groups never expire.
+	IdleTimeout time.Duration
+}
+
+func (l GroupLimits) tracksUsage() bool {
+	return (l.MaxGroups > 0 && l.Evict) || l.IdleTimeout > 0
+}
+
 // GroupInfo identifies and contians information about a specific group.
 type GroupInfo struct {
 	ID         models.GroupID
@@ -31,17 +62,51 @@ type GroupInfo struct {
 type groupedConsumer struct {
 	consumer    Consumer
 	gr          GroupedReceiver
-	groups      map[models.GroupID]Receiver
+	groups      map[models.GroupID]*group
 	current     Receiver
 	cardinality *expvar.Int
+	limits      GroupLimits
+	dropped     *expvar.Int
+	evicted     *expvar.Int
+	expired     *expvar.Int
+
+	// lru orders the groups from most to least recently used.
+	// It is only maintained if the limits require usage tracking.
+	lru *list.List
+}
+
+type group struct {
+	id       models.GroupID
+	r        Receiver
+	lastSeen time.Time
+	elem     *list.Element
 }
 
 // NewGroupedConsumer creates a new grouped consumer for edge e and grouped receiver r.
 func NewGroupedConsumer(e Edge, r GroupedReceiver) GroupedConsumer {
+	return NewGroupedConsumerWithLimit(e, r, 0)
+}
+
+// NewGroupedConsumerWithLimit creates a new grouped consumer that manages at most maxGroups groups.
+// Messages for new groups beyond the limit are discarded.
+// A maxGroups of zero means no limit.
+func NewGroupedConsumerWithLimit(e Edge, r GroupedReceiver, maxGroups int) GroupedConsumer {
+	return NewGroupedConsumerWithLimits(e, r, GroupLimits{MaxGroups: maxGroups})
+}
+
+// NewGroupedConsumerWithLimits creates a new grouped consumer that enforces the group limits l.
+func NewGroupedConsumerWithLimits(e Edge, r GroupedReceiver, l GroupLimits) GroupedConsumer {
 	gc := &groupedConsumer{
 		gr:          r,
-		groups:      make(map[models.GroupID]Receiver),
+		groups:      make(map[models.GroupID]*group),
 		cardinality: new(expvar.Int),
+		limits:      l,
+		dropped:     new(expvar.Int),
+		evicted:     new(expvar.Int),
+		expired:     new(expvar.Int),
+	}
+	if l.tracksUsage() {
+		gc.lru = list.New()
 	}
 	gc.consumer = NewConsumerWithReceiver(e, gc)
 	return gc
@@ -53,19 +118,75 @@ func (c *groupedConsumer) Consume() error {
 func (c *groupedConsumer) CardinalityVar() expvar.IntVar {
 	return c.cardinality
 }
+func (c *groupedConsumer) DroppedVar() expvar.IntVar {
+	return c.dropped
+}
+
+func (c *groupedConsumer) EvictedVar() expvar.IntVar {
+	return c.evicted
+}
+func (c *groupedConsumer) ExpiredVar() expvar.IntVar {
+	return c.expired
+}
 
-func (c *groupedConsumer) getOrCreateGroup(group GroupInfo, first PointMeta) (Receiver, error) {
-	r, ok := c.groups[group.ID]
+func (c *groupedConsumer) getOrCreateGroup(info GroupInfo, first PointMeta) (Receiver, error) {
+	c.expireIdle(first.Time())
+	g, ok := c.groups[info.ID]
 	if !ok {
+		if c.limits.MaxGroups > 0 && len(c.groups) >= c.limits.MaxGroups {
+			if !c.limits.Evict {
+				c.dropped.Add(1)
+				return discardReceiver{}, nil
+			}
+			c.evict(c.lru.Back().Value.(*group))
+			c.evicted.Add(1)
+		}
 		c.cardinality.Add(1)
-		recv, err := c.gr.NewGroup(group, first)
+		recv, err := c.gr.NewGroup(info, first)
 		if err != nil {
 			return nil, err
 		}
-		c.groups[group.ID] = recv
-		r = recv
+		g = &group{
+			id: info.ID,
+			r:  recv,
+		}
+		c.groups[info.ID] = g
+		if c.lru != nil {
+			g.elem = c.lru.PushFront(g)
+		}
+	}
+	if c.lru != nil {
+		c.lru.MoveToFront(g.elem)
+		if t := first.Time(); t.After(g.lastSeen) {
+			g.lastSeen = t
+		}
+	}
+	return g.r, nil
+}
+
+// expireIdle evicts the least recently used groups that have been idle longer than the idle timeout as of now.
+func (c *groupedConsumer) expireIdle(now time.Time) {
+	if c.limits.IdleTimeout <= 0 {
+		return
+	}
+	cutoff := now.Add(-c.limits.IdleTimeout)
+	for e := c.lru.Back(); e != nil; e = c.lru.Back() {
+		g := e.Value.(*group)
+		if !g.lastSeen.Before(cutoff) {
+			return
+		}
+		c.evict(g)
+		c.expired.Add(1)
+	}
+}
+
+func (c *groupedConsumer) evict(g *group) {
+	delete(c.groups, g.id)
+	c.lru.Remove(g.elem)
+	c.cardinality.Add(-1)
+	if ge, ok := c.gr.(GroupEvicter); ok {
+		ge.EvictGroup(g.id)
 	}
-	return r, nil
 }
 
 func (c *groupedConsumer) BeginBatch(begin BeginBatchMessage) error {
@@ -108,9 +229,10 @@ func (c *groupedConsumer) Point(p PointMessage) error {
 }
 
 func (c *groupedConsumer) Barrier(b BarrierMessage) error {
+	c.expireIdle(b.Time())
 	// Barriers messages apply to all gorups
-	for _, r := range c.groups {
-		if err := r.Barrier(b); err != nil {
+	for _, g := range c.groups {
+		if err := g.r.Barrier(b); err != nil {
 			return err
 		}
 	}
@@ -119,11 +241,24 @@ func (c *groupedConsumer) Barrier(b BarrierMessage) error {
 
 func (c *groupedConsumer) DeleteGroup(d DeleteGroupMessage) error {
 	id := d.GroupID()
-	r, ok := c.groups[id]
+	g, ok := c.groups[id]
 	if ok {
 		delete(c.groups, id)
+		if c.lru != nil {
+			c.lru.Remove(g.elem)
+		}
 		c.cardinality.Add(-1)
-		return r.DeleteGroup(d)
+		return g.r.DeleteGroup(d)
 	}
 	return nil
 }
+
+// discardReceiver discards all messages.
+type discardReceiver struct{}
+
+func (discardReceiver) BeginBatch(BeginBatchMessage) error   { return nil }
+func (discardReceiver) BatchPoint(BatchPointMessage) error   { return nil }
+func (discardReceiver) EndBatch(EndBatchMessage) error       { return nil }
+func (discardReceiver) Point(PointMessage) error             { return nil }
+func (discardReceiver) Barrier(BarrierMessage) error         { return nil }
+func (discardReceiver) DeleteGroup(DeleteGroupMessage) error { return nil }
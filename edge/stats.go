@@ -21,6 +21,8 @@ type StatsEdge interface {
 	EmittedVar() expvar.IntVar
 	// ReadGroupStats allows for the reading of the current statistics by group.
 	ReadGroupStats(func(*GroupStats))
+	// ForgetGroup removes the statistics of the group, i.e. once the group has been evicted.
+	ForgetGroup(group models.GroupID)
 }
 
 //  GroupStats represents the statistics for a specific group.
@@ -95,6 +97,13 @@ func (e *statsEdge) ReadGroupStats(f func(groupStat *GroupStats)) {
 	}
 }
 
+// ForgetGroup removes the statistics of the group.
+func (e *statsEdge) ForgetGroup(group models.GroupID) {
+	e.mu.Lock()
+	delete(e.groupStats, group)
+	e.mu.Unlock()
+}
+
 func (e *statsEdge) incCollected(group models.GroupID, infoF func() GroupInfo, count int64) {
 	// Manually unlock below as defer was too much of a performance hit
 	e.mu.Lock()
@@ -112,18 +121,14 @@ func (e *statsEdge) incCollected(group models.GroupID, infoF func() GroupInfo, c
 }
 
 // Increment the emitted count of the group for this edge.
-func (e *statsEdge) incEmitted(group models.GroupID, infoF func() GroupInfo, count int64) {
+// Messages are collected before they are emitted, so the stats of the group only miss
+// if the group has been forgotten since, in which case they are not created again.
+func (e *statsEdge) incEmitted(group models.GroupID, count int64) {
 	// Manually unlock below as defer was too much of a performance hit
 	e.mu.Lock()
 
 	if stats, ok := e.groupStats[group]; ok {
 		stats.Emitted += count
-	} else {
-		stats = &GroupStats{
-			Emitted:   count,
-			GroupInfo: infoF(),
-		}
-		e.groupStats[group] = stats
 	}
 	e.mu.Unlock()
 }
@@ -178,15 +183,11 @@ func (e *batchStatsEdge) Emit() (m Message, ok bool) {
 			e.emitSize++
 		case EndBatchMessage:
 			e.emitted.Add(1)
-			e.incEmitted(
-				e.currentEmitGroup.ID,
-				func() GroupInfo { return e.currentEmitGroup },
-				e.emitSize,
-			)
+			e.incEmitted(e.currentEmitGroup.ID, e.emitSize)
 		case BufferedBatchMessage:
 			e.emitted.Add(1)
 			begin := b.Begin()
-			e.incEmitted(begin.GroupID(), begin.GroupInfo, int64(len(b.Points())))
+			e.incEmitted(begin.GroupID(), int64(len(b.Points())))
 		default:
 			// Do not count other messages
 			// TODO(nathanielc): How should we count other messages?
@@ -220,7 +221,7 @@ func (e *streamStatsEdge) Emit() (m Message, ok bool) {
 	if ok && m.Type() == Point {
 		e.emitted.Add(1)
 		p := m.(GroupInfoer)
-		e.incEmitted(p.GroupID(), p.GroupInfo, 1)
+		e.incEmitted(p.GroupID(), 1)
 	}
 	return
 }
@@ -45,11 +45,11 @@ func newEvalNode(et *ExecutingTask, n *pipeline.EvalNode, l *log.Logger) (*EvalN
 			return nil, fmt.Errorf("Failed to compile %v expression: %v", i, err)
 		}
 		en.expressions[i] = statefulExpr
-		refVars := ast.FindReferenceVariables(lambda.Expression)
+		refVars := stateful.FindReferenceVariables(lambda.Expression)
 		en.refVarList[i] = refVars
 	}
 	// Create a single pool for the combination of all expressions
-	en.scopePool = stateful.NewScopePool(ast.FindReferenceVariables(expressions...))
+	en.scopePool = stateful.NewScopePool(stateful.FindReferenceVariables(expressions...))
 
 	// Create map of tags
 	if l := len(n.TagsList); l > 0 {
@@ -64,11 +64,7 @@ func newEvalNode(et *ExecutingTask, n *pipeline.EvalNode, l *log.Logger) (*EvalN
 }
 
 func (n *EvalNode) runEval(snapshot []byte) error {
-	consumer := edge.NewGroupedConsumer(
-		n.ins[0],
-		n,
-	)
-	n.statMap.Set(statCardinalityGauge, consumer.CardinalityVar())
+	consumer := n.newGroupedConsumer(n)
 
 	return consumer.Consume()
 
@@ -25,6 +25,22 @@ func EvalPredicate(se stateful.Expression, scopePool stateful.ScopePool, p edge.
 	return se.EvalBool(vars)
 }
 
+// evalKey - Evaluate a given expression as the key of a point, values of different types yield different keys
+func evalKey(se stateful.Expression, scopePool stateful.ScopePool, p edge.FieldsTagsTimeGetter) (string, error) {
+	vars := scopePool.Get()
+	defer scopePool.Put(vars)
+	err := fillScope(vars, scopePool.ReferenceVariables(), p)
+	if err != nil {
+		return "", err
+	}
+
+	v, err := se.Eval(vars)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%T:%v", v, v), nil
+}
+
 // fillScope - given a scope and reference variables, we fill the exact variables from the now, fields and tags.
 func fillScope(vars *stateful.Scope, referenceVariables []string, p edge.FieldsTagsTimeGetter) error {
 	now := p.Time()
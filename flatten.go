@@ -32,11 +32,7 @@ func newFlattenNode(et *ExecutingTask, n *pipeline.FlattenNode, l *log.Logger) (
 }
 
 func (n *FlattenNode) runFlatten([]byte) error {
-	consumer := edge.NewGroupedConsumer(
-		n.ins[0],
-		n,
-	)
-	n.statMap.Set(statCardinalityGauge, consumer.CardinalityVar())
+	consumer := n.newGroupedConsumer(n)
 	return consumer.Consume()
 }
 
@@ -0,0 +1,272 @@
+package kapacitor
+
+import (
+	"errors"
+	"log"
+	"math"
+	"time"
+
+	"github.com/influxdata/kapacitor/edge"
+	"github.com/influxdata/kapacitor/pipeline"
+)
+
+const (
+	// The maximum number of steps evaluated when searching for the time to threshold of a seasonal model.
+	maxForecastSearchSteps = 100000
+)
+
+type ForecastNode struct {
+	node
+	f *pipeline.ForecastNode
+}
+
+// Create a new ForecastNode which forecasts a field using an online model.
+func newForecastNode(et *ExecutingTask, n *pipeline.ForecastNode, l *log.Logger) (*ForecastNode, error) {
+	fn := &ForecastNode{
+		node: node{Node: n, et: et, logger: l},
+		f:    n,
+	}
+	fn.node.runF = fn.runForecast
+	return fn, nil
+}
+
+func (n *ForecastNode) runForecast([]byte) error {
+	consumer := n.newGroupedConsumer(n)
+	return consumer.Consume()
+}
+
+func (n *ForecastNode) NewGroup(group edge.GroupInfo, first edge.PointMeta) (edge.Receiver, error) {
+	return edge.NewReceiverFromForwardReceiverWithStats(
+		n.outs,
+		edge.NewTimedForwardReceiver(n.timer, n.newGroup()),
+	), nil
+}
+
+func (n *ForecastNode) newGroup() *forecastGroup {
+	return &forecastGroup{
+		n:     n,
+		model: newHoltWinters(n.f.Alpha, n.f.Beta, n.f.Gamma, int(n.f.Season)),
+	}
+}
+
+type forecastGroup struct {
+	n     *ForecastNode
+	model *holtWinters
+
+	first time.Time
+	last  time.Time
+	count int64
+}
+
+func (g *forecastGroup) BeginBatch(begin edge.BeginBatchMessage) (edge.Message, error) {
+	g.reset()
+	begin = begin.ShallowCopy()
+	begin.SetSizeHint(0)
+	return begin, nil
+}
+
+func (g *forecastGroup) BatchPoint(bp edge.BatchPointMessage) (edge.Message, error) {
+	bp = bp.ShallowCopy()
+	if !g.forecast(bp) {
+		return nil, nil
+	}
+	return bp, nil
+}
+
+func (g *forecastGroup) EndBatch(end edge.EndBatchMessage) (edge.Message, error) {
+	return end, nil
+}
+
+func (g *forecastGroup) Point(p edge.PointMessage) (edge.Message, error) {
+	p = p.ShallowCopy()
+	if !g.forecast(p) {
+		return nil, nil
+	}
+	return p, nil
+}
+
+func (g *forecastGroup) Barrier(b edge.BarrierMessage) (edge.Message, error) {
+	return b, nil
+}
+
+func (g *forecastGroup) DeleteGroup(d edge.DeleteGroupMessage) (edge.Message, error) {
+	return d, nil
+}
+
+func (g *forecastGroup) reset() {
+	g.model.reset()
+	g.first = time.Time{}
+	g.last = time.Time{}
+	g.count = 0
+}
+
+// forecast updates the model with the point and adds the forecast fields to the point.
+// Returns false if the point should be dropped.
+func (g *forecastGroup) forecast(p edge.FieldsTagsTimeSetter) bool {
+	x, ok := numToFloat(p.Fields()[g.n.f.Field])
+	if !ok {
+		g.n.incrementErrorCount()
+		g.n.logger.Printf("E! field %s missing or not a number", g.n.f.Field)
+		return false
+	}
+	t := p.Time()
+	if g.count == 0 {
+		g.first = t
+	}
+	g.last = t
+	g.count++
+	if !g.model.update(x) {
+		return false
+	}
+
+	interval, err := g.interval()
+	if err != nil {
+		g.n.incrementErrorCount()
+		g.n.logger.Println("E! cannot forecast:", err)
+		return false
+	}
+	steps := float64(g.n.f.Horizon) / float64(interval)
+
+	fields := p.Fields().Copy()
+	fields[g.n.f.As] = g.model.forecast(steps)
+	if g.n.f.UseThreshold {
+		ttt := float64(-1)
+		if k, ok := g.model.stepsTo(g.n.f.ThresholdValue, steps); ok {
+			ttt = k * float64(interval) / float64(g.n.f.Unit)
+		}
+		fields[g.n.f.TimeToThresholdAs] = ttt
+	}
+	p.SetFields(fields)
+	return true
+}
+
+// interval returns the configured interval or the mean interval between the points seen.
+func (g *forecastGroup) interval() (time.Duration, error) {
+	if g.n.f.Interval > 0 {
+		return g.n.f.Interval, nil
+	}
+	interval := g.last.Sub(g.first) / time.Duration(g.count-1)
+	if interval <= 0 {
+		return 0, errors.New("points have no interval, set the interval property")
+	}
+	return interval, nil
+}
+
+// holtWinters is an additive Holt-Winters model that is updated one value at a time.
+// Without a season it is Holt's linear trend model.
+type holtWinters struct {
+	alpha, beta, gamma float64
+	season             int
+
+	// Values buffered until the model is initialized.
+	init        []float64
+	initialized bool
+
+	level    float64
+	trend    float64
+	seasonal []float64
+	// Index of the seasonal component of the next value.
+	next int
+}
+
+func newHoltWinters(alpha, beta, gamma float64, season int) *holtWinters {
+	return &holtWinters{
+		alpha:  alpha,
+		beta:   beta,
+		gamma:  gamma,
+		season: season,
+	}
+}
+
+func (m *holtWinters) reset() {
+	m.init = m.init[:0]
+	m.initialized = false
+	m.level = 0
+	m.trend = 0
+	m.seasonal = nil
+	m.next = 0
+}
+
+// update adds a value to the model and reports whether the model is initialized.
+func (m *holtWinters) update(x float64) bool {
+	if !m.initialized {
+		m.init = append(m.init, x)
+		m.initialize()
+		return m.initialized
+	}
+	var s float64
+	if m.season > 0 {
+		s = m.seasonal[m.next]
+	}
+	prevLevel := m.level
+	m.level = m.alpha*(x-s) + (1-m.alpha)*(m.level+m.trend)
+	m.trend = m.beta*(m.level-prevLevel) + (1-m.beta)*m.trend
+	if m.season > 0 {
+		m.seasonal[m.next] = m.gamma*(x-m.level) + (1-m.gamma)*s
+		m.next = (m.next + 1) % m.season
+	}
+	return true
+}
+
+// initialize the model once enough values are buffered.
+func (m *holtWinters) initialize() {
+	if m.season == 0 {
+		if len(m.init) < 2 {
+			return
+		}
+		m.level = m.init[1]
+		m.trend = m.init[1] - m.init[0]
+	} else {
+		if len(m.init) < m.season {
+			return
+		}
+		var sum float64
+		for _, v := range m.init {
+			sum += v
+		}
+		m.level = sum / float64(m.season)
+		m.seasonal = make([]float64, m.season)
+		for i, v := range m.init {
+			m.seasonal[i] = v - m.level
+		}
+	}
+	m.init = m.init[:0]
+	m.initialized = true
+}
+
+// seasonalAt returns the seasonal component k steps ahead, the current value is 0 steps ahead.
+func (m *holtWinters) seasonalAt(k int) float64 {
+	if m.season == 0 {
+		return 0
+	}
+	return m.seasonal[((m.next-1+k)%m.season+m.season)%m.season]
+}
+
+// forecast returns the forecast steps ahead of the current value.
+func (m *holtWinters) forecast(steps float64) float64 {
+	return m.level + steps*m.trend + m.seasonalAt(int(math.Ceil(steps)))
+}
+
+// stepsTo returns the number of steps until the forecast crosses the threshold,
+// searching up to the given number of steps.
+func (m *holtWinters) stepsTo(threshold, maxSteps float64) (float64, bool) {
+	current := m.forecast(0)
+	if current == threshold {
+		return 0, true
+	}
+	above := current > threshold
+	if m.season == 0 {
+		if m.trend == 0 {
+			return 0, false
+		}
+		k := (threshold - m.level) / m.trend
+		return k, k > 0 && k <= maxSteps
+	}
+	stride := math.Max(1, math.Ceil(maxSteps/maxForecastSearchSteps))
+	for k := stride; k <= maxSteps; k += stride {
+		if f := m.forecast(k); f == threshold || (f > threshold) != above {
+			return k, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,52 @@
+package kapacitor
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHoltWinters_Linear(t *testing.T) {
+	m := newHoltWinters(0.5, 0.1, 0.1, 0)
+	if m.update(10) {
+		t.Fatal("expected model to need two values to initialize")
+	}
+	for _, v := range []float64{20, 30, 40} {
+		if !m.update(v) {
+			t.Fatal("expected model to be initialized")
+		}
+	}
+	if got, exp := m.forecast(3), 70.0; got != exp {
+		t.Errorf("unexpected forecast got %v exp %v", got, exp)
+	}
+	if k, ok := m.stepsTo(60, 10); !ok || k != 2 {
+		t.Errorf("unexpected steps to threshold got %v %v exp 2 true", k, ok)
+	}
+	if _, ok := m.stepsTo(60, 1); ok {
+		t.Error("expected threshold not to be reached within one step")
+	}
+	if _, ok := m.stepsTo(0, 10); ok {
+		t.Error("expected threshold below an increasing trend not to be reached")
+	}
+}
+
+func TestHoltWinters_Seasonal(t *testing.T) {
+	pattern := []float64{0, 10, 0, -10}
+	m := newHoltWinters(0.5, 0.1, 0.5, len(pattern))
+	// An increasing trend of 1 per step with a seasonal pattern.
+	value := func(i int) float64 {
+		return float64(i) + pattern[i%len(pattern)]
+	}
+	i := 0
+	for ; i < 40; i++ {
+		m.update(value(i))
+	}
+	for k := 1; k <= 8; k++ {
+		if got, exp := m.forecast(float64(k)), value(i-1+k); math.Abs(got-exp) > 1 {
+			t.Errorf("unexpected forecast %d steps ahead got %v exp %v", k, got, exp)
+		}
+	}
+	// The next peak is at step 2 with a value of 51.
+	if k, ok := m.stepsTo(50, 8); !ok || k != 2 {
+		t.Errorf("unexpected steps to threshold got %v %v exp 2 true", k, ok)
+	}
+}
@@ -1,6 +1,7 @@
 package kapacitor
 
 import (
+	"container/list"
 	"log"
 	"sort"
 	"sync"
@@ -28,6 +29,18 @@ type GroupByNode struct {
 	mu       sync.RWMutex
 	lastTime time.Time
 	groups   map[models.GroupID]edge.BufferedBatchMessage
+
+	// Usage of the forwarded groups, only tracked if the node has group limits.
+	// The list orders the groups from most to least recently used.
+	lru     *list.List
+	usage   map[models.GroupID]*list.Element
+	evicted *expvar.Int
+	expired *expvar.Int
+}
+
+type groupUsage struct {
+	id       models.GroupID
+	lastSeen time.Time
 }
 
 // Create a new GroupByNode which splits the stream dynamically based on the specified dimensions.
@@ -52,6 +65,18 @@ func (n *GroupByNode) runGroupBy([]byte) error {
 		return int64(l)
 	}
 	n.statMap.Set(statCardinalityGauge, expvar.NewIntFuncGauge(valueF))
+	if n.g.MaxGroups > 0 || n.g.GroupIdleTimeout > 0 {
+		n.lru = list.New()
+		n.usage = make(map[models.GroupID]*list.Element)
+		n.evicted = new(expvar.Int)
+		n.expired = new(expvar.Int)
+		if n.g.MaxGroups > 0 {
+			n.statMap.Set(statGroupsEvicted, n.evicted)
+		}
+		if n.g.GroupIdleTimeout > 0 {
+			n.statMap.Set(statGroupsExpired, n.expired)
+		}
+	}
 
 	consumer := edge.NewConsumerWithReceiver(
 		n.ins[0],
@@ -67,6 +92,7 @@ func (n *GroupByNode) Point(p edge.PointMessage) error {
 	dims.ByName = dims.ByName || n.byName
 	dims.TagNames = computeTagNames(p.Tags(), n.allDimensions, n.tagNames, n.g.ExcludedDimensions)
 	p.SetDimensions(dims)
+	n.track(p.GroupID(), p.Time())
 	n.timer.Stop()
 	if err := edge.Forward(n.outs, p); err != nil {
 		return err
@@ -143,6 +169,7 @@ func (n *GroupByNode) emit(t time.Time) error {
 			group.Begin().SetSizeHint(len(group.Points()))
 			// Sort points since we didn't guarantee insertion order was sorted
 			sort.Sort(edge.BatchPointMessages(group.Points()))
+			n.track(id, group.Begin().Time())
 			// Send group batch to all children
 			n.timer.Pause()
 			if err := edge.Forward(n.outs, group); err != nil {
@@ -158,6 +185,42 @@ func (n *GroupByNode) emit(t time.Time) error {
 	return nil
 }
 
+// track records the use of the group at time t and evicts groups beyond the group limits.
+// Evicted groups only lose their statistics, since the node keeps no other state for them.
+func (n *GroupByNode) track(id models.GroupID, t time.Time) {
+	if n.lru == nil {
+		return
+	}
+	if n.g.GroupIdleTimeout > 0 {
+		cutoff := t.Add(-n.g.GroupIdleTimeout)
+		for e := n.lru.Back(); e != nil && e.Value.(*groupUsage).lastSeen.Before(cutoff); e = n.lru.Back() {
+			n.evict(e)
+			n.expired.Add(1)
+		}
+	}
+	e, ok := n.usage[id]
+	if !ok {
+		if n.g.MaxGroups > 0 && int64(n.lru.Len()) >= n.g.MaxGroups {
+			n.evict(n.lru.Back())
+			n.evicted.Add(1)
+		}
+		e = n.lru.PushFront(&groupUsage{id: id})
+		n.usage[id] = e
+	}
+	n.lru.MoveToFront(e)
+	if u := e.Value.(*groupUsage); t.After(u.lastSeen) {
+		u.lastSeen = t
+	}
+}
+
+func (n *GroupByNode) evict(e *list.Element) {
+	u := n.lru.Remove(e).(*groupUsage)
+	delete(n.usage, u.id)
+	for _, out := range n.outs {
+		out.ForgetGroup(u.id)
+	}
+}
+
 func determineTagNames(dimensions []interface{}, excluded []string) (allDimensions bool, realDimensions []string) {
 	for _, dim := range dimensions {
 		switch d := dim.(type) {
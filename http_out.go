@@ -77,11 +77,7 @@ func (n *HTTPOutNode) runOut([]byte) error {
 		return err
 	}
 
-	consumer := edge.NewGroupedConsumer(
-		n.ins[0],
-		n,
-	)
-	n.statMap.Set(statCardinalityGauge, consumer.CardinalityVar())
+	consumer := n.newGroupedConsumer(n)
 
 	return consumer.Consume()
 }
@@ -128,7 +124,10 @@ func (n *HTTPOutNode) newGroup(groupID models.GroupID) *httpOutGroup {
 func (n *HTTPOutNode) deleteGroup(idx int) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
+	n.deleteGroupLocked(idx)
+}
 
+func (n *HTTPOutNode) deleteGroupLocked(idx int) {
 	for _, g := range n.indexes[idx+1:] {
 		g.idx--
 	}
@@ -136,6 +135,19 @@ func (n *HTTPOutNode) deleteGroup(idx int) {
 	n.result.Series = append(n.result.Series[0:idx], n.result.Series[idx+1:]...)
 }
 
+// EvictGroup removes the result of an evicted group.
+func (n *HTTPOutNode) EvictGroup(id models.GroupID) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for idx, g := range n.indexes {
+		if g.id == id {
+			n.deleteGroupLocked(idx)
+			return
+		}
+	}
+}
+
 type httpOutGroup struct {
 	n      *HTTPOutNode
 	id     models.GroupID
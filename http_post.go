@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/influxdata/kapacitor/bufpool"
 	"github.com/influxdata/kapacitor/edge"
@@ -52,11 +53,7 @@ func newHTTPPostNode(et *ExecutingTask, n *pipeline.HTTPPostNode, l *log.Logger)
 }
 
 func (n *HTTPPostNode) runPost([]byte) error {
-	consumer := edge.NewGroupedConsumer(
-		n.ins[0],
-		n,
-	)
-	n.statMap.Set(statCardinalityGauge, consumer.CardinalityVar())
+	consumer := n.newGroupedConsumer(n)
 
 	return consumer.Consume()
 
@@ -92,13 +89,13 @@ func (g *httpPostGroup) EndBatch(end edge.EndBatchMessage) (edge.Message, error)
 
 func (g *httpPostGroup) BufferedBatch(batch edge.BufferedBatchMessage) (edge.Message, error) {
 	row := batch.ToRow()
-	g.n.postRow(row)
+	g.n.postRow(batch.Time(), row)
 	return batch, nil
 }
 
 func (g *httpPostGroup) Point(p edge.PointMessage) (edge.Message, error) {
 	row := p.ToRow()
-	g.n.postRow(row)
+	g.n.postRow(p.Time(), row)
 	return p, nil
 }
 
@@ -109,7 +106,7 @@ func (g *httpPostGroup) DeleteGroup(d edge.DeleteGroupMessage) (edge.Message, er
 	return d, nil
 }
 
-func (n *HTTPPostNode) postRow(row *models.Row) {
+func (n *HTTPPostNode) postRow(t time.Time, row *models.Row) {
 	result := new(models.Result)
 	result.Series = []*models.Row{row}
 
@@ -132,6 +129,13 @@ func (n *HTTPPostNode) postRow(row *models.Row) {
 	for k, v := range n.c.Headers {
 		req.Header.Set(k, v)
 	}
+	if n.et.shadow != nil {
+		n.et.shadow.record(n.Name(), ShadowHTTPPost, t, shadowPostData{
+			URL:    req.URL.String(),
+			Result: result,
+		})
+		return
+	}
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		n.incrementErrorCount()
@@ -140,3 +144,9 @@ func (n *HTTPPostNode) postRow(row *models.Row) {
 	}
 	resp.Body.Close()
 }
+
+// An HTTP POST captured by a shadow task.
+type shadowPostData struct {
+	URL    string         `json:"url"`
+	Result *models.Result `json:"result"`
+}
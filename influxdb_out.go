@@ -61,7 +61,7 @@ func (n *InfluxDBOutNode) runOut([]byte) error {
 	n.wb.start()
 
 	// Create the database and retention policy
-	if n.i.CreateFlag {
+	if n.i.CreateFlag && n.et.shadow == nil {
 		err := func() error {
 			cli, err := n.et.tm.InfluxDBService.NewNamedClient(n.i.Cluster)
 			if err != nil {
@@ -187,10 +187,42 @@ func (n *InfluxDBOutNode) write(db, rp string, batch edge.BufferedBatchMessage)
 		WriteConsistency: n.i.WriteConsistency,
 		Precision:        n.i.Precision,
 	}
+	if n.et.shadow != nil {
+		n.shadowWrite(bpc, points)
+		return nil
+	}
 	n.wb.enqueue(bpc, points)
 	return nil
 }
 
+// An InfluxDB write captured by a shadow task.
+type shadowWriteData struct {
+	Database        string   `json:"database"`
+	RetentionPolicy string   `json:"retentionPolicy"`
+	Points          []string `json:"points"`
+}
+
+// shadowWrite captures the points instead of writing them.
+func (n *InfluxDBOutNode) shadowWrite(bpc influxdb.BatchPointsConfig, points []influxdb.Point) {
+	if len(points) == 0 {
+		return
+	}
+	precision := bpc.Precision
+	if precision == "" {
+		precision = "ns"
+	}
+	lines := make([]string, len(points))
+	for i, p := range points {
+		lines[i] = string(p.Bytes(precision))
+	}
+	n.et.shadow.record(n.Name(), ShadowInfluxDBWrite, points[0].Time, shadowWriteData{
+		Database:        bpc.Database,
+		RetentionPolicy: bpc.RetentionPolicy,
+		Points:          lines,
+	})
+	n.pointsWritten.Add(int64(len(points)))
+}
+
 type writeBuffer struct {
 	size          int
 	flushInterval time.Duration
@@ -2976,6 +2976,7 @@ func testBatcher(t *testing.T, name, script string) (clock.Setter, *kapacitor.Ex
 
 	// Create a new execution env
 	tm := kapacitor.NewTaskMaster("testBatcher", newServerInfo(), logService)
+	tm.Replay = true
 	httpdService := newHTTPDService()
 	tm.HTTPDService = httpdService
 	tm.TaskStore = taskStore{}
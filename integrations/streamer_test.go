@@ -8,6 +8,7 @@ import (
 	"html"
 	"io/ioutil"
 	"log"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"net/mail"
@@ -29,6 +30,7 @@ import (
 	"github.com/influxdata/kapacitor/clock"
 	"github.com/influxdata/kapacitor/command"
 	"github.com/influxdata/kapacitor/command/commandtest"
+	"github.com/influxdata/kapacitor/edge"
 	"github.com/influxdata/kapacitor/models"
 	alertservice "github.com/influxdata/kapacitor/services/alert"
 	"github.com/influxdata/kapacitor/services/alert/alerttest"
@@ -783,6 +785,361 @@ stream
 	testStreamerWithOutput(t, "TestStream_Window", script, 13*time.Second, er, false, nil)
 }
 
+func TestStream_Window_Session(t *testing.T) {
+
+	var script = `
+stream
+	|from()
+		.database('dbname')
+		.retentionPolicy('rpname')
+		.measurement('cpu')
+		.where(lambda: "host" == 'serverA')
+	|window()
+		.sessionGap(5s)
+	|httpOut('TestStream_Window_Session')
+`
+
+	start := time.Date(1971, 1, 1, 0, 0, 9, 0, time.UTC).UnixNano()
+	end := time.Date(1971, 1, 1, 0, 0, 10, 0, time.UTC).UnixNano()
+	er := models.Result{
+		Series: models.Rows{
+			{
+				Name:    "cpu",
+				Tags:    nil,
+				Columns: []string{"time", "host", "session_count", "session_end", "session_start", "type", "value"},
+				Values: [][]interface{}{
+					{
+						time.Date(1971, 1, 1, 0, 0, 9, 0, time.UTC),
+						"serverA",
+						2.0,
+						float64(end),
+						float64(start),
+						"idle",
+						100.0,
+					},
+					{
+						time.Date(1971, 1, 1, 0, 0, 10, 0, time.UTC),
+						"serverA",
+						2.0,
+						float64(end),
+						float64(start),
+						"idle",
+						101.0,
+					},
+				},
+			},
+		},
+	}
+
+	testStreamerWithOutput(t, "TestStream_Window_Session", script, 21*time.Second, er, false, nil)
+}
+
+func TestStream_Window_Session_Idle(t *testing.T) {
+
+	var script = `
+stream
+	|from()
+		.database('dbname')
+		.retentionPolicy('rpname')
+		.measurement('cpu')
+		.groupBy('host')
+	|window()
+		.sessionGap(5s)
+	|httpOut('TestStream_Window_Session_Idle')
+`
+
+	// serverB stops sending after 2s, its session is closed once the stream moves past the gap.
+	sessionValues := func(start, end int64, values ...float64) [][]interface{} {
+		rows := make([][]interface{}, len(values))
+		for i, v := range values {
+			rows[i] = []interface{}{
+				time.Date(1971, 1, 1, 0, 0, int(start)+i, 0, time.UTC),
+				float64(len(values)),
+				float64(time.Date(1971, 1, 1, 0, 0, int(end), 0, time.UTC).UnixNano()),
+				float64(time.Date(1971, 1, 1, 0, 0, int(start), 0, time.UTC).UnixNano()),
+				"idle",
+				v,
+			}
+		}
+		return rows
+	}
+	columns := []string{"time", "session_count", "session_end", "session_start", "type", "value"}
+	er := models.Result{
+		Series: models.Rows{
+			{
+				Name:    "cpu",
+				Tags:    map[string]string{"host": "serverA"},
+				Columns: columns,
+				Values:  sessionValues(9, 10, 100, 101),
+			},
+			{
+				Name:    "cpu",
+				Tags:    map[string]string{"host": "serverB"},
+				Columns: columns,
+				Values:  sessionValues(0, 2, 91, 92, 93),
+			},
+		},
+	}
+
+	testStreamerWithOutput(t, "TestStream_Window_Session_Idle", script, 21*time.Second, er, true, nil)
+}
+
+func TestStream_Window_Late(t *testing.T) {
+
+	var script = `
+var window = stream
+	|from()
+		.database('dbname')
+		.retentionPolicy('rpname')
+		.measurement('cpu')
+		.where(lambda: "host" == 'serverA')
+	|window()
+		.period(5s)
+		.every(5s)
+		.allowedLateness(2s)
+
+window
+	|count('value')
+
+window
+	|late()
+	|httpOut('TestStream_Window_Late')
+`
+
+	er := models.Result{
+		Series: models.Rows{
+			{
+				Name:    "cpu",
+				Tags:    map[string]string{"host": "serverA", "type": "idle"},
+				Columns: []string{"time", "value"},
+				Values: [][]interface{}{
+					{
+						time.Date(1971, 1, 1, 0, 0, 2, 0, time.UTC),
+						42.0,
+					},
+				},
+			},
+		},
+	}
+
+	testStreamerWithOutput(t, "TestStream_Window_Late", script, 13*time.Second, er, false, nil)
+}
+
+func TestStream_Dedup(t *testing.T) {
+	var script = `
+stream
+	|from()
+		.measurement('cpu')
+	|dedup()
+		.ttl(10s)
+	|window()
+		.period(5s)
+		.every(5s)
+	|httpOut('TestStream_Dedup')
+`
+	er := models.Result{
+		Series: models.Rows{
+			{
+				Name:    "cpu",
+				Columns: []string{"time", "host", "value"},
+				Values: [][]interface{}{
+					{time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC), "A", 1.0},
+					{time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC), "B", 1.0},
+					{time.Date(1971, 1, 1, 0, 0, 1, 0, time.UTC), "A", 2.0},
+					{time.Date(1971, 1, 1, 0, 0, 2, 0, time.UTC), "A", 3.0},
+					{time.Date(1971, 1, 1, 0, 0, 3, 0, time.UTC), "A", 3.0},
+				},
+			},
+		},
+	}
+
+	clock, et, replayErr, tm := testStreamer(t, "TestStream_Dedup", script, nil)
+	defer tm.Close()
+	if err := fastForwardTask(clock, et, replayErr, tm, 6*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := et.GetOutput("TestStream_Dedup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(output.Endpoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	result := models.Result{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if eq, msg := compareResults(er, result); !eq {
+		t.Error(msg)
+	}
+
+	// The repeated points at 1s and 2s are dropped.
+	stats, err := et.ExecutionStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := stats.NodeStats["dedup2"]["duplicates_dropped"], int64(2); got != exp {
+		t.Errorf("unexpected duplicates_dropped got %v exp %v", got, exp)
+	}
+}
+
+func TestStream_Throttle(t *testing.T) {
+	var script = `
+stream
+	|from()
+		.measurement('cpu')
+	|throttle()
+		.rate(1)
+		.per(2s)
+		.burst(2)
+	|window()
+		.period(10s)
+		.every(10s)
+	|httpOut('TestStream_Throttle')
+`
+	er := models.Result{
+		Series: models.Rows{
+			{
+				Name:    "cpu",
+				Columns: []string{"time", "host", "value"},
+				Values: [][]interface{}{
+					{time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC), "A", 1.0},
+					{time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC), "A", 2.0},
+					{time.Date(1971, 1, 1, 0, 0, 2, 0, time.UTC), "A", 5.0},
+					{time.Date(1971, 1, 1, 0, 0, 6, 0, time.UTC), "A", 7.0},
+					{time.Date(1971, 1, 1, 0, 0, 6, 0, time.UTC), "A", 8.0},
+				},
+			},
+		},
+	}
+
+	testStreamerWithOutput(t, "TestStream_Throttle", script, 11*time.Second, er, false, nil)
+}
+
+func TestStream_Throttle_ThrottledTag(t *testing.T) {
+	var script = `
+stream
+	|from()
+		.measurement('cpu')
+	|throttle()
+		.rate(1)
+		.per(2s)
+		.burst(2)
+		.throttledTag('throttled')
+	|where(lambda: !isPresent("throttled"))
+	|window()
+		.period(10s)
+		.every(10s)
+	|httpOut('TestStream_Throttle_ThrottledTag')
+`
+	er := models.Result{
+		Series: models.Rows{
+			{
+				Name:    "cpu",
+				Columns: []string{"time", "host", "value"},
+				Values: [][]interface{}{
+					{time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC), "A", 1.0},
+					{time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC), "A", 2.0},
+					{time.Date(1971, 1, 1, 0, 0, 2, 0, time.UTC), "A", 5.0},
+					{time.Date(1971, 1, 1, 0, 0, 6, 0, time.UTC), "A", 7.0},
+					{time.Date(1971, 1, 1, 0, 0, 6, 0, time.UTC), "A", 8.0},
+				},
+			},
+		},
+	}
+
+	testStreamerWithOutput(t, "TestStream_Throttle_ThrottledTag", script, 11*time.Second, er, false, nil)
+}
+
+func TestStream_Forecast(t *testing.T) {
+	var script = `
+stream
+	|from()
+		.measurement('disk')
+	|forecast('used')
+		.horizon(5s)
+		.threshold(60.0)
+	|window()
+		.period(10s)
+		.every(10s)
+	|httpOut('TestStream_Forecast')
+`
+	er := models.Result{
+		Series: models.Rows{
+			{
+				Name:    "disk",
+				Columns: []string{"time", "forecast", "host", "time_to_threshold", "used"},
+				Values: [][]interface{}{
+					{time.Date(1971, 1, 1, 0, 0, 1, 0, time.UTC), 70.0, "A", 4.0, 20.0},
+					{time.Date(1971, 1, 1, 0, 0, 2, 0, time.UTC), 80.0, "A", 3.0, 30.0},
+					{time.Date(1971, 1, 1, 0, 0, 3, 0, time.UTC), 90.0, "A", 2.0, 40.0},
+					{time.Date(1971, 1, 1, 0, 0, 4, 0, time.UTC), 100.0, "A", 1.0, 50.0},
+				},
+			},
+		},
+	}
+
+	testStreamerWithOutput(t, "TestStream_Forecast", script, 12*time.Second, er, false, nil)
+}
+
+func TestStream_Aggregate(t *testing.T) {
+
+	var script = `
+stream
+	|from()
+		.database('dbname')
+		.retentionPolicy('rpname')
+		.measurement('cpu')
+		.groupBy('host')
+	|aggregate('value')
+		.functions('count', 'sum', 'mean', 'min', 'max', 'stddev')
+		.quantiles(0.5, 0.9)
+	|httpOut('TestStream_Aggregate')
+`
+
+	er := models.Result{
+		Series: models.Rows{
+			{
+				Name:    "cpu",
+				Tags:    map[string]string{"host": "serverA"},
+				Columns: []string{"time", "count", "max", "mean", "min", "p50", "p90", "stddev", "sum"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 0, 0, 9, 0, time.UTC),
+					10.0,
+					10.0,
+					5.5,
+					1.0,
+					5.002829575110683,
+					8.93541864376352,
+					math.Sqrt(82.5 / 9),
+					55.0,
+				}},
+			},
+			{
+				Name:    "cpu",
+				Tags:    map[string]string{"host": "serverB"},
+				Columns: []string{"time", "count", "max", "mean", "min", "p50", "p90", "stddev", "sum"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 0, 0, 9, 0, time.UTC),
+					10.0,
+					20.0,
+					11.0,
+					2.0,
+					10.074696689511264,
+					17.99414336990081,
+					math.Sqrt(330.0 / 9),
+					110.0,
+				}},
+			},
+		},
+	}
+
+	testStreamerWithOutput(t, "TestStream_Aggregate", script, 13*time.Second, er, true, nil)
+}
+
 func TestStream_Window_Count(t *testing.T) {
 
 	var script = `
@@ -4005,6 +4362,167 @@ errorsByServiceGlobal
 	testStreamerWithOutput(t, "TestStream_JoinOn", script, 13*time.Second, er, true, nil)
 }
 
+func TestStream_JoinAsOf(t *testing.T) {
+	var script = `
+var requests = stream
+	|from()
+		.measurement('requests')
+		.groupBy('service', 'host')
+
+var deploys = stream
+	|from()
+		.measurement('deploys')
+		.groupBy('service')
+
+requests
+	|join(deploys)
+		.as('requests', 'deploy')
+		.on('service')
+		.asOf()
+		.maxStaleness(5s)
+		.fill('null')
+	|httpOut('TestStream_JoinAsOf')
+`
+
+	er := models.Result{
+		Series: models.Rows{
+			{
+				Name:    "requests",
+				Tags:    map[string]string{"host": "a", "service": "api"},
+				Columns: []string{"time", "deploy.version", "requests.value"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 0, 0, 6, 0, time.UTC),
+					"v2",
+					6.0,
+				}},
+			},
+			{
+				Name:    "requests",
+				Tags:    map[string]string{"host": "a", "service": "web"},
+				Columns: []string{"time", "deploy.value", "requests.value"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 0, 0, 7, 0, time.UTC),
+					nil,
+					7.0,
+				}},
+			},
+		},
+	}
+
+	testStreamerWithOutput(t, "TestStream_JoinAsOf", script, 10*time.Second, er, true, nil)
+}
+
+func TestStream_JoinAsOf_SilentSecondary(t *testing.T) {
+	var script = `
+var requests = stream
+	|from()
+		.measurement('requests')
+		.groupBy('service', 'host')
+
+var deploys = stream
+	|from()
+		.measurement('deploys')
+		.groupBy('service')
+
+requests
+	|join(deploys)
+		.as('requests', 'deploy')
+		.on('service')
+		.asOf()
+		.fill('null')
+	|httpOut('TestStream_JoinAsOf_SilentSecondary')
+`
+
+	// The deploys stop after the first point and never reach the time of the requests,
+	// the requests are joined once they waited for the default max wait
+	// without waiting for the stream to finish.
+	er := models.Result{
+		Series: models.Rows{
+			{
+				Name:    "requests",
+				Tags:    map[string]string{"host": "a", "service": "api"},
+				Columns: []string{"time", "deploy.value", "requests.value"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 0, 0, 3, 0, time.UTC),
+					nil,
+					3.0,
+				}},
+			},
+		},
+	}
+
+	clock, et, replayErr, tm := testStreamer(t, "TestStream_JoinAsOf_SilentSecondary", script, nil)
+	defer tm.Close()
+	clock.Set(clock.Zero().Add(71 * time.Second))
+	if err := <-replayErr; err != nil {
+		t.Fatal(err)
+	}
+	output, err := et.GetOutput("TestStream_JoinAsOf_SilentSecondary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg string
+	for i := 0; i < 100; i++ {
+		resp, err := http.Get(output.Endpoint())
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := models.Result{}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var eq bool
+		if eq, msg = compareResults(er, result); eq {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error(msg)
+}
+
+func TestStream_JoinLeft(t *testing.T) {
+	var script = `
+var requests = stream
+	|from()
+		.measurement('requests')
+		.groupBy('service')
+
+var errors = stream
+	|from()
+		.measurement('errors')
+		.groupBy('service')
+
+requests
+	|join(errors)
+		.as('requests', 'errors')
+		.joinType('left')
+		.sourceFill('errors', 0.0)
+		.presenceField('present')
+	|httpOut('TestStream_JoinLeft')
+`
+
+	er := models.Result{
+		Series: models.Rows{
+			{
+				Name:    "requests",
+				Tags:    map[string]string{"service": "api"},
+				Columns: []string{"time", "errors.present", "errors.value", "requests.present", "requests.value"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 0, 0, 4, 0, time.UTC),
+					false,
+					0.0,
+					true,
+					5.0,
+				}},
+			},
+		},
+	}
+
+	testStreamerWithOutput(t, "TestStream_JoinLeft", script, 10*time.Second, er, false, nil)
+}
+
 func TestStream_JoinOnGap(t *testing.T) {
 	var script = `
 var errorsByServiceDCRack = stream
@@ -4961,11 +5479,44 @@ stream
 				Series: models.Rows{
 					{
 						Name:    "cpu",
-						Tags:    map[string]string{"host": "serverA", "type": "idle"},
-						Columns: []string{"time", "percentile"},
+						Tags:    map[string]string{"host": "serverA", "type": "idle"},
+						Columns: []string{"time", "percentile"},
+						Values: [][]interface{}{[]interface{}{
+							endTime,
+							93.0,
+						}},
+					},
+				},
+			},
+		},
+		testCase{
+			Method: "quantileSketch",
+			Args:   "'value', 50.0",
+			ER: models.Result{
+				Series: models.Rows{
+					{
+						Name:    "cpu",
+						Tags:    models.Tags{"host": "serverA"},
+						Columns: []string{"time", "quantileSketch"},
+						Values: [][]interface{}{[]interface{}{
+							endTime,
+							92.76793077851113,
+						}},
+					},
+				},
+			},
+		},
+		testCase{
+			Method: "approxDistinct",
+			ER: models.Result{
+				Series: models.Rows{
+					{
+						Name:    "cpu",
+						Tags:    models.Tags{"host": "serverA"},
+						Columns: []string{"time", "approxDistinct"},
 						Values: [][]interface{}{[]interface{}{
 							endTime,
-							93.0,
+							6.0,
 						}},
 					},
 				},
@@ -5111,6 +5662,41 @@ stream
 	}
 }
 
+func TestStream_InfluxQL_SketchMerge(t *testing.T) {
+	var script = `
+stream
+	|from()
+		.measurement('cpu')
+		.where(lambda: "host" == 'serverA')
+		.groupBy('host')
+	|window()
+		.period(5s)
+		.every(5s)
+	|quantileSketchState('value')
+	// Merge the sketches of two 5s windows.
+	|window()
+		.periodCount(2)
+		.everyCount(2)
+	|quantileSketch('quantileSketchState', 50.0)
+	|httpOut('TestStream_InfluxQL_Float')
+`
+	er := models.Result{
+		Series: models.Rows{
+			{
+				Name:    "cpu",
+				Tags:    models.Tags{"host": "serverA"},
+				Columns: []string{"time", "quantileSketch"},
+				Values: [][]interface{}{[]interface{}{
+					time.Date(1971, 1, 1, 0, 0, 10, 0, time.UTC),
+					92.76793077851113,
+				}},
+			},
+		},
+	}
+
+	testStreamerWithOutput(t, "TestStream_InfluxQL_Float", script, 13*time.Second, er, false, nil)
+}
+
 func TestStream_InfluxQL_Integer(t *testing.T) {
 	type testCase struct {
 		Method        string
@@ -9492,6 +10078,234 @@ stream
 		}
 	}
 }
+func TestStream_Reload(t *testing.T) {
+	name := "TestStream_Reload"
+	var script = `
+var w = stream
+	|from()
+		.measurement('cpu')
+	|window()
+		.period(10s)
+		.every(10s)
+
+w
+	|httpOut('TestStream_Reload')
+`
+	// The window and httpOut nodes are unchanged, the new count node starts without state.
+	var reloaded = script + `
+w
+	|count('value')
+	|httpOut('count')
+`
+
+	tm, err := createTaskMaster()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm.Open()
+	defer tm.Close()
+
+	task, err := tm.NewTask(name, script, kapacitor.StreamTask, dbrps, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	et, err := tm.StartTask(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := tm.Stream(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	start := time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC)
+	write := func(from, to int) {
+		for i := from; i <= to; i++ {
+			p := edge.NewPointMessage(
+				"cpu",
+				"dbname",
+				"rpname",
+				models.Dimensions{},
+				models.Fields{"value": float64(i)},
+				models.Tags{},
+				start.Add(time.Duration(i)*time.Second),
+			)
+			if err := stream.CollectPoint(p); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	waitForCollected := func(et *kapacitor.ExecutingTask, node string, exp int64) {
+		timeout := time.After(5 * time.Second)
+		for {
+			stats, err := et.ExecutionStats()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.NodeStats[node]["collected"] == exp {
+				return
+			}
+			select {
+			case <-timeout:
+				t.Fatalf("timed out waiting for %s to collect %d, stats: %v", node, exp, stats.NodeStats[node])
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+
+	write(0, 4)
+	waitForCollected(et, "window2", 5)
+
+	task, err = tm.NewTask(name, reloaded, kapacitor.StreamTask, dbrps, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	et, err = tm.ReloadTask(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The point at 10s closes the window, which must contain the points from before the reload.
+	write(5, 10)
+	waitForCollected(et, "http_out3", 1)
+
+	output, err := et.GetOutput(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(output.Endpoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	result := models.Result{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Series) != 1 {
+		t.Fatalf("unexpected result, got %v", result)
+	}
+	if got, exp := len(result.Series[0].Values), 10; got != exp {
+		t.Errorf("unexpected number of points in window got %d exp %d: %v", got, exp, result.Series[0].Values)
+	}
+}
+
+func TestStream_Shadow(t *testing.T) {
+	var posts, writes int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&posts, 1)
+	}))
+	defer ts.Close()
+
+	var script = `
+stream
+	|from()
+		.measurement('cpu')
+		.where(lambda: "host" == 'serverA')
+	|window()
+		.period(10s)
+		.every(10s)
+	|count('value')
+	|alert()
+		.id('cpu')
+		.crit(lambda: "count" > 5)
+		.post('` + ts.URL + `')
+	|influxDBOut()
+		.database('db')
+		.retentionPolicy('rp')
+		.measurement('m')
+		.precision('s')
+		.flushInterval(1ms)
+`
+
+	influxdb := NewMockInfluxDBService(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ping" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		atomic.AddInt32(&writes, 1)
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(client.Response{})
+	}))
+
+	tm, err := createTaskMaster()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm.InfluxDBService = influxdb
+	tm.Open()
+	defer tm.Close()
+
+	task, err := tm.NewTask("TestStream_Shadow", script, kapacitor.StreamTask, dbrps, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task.ShadowOf = "live"
+
+	data, err := os.Open(path.Join("data", "TestStream_InfluxDBOut.srpl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	et, err := tm.StartTask(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := tm.Stream(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := clock.New(time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC))
+	replayErr := kapacitor.ReplayStreamFromIO(c, data, stream, false, "s")
+	if err := fastForwardTask(c, et, replayErr, tm, 15*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := et.GetOutput("shadow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(output.Endpoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var report kapacitor.ShadowReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := atomic.LoadInt32(&posts); got != 0 {
+		t.Errorf("shadow task executed alert handler %d times", got)
+	}
+	if got := atomic.LoadInt32(&writes); got != 0 {
+		t.Errorf("shadow task wrote to InfluxDB %d times", got)
+	}
+	if got, exp := report.ShadowOf, "live"; got != exp {
+		t.Errorf("unexpected shadow-of got %s exp %s", got, exp)
+	}
+	if got, exp := report.Captured, int64(2); got != exp {
+		t.Fatalf("unexpected number of captured effects got %d exp %d: %v", got, exp, report.Effects)
+	}
+	alertEffect := report.Effects[0]
+	if alertEffect.Kind != kapacitor.ShadowAlert || alertEffect.Node != "alert4" {
+		t.Errorf("unexpected alert effect %v", alertEffect)
+	}
+	if data, ok := alertEffect.Data.(map[string]interface{}); !ok || data["level"] != "CRITICAL" || data["handlers"] != 1.0 {
+		t.Errorf("unexpected alert effect data %v", alertEffect.Data)
+	}
+	writeEffect := report.Effects[1]
+	if writeEffect.Kind != kapacitor.ShadowInfluxDBWrite || writeEffect.Node != "influxdb_out5" {
+		t.Errorf("unexpected write effect %v", writeEffect)
+	}
+	expPoints := []interface{}{"m count=10i 31536010"}
+	if data, ok := writeEffect.Data.(map[string]interface{}); !ok || !reflect.DeepEqual(data["points"], expPoints) {
+		t.Errorf("unexpected write effect data got %v exp points %v", writeEffect.Data, expPoints)
+	}
+	if got, exp := report.NodeStats["alert4"].Shadow["crits_triggered"], 1.0; got != exp {
+		t.Errorf("unexpected crits_triggered got %v exp %v", got, exp)
+	}
+}
+
 func TestStream_InfluxDBOut_CreateDatabase(t *testing.T) {
 
 	var script = `
@@ -9797,6 +10611,132 @@ stream
 	testStreamerCardinality(t, "TestStream_Cardinality", script, es, nil)
 }
 
+func TestStream_DerivativeCardinality_MaxGroups(t *testing.T) {
+
+	var script = `
+stream
+    |from()
+        .measurement('cpu')
+        .groupBy('host','cpu')
+    |derivative('usage_user')
+        .maxGroups(4)
+`
+
+	// The 9 groups arrive in turn so every point evicts the least recently used group.
+	es := map[string]map[string]interface{}{
+		"stream0": map[string]interface{}{
+			"avg_exec_time_ns":    int64(0),
+			"errors":              int64(0),
+			"working_cardinality": int64(0),
+			"collected":           int64(90),
+			"emitted":             int64(90),
+		},
+		"from1": map[string]interface{}{
+			"avg_exec_time_ns":    int64(0),
+			"errors":              int64(0),
+			"working_cardinality": int64(0),
+			"collected":           int64(90),
+			"emitted":             int64(90),
+		},
+		"derivative2": map[string]interface{}{
+			"emitted":             int64(0),
+			"working_cardinality": int64(4),
+			"groups_evicted":      int64(86),
+			"avg_exec_time_ns":    int64(0),
+			"errors":              int64(0),
+			"collected":           int64(90),
+		},
+	}
+
+	testStreamerCardinality(t, "TestStream_Cardinality", script, es, nil)
+}
+
+func TestStream_GroupLimits_TopGroups(t *testing.T) {
+	testCases := []struct {
+		name   string
+		script string
+		node   string
+		stats  map[string]map[string]interface{}
+	}{
+		{
+			name: "evicting node",
+			script: `
+stream
+    |from()
+        .measurement('cpu')
+        .groupBy('host','cpu')
+    |derivative('usage_user')
+        .maxGroups(4)
+`,
+			node: "derivative2",
+		},
+		{
+			name: "groupBy",
+			script: `
+stream
+    |from()
+        .measurement('cpu')
+    |groupBy('host','cpu')
+        .maxGroups(4)
+    |derivative('usage_user')
+`,
+			node: "derivative3",
+			stats: map[string]map[string]interface{}{
+				"groupby2": map[string]interface{}{
+					"groups_evicted": int64(86),
+				},
+			},
+		},
+		{
+			name: "join",
+			script: `
+var s1 = stream
+    |from()
+        .measurement('cpu')
+        .groupBy('host')
+
+var s2 = stream
+    |from()
+        .measurement('cpu')
+        .groupBy('cpu')
+
+s2|join(s1)
+   .as('s1','s2')
+   .allowedLateness(1s)
+   .maxGroups(4)
+`,
+			node: "join4",
+			stats: map[string]map[string]interface{}{
+				"join4": map[string]interface{}{
+					"groups_evicted": int64(87),
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		clock, et, replayErr, tm := testStreamer(t, "TestStream_Cardinality", tc.script, nil)
+		if err := fastForwardTask(clock, et, replayErr, tm, 20*time.Second); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		stats, err := et.ExecutionStats()
+		tm.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		// The statistics of evicted groups are removed, at most the 4 remaining groups are reported.
+		if got, max := len(stats.TopGroups[tc.node]), 4; got > max {
+			t.Errorf("%s: unexpected number of top groups got %d exp at most %d", tc.name, got, max)
+		}
+		for node, es := range tc.stats {
+			for stat, exp := range es {
+				if got := stats.NodeStats[node][stat]; got != exp {
+					t.Errorf("%s: unexpected %s stat of %s got %v exp %v", tc.name, stat, node, got, exp)
+				}
+			}
+		}
+	}
+}
+
 func TestStream_WhereCardinality(t *testing.T) {
 
 	var script = `
@@ -10881,6 +11821,7 @@ func compareListIgnoreOrder(got, exp []interface{}, cmpF func(got, exp interface
 
 func createTaskMaster() (*kapacitor.TaskMaster, error) {
 	tm := kapacitor.NewTaskMaster("testStreamer", newServerInfo(), logService)
+	tm.Replay = true
 	httpdService := newHTTPDService()
 	tm.HTTPDService = httpdService
 	tm.TaskStore = taskStore{}
@@ -1,6 +1,7 @@
 package kapacitor
 
 import (
+	"container/list"
 	"fmt"
 	"log"
 	"sync"
@@ -33,6 +34,65 @@ type JoinNode struct {
 
 	reported    map[int]bool
 	allReported bool
+
+	late *lateHandler
+	// Event watermark per group per source, used to detect late data.
+	watermarks map[srcGroup]time.Time
+
+	// Usage of the groups per source, only tracked if the node has group limits.
+	// The list orders the groups from most to least recently used.
+	lru     *list.List
+	usage   map[srcGroup]*list.Element
+	evicted *expvar.Int
+	expired *expvar.Int
+
+	// Points of the secondary parents of an as-of join ordered by time, per parent per group.
+	asOfTables map[srcGroup][]edge.PointMessage
+	// Points of the primary parent of an as-of join waiting for the secondary parents.
+	asOfPending []edge.PointMessage
+	// Newest time per parent of an as-of join.
+	asOfHeads []time.Time
+	// Newest time of the emitted points of an as-of join.
+	asOfTime time.Time
+
+	// Type of the join, implied by the fill if not set.
+	joinType string
+	// Fill per parent.
+	sourceFills []joinFill
+	// Fields of the last data received from each parent, used to name the fields of missing parents.
+	sourceFields []models.Fields
+	// Whether only the fill property is set, the join then emits the same data as before join types existed:
+	// the fields of missing parents are named after the fields of the first present parent
+	// and points that are never matched by specific points are not emitted alone.
+	fillOnly bool
+}
+
+type joinUsage struct {
+	sg       srcGroup
+	lastSeen time.Time
+}
+
+type joinFill struct {
+	fill  influxql.FillOption
+	value interface{}
+}
+
+func newJoinFill(fill interface{}) (joinFill, error) {
+	switch fill := fill.(type) {
+	case string:
+		switch fill {
+		case "null":
+			return joinFill{fill: influxql.NullFill}, nil
+		case "none":
+			return joinFill{fill: influxql.NoFill}, nil
+		default:
+			return joinFill{}, fmt.Errorf("unexpected fill option %s", fill)
+		}
+	case int64, float64:
+		return joinFill{fill: influxql.NumberFill, value: fill}, nil
+	default:
+		return joinFill{fill: influxql.NoFill}, nil
+	}
 }
 
 // Create a new JoinNode, which takes pairs from parent streams combines them into a single point.
@@ -45,23 +105,36 @@ func newJoinNode(et *ExecutingTask, n *pipeline.JoinNode, l *log.Logger) (*JoinN
 		specificGroupsBuffer: make(map[models.GroupID][]srcPoint),
 		lowMarks:             make(map[srcGroup]time.Time),
 		reported:             make(map[int]bool),
+		watermarks:           make(map[srcGroup]time.Time),
+		asOfTables:           make(map[srcGroup][]edge.PointMessage),
 	}
 	// Set fill
-	switch fill := n.Fill.(type) {
-	case string:
-		switch fill {
-		case "null":
-			jn.fill = influxql.NullFill
-		case "none":
-			jn.fill = influxql.NoFill
-		default:
-			return nil, fmt.Errorf("unexpected fill option %s", fill)
+	fill, err := newJoinFill(n.Fill)
+	if err != nil {
+		return nil, err
+	}
+	jn.fill = fill.fill
+	jn.fillValue = fill.value
+	jn.joinType = n.JoinType
+	jn.fillOnly = n.JoinType == "" && len(n.SourceFills) == 0 && len(n.FieldFills) == 0
+	if jn.joinType == "" {
+		if jn.fill == influxql.NoFill {
+			jn.joinType = pipeline.InnerJoin
+		} else {
+			jn.joinType = pipeline.FullJoin
+		}
+	}
+	jn.sourceFills = make([]joinFill, len(n.Names))
+	jn.sourceFields = make([]models.Fields, len(n.Names))
+	for i, name := range n.Names {
+		jn.sourceFills[i] = fill
+		if f, ok := n.SourceFills[name]; ok {
+			sf, err := newJoinFill(f)
+			if err != nil {
+				return nil, err
+			}
+			jn.sourceFills[i] = sf
 		}
-	case int64, float64:
-		jn.fill = influxql.NumberFill
-		jn.fillValue = fill
-	default:
-		jn.fill = influxql.NoFill
 	}
 	jn.node.runF = jn.runJoin
 	return jn, nil
@@ -76,6 +149,20 @@ func (n *JoinNode) runJoin([]byte) error {
 		return int64(l)
 	}
 	n.statMap.Set(statCardinalityGauge, expvar.NewIntFuncGauge(valueF))
+	n.late = n.newLateHandler()
+	n.asOfHeads = make([]time.Time, len(n.ins))
+	if n.j.MaxGroups > 0 || n.j.GroupIdleTimeout > 0 {
+		n.lru = list.New()
+		n.usage = make(map[srcGroup]*list.Element)
+		n.evicted = new(expvar.Int)
+		n.expired = new(expvar.Int)
+		if n.j.MaxGroups > 0 {
+			n.statMap.Set(statGroupsEvicted, n.evicted)
+		}
+		if n.j.GroupIdleTimeout > 0 {
+			n.statMap.Set(statGroupsExpired, n.expired)
+		}
+	}
 
 	return consumer.Consume()
 }
@@ -89,10 +176,105 @@ func (n *JoinNode) Point(src int, p edge.PointMessage) error {
 }
 
 func (n *JoinNode) Barrier(src int, b edge.BarrierMessage) error {
+	n.timer.Start()
+	n.expireIdle(b.Time())
+	var err error
+	if n.j.AsOfFlag {
+		// The parent sends no data before the barrier, pending primary points up to it can be emitted.
+		if b.Time().After(n.asOfHeads[src]) {
+			n.asOfHeads[src] = b.Time()
+		}
+		err = n.emitAsOf(false)
+	}
+	n.timer.Stop()
+	if err != nil {
+		return err
+	}
 	return edge.Forward(n.outs, b)
 }
 
+func (n *JoinNode) DeleteGroup(src int, d edge.DeleteGroupMessage) error {
+	sg := srcGroup{src: src, groupId: d.GroupID()}
+	if n.lru != nil {
+		if e, ok := n.usage[sg]; ok {
+			n.lru.Remove(e)
+			delete(n.usage, sg)
+		}
+	}
+	n.forgetSrcGroup(sg)
+	return nil
+}
+
+// track records the use of the group of the source at time t and evicts groups beyond the group limits.
+func (n *JoinNode) track(sg srcGroup, t time.Time) {
+	if n.lru == nil {
+		return
+	}
+	n.expireIdle(t)
+	e, ok := n.usage[sg]
+	if !ok {
+		if n.j.MaxGroups > 0 && int64(n.lru.Len()) >= n.j.MaxGroups {
+			n.evict(n.lru.Back())
+			n.evicted.Add(1)
+		}
+		e = n.lru.PushFront(&joinUsage{sg: sg})
+		n.usage[sg] = e
+	}
+	n.lru.MoveToFront(e)
+	if u := e.Value.(*joinUsage); t.After(u.lastSeen) {
+		u.lastSeen = t
+	}
+}
+
+// expireIdle evicts the groups that have been idle longer than the idle timeout as of now.
+func (n *JoinNode) expireIdle(now time.Time) {
+	if n.lru == nil || n.j.GroupIdleTimeout <= 0 {
+		return
+	}
+	cutoff := now.Add(-n.j.GroupIdleTimeout)
+	for e := n.lru.Back(); e != nil && e.Value.(*joinUsage).lastSeen.Before(cutoff); e = n.lru.Back() {
+		n.evict(e)
+		n.expired.Add(1)
+	}
+}
+
+func (n *JoinNode) evict(e *list.Element) {
+	u := n.lru.Remove(e).(*joinUsage)
+	delete(n.usage, u.sg)
+	n.forgetSrcGroup(u.sg)
+	n.ins[u.sg.src].ForgetGroup(u.sg.groupId)
+}
+
+// forgetSrcGroup releases the state kept for the group of the source.
+func (n *JoinNode) forgetSrcGroup(sg srcGroup) {
+	delete(n.watermarks, sg)
+	if len(n.j.Dimensions) == 0 {
+		delete(n.asOfTables, sg)
+		return
+	}
+	// As-of tables are kept per join dimensions, remove the points of the group from them.
+	for key, table := range n.asOfTables {
+		if key.src != sg.src {
+			continue
+		}
+		kept := table[:0]
+		for _, p := range table {
+			if p.GroupID() != sg.groupId {
+				kept = append(kept, p)
+			}
+		}
+		if len(kept) == 0 {
+			delete(n.asOfTables, key)
+		} else {
+			n.asOfTables[key] = kept
+		}
+	}
+}
+
 func (n *JoinNode) Finish() error {
+	if n.j.AsOfFlag {
+		return n.emitAsOf(true)
+	}
 	// No more points are coming signal all groups to finish up.
 	for _, group := range n.groups {
 		if err := group.Finish(); err != nil {
@@ -109,11 +291,35 @@ type messageMeta interface {
 type srcPoint struct {
 	Src int
 	Msg messageMeta
+	// Whether the point was matched with a specific point.
+	Matched bool
 }
 
 func (n *JoinNode) doMessage(src int, m messageMeta) error {
 	n.timer.Start()
 	defer n.timer.Stop()
+	sg := srcGroup{src: src, groupId: m.GroupID()}
+	n.track(sg, m.Time())
+	if n.late != nil {
+		wm := n.watermarks[sg]
+		if n.late.isLate(wm, m.Time()) {
+			return n.late.late(m)
+		}
+		if m.Time().After(wm) {
+			n.watermarks[sg] = m.Time()
+		}
+	}
+	switch msg := m.(type) {
+	case edge.PointMessage:
+		n.sourceFields[src] = msg.Fields()
+	case edge.BufferedBatchMessage:
+		if points := msg.Points(); len(points) > 0 {
+			n.sourceFields[src] = points[0].Fields()
+		}
+	}
+	if n.j.AsOfFlag {
+		return n.joinAsOf(src, m)
+	}
 	if len(n.j.Dimensions) > 0 {
 		// Match points with their group based on join dimensions.
 		n.matchPoints(srcPoint{Src: src, Msg: m})
@@ -200,6 +406,7 @@ func (n *JoinNode) matchPoints(p srcPoint) {
 			if pt.Equal(t) {
 				// Option 1, send both points
 				n.sendMatchPoint(p, match)
+				matches[i].Matched = true
 				matched = true
 			}
 			if !pt.Before(lowMark) {
@@ -209,6 +416,7 @@ func (n *JoinNode) matchPoints(p srcPoint) {
 		if n.allReported {
 			// Can't trust lowMark until all parents have reported.
 			// Remove any unneeded match points.
+			n.sendUnmatchedPoints(matches[:i])
 			n.matchGroupsBuffer[groupId] = matches[i:]
 		}
 
@@ -225,9 +433,6 @@ func (n *JoinNode) matchPoints(p srcPoint) {
 			}
 		}
 	} else {
-		// Cache match point.
-		n.matchGroupsBuffer[groupId] = append(n.matchGroupsBuffer[groupId], p)
-
 		// Send all specific points that match, to the group.
 		var i int
 		buf := n.specificGroupsBuffer[groupId]
@@ -236,15 +441,183 @@ func (n *JoinNode) matchPoints(p srcPoint) {
 			st := buf[i].Msg.Time().Round(n.j.Tolerance)
 			if st.Equal(t) {
 				n.sendMatchPoint(buf[i], p)
+				p.Matched = true
 			} else {
 				break
 			}
 		}
 		// Remove all sent points
 		n.specificGroupsBuffer[groupId] = buf[i:]
+
+		// Cache match point.
+		n.matchGroupsBuffer[groupId] = append(n.matchGroupsBuffer[groupId], p)
+	}
+}
+
+// Send the match points that were never matched by themselves,
+// if the join type emits data without the specific points.
+func (n *JoinNode) sendUnmatchedPoints(points []srcPoint) {
+	if n.fillOnly {
+		return
+	}
+	for _, p := range points {
+		if p.Matched {
+			continue
+		}
+		src := p.Src
+		alone := n.emits(func(i int) bool { return i == src })
+		if alone {
+			n.sendSpecificPoint(p)
+		}
 	}
 }
 
+// emits reports whether the join emits data when only the present parents are present.
+func (n *JoinNode) emits(present func(i int) bool) bool {
+	any := false
+	count := len(n.j.Names)
+	for i := 0; i < count; i++ {
+		if present(i) {
+			any = true
+			continue
+		}
+		switch n.joinType {
+		case pipeline.InnerJoin:
+			return false
+		case pipeline.LeftJoin:
+			if i == 0 {
+				return false
+			}
+		case pipeline.RightJoin:
+			if i == count-1 {
+				return false
+			}
+		}
+	}
+	return any
+}
+
+// missingFieldNames returns the names of the fields of a missing parent.
+// The fields of the last data received from the parent are used, or else the given field names.
+func (n *JoinNode) missingFieldNames(src int, names []string) []string {
+	fields := n.sourceFields[src]
+	if n.fillOnly || len(fields) == 0 {
+		return names
+	}
+	sourceNames := make([]string, 0, len(fields))
+	for k := range fields {
+		sourceNames = append(sourceNames, k)
+	}
+	return sourceNames
+}
+
+// joinAsOf joins points of the primary parent with the latest points of the secondary parents.
+// Points of the primary parent wait until all secondary parents have reached their time,
+// or until the primary parent is ahead of them by more than the max wait.
+func (n *JoinNode) joinAsOf(src int, m messageMeta) error {
+	p, ok := m.(edge.PointMessage)
+	if !ok {
+		return fmt.Errorf("as-of join does not support batch data")
+	}
+	if p.Time().After(n.asOfHeads[src]) {
+		n.asOfHeads[src] = p.Time()
+	}
+	if src == 0 {
+		n.asOfPending = append(n.asOfPending, p)
+	} else {
+		sg := srcGroup{src: src, groupId: n.asOfGroupID(p)}
+		table := append(n.asOfTables[sg], p)
+		// Keep the table ordered by time.
+		for i := len(table) - 1; i > 0 && table[i].Time().Before(table[i-1].Time()); i-- {
+			table[i], table[i-1] = table[i-1], table[i]
+		}
+		n.asOfTables[sg] = n.pruneAsOf(table)
+	}
+	return n.emitAsOf(false)
+}
+
+// asOfGroupID returns the group on which points are matched in an as-of join.
+func (n *JoinNode) asOfGroupID(p edge.PointMessage) models.GroupID {
+	if len(n.j.Dimensions) == 0 {
+		return p.GroupID()
+	}
+	return models.ToGroupID(
+		p.Name(),
+		p.GroupInfo().Tags,
+		models.Dimensions{
+			ByName:   p.Dimensions().ByName,
+			TagNames: n.j.Dimensions,
+		},
+	)
+}
+
+// emitAsOf joins and emits the pending primary points that are ready, or all of them if all is true.
+func (n *JoinNode) emitAsOf(all bool) error {
+	// The oldest time all secondary parents have reached.
+	var low time.Time
+	for s := 1; s < len(n.ins); s++ {
+		h := n.asOfHeads[s]
+		if s == 1 || h.Before(low) {
+			low = h
+		}
+	}
+	i := 0
+	for ; i < len(n.asOfPending); i++ {
+		p := n.asOfPending[i]
+		ready := all || !p.Time().After(low)
+		if !ready && n.j.MaxWait > 0 {
+			ready = n.asOfHeads[0].Sub(p.Time()) >= n.j.MaxWait
+		}
+		if !ready {
+			break
+		}
+		if err := n.emitAsOfPoint(p); err != nil {
+			return err
+		}
+	}
+	n.asOfPending = n.asOfPending[i:]
+	return nil
+}
+
+// emitAsOfPoint joins a point of the primary parent with the latest points at or before its time.
+func (n *JoinNode) emitAsOfPoint(p edge.PointMessage) error {
+	if p.Time().After(n.asOfTime) {
+		n.asOfTime = p.Time()
+	}
+	groupId := n.asOfGroupID(p)
+	group := n.getOrCreateGroup(p.GroupID())
+	set := group.newJoinset(p.Time())
+	set.Set(0, p)
+	for s := 1; s < len(n.ins); s++ {
+		sg := srcGroup{src: s, groupId: groupId}
+		table := n.asOfTables[sg]
+		// Find the latest point at or before the primary point.
+		i := len(table) - 1
+		for i >= 0 && table[i].Time().After(p.Time()) {
+			i--
+		}
+		if i < 0 {
+			continue
+		}
+		match := table[i]
+		n.asOfTables[sg] = n.pruneAsOf(table)
+		if n.j.MaxStaleness > 0 && p.Time().Sub(match.Time()) > n.j.MaxStaleness {
+			continue
+		}
+		set.Set(s, match)
+	}
+	return group.emitJoinedSet(set)
+}
+
+// pruneAsOf removes the points of a table that can no longer be the latest point for a primary point.
+func (n *JoinNode) pruneAsOf(table []edge.PointMessage) []edge.PointMessage {
+	i := 0
+	for i+1 < len(table) && !table[i+1].Time().After(n.asOfTime) {
+		i++
+	}
+	return table[i:]
+}
+
 // Add the specific tags from the specific point to the matched point
 // and then send both on to the group.
 func (n *JoinNode) sendMatchPoint(specific, matched srcPoint) {
@@ -301,6 +674,8 @@ type joinGroup struct {
 	sets       map[time.Time][]*joinset
 	head       []time.Time
 	oldestTime time.Time
+	// Time of the last emitted set.
+	emittedTime time.Time
 }
 
 func (g *joinGroup) Finish() error {
@@ -311,6 +686,10 @@ func (g *joinGroup) Finish() error {
 // emit the oldest set if we have collected enough data.
 func (g *joinGroup) Collect(src int, p timeMessage) error {
 	t := p.Time().Round(g.n.j.Tolerance)
+	if g.n.late != nil && t.Before(g.emittedTime) {
+		// The set for this time was already emitted.
+		return g.n.late.late(p)
+	}
 	if t.Before(g.oldestTime) || g.oldestTime.IsZero() {
 		g.oldestTime = t
 	}
@@ -380,6 +759,9 @@ func (g *joinGroup) emit(onlyReadySets bool) error {
 			break
 		}
 	}
+	if i > 0 {
+		g.emittedTime = g.oldestTime
+	}
 	if i == len(sets) {
 		delete(g.sets, g.oldestTime)
 	} else {
@@ -515,29 +897,53 @@ func (js *joinset) First() edge.Message {
 	return js.values[js.first]
 }
 
+// fillMissing adds the fill values of the fields of a missing parent.
+func (js *joinset) fillMissing(fields models.Fields, i int, names []string) {
+	fill := js.j.sourceFills[i]
+	for _, k := range js.j.missingFieldNames(i, names) {
+		name := js.prefixes[i] + js.delimiter + k
+		if v, ok := js.j.j.FieldFills[name]; ok {
+			fields[name] = v
+			continue
+		}
+		switch fill.fill {
+		case influxql.NullFill:
+			fields[name] = nil
+		case influxql.NumberFill:
+			fields[name] = fill.value
+		}
+	}
+}
+
+// setPresence adds the presence field of a parent if configured.
+func (js *joinset) setPresence(fields models.Fields, i int, present bool) {
+	if js.j.j.PresenceField != "" {
+		fields[js.prefixes[i]+js.delimiter+js.j.j.PresenceField] = present
+	}
+}
+
 // join all points into a single point
 func (js *joinset) JoinIntoPoint() (edge.PointMessage, error) {
 	first, ok := js.First().(edge.PointMessage)
 	if !ok {
 		return nil, fmt.Errorf("unexpected type of first value %T", js.First())
 	}
+	if !js.j.emits(func(i int) bool { return js.values[i] != nil }) {
+		// no valid point possible for the join type
+		return nil, nil
+	}
 	firstFields := first.Fields()
+	var firstNames []string
 	fields := make(models.Fields, js.size*len(firstFields))
 	for i, v := range js.values {
 		if v == nil {
-			switch js.fill {
-			case influxql.NullFill:
-				for k := range firstFields {
-					fields[js.prefixes[i]+js.delimiter+k] = nil
-				}
-			case influxql.NumberFill:
+			if firstNames == nil {
+				firstNames = make([]string, 0, len(firstFields))
 				for k := range firstFields {
-					fields[js.prefixes[i]+js.delimiter+k] = js.fillValue
+					firstNames = append(firstNames, k)
 				}
-			default:
-				// inner join no valid point possible
-				return nil, nil
 			}
+			js.fillMissing(fields, i, firstNames)
 		} else {
 			p, ok := v.(edge.FieldGetter)
 			if !ok {
@@ -547,6 +953,7 @@ func (js *joinset) JoinIntoPoint() (edge.PointMessage, error) {
 				fields[js.prefixes[i]+js.delimiter+k] = v
 			}
 		}
+		js.setPresence(fields, i, v != nil)
 	}
 	np := edge.NewPointMessage(
 		js.name, "", "",
@@ -633,28 +1040,21 @@ BATCH_POINT:
 		if count == 0 {
 			continue
 		}
+		if !js.j.emits(func(i int) bool { return set[i] != nil }) {
+			// no valid point possible for the join type
+			continue BATCH_POINT
+		}
 		// Join all batch points in set
 		fields := make(models.Fields, js.expected*len(fieldNames))
 		for i, bp := range set {
 			if bp == nil {
-				switch js.fill {
-				case influxql.NullFill:
-					for _, k := range fieldNames {
-						fields[js.prefixes[i]+js.delimiter+k] = nil
-					}
-				case influxql.NumberFill:
-					for _, k := range fieldNames {
-						fields[js.prefixes[i]+js.delimiter+k] = js.fillValue
-					}
-				default:
-					// inner join no valid point possible
-					continue BATCH_POINT
-				}
+				js.fillMissing(fields, i, fieldNames)
 			} else {
 				for k, v := range bp.Fields() {
 					fields[js.prefixes[i]+js.delimiter+k] = v
 				}
 			}
+			js.setPresence(fields, i, bp != nil)
 		}
 		bp := edge.NewBatchPointMessage(
 			fields,
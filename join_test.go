@@ -0,0 +1,53 @@
+package kapacitor
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdata/kapacitor/edge"
+	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/pipeline"
+)
+
+func TestJoinNode_ForgetSrcGroup(t *testing.T) {
+	point := func(host string, sec int64) edge.PointMessage {
+		return edge.NewPointMessage(
+			"deploys", "db", "rp",
+			models.Dimensions{TagNames: []string{"host", "service"}},
+			models.Fields{"value": float64(sec)},
+			models.Tags{"host": host, "service": "api"},
+			time.Unix(sec, 0),
+		)
+	}
+	n := &JoinNode{
+		j:          &pipeline.JoinNode{Dimensions: []string{"service"}},
+		watermarks: make(map[srcGroup]time.Time),
+		asOfTables: make(map[srcGroup][]edge.PointMessage),
+	}
+	a, b := point("a", 1), point("b", 2)
+	table := srcGroup{src: 1, groupId: n.asOfGroupID(a)}
+	n.asOfTables[table] = []edge.PointMessage{a, b}
+	sa := srcGroup{src: 1, groupId: a.GroupID()}
+	sb := srcGroup{src: 1, groupId: b.GroupID()}
+	n.watermarks[sa] = a.Time()
+	n.watermarks[sb] = b.Time()
+
+	// The points of the forgotten group are removed from the table of the join dimensions.
+	n.forgetSrcGroup(sa)
+	if _, ok := n.watermarks[sa]; ok {
+		t.Error("expected watermark of forgotten group to be removed")
+	}
+	if _, ok := n.watermarks[sb]; !ok {
+		t.Error("expected watermark of other group to be kept")
+	}
+	if got, exp := n.asOfTables[table], []edge.PointMessage{b}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("unexpected as-of table got %v exp %v", got, exp)
+	}
+
+	// Empty tables are removed.
+	n.forgetSrcGroup(sb)
+	if got, exp := len(n.asOfTables), 0; got != exp {
+		t.Errorf("unexpected number of as-of tables got %d exp %d", got, exp)
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/influxdata/kapacitor/edge"
 	"github.com/influxdata/kapacitor/expvar"
+	"github.com/influxdata/kapacitor/influxdb"
 	"github.com/influxdata/kapacitor/models"
 	"github.com/influxdata/kapacitor/pipeline"
 )
@@ -73,7 +74,7 @@ func (n *KapacitorLoopbackNode) Point(p edge.PointMessage) error {
 	}
 
 	n.timer.Pause()
-	err := n.et.tm.WriteKapacitorPoint(p)
+	err := n.writePoint(p)
 	n.timer.Resume()
 
 	if err != nil {
@@ -109,7 +110,7 @@ func (n *KapacitorLoopbackNode) BatchPoint(bp edge.BatchPointMessage) error {
 	)
 
 	n.timer.Pause()
-	err := n.et.tm.WriteKapacitorPoint(p)
+	err := n.writePoint(p)
 	n.timer.Resume()
 
 	if err != nil {
@@ -129,3 +130,29 @@ func (n *KapacitorLoopbackNode) Barrier(edge.BarrierMessage) error {
 func (n *KapacitorLoopbackNode) DeleteGroup(edge.DeleteGroupMessage) error {
 	return nil
 }
+
+// writePoint writes the point back into Kapacitor,
+// shadow tasks capture the point instead.
+func (n *KapacitorLoopbackNode) writePoint(p edge.PointMessage) error {
+	if n.et.shadow != nil {
+		n.et.shadow.record(n.Name(), ShadowLoopback, p.Time(), shadowLoopbackData{
+			Database:        p.Database(),
+			RetentionPolicy: p.RetentionPolicy(),
+			Point: string(influxdb.Point{
+				Name:   p.Name(),
+				Tags:   p.Tags(),
+				Fields: p.Fields(),
+				Time:   p.Time(),
+			}.Bytes("ns")),
+		})
+		return nil
+	}
+	return n.et.tm.WriteKapacitorPoint(p)
+}
+
+// A point written over the loopback captured by a shadow task.
+type shadowLoopbackData struct {
+	Database        string `json:"database"`
+	RetentionPolicy string `json:"retentionPolicy"`
+	Point           string `json:"point"`
+}
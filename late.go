@@ -0,0 +1,113 @@
+package kapacitor
+
+import (
+	"log"
+	"time"
+
+	"github.com/influxdata/kapacitor/edge"
+	"github.com/influxdata/kapacitor/expvar"
+	"github.com/influxdata/kapacitor/pipeline"
+)
+
+type LateNode struct {
+	node
+}
+
+// Create a new LateNode which passes through the late data of its parent.
+func newLateNode(et *ExecutingTask, n *pipeline.LateNode, l *log.Logger) (*LateNode, error) {
+	ln := &LateNode{
+		node: node{Node: n, et: et, logger: l},
+	}
+	ln.node.runF = ln.runLate
+	return ln, nil
+}
+
+func (n *LateNode) runLate([]byte) error {
+	for m, ok := n.ins[0].Emit(); ok; m, ok = n.ins[0].Emit() {
+		if err := edge.Forward(n.outs, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lateHandler decides whether data is late and sends late data to the late children of a node.
+type lateHandler struct {
+	allowed time.Duration
+	// Whether the wall clock is the watermark, never set for replays.
+	clock bool
+	outs  []edge.StatsEdge
+
+	latePoints *expvar.Int
+}
+
+// newLateHandler returns the late handler of the node, or nil if the node does not detect late data.
+func (n *node) newLateHandler() *lateHandler {
+	ln, ok := n.Node.(pipeline.LatenessNode)
+	if !ok || !pipeline.LateEnabled(ln) {
+		return nil
+	}
+	l := ln.GetLateness()
+	h := &lateHandler{
+		allowed:    l.AllowedLateness,
+		clock:      l.Watermark == pipeline.WatermarkClock && !n.et.tm.Replay,
+		outs:       n.lateOuts,
+		latePoints: new(expvar.Int),
+	}
+	n.statMap.Set(statLatePoints, h.latePoints)
+	return h
+}
+
+// isLate reports whether data with time t is late given the watermark.
+// With a clock watermark the given watermark is ignored.
+func (h *lateHandler) isLate(watermark, t time.Time) bool {
+	if h == nil {
+		return false
+	}
+	if h.clock {
+		watermark = time.Now()
+	} else if watermark.IsZero() {
+		return false
+	}
+	return t.Before(watermark.Add(-h.allowed))
+}
+
+// late counts the late data and forwards it to the late children.
+func (h *lateHandler) late(m edge.Message) error {
+	if b, ok := m.(edge.BufferedBatchMessage); ok {
+		h.latePoints.Add(int64(len(b.Points())))
+	} else {
+		h.latePoints.Add(1)
+	}
+	if len(h.outs) == 0 {
+		return nil
+	}
+	return edge.Forward(h.outs, m)
+}
+
+// lateFilter tracks the event watermark of a group and diverts its late points.
+type lateFilter struct {
+	edge.ForwardReceiver
+	h         *lateHandler
+	watermark time.Time
+}
+
+func newLateFilter(h *lateHandler, r edge.ForwardReceiver) edge.ForwardReceiver {
+	if h == nil {
+		return r
+	}
+	return &lateFilter{
+		ForwardReceiver: r,
+		h:               h,
+	}
+}
+
+func (f *lateFilter) Point(p edge.PointMessage) (edge.Message, error) {
+	if f.h.isLate(f.watermark, p.Time()) {
+		return nil, f.h.late(p)
+	}
+	if p.Time().After(f.watermark) {
+		f.watermark = p.Time()
+	}
+	return f.ForwardReceiver.Point(p)
+}
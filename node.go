@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"runtime"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -23,6 +24,11 @@ const (
 	statErrorCount       = "errors"
 	statCardinalityGauge = "working_cardinality"
 	statAverageExecTime  = "avg_exec_time_ns"
+	statGroupLimitDrops  = "group_limit_drops"
+	statQueueDropped     = "queue_dropped"
+	statGroupsEvicted    = "groups_evicted"
+	statGroupsExpired    = "groups_expired"
+	statLatePoints       = "late_points"
 )
 
 // A node that can be  in an executor.
@@ -65,6 +71,8 @@ type Node interface {
 	incrementErrorCount()
 
 	stats() map[string]interface{}
+
+	topGroups(count int) []GroupVolume
 }
 
 //implementation of Node
@@ -81,6 +89,10 @@ type node struct {
 	finished   bool
 	ins        []edge.StatsEdge
 	outs       []edge.StatsEdge
+	// Edges to the children that receive late data, these are not part of outs.
+	lateOuts []edge.StatsEdge
+	// Edges to all children in the same order as the children.
+	childEdges []edge.StatsEdge
 	logger     *log.Logger
 	timer      timer.Timer
 	statsKey   string
@@ -116,6 +128,60 @@ func (n *node) init() {
 	n.errCh = make(chan error, 1)
 }
 
+// newGroupedConsumer creates a grouped consumer reading from the first parent edge of the node.
+// The consumer enforces the group limits of the node, or else the group limit of the task,
+// and reports its cardinality in the node stats.
+func (n *node) newGroupedConsumer(r edge.GroupedReceiver) edge.GroupedConsumer {
+	limits := edge.GroupLimits{
+		MaxGroups: n.et.Task.Limits.MaxGroups,
+	}
+	if gl, ok := n.Node.(pipeline.GroupLimitedNode); ok {
+		l := gl.GetGroupLimits()
+		if l.MaxGroups > 0 {
+			limits.MaxGroups = int(l.MaxGroups)
+			limits.Evict = true
+		}
+		limits.IdleTimeout = l.GroupIdleTimeout
+	}
+	consumer := edge.NewGroupedConsumerWithLimits(n.ins[0], groupStatsEvicter{GroupedReceiver: r, n: n}, limits)
+	n.statMap.Set(statCardinalityGauge, consumer.CardinalityVar())
+	if limits.MaxGroups > 0 {
+		if limits.Evict {
+			n.statMap.Set(statGroupsEvicted, consumer.EvictedVar())
+		} else {
+			n.statMap.Set(statGroupLimitDrops, consumer.DroppedVar())
+		}
+	}
+	if limits.IdleTimeout > 0 {
+		n.statMap.Set(statGroupsExpired, consumer.ExpiredVar())
+	}
+	return consumer
+}
+
+// groupStatsEvicter removes the statistics of evicted groups from the edges of the node,
+// so that the statistics do not grow with the number of groups ever seen.
+type groupStatsEvicter struct {
+	edge.GroupedReceiver
+	n *node
+}
+
+func (r groupStatsEvicter) EvictGroup(id models.GroupID) {
+	if ge, ok := r.GroupedReceiver.(edge.GroupEvicter); ok {
+		ge.EvictGroup(id)
+	}
+	r.n.forgetGroup(id)
+}
+
+// forgetGroup removes the statistics of the group from the edges of the node.
+func (n *node) forgetGroup(id models.GroupID) {
+	for _, in := range n.ins {
+		in.ForgetGroup(id)
+	}
+	for _, out := range n.outs {
+		out.ForgetGroup(id)
+	}
+}
+
 func (n *node) start(snapshot []byte) {
 	go func() {
 		var err error
@@ -166,17 +232,22 @@ func (n *node) Wait() error {
 }
 
 func (n *node) addChild(c Node) (edge.StatsEdge, error) {
-	if n.Provides() != c.Wants() {
-		return nil, fmt.Errorf("cannot add child mismatched edges: %s:%s -> %s:%s", n.Name(), n.Provides(), c.Name(), c.Wants())
+	provides := n.Provides()
+	if _, ok := c.(*LateNode); ok {
+		// Late data is the data the node wants.
+		provides = n.Wants()
+	}
+	if provides != c.Wants() {
+		return nil, fmt.Errorf("cannot add child mismatched edges: %s:%s -> %s:%s", n.Name(), provides, c.Name(), c.Wants())
 	}
-	if n.Provides() == pipeline.NoEdge {
-		return nil, fmt.Errorf("cannot add child no edge expected: %s:%s -> %s:%s", n.Name(), n.Provides(), c.Name(), c.Wants())
+	if provides == pipeline.NoEdge {
+		return nil, fmt.Errorf("cannot add child no edge expected: %s:%s -> %s:%s", n.Name(), provides, c.Name(), c.Wants())
 	}
 	n.children = append(n.children, c)
 
-	edge := newEdge(n.et.Task.ID, n.Name(), c.Name(), n.Provides(), defaultEdgeBufferSize, n.et.tm.LogService)
+	edge := newEdge(n.et.Task.ID, n.Name(), c.Name(), provides, defaultEdgeBufferSize, n.et.tm.LogService)
 	if edge == nil {
-		return nil, fmt.Errorf("unknown edge type %s", n.Provides())
+		return nil, fmt.Errorf("unknown edge type %s", provides)
 	}
 	c.addParentEdge(edge)
 	return edge, nil
@@ -197,12 +268,17 @@ func (n *node) linkChild(c Node) error {
 	c.addParent(n)
 
 	// store edge to child
-	n.outs = append(n.outs, edge)
+	if _, ok := c.(*LateNode); ok {
+		n.lateOuts = append(n.lateOuts, edge)
+	} else {
+		n.outs = append(n.outs, edge)
+	}
+	n.childEdges = append(n.childEdges, edge)
 	return nil
 }
 
 func (n *node) closeChildEdges() {
-	for _, child := range n.outs {
+	for _, child := range n.childEdges {
 		child.Close()
 	}
 }
@@ -242,7 +318,7 @@ func (n *node) edot(buf *bytes.Buffer, labels bool) {
 				fmt.Sprintf("%s -> %s [label=\"processed=%d\"];\n",
 					n.Name(),
 					c.Name(),
-					n.outs[i].Collected(),
+					n.childEdges[i].Collected(),
 				),
 			))
 		}
@@ -274,7 +350,7 @@ func (n *node) edot(buf *bytes.Buffer, labels bool) {
 				fmt.Sprintf("%s -> %s [processed=\"%d\"];\n",
 					n.Name(),
 					c.Name(),
-					n.outs[i].Collected(),
+					n.childEdges[i].Collected(),
 				),
 			))
 		}
@@ -291,7 +367,7 @@ func (n *node) collectedCount() (count int64) {
 
 // node emitted count is the sum of collected counts of children edges
 func (n *node) emittedCount() (count int64) {
-	for _, out := range n.outs {
+	for _, out := range n.childEdges {
 		count += out.Collected()
 	}
 	return
@@ -354,6 +430,52 @@ func (n *node) nodeStatsByGroup() (stats map[models.GroupID]nodeStats) {
 	return
 }
 
+// GroupVolume is the number of points a node has received for a single group.
+type GroupVolume struct {
+	Group  models.GroupID
+	Tags   models.Tags
+	Points int64
+}
+
+// topGroups returns the count groups with the most points received by the node, most points first.
+func (n *node) topGroups(count int) []GroupVolume {
+	volumes := make(map[models.GroupID]*GroupVolume)
+	for _, in := range n.ins {
+		in.ReadGroupStats(func(g *edge.GroupStats) {
+			v, ok := volumes[g.GroupInfo.ID]
+			if !ok {
+				v = &GroupVolume{
+					Group: g.GroupInfo.ID,
+					Tags:  g.GroupInfo.Tags,
+				}
+				volumes[g.GroupInfo.ID] = v
+			}
+			v.Points += g.Collected
+		})
+	}
+	groups := make(groupVolumes, 0, len(volumes))
+	for _, v := range volumes {
+		groups = append(groups, *v)
+	}
+	sort.Sort(groups)
+	if len(groups) > count {
+		groups = groups[:count]
+	}
+	return groups
+}
+
+// groupVolumes sorts by points descending and then by group.
+type groupVolumes []GroupVolume
+
+func (g groupVolumes) Len() int      { return len(g) }
+func (g groupVolumes) Swap(i, j int) { g[i], g[j] = g[j], g[i] }
+func (g groupVolumes) Less(i, j int) bool {
+	if g[i].Points != g[j].Points {
+		return g[i].Points > g[j].Points
+	}
+	return g[i].Group < g[j].Group
+}
+
 // MaxDuration is a 64-bit int variable representing a duration in nanoseconds,that satisfies the expvar.Var interface.
 // When setting a value it will only be set if it is greater than the current value.
 type MaxDuration struct {
@@ -0,0 +1,130 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Functions supported by the AggregateNode.
+const (
+	AggregateCount  = "count"
+	AggregateSum    = "sum"
+	AggregateMean   = "mean"
+	AggregateMin    = "min"
+	AggregateMax    = "max"
+	AggregateStddev = "stddev"
+)
+
+// Compute running aggregates of a field without buffering the data.
+// The aggregates are updated incrementally with each point, so no window is needed.
+// Quantiles are estimated using a sketch with a bounded relative error.
+//
+// Example:
+//    stream
+//        |from()
+//            .measurement('requests')
+//            .groupBy('service')
+//        |aggregate('duration')
+//            .functions('count', 'mean', 'max')
+//            .quantiles(0.5, 0.99)
+//            .every(10s)
+//            .reset()
+//        |influxDBOut()
+//            .database('stats')
+//            .measurement('request_durations')
+//
+// Every 10 seconds a point per service is emitted with the fields
+// `count`, `mean`, `max`, `p50` and `p99` computed over the last 10 seconds.
+// Without the `reset` property the aggregates cover all the data since the task started.
+//
+// The quantile fields are named by the percentile they represent, for example `p99` or `p99.9`.
+// Points whose field is missing or not a number are ignored.
+type AggregateNode struct {
+	chainnode
+	GroupLimits
+
+	// The field to aggregate.
+	// tick:ignore
+	Field string
+
+	// The aggregate functions to compute, see the Functions property method.
+	// tick:ignore
+	Aggregates []string `tick:"Functions"`
+
+	// The quantiles to estimate, see the Quantiles property method.
+	// tick:ignore
+	QuantileList []float64 `tick:"Quantiles"`
+
+	// The relative accuracy of the quantile estimates.
+	// A quantile estimate of v is within v*(1 +/- accuracy).
+	// Default: 0.01
+	Accuracy float64
+
+	// How often the aggregates are emitted, measured using the time of the data.
+	// If zero, the aggregates are emitted for every point, or for every batch if the node receives batches.
+	Every time.Duration
+
+	// Whether to reset the aggregates after they are emitted.
+	// tick:ignore
+	ResetFlag bool `tick:"Reset"`
+}
+
+func newAggregateNode(wants EdgeType, field string) *AggregateNode {
+	return &AggregateNode{
+		chainnode: newBasicChainNode("aggregate", wants, StreamEdge),
+		Field:     field,
+		Accuracy:  0.01,
+	}
+}
+
+// The aggregate functions to compute.
+// Options are count, sum, mean, min, max and stddev.
+// If neither functions nor quantiles are specified, count, sum, mean, min and max are computed.
+// tick:property
+func (n *AggregateNode) Functions(functions ...string) *AggregateNode {
+	n.Aggregates = functions
+	return n
+}
+
+// The quantiles to estimate, as floats between 0 and 1.
+// tick:property
+func (n *AggregateNode) Quantiles(quantiles ...float64) *AggregateNode {
+	n.QuantileList = quantiles
+	return n
+}
+
+// Reset the aggregates after they are emitted.
+// tick:property
+func (n *AggregateNode) Reset() *AggregateNode {
+	n.ResetFlag = true
+	return n
+}
+
+func (n *AggregateNode) validate() error {
+	if n.Field == "" {
+		return errors.New("must provide a field to aggregate")
+	}
+	for _, f := range n.Aggregates {
+		switch f {
+		case AggregateCount, AggregateSum, AggregateMean, AggregateMin, AggregateMax, AggregateStddev:
+		default:
+			return fmt.Errorf("unknown aggregate function %q", f)
+		}
+	}
+	for _, q := range n.QuantileList {
+		if q < 0 || q > 1 {
+			return fmt.Errorf("quantile %v must be between 0 and 1", q)
+		}
+	}
+	if n.Accuracy <= 0 || n.Accuracy >= 1 {
+		return fmt.Errorf("accuracy must be between 0 and 1, got %v", n.Accuracy)
+	}
+	if n.Every < 0 {
+		return errors.New("every must not be negative")
+	}
+	if n.ResetFlag && n.Every == 0 && n.Wants() == StreamEdge {
+		return errors.New("reset requires every when aggregating a stream")
+	}
+	return nil
+}
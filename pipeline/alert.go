@@ -122,6 +122,7 @@ const defaultDetailsTmpl = "{{ json . }}"
 //
 type AlertNode struct {
 	chainnode
+	GroupLimits
 
 	// Topic specifies the name of an alert topic to which,
 	// alerts will be published.
@@ -51,6 +51,7 @@ const (
 // In the above example all combinations triples are created.
 type CombineNode struct {
 	chainnode
+	GroupLimits
 
 	// The list of expressions for matching pairs
 	// tick:ignore
@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"errors"
+	"time"
+
+	"github.com/influxdata/kapacitor/tick/ast"
+)
+
+// Drop duplicate points.
+// A point is a duplicate if a point with the same key was seen within the TTL.
+// By default the key of a point is its time, its tags and all of its fields.
+//
+// Example:
+//    stream
+//        |from()
+//            .measurement('requests')
+//            .groupBy('service')
+//        |dedup()
+//            .fields('request_id')
+//            .ttl(5m)
+//        |count('request_id')
+//
+// Points with the same time, tags and request_id as a point seen in the last 5 minutes are dropped.
+//
+// The key can instead be computed using a lambda expression,
+// in which case the time, tags and fields are not part of the key unless referenced by the expression.
+//
+// Example:
+//    stream
+//        |from()
+//            .measurement('orders')
+//        |dedup()
+//            .key(lambda: "order_id")
+//            .ttl(1h)
+//
+// The TTL is measured using the time of the data, keys of points arriving out of order expire by their time as well.
+// The number of keys remembered per group is bounded, see the MaxKeys property.
+// When the bound is reached the keys with the oldest time are forgotten.
+// Batches are deduplicated point by point.
+//
+// Available Statistics:
+//
+//    * duplicates_dropped -- number of duplicate points that were dropped
+//
+type DedupNode struct {
+	chainnode
+	GroupLimits
+
+	// The fields that are part of the key, see the Fields property method.
+	// tick:ignore
+	FieldList []string `tick:"Fields"`
+
+	// An expression computing the key of a point.
+	// If set, the key is the result of the expression instead of the time, tags and fields of the point.
+	Key *ast.LambdaNode
+
+	// How long a key is remembered.
+	// Default: 1m
+	Ttl time.Duration
+
+	// The maximum number of keys remembered per group.
+	// Default: 10000
+	MaxKeys int64
+}
+
+func newDedupNode(e EdgeType) *DedupNode {
+	return &DedupNode{
+		chainnode: newBasicChainNode("dedup", e, e),
+		Ttl:       time.Minute,
+		MaxKeys:   10000,
+	}
+}
+
+// The fields that are part of the key.
+// If no fields are specified all fields of the point are part of the key.
+// tick:property
+func (n *DedupNode) Fields(fields ...string) *DedupNode {
+	n.FieldList = fields
+	return n
+}
+
+func (n *DedupNode) validate() error {
+	if n.Ttl <= 0 {
+		return errors.New("ttl must be positive")
+	}
+	if n.MaxKeys <= 0 {
+		return errors.New("maxKeys must be positive")
+	}
+	if n.Key != nil && len(n.FieldList) > 0 {
+		return errors.New("cannot use both key and fields")
+	}
+	return nil
+}
@@ -28,6 +28,7 @@ import (
 // dropped.
 type DerivativeNode struct {
 	chainnode
+	GroupLimits
 
 	// The field to use when calculating the derivative
 	// tick:ignore
@@ -39,6 +39,7 @@ const (
 // that the resultant data is passed to a UDF or similar for custom processing.
 type FlattenNode struct {
 	chainnode
+	GroupLimits
 
 	// The dimensions on which to join
 	// tick:ignore
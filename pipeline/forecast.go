@@ -0,0 +1,145 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Forecast a field using an online Holt-Winters model per group.
+// Each point is passed on with two additional fields:
+// the forecast of the field at the horizon,
+// and, if a threshold is set, the time until the forecast crosses the threshold.
+//
+// Without a season the model is Holt's linear trend model, which follows the level and the trend of the field.
+// With a season the model also follows a repeating pattern of the given number of points.
+//
+// Example:
+//     stream
+//         |from()
+//             .measurement('disk')
+//             .groupBy('host', 'path')
+//         |forecast('used_percent')
+//             .horizon(12h)
+//             .threshold(100.0)
+//             .unit(1h)
+//         |alert()
+//             // Warn if the disk is predicted to be full within 12 hours.
+//             .warn(lambda: "time_to_threshold" >= 0)
+//             // Critical if the disk is predicted to be full within 4 hours.
+//             .crit(lambda: "time_to_threshold" >= 0 AND "time_to_threshold" < 4)
+//             .message('{{ index .Tags "host" }} disk {{ index .Tags "path" }} full in {{ index .Fields "time_to_threshold" | printf "%0.1f" }}h')
+//
+// The model is updated with each point, the points are assumed to arrive at a regular interval.
+// Unless set using the Interval property, the interval is estimated from the times of the points.
+// The time to threshold is only computed up to the horizon, it is -1 if the forecast does not cross the threshold within the horizon.
+//
+// Points are dropped until the model is initialized, which takes two points,
+// or one season of points if a season is set.
+// Points whose field is missing or not a number are dropped.
+// The model is reset for each batch, so each batch is forecast independently.
+type ForecastNode struct {
+	chainnode
+	GroupLimits
+
+	// The field to forecast.
+	// tick:ignore
+	Field string
+
+	// The name of the forecast field.
+	// Default: forecast
+	As string
+
+	// The name of the time to threshold field.
+	// Default: time_to_threshold
+	TimeToThresholdAs string
+
+	// How far ahead to forecast.
+	// Default: 1h
+	Horizon time.Duration
+
+	// The threshold, see the Threshold property method.
+	// tick:ignore
+	ThresholdValue float64 `tick:"Threshold"`
+
+	// Whether a threshold is set.
+	// tick:ignore
+	UseThreshold bool
+
+	// The time unit of the time to threshold field.
+	// Default: 1s
+	Unit time.Duration
+
+	// The interval between points.
+	// If zero, the interval is estimated from the times of the points.
+	Interval time.Duration
+
+	// The number of points in a season.
+	// If zero, the model has no season.
+	Season int64
+
+	// The smoothing factor of the level, between 0 and 1.
+	// Higher values follow recent points more closely.
+	// Default: 0.5
+	Alpha float64
+
+	// The smoothing factor of the trend, between 0 and 1.
+	// Default: 0.1
+	Beta float64
+
+	// The smoothing factor of the season, between 0 and 1.
+	// Default: 0.1
+	Gamma float64
+}
+
+func newForecastNode(wants EdgeType, field string) *ForecastNode {
+	return &ForecastNode{
+		chainnode:         newBasicChainNode("forecast", wants, wants),
+		Field:             field,
+		As:                "forecast",
+		TimeToThresholdAs: "time_to_threshold",
+		Horizon:           time.Hour,
+		Unit:              time.Second,
+		Alpha:             0.5,
+		Beta:              0.1,
+		Gamma:             0.1,
+	}
+}
+
+// Compute the time until the forecast crosses the threshold.
+// tick:property
+func (n *ForecastNode) Threshold(value float64) *ForecastNode {
+	n.ThresholdValue = value
+	n.UseThreshold = true
+	return n
+}
+
+func (n *ForecastNode) validate() error {
+	if n.Field == "" {
+		return errors.New("must provide a field to forecast")
+	}
+	if n.As == "" || n.TimeToThresholdAs == "" {
+		return errors.New("field names must not be empty")
+	}
+	if n.As == n.TimeToThresholdAs {
+		return fmt.Errorf("as and timeToThresholdAs must differ, both are %q", n.As)
+	}
+	if n.Horizon <= 0 {
+		return errors.New("horizon must be positive")
+	}
+	if n.Unit <= 0 {
+		return errors.New("unit must be positive")
+	}
+	if n.Interval < 0 {
+		return errors.New("interval must not be negative")
+	}
+	if n.Season < 0 || n.Season == 1 {
+		return fmt.Errorf("season must be zero or at least 2, got %d", n.Season)
+	}
+	for name, v := range map[string]float64{"alpha": n.Alpha, "beta": n.Beta, "gamma": n.Gamma} {
+		if v < 0 || v > 1 {
+			return fmt.Errorf("%s must be between 0 and 1, got %v", name, v)
+		}
+	}
+	return nil
+}
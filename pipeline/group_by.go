@@ -20,8 +20,12 @@ import (
 // The above example groups the data along two dimensions `service` and `datacenter`.
 // Groups are dynamically created as new data arrives and each group is processed
 // independently.
+//
+// The `maxGroups` and `groupIdleTimeout` properties limit the groups the node keeps statistics for,
+// evicted groups are still forwarded but start new statistics.
 type GroupByNode struct {
 	chainnode
+	GroupLimits
 	//The dimensions by which to group to the data.
 	// tick:ignore
 	Dimensions []interface{}
@@ -0,0 +1,62 @@
+package pipeline
+
+import (
+	"errors"
+	"time"
+)
+
+// GroupLimits protects a node against unbounded group cardinality.
+// Nodes that keep state per group accept the `maxGroups` and `groupIdleTimeout` properties.
+//
+// Example:
+//    stream
+//        |from()
+//            .measurement('requests')
+//            .groupBy('path')
+//        |window()
+//            .period(1m)
+//            .every(1m)
+//            .maxGroups(1000)
+//            .groupIdleTimeout(10m)
+//        |count('value')
+//
+// The window node keeps state for at most 1000 paths.
+// When a new path arrives and the limit is reached the least recently used path is evicted.
+// Paths that have not received any data for 10 minutes are evicted as well.
+//
+// Evicted groups lose their state, if data arrives for the group again it is treated as a new group.
+// The number of evicted groups is reported in the `groups_evicted` and `groups_expired` stats of the node.
+type GroupLimits struct {
+	// Maximum number of groups the node keeps state for.
+	// When a new group arrives and the limit is reached the least recently used group is evicted.
+	// If zero, the max-groups limit of the task applies, which discards data of new groups instead.
+	MaxGroups int64
+
+	// Duration after which a group that has not received any data is evicted.
+	// The time is measured using the time of the data, not the wall clock.
+	// If zero, groups never expire.
+	GroupIdleTimeout time.Duration
+}
+
+// GetGroupLimits returns the group limits of the node.
+// tick:ignore
+func (l *GroupLimits) GetGroupLimits() GroupLimits {
+	return *l
+}
+
+func (l *GroupLimits) validateGroupLimits() error {
+	if l.MaxGroups < 0 {
+		return errors.New("maxGroups must not be negative")
+	}
+	if l.GroupIdleTimeout < 0 {
+		return errors.New("groupIdleTimeout must not be negative")
+	}
+	return nil
+}
+
+// GroupLimitedNode is a node that supports limits on the number of groups it keeps state for.
+type GroupLimitedNode interface {
+	Node
+	GetGroupLimits() GroupLimits
+	validateGroupLimits() error
+}
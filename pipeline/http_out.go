@@ -18,6 +18,7 @@ package pipeline
 //
 type HTTPOutNode struct {
 	chainnode
+	GroupLimits
 
 	// The relative path where the cached data is exposed
 	// tick:ignore
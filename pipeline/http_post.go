@@ -31,6 +31,7 @@ import (
 //
 type HTTPPostNode struct {
 	chainnode
+	GroupLimits
 
 	// tick:ignore
 	Endpoints []string `tick:"Endpoint"`
@@ -29,6 +29,7 @@ import (
 // InfluxQL functions.
 type InfluxQLNode struct {
 	chainnode
+	GroupLimits
 
 	// tick:ignore
 	Method string
@@ -373,6 +374,131 @@ func (n *chainnode) Bottom(num int64, field string, fieldsAndTags ...string) *In
 	return i
 }
 
+//------------------------------------
+// Sketch Functions
+//
+// The sketch functions estimate their result using a fixed amount of memory,
+// instead of keeping every value like the percentile and distinct functions.
+// The state of a sketch can be emitted as an encoded string field using the State variant of the function.
+// Sketch functions applied to such a field merge the encoded sketches,
+// so sketches computed over small windows or by other tasks can be combined later.
+//
+// Example:
+//    stream
+//        |from()
+//            .measurement('requests')
+//        |window()
+//            .period(1m)
+//            .every(1m)
+//        |quantileSketchState('duration')
+//        |window()
+//            .period(1h)
+//            .every(1m)
+//        |quantileSketch('quantileSketchState', 99.0)
+//
+// The 99th percentile of the last hour is computed from the sketches of each minute,
+// without keeping the durations of the last hour in memory.
+
+// Estimate the value at the given percentile using a quantile sketch.
+// The estimate of a value v is within 1% of v.
+//
+// The field may also hold sketches encoded by quantileSketchState, which are merged.
+func (n *chainnode) QuantileSketch(field string, percentile float64) *InfluxQLNode {
+	createReducer := func() (*quantileSketchReducer, quantileEmitter) {
+		r := newQuantileSketchReducer()
+		return r, quantileEmitter{quantileSketchReducer: r, quantile: percentile / 100}
+	}
+	i := newInfluxQLNode("quantileSketch", field, n.Provides(), StreamEdge, ReduceCreater{
+		CreateFloatReducer: func() (influxql.FloatPointAggregator, influxql.FloatPointEmitter) {
+			return createReducer()
+		},
+		CreateIntegerFloatReducer: func() (influxql.IntegerPointAggregator, influxql.FloatPointEmitter) {
+			return createReducer()
+		},
+		CreateStringFloatReducer: func() (influxql.StringPointAggregator, influxql.FloatPointEmitter) {
+			return createReducer()
+		},
+	})
+	n.linkChild(i)
+	return i
+}
+
+// Encode the quantile sketch of the data as a string field.
+// The encoded sketch can be merged by the quantileSketch and quantileSketchState functions.
+func (n *chainnode) QuantileSketchState(field string) *InfluxQLNode {
+	createReducer := func() (*quantileSketchReducer, quantileSketchStateEmitter) {
+		r := newQuantileSketchReducer()
+		return r, quantileSketchStateEmitter{quantileSketchReducer: r}
+	}
+	i := newInfluxQLNode("quantileSketchState", field, n.Provides(), StreamEdge, ReduceCreater{
+		CreateFloatStringReducer: func() (influxql.FloatPointAggregator, influxql.StringPointEmitter) {
+			return createReducer()
+		},
+		CreateIntegerStringReducer: func() (influxql.IntegerPointAggregator, influxql.StringPointEmitter) {
+			return createReducer()
+		},
+		CreateStringReducer: func() (influxql.StringPointAggregator, influxql.StringPointEmitter) {
+			return createReducer()
+		},
+	})
+	n.linkChild(i)
+	return i
+}
+
+// Estimate the number of distinct values using a HyperLogLog.
+// The estimate has a standard error of about 0.8%, small counts are nearly exact.
+//
+// String values holding a HyperLogLog encoded by approxDistinctState are merged instead of counted.
+func (n *chainnode) ApproxDistinct(field string) *InfluxQLNode {
+	createReducer := func() (*approxDistinctReducer, approxDistinctEmitter) {
+		r := newApproxDistinctReducer()
+		return r, approxDistinctEmitter{approxDistinctReducer: r}
+	}
+	i := newInfluxQLNode("approxDistinct", field, n.Provides(), StreamEdge, ReduceCreater{
+		CreateFloatIntegerReducer: func() (influxql.FloatPointAggregator, influxql.IntegerPointEmitter) {
+			return createReducer()
+		},
+		CreateIntegerReducer: func() (influxql.IntegerPointAggregator, influxql.IntegerPointEmitter) {
+			return createReducer()
+		},
+		CreateStringIntegerReducer: func() (influxql.StringPointAggregator, influxql.IntegerPointEmitter) {
+			return createReducer()
+		},
+		CreateBooleanIntegerReducer: func() (influxql.BooleanPointAggregator, influxql.IntegerPointEmitter) {
+			return createReducer()
+		},
+		IsEmptyOK: true,
+	})
+	n.linkChild(i)
+	return i
+}
+
+// Encode the HyperLogLog of the distinct values of the data as a string field.
+// The encoded HyperLogLog can be merged by the approxDistinct and approxDistinctState functions.
+func (n *chainnode) ApproxDistinctState(field string) *InfluxQLNode {
+	createReducer := func() (*approxDistinctReducer, approxDistinctStateEmitter) {
+		r := newApproxDistinctReducer()
+		return r, approxDistinctStateEmitter{approxDistinctReducer: r}
+	}
+	i := newInfluxQLNode("approxDistinctState", field, n.Provides(), StreamEdge, ReduceCreater{
+		CreateFloatStringReducer: func() (influxql.FloatPointAggregator, influxql.StringPointEmitter) {
+			return createReducer()
+		},
+		CreateIntegerStringReducer: func() (influxql.IntegerPointAggregator, influxql.StringPointEmitter) {
+			return createReducer()
+		},
+		CreateStringReducer: func() (influxql.StringPointAggregator, influxql.StringPointEmitter) {
+			return createReducer()
+		},
+		CreateBooleanStringReducer: func() (influxql.BooleanPointAggregator, influxql.StringPointEmitter) {
+			return createReducer()
+		},
+		IsEmptyOK: true,
+	})
+	n.linkChild(i)
+	return i
+}
+
 //------------------------------------
 // Transformation Functions
 //
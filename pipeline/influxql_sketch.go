@@ -0,0 +1,134 @@
+package pipeline
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+
+	"github.com/influxdata/influxdb/influxql"
+	"github.com/influxdata/kapacitor/sketch"
+)
+
+// Reducers of the sketch functions.
+// Numeric values are added to the sketch,
+// string values holding an encoded sketch are merged into the sketch.
+
+type quantileSketchReducer struct {
+	sketch *sketch.DDSketch
+}
+
+func newQuantileSketchReducer() *quantileSketchReducer {
+	s, _ := sketch.NewDDSketch(sketch.DefaultRelativeAccuracy, sketch.DefaultMaxBins)
+	return &quantileSketchReducer{sketch: s}
+}
+
+func (r *quantileSketchReducer) AggregateFloat(p *influxql.FloatPoint) {
+	r.sketch.Add(p.Value)
+}
+
+func (r *quantileSketchReducer) AggregateInteger(p *influxql.IntegerPoint) {
+	r.sketch.Add(float64(p.Value))
+}
+
+func (r *quantileSketchReducer) AggregateString(p *influxql.StringPoint) {
+	var s sketch.DDSketch
+	if err := s.UnmarshalText([]byte(p.Value)); err != nil {
+		// Not an encoded sketch, nothing to merge.
+		return
+	}
+	r.sketch.Merge(&s)
+}
+
+// quantileEmitter emits the quantile of the sketch.
+type quantileEmitter struct {
+	*quantileSketchReducer
+	quantile float64
+}
+
+func (e quantileEmitter) Emit() []influxql.FloatPoint {
+	v := e.sketch.Quantile(e.quantile)
+	if math.IsNaN(v) {
+		return nil
+	}
+	return []influxql.FloatPoint{{Time: influxql.ZeroTime, Value: v}}
+}
+
+// quantileSketchStateEmitter emits the encoded sketch.
+type quantileSketchStateEmitter struct {
+	*quantileSketchReducer
+}
+
+func (e quantileSketchStateEmitter) Emit() []influxql.StringPoint {
+	if e.sketch.Count() == 0 {
+		return nil
+	}
+	text, err := e.sketch.MarshalText()
+	if err != nil {
+		return nil
+	}
+	return []influxql.StringPoint{{Time: influxql.ZeroTime, Value: string(text)}}
+}
+
+type approxDistinctReducer struct {
+	hll *sketch.HyperLogLog
+	buf [8]byte
+}
+
+func newApproxDistinctReducer() *approxDistinctReducer {
+	h, _ := sketch.NewHyperLogLog(sketch.DefaultPrecision)
+	return &approxDistinctReducer{hll: h}
+}
+
+func (r *approxDistinctReducer) addUint64(v uint64) {
+	binary.LittleEndian.PutUint64(r.buf[:], v)
+	r.hll.Add(r.buf[:])
+}
+
+func (r *approxDistinctReducer) AggregateFloat(p *influxql.FloatPoint) {
+	r.addUint64(math.Float64bits(p.Value))
+}
+
+func (r *approxDistinctReducer) AggregateInteger(p *influxql.IntegerPoint) {
+	r.addUint64(uint64(p.Value))
+}
+
+func (r *approxDistinctReducer) AggregateString(p *influxql.StringPoint) {
+	if strings.HasPrefix(p.Value, sketch.HyperLogLogTextPrefix) {
+		var h sketch.HyperLogLog
+		if err := h.UnmarshalText([]byte(p.Value)); err == nil {
+			r.hll.Merge(&h)
+			return
+		}
+	}
+	r.hll.Add([]byte(p.Value))
+}
+
+func (r *approxDistinctReducer) AggregateBoolean(p *influxql.BooleanPoint) {
+	if p.Value {
+		r.hll.Add([]byte{1})
+	} else {
+		r.hll.Add([]byte{0})
+	}
+}
+
+// approxDistinctEmitter emits the estimated number of distinct values.
+type approxDistinctEmitter struct {
+	*approxDistinctReducer
+}
+
+func (e approxDistinctEmitter) Emit() []influxql.IntegerPoint {
+	return []influxql.IntegerPoint{{Time: influxql.ZeroTime, Value: int64(e.hll.Count())}}
+}
+
+// approxDistinctStateEmitter emits the encoded HyperLogLog.
+type approxDistinctStateEmitter struct {
+	*approxDistinctReducer
+}
+
+func (e approxDistinctStateEmitter) Emit() []influxql.StringPoint {
+	text, err := e.hll.MarshalText()
+	if err != nil {
+		return nil
+	}
+	return []influxql.StringPoint{{Time: influxql.ZeroTime, Value: string(text)}}
+}
@@ -8,6 +8,18 @@ import (
 
 const (
 	defaultJoinDelimiter = "."
+	defaultAsOfMaxWait   = time.Minute
+)
+
+const (
+	// InnerJoin only emits data when all parents are present.
+	InnerJoin = "inner"
+	// LeftJoin emits data when the first parent is present.
+	LeftJoin = "left"
+	// RightJoin emits data when the last parent is present.
+	RightJoin = "right"
+	// FullJoin emits data when any parent is present.
+	FullJoin = "full"
 )
 
 // Joins the data from any number of nodes.
@@ -50,8 +62,13 @@ const (
 //
 // In the above example the `errors` and `requests` streams are joined
 // and then transformed to calculate a combined field.
+//
+// The `maxGroups` and `groupIdleTimeout` properties limit the groups per parent the node keeps the watermark for,
+// and in an as-of join the latest points. Evicted groups start with a new watermark and without latest points.
 type JoinNode struct {
 	chainnode
+	GroupLimits
+	Lateness
 	// The alias names of the two parents.
 	// Note:
 	//       Names[1] corresponds to the left  parent
@@ -88,7 +105,8 @@ type JoinNode struct {
 	// When using a numerical or null fill, the fields names are determined by copying
 	// the field names from another point.
 	// This doesn't work well when different sources have different field names.
-	// Use the DefaultNode and DeleteNode to finalize the fill operation if necessary.
+	// Use the DefaultNode and DeleteNode to finalize the fill operation if necessary,
+	// or the sourceFill and fieldFill properties.
 	//
 	// Example:
 	//    var maintlock = stream
@@ -121,12 +139,78 @@ type JoinNode struct {
 	//        |where(lambda: "maintlock.mode")
 	//        |...
 	Fill interface{}
+
+	// The type of join.
+	// Options are:
+	//
+	//   - inner - emit data only when all parents are present.
+	//   - left - emit data when the first parent, the node on which join was called, is present.
+	//   - right - emit data when the last parent is present.
+	//   - full - emit data when any parent is present.
+	//
+	// If empty, the type is implied by the fill property, see above.
+	// The fields of missing parents are filled using the fill, sourceFill and fieldFill properties.
+	// Without any fill the fields of missing parents are left out.
+	// The sourceFill and fieldFill properties require an outer join, either set here or implied by the fill.
+	//
+	// Once the joinType, sourceFill or fieldFill is set, the fields of a missing parent are named
+	// after the fields last received from that parent, and points of the parent with fewer dimensions
+	// that never matched are emitted alone if the join type allows it.
+	// With only the fill property, the join emits the same data as described above.
+	//
+	// Example:
+	//    var requests = stream
+	//        |from()
+	//            .measurement('requests')
+	//    var errors = stream
+	//        |from()
+	//            .measurement('errors')
+	//    requests
+	//        |join(errors)
+	//            .as('requests', 'errors')
+	//            .joinType('left')
+	//            .sourceFill('errors', 0)
+	//            .presenceField('present')
+	//        ...
+	//
+	// Every requests point is emitted, with an `errors.value` of 0 if no errors point arrived for its time.
+	// The boolean fields `requests.present` and `errors.present` mark which parents were present.
+	JoinType string
+
+	// Fill values per parent, by alias name, see the SourceFill property method.
+	// tick:ignore
+	SourceFills map[string]interface{} `tick:"SourceFill"`
+
+	// Fill values per field, by prefixed field name, see the FieldFill property method.
+	// tick:ignore
+	FieldFills map[string]interface{} `tick:"FieldFill"`
+
+	// The name of a boolean field added for each parent marking whether the parent was present in the joined data.
+	// The field name is prefixed like the other fields of the parent.
+	// If empty, no presence fields are added.
+	PresenceField string
+
+	// Whether to join as of the time of the primary parent.
+	// tick:ignore
+	AsOfFlag bool `tick:"AsOf"`
+
+	// The maximum age of a point from a secondary parent in an as-of join.
+	// Older points are treated as missing, see the fill property.
+	// If zero, points of any age are joined.
+	MaxStaleness time.Duration
+
+	// The maximum time a point of the primary parent of an as-of join waits for the other parents to reach its time.
+	// The time is measured using the time of the data of the primary parent.
+	// Defaults to 1m, so that parents that send data rarely do not hold back the primary points.
+	MaxWait time.Duration
 }
 
 func newJoinNode(e EdgeType, parents []Node) *JoinNode {
 	j := &JoinNode{
-		chainnode: newBasicChainNode("join", e, e),
-		Delimiter: defaultJoinDelimiter,
+		chainnode:   newBasicChainNode("join", e, e),
+		Delimiter:   defaultJoinDelimiter,
+		SourceFills: make(map[string]interface{}),
+		FieldFills:  make(map[string]interface{}),
 	}
 	for _, n := range parents {
 		n.linkChild(j)
@@ -184,6 +268,74 @@ func (j *JoinNode) On(dims ...string) *JoinNode {
 	return j
 }
 
+// Creates a new LateNode that receives the data arriving behind the watermark of the join.
+// See the allowedLateness property.
+func (j *JoinNode) Late() *LateNode {
+	l := newLateNode(j.Wants())
+	j.linkChild(l)
+	return l
+}
+
+// Fill the fields of a missing parent with a value specific to the parent.
+// The parent is identified by its alias name, see the As property method.
+// The value is either 'null' or a number and overrides the fill property for the parent.
+// tick:property
+func (j *JoinNode) SourceFill(name string, value interface{}) *JoinNode {
+	j.SourceFills[name] = value
+	return j
+}
+
+// Fill a field of a missing parent with a specific value.
+// The field is identified by its prefixed name, for example 'errors.value'.
+// The value may be a float, int, string or bool and overrides any other fill for the field.
+// tick:property
+func (j *JoinNode) FieldFill(name string, value interface{}) *JoinNode {
+	j.FieldFills[name] = value
+	return j
+}
+
+// Join each point of the primary parent, the node on which join was called,
+// with the most recent point at or before its time from each of the other parents.
+// Points of the other parents are matched on the `on` dimensions, or else on their group.
+// Joined points have the time and tags of the primary point.
+//
+// This is useful to attach slowly changing values, like the deployed version of a service, to every point.
+//
+// Example:
+//    var requests = stream
+//        |from()
+//            .measurement('requests')
+//            .groupBy('service', 'host')
+//    var deploys = stream
+//        |from()
+//            .measurement('deploys')
+//            .groupBy('service')
+//    requests
+//        |join(deploys)
+//            .as('requests', 'deploy')
+//            .on('service')
+//            .asOf()
+//            .maxStaleness(24h)
+//            .fill('null')
+//        ...
+//
+// Each request point carries the field `deploy.version` of the latest deploy of its service,
+// or null if the service was not deployed in the last 24 hours.
+// Without a fill the request points that have no recent deploy are dropped.
+//
+// Primary points wait until the other parents have sent data or barriers at or after their time,
+// but at most for the maxWait property, by default 1m.
+// The primary points are expected to arrive in time order.
+// An as-of join only supports stream data.
+// tick:property
+func (j *JoinNode) AsOf() *JoinNode {
+	j.AsOfFlag = true
+	if j.MaxWait == 0 {
+		j.MaxWait = defaultAsOfMaxWait
+	}
+	return j
+}
+
 // Validate that the as() specification is consistent with the number of join arms.
 func (j *JoinNode) validate() error {
 	if len(j.Names) == 0 {
@@ -202,6 +354,59 @@ func (j *JoinNode) validate() error {
 			return fmt.Errorf("cannot use name %s as field prefix, it contains the delimiter %q	", name, j.Delimiter)
 		}
 	}
+	if j.MaxStaleness < 0 {
+		return fmt.Errorf("maxStaleness must not be negative")
+	}
+	if err := validateJoinFill(j.Fill); err != nil {
+		return err
+	}
+	switch j.JoinType {
+	case "", InnerJoin, FullJoin, LeftJoin, RightJoin:
+	default:
+		return fmt.Errorf("invalid joinType %q, must be one of %q, %q, %q or %q", j.JoinType, InnerJoin, LeftJoin, RightJoin, FullJoin)
+	}
+	// Without a join type the join is an inner join unless a fill is set.
+	inner := j.JoinType == InnerJoin || (j.JoinType == "" && (j.Fill == nil || j.Fill == "none"))
+	if inner && (len(j.SourceFills) > 0 || len(j.FieldFills) > 0) {
+		return fmt.Errorf("cannot fill the fields of an inner join, set an outer joinType or a fill")
+	}
+	for name, value := range j.SourceFills {
+		found := false
+		for _, n := range j.Names {
+			if n == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown parent %q in sourceFill, must be one of the names specified by join.as()", name)
+		}
+		if err := validateJoinFill(value); err != nil {
+			return fmt.Errorf("invalid sourceFill for %q: %v", name, err)
+		}
+	}
+	for field, value := range j.FieldFills {
+		switch value.(type) {
+		case float64, int64, bool, string:
+		default:
+			return fmt.Errorf("unsupported type %T for fieldFill %q, fill values must be float,int,string or bool", value, field)
+		}
+	}
+	if j.MaxWait < 0 || (j.AsOfFlag && j.MaxWait == 0) {
+		return fmt.Errorf("maxWait must be positive")
+	}
+	if (j.MaxStaleness != 0 || j.MaxWait != 0) && !j.AsOfFlag {
+		return fmt.Errorf("maxStaleness and maxWait only apply to an as-of join, see .asOf() property method")
+	}
+	if j.AsOfFlag {
+		if j.Wants() != StreamEdge {
+			return fmt.Errorf("an as-of join only supports stream data")
+		}
+		if j.Tolerance != 0 {
+			return fmt.Errorf("cannot use a tolerance with an as-of join")
+		}
+	}
+
 	names := make(map[string]bool, len(j.Names))
 	for _, name := range j.Names {
 		if names[name] {
@@ -212,3 +417,16 @@ func (j *JoinNode) validate() error {
 
 	return nil
 }
+
+func validateJoinFill(fill interface{}) error {
+	switch fill := fill.(type) {
+	case nil, int64, float64:
+	case string:
+		if fill != "null" && fill != "none" {
+			return fmt.Errorf("unexpected fill option %s", fill)
+		}
+	default:
+		return fmt.Errorf("unexpected fill option %v", fill)
+	}
+	return nil
+}
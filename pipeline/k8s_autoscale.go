@@ -78,6 +78,7 @@ const (
 //
 type K8sAutoscaleNode struct {
 	chainnode
+	GroupLimits
 
 	// Cluster is the name of the Kubernetes cluster to use.
 	Cluster string
@@ -0,0 +1,126 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// WatermarkEvent tracks the watermark as the largest time of the data received.
+	WatermarkEvent = "event"
+	// WatermarkClock tracks the watermark as the current wall clock time.
+	WatermarkClock = "clock"
+)
+
+// Lateness configures how a node handles data that arrives behind its watermark.
+// The `window`, `join` and `stream` nodes accept the `allowedLateness` and `watermark` properties.
+//
+// The watermark is the time up to which a node considers its data complete.
+// Data older than the watermark minus the allowed lateness is late.
+// Late data is counted in the `late_points` stat of the node and dropped,
+// unless the node has a `late` child, in which case the late data is sent to that child instead.
+//
+// Example:
+//    var cpu = stream
+//        |from()
+//            .measurement('cpu')
+//            .groupBy('host')
+//        |window()
+//            .period(1m)
+//            .every(1m)
+//            .allowedLateness(10s)
+//            .lateUpdate()
+//    cpu
+//        |mean('usage')
+//        ...
+//    cpu
+//        |late()
+//        |influxDBOut()
+//            .database('late')
+//            .measurement('cpu')
+//
+// Points arriving at most 10 seconds behind the newest point of their host update the windows already emitted.
+// Points arriving later are written to the `late` database.
+type Lateness struct {
+	// How far behind the watermark data may arrive and still be processed.
+	// If zero, only data at or after the watermark is processed.
+	AllowedLateness time.Duration
+
+	// How the watermark is tracked.
+	// Options are:
+	//
+	//   - event - (default) the largest time of the data received.
+	//             The `window` and `join` nodes track it per group, and the `join` node per parent as well.
+	//             The `stream` node tracks it for all data of the task.
+	//   - clock - the current wall clock time.
+	//             Replays track the event watermark instead, since replayed data does not arrive in real time.
+	Watermark string
+}
+
+// GetLateness returns the lateness configuration of the node.
+// tick:ignore
+func (l *Lateness) GetLateness() Lateness {
+	return *l
+}
+
+// Whether the node was configured to detect late data.
+func (l *Lateness) latenessEnabled() bool {
+	return l.AllowedLateness != 0 || l.Watermark != ""
+}
+
+func (l *Lateness) validateLateness() error {
+	if l.AllowedLateness < 0 {
+		return errors.New("allowedLateness must not be negative")
+	}
+	switch l.Watermark {
+	case "", WatermarkEvent, WatermarkClock:
+	default:
+		return fmt.Errorf("invalid watermark %q, must be one of %q or %q", l.Watermark, WatermarkEvent, WatermarkClock)
+	}
+	return nil
+}
+
+// LatenessNode is a node that detects data arriving behind its watermark.
+type LatenessNode interface {
+	Node
+	GetLateness() Lateness
+	validateLateness() error
+	latenessEnabled() bool
+}
+
+// LateEnabled reports whether late data detection is active for the node,
+// either because lateness was configured or because the node has a `late` child.
+// tick:ignore
+func LateEnabled(n LatenessNode) bool {
+	if n.latenessEnabled() {
+		return true
+	}
+	for _, c := range n.Children() {
+		if _, ok := c.(*LateNode); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// A LateNode receives the late data of its parent.
+// The data that arrives behind the watermark of the parent is sent to the LateNode
+// instead of being dropped, see the allowedLateness property of the parent.
+//
+// Example:
+//    stream
+//        .allowedLateness(1m)
+//        |late()
+//        |log()
+//
+// Log all points that arrive more than a minute behind the newest point of the task.
+type LateNode struct {
+	chainnode
+}
+
+func newLateNode(e EdgeType) *LateNode {
+	return &LateNode{
+		chainnode: newBasicChainNode("late", e, e),
+	}
+}
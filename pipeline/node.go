@@ -2,7 +2,11 @@ package pipeline
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"reflect"
 	"strings"
 	"time"
 
@@ -433,6 +437,34 @@ func (n *chainnode) Derivative(field string) *DerivativeNode {
 	return s
 }
 
+// Create a new node that computes running aggregates of a field without a window.
+func (n *chainnode) Aggregate(field string) *AggregateNode {
+	a := newAggregateNode(n.Provides(), field)
+	n.linkChild(a)
+	return a
+}
+
+// Create a new node that forecasts a field using an online model.
+func (n *chainnode) Forecast(field string) *ForecastNode {
+	f := newForecastNode(n.Provides(), field)
+	n.linkChild(f)
+	return f
+}
+
+// Create a new node that drops duplicate points.
+func (n *chainnode) Dedup() *DedupNode {
+	d := newDedupNode(n.Provides())
+	n.linkChild(d)
+	return d
+}
+
+// Create a new node that limits the rate of points using a token bucket per group.
+func (n *chainnode) Throttle() *ThrottleNode {
+	t := newThrottleNode(n.Provides())
+	n.linkChild(t)
+	return t
+}
+
 // Create a new node that shifts the incoming points or batches in time.
 func (n *chainnode) Shift(shift time.Duration) *ShiftNode {
 	s := newShiftNode(n.Provides(), shift)
@@ -488,3 +520,184 @@ func (n *chainnode) StateCount(expression *ast.LambdaNode) *StateCountNode {
 	n.linkChild(sc)
 	return sc
 }
+
+var (
+	nodeType    = reflect.TypeOf((*Node)(nil)).Elem()
+	equalerType = reflect.TypeOf((*equaler)(nil)).Elem()
+)
+
+// isNodeRef reports whether values of the type reference a node.
+// Alert handlers implement Node by embedding their alert node, but are properties of the alert node.
+func isNodeRef(t reflect.Type) bool {
+	if !t.Implements(nodeType) {
+		return false
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct {
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.Anonymous && f.PkgPath == "" && f.Type.Implements(nodeType) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+type equaler interface {
+	Equal(interface{}) bool
+}
+
+// Equivalent reports whether a and b are nodes of the same type with the same properties.
+// The parents and children of the nodes are not compared,
+// references to other nodes are compared by name.
+func Equivalent(a, b Node) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Type() != vb.Type() || va.Kind() != reflect.Ptr {
+		return false
+	}
+	return equalFields(va.Elem(), vb.Elem())
+}
+
+// equalFields compares the exported fields of two structs of the same type.
+func equalFields(a, b reflect.Value) bool {
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			// Unexported fields hold the links between nodes.
+			continue
+		}
+		fa, fb := a.Field(i), b.Field(i)
+		if f.Anonymous {
+			if fa.Kind() == reflect.Struct {
+				if !equalFields(fa, fb) {
+					return false
+				}
+			}
+			// Anonymous pointers link back to the parent node, i.e. alert handlers.
+			continue
+		}
+		if !equalValues(fa, fb) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalValues(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+	}
+	switch {
+	case isNodeRef(a.Type()):
+		return a.Interface().(Node).Name() == b.Interface().(Node).Name()
+	case a.Type().Implements(equalerType):
+		// AST nodes are compared ignoring their position in the script.
+		return a.Interface().(equaler).Equal(b.Interface())
+	}
+	switch a.Kind() {
+	case reflect.Slice:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !equalValues(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Ptr:
+		if a.Elem().Kind() == reflect.Struct {
+			return equalFields(a.Elem(), b.Elem())
+		}
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+// Fingerprint returns a digest of the nodes of the pipeline, their parents and properties.
+// Pipelines with the same fingerprint have equivalent nodes, see Equivalent.
+func Fingerprint(p *Pipeline) string {
+	h := sha256.New()
+	_ = p.Walk(func(n Node) error {
+		fmt.Fprintf(h, "%s %T", n.Name(), n)
+		for _, parent := range n.Parents() {
+			fmt.Fprintf(h, " %s", parent.Name())
+		}
+		io.WriteString(h, "\n")
+		writeFields(h, reflect.ValueOf(n).Elem())
+		return nil
+	})
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeFields writes the exported fields of a struct, skipping the same fields as equalFields.
+func writeFields(w io.Writer, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		fv := v.Field(i)
+		if f.Anonymous {
+			if fv.Kind() == reflect.Struct {
+				writeFields(w, fv)
+			}
+			continue
+		}
+		fmt.Fprintf(w, "%s=", f.Name)
+		writeValue(w, fv)
+		io.WriteString(w, "\n")
+	}
+}
+
+// writeValue writes a value, comparing equal to another value as in equalValues only if the written values are equal.
+func writeValue(w io.Writer, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			io.WriteString(w, "nil")
+			return
+		}
+	}
+	switch {
+	case isNodeRef(v.Type()):
+		io.WriteString(w, v.Interface().(Node).Name())
+		return
+	case v.Type().Implements(equalerType):
+		if n, ok := v.Interface().(ast.Node); ok {
+			// AST nodes are written without their position in the script.
+			io.WriteString(w, ast.Format(n))
+			return
+		}
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		fmt.Fprintf(w, "[%d:", v.Len())
+		for i := 0; i < v.Len(); i++ {
+			writeValue(w, v.Index(i))
+			io.WriteString(w, ",")
+		}
+		io.WriteString(w, "]")
+		return
+	case reflect.Ptr, reflect.Interface:
+		writeValue(w, v.Elem())
+		return
+	case reflect.Struct:
+		if s, ok := v.Interface().(fmt.Stringer); ok && !v.Type().Implements(nodeType) {
+			// Structs with unexported fields such as time.Time.
+			io.WriteString(w, s.String())
+			return
+		}
+		io.WriteString(w, "{")
+		writeFields(w, v)
+		io.WriteString(w, "}")
+		return
+	}
+	fmt.Fprintf(w, "%#v", v.Interface())
+}
@@ -93,6 +93,16 @@ func createPipelineAndVars(
 	}
 	if err = p.Walk(
 		func(n Node) error {
+			if gl, ok := n.(GroupLimitedNode); ok {
+				if err := gl.validateGroupLimits(); err != nil {
+					return fmt.Errorf("%s: %v", n.Name(), err)
+				}
+			}
+			if ln, ok := n.(LatenessNode); ok {
+				if err := ln.validateLateness(); err != nil {
+					return fmt.Errorf("%s: %v", n.Name(), err)
+				}
+			}
 			return n.validate()
 		}); err != nil {
 		return nil, nil, err
@@ -1,6 +1,7 @@
 package pipeline
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -96,3 +97,137 @@ func TestPipelineSort(t *testing.T) {
 
 	assert.Equal(sorted, p.sorted)
 }
+
+func TestEquivalent(t *testing.T) {
+	createPipeline := func(script string) *Pipeline {
+		p, err := CreatePipeline(script, StreamEdge, stateful.NewScope(), deadman{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	nodes := func(p *Pipeline) map[string]Node {
+		m := make(map[string]Node)
+		p.Walk(func(n Node) error {
+			m[n.Name()] = n
+			return nil
+		})
+		return m
+	}
+	a := nodes(createPipeline(`
+stream
+	|from()
+		.where(lambda: "host" == 'A')
+	|window()
+		.period(10s)
+		.every(10s)
+	|alert()
+		.crit(lambda: "value" > 10)
+		.log('/tmp/alert.log')
+`))
+	// Only the formatting of the where lambda and the period of the window change.
+	b := nodes(createPipeline(`
+stream
+	|from()
+		.where(lambda:   "host" ==   'A')
+	|window()
+		.period(20s)
+		.every(10s)
+	|alert()
+		.crit(lambda: "value" > 10)
+		.log('/tmp/alert.log')
+`))
+	testCases := map[string]bool{
+		"stream0": true,
+		"from1":   true,
+		"window2": false,
+		"alert3":  true,
+	}
+	for name, exp := range testCases {
+		if got := Equivalent(a[name], b[name]); got != exp {
+			t.Errorf("%s: unexpected equivalence got %v exp %v", name, got, exp)
+		}
+	}
+	if Equivalent(a["window2"], a["alert3"]) {
+		t.Error("expected nodes of different types not to be equivalent")
+	}
+	c := nodes(createPipeline(`
+stream
+	|from()
+		.where(lambda: "host" == 'A')
+	|window()
+		.period(10s)
+		.every(10s)
+	|alert()
+		.crit(lambda: "value" > 10)
+		.log('/tmp/other.log')
+`))
+	if Equivalent(a["alert3"], c["alert3"]) {
+		t.Error("expected alert nodes with different handlers not to be equivalent")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	fingerprint := func(script string) string {
+		p, err := CreatePipeline(script, StreamEdge, stateful.NewScope(), deadman{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return Fingerprint(p)
+	}
+	const script = `
+stream
+	|from()
+		.where(lambda: "host" == 'A')
+	|window()
+		.period(10s)
+		.every(10s)
+	|alert()
+		.crit(lambda: "value" > 10)
+		.log('/tmp/alert.log')
+`
+	a := fingerprint(script)
+	if b := fingerprint(script); a != b {
+		t.Errorf("expected the same pipeline to have the same fingerprint got %s and %s", a, b)
+	}
+	if b := fingerprint(strings.Replace(script, `"host" == 'A'`, `"host"   ==   'A'`, 1)); a != b {
+		t.Error("expected reformatted pipeline to have the same fingerprint")
+	}
+	if b := fingerprint(strings.Replace(script, "period(10s)", "period(20s)", 1)); a == b {
+		t.Error("expected changed pipeline to have a different fingerprint")
+	}
+	if b := fingerprint(strings.Replace(script, "/tmp/alert.log", "/tmp/other.log", 1)); a == b {
+		t.Error("expected pipeline with a changed alert handler to have a different fingerprint")
+	}
+}
+
+func TestJoinFillValidation(t *testing.T) {
+	const script = `
+var errors = stream
+	|from()
+		.measurement('errors')
+stream
+	|from()
+		.measurement('requests')
+	|join(errors)
+		.as('requests', 'errors')
+`
+	testCases := []struct {
+		props string
+		valid bool
+	}{
+		{props: ".sourceFill('errors', 0)", valid: false},
+		{props: ".fill('none').fieldFill('errors.value', 0)", valid: false},
+		{props: ".joinType('inner').fill(0).sourceFill('errors', 0)", valid: false},
+		{props: ".fill('null').sourceFill('errors', 0)", valid: true},
+		{props: ".joinType('left').sourceFill('errors', 0)", valid: true},
+	}
+	for _, tc := range testCases {
+		_, err := CreatePipeline(script+"\t\t"+tc.props+"\n", StreamEdge, stateful.NewScope(), deadman{}, nil)
+		if tc.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.props, err)
+		} else if !tc.valid && err == nil {
+			t.Errorf("%s: expected error filling an inner join", tc.props)
+		}
+	}
+}
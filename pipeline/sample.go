@@ -22,6 +22,7 @@ import (
 // for ensuring data is aligned with a boundary.
 type SampleNode struct {
 	chainnode
+	GroupLimits
 
 	// Keep every N point or batch
 	// tick:ignore
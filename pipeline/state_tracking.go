@@ -35,6 +35,7 @@ import (
 // state duration will be 0.
 type StateDurationNode struct {
 	chainnode
+	GroupLimits
 
 	// Expression to determine whether state is active.
 	// tick:ignore
@@ -82,6 +83,7 @@ func newStateDurationNode(wants EdgeType, predicate *ast.LambdaNode) *StateDurat
 //             .crit(lambda: "state_count" >= 5)
 type StateCountNode struct {
 	chainnode
+	GroupLimits
 
 	// Expression to determine whether state is active.
 	// tick:ignore
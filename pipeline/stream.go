@@ -14,6 +14,7 @@ import (
 // StreamNode.From is the method/property of this node.
 type StreamNode struct {
 	node
+	Lateness
 }
 
 func newStreamNode() *StreamNode {
@@ -55,6 +56,14 @@ func (s *StreamNode) From() *FromNode {
 	return f
 }
 
+// Creates a new LateNode that receives the points arriving behind the watermark of the stream.
+// See the allowedLateness property.
+func (s *StreamNode) Late() *LateNode {
+	l := newLateNode(StreamEdge)
+	s.linkChild(l)
+	return l
+}
+
 // A FromNode selects a subset of the data flowing through a StreamNode.
 // The stream node allows you to select which portion of the stream you want to process.
 //
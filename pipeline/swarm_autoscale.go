@@ -67,6 +67,7 @@ import (
 //
 type SwarmAutoscaleNode struct {
 	chainnode
+	GroupLimits
 
 	// Cluster is the ID docker swarm cluster to use.
 	// The ID of the cluster is specified in the kapacitor configuration.
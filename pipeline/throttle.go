@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"errors"
+	"time"
+
+	"github.com/influxdata/kapacitor/tick/ast"
+)
+
+// Limit the rate of points using a token bucket per group.
+// The bucket holds up to Burst tokens and is refilled with Rate tokens every Per duration.
+// Each point takes a token, points arriving while the bucket is empty are throttled.
+// Throttled points are dropped, unless the ThrottledTag property is set.
+//
+// Example:
+//    stream
+//        |from()
+//            .measurement('errors')
+//            .groupBy('service')
+//        |throttle()
+//            .rate(10)
+//            .per(1m)
+//            .burst(20)
+//        |httpPost('http://example.com/hook')
+//
+// At most 10 points per minute per service are posted, with bursts of up to 20 points.
+//
+// The rate can be limited per key within a group by using a lambda expression.
+//
+// Example:
+//    stream
+//        |from()
+//            .measurement('logins')
+//        |throttle()
+//            .rate(1)
+//            .per(10s)
+//            .key(lambda: "user")
+//            .throttledTag('throttled')
+//        |alert()
+//            .crit(lambda: !isPresent("throttled"))
+//
+// The throttled points of each user are tagged with throttled=true instead of being dropped,
+// the other points are not tagged so the alert checks whether the tag is present.
+//
+// The tokens are refilled using the time of the data.
+// Batches are throttled point by point.
+//
+// Available Statistics:
+//
+//    * points_throttled -- number of points that were throttled
+//
+type ThrottleNode struct {
+	chainnode
+	GroupLimits
+
+	// The number of tokens added to the bucket every Per duration.
+	Rate int64
+
+	// The duration over which Rate tokens are added.
+	// Default: 1s
+	Per time.Duration
+
+	// The maximum number of tokens in the bucket.
+	// A full bucket lets Burst points pass at once.
+	// Default: the rate
+	Burst int64
+
+	// An expression computing the key of a point.
+	// If set, each key within a group has its own bucket.
+	Key *ast.LambdaNode
+
+	// If set, throttled points are tagged with this tag set to 'true' instead of being dropped.
+	// Points that are not throttled are not tagged.
+	ThrottledTag string
+}
+
+func newThrottleNode(e EdgeType) *ThrottleNode {
+	return &ThrottleNode{
+		chainnode: newBasicChainNode("throttle", e, e),
+		Per:       time.Second,
+	}
+}
+
+func (n *ThrottleNode) validate() error {
+	if n.Rate <= 0 {
+		return errors.New("rate must be positive")
+	}
+	if n.Per <= 0 {
+		return errors.New("per must be positive")
+	}
+	if n.Burst < 0 {
+		return errors.New("burst must not be negative")
+	}
+	return nil
+}
@@ -18,6 +18,7 @@ import "github.com/influxdata/kapacitor/tick/ast"
 //
 type WhereNode struct {
 	chainnode
+	GroupLimits
 	// The expression predicate.
 	// tick:ignore
 	Lambda *ast.LambdaNode
@@ -28,8 +28,30 @@ import (
 // new data and `5 minutes` of the previous period's data.
 //
 // NOTE: Because no `align` property is defined, the `window` edge is defined relative to the first data point.
+//
+// The `sessionGap` property turns the `window` into a session window.
+// A session window collects the data of a group until no data arrives for the length of the gap,
+// then emits the session as a single batch.
+//
+// Example:
+//    stream
+//        |from()
+//            .measurement('spans')
+//            .groupBy('trace_id')
+//        |window()
+//            .sessionGap(30s)
+//            .sessionMaxLength(10m)
+//        |httpOut('traces')
+//
+// This example emits the spans of each trace once no span has arrived for `30 seconds`,
+// or once the trace is `10 minutes` long.
+// Each point of an emitted session carries the fields `session_start` and `session_end`,
+// the times of the first and last point of the session in nanoseconds since the epoch,
+// and `session_count`, the number of points in the session.
 type WindowNode struct {
 	chainnode
+	GroupLimits
+	Lateness
 	// The period, or length in time, of the window.
 	Period time.Duration
 	// How often the current window is emitted into the pipeline.
@@ -41,12 +63,25 @@ type WindowNode struct {
 	// Whether to wait till the period is full before the first emit.
 	// tick:ignore
 	FillPeriodFlag bool `tick:"FillPeriod"`
+	// Whether late points update the windows already emitted.
+	// tick:ignore
+	LateUpdateFlag bool `tick:"LateUpdate"`
 
 	// PeriodCount is the number of points per window.
 	PeriodCount int64
 	// EveryCount determines how often the window is emitted based on the count of points.
 	// A value of 1 means that every new point will emit the window.
 	EveryCount int64
+
+	// SessionGap is the duration of inactivity after which the session window of a group is closed and emitted.
+	// Sessions are closed when a point or barrier of any group arrives later than the gap after the last point of the session.
+	// Only the time of the data is used, so a session stays open while the whole stream is idle.
+	SessionGap time.Duration
+	// SessionMaxLength is the maximum length in time of a session window.
+	// A session is closed once a point arrives at or after the session start plus the max length,
+	// even if the gap was not exceeded.
+	// If zero, sessions have no maximum length.
+	SessionMaxLength time.Duration
 }
 
 func newWindowNode() *WindowNode {
@@ -75,7 +110,45 @@ func (w *WindowNode) FillPeriod() *WindowNode {
 	return w
 }
 
+// LateUpdate instructs the WindowNode to emit an updated window when a point arrives for a window already emitted.
+// Points are only accepted within the allowed lateness, see the allowedLateness property.
+// Points arriving for an emitted window are late otherwise, since they can no longer be part of any window.
+// This only applies to windows by time.
+// tick:property
+func (w *WindowNode) LateUpdate() *WindowNode {
+	w.LateUpdateFlag = true
+	return w
+}
+
+// Creates a new LateNode that receives the points arriving behind the watermark of the window.
+// See the allowedLateness property.
+func (w *WindowNode) Late() *LateNode {
+	l := newLateNode(StreamEdge)
+	w.linkChild(l)
+	return l
+}
+
 func (w *WindowNode) validate() error {
+	if w.LateUpdateFlag && w.Period == 0 {
+		return errors.New("lateUpdate only applies to windows by time, a period is required")
+	}
+	if w.SessionGap < 0 {
+		return errors.New("sessionGap must not be negative")
+	}
+	if w.SessionMaxLength < 0 {
+		return errors.New("sessionMaxLength must not be negative")
+	}
+	if w.SessionMaxLength != 0 && w.SessionGap == 0 {
+		return errors.New("sessionMaxLength requires a sessionGap")
+	}
+	if w.SessionGap != 0 {
+		if w.Period != 0 || w.PeriodCount != 0 || w.Every != 0 || w.EveryCount != 0 {
+			return errors.New("cannot specify sessionGap with period, every, periodCount or everyCount")
+		}
+		if w.AlignFlag || w.FillPeriodFlag {
+			return errors.New("cannot specify sessionGap with align or fillPeriod")
+		}
+	}
 	if w.PeriodCount != 0 && w.Period != 0 {
 		return errors.New("cannot specify both period and periodCount")
 	}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/influxdata/kapacitor/command"
 	"github.com/influxdata/kapacitor/services/alerta"
+	"github.com/influxdata/kapacitor/services/audit"
 	"github.com/influxdata/kapacitor/services/azure"
 	"github.com/influxdata/kapacitor/services/config"
 	"github.com/influxdata/kapacitor/services/consul"
@@ -26,6 +27,7 @@ import (
 	"github.com/influxdata/kapacitor/services/httppost"
 	"github.com/influxdata/kapacitor/services/influxdb"
 	"github.com/influxdata/kapacitor/services/k8s"
+	"github.com/influxdata/kapacitor/services/localauth"
 	"github.com/influxdata/kapacitor/services/logging"
 	"github.com/influxdata/kapacitor/services/marathon"
 	"github.com/influxdata/kapacitor/services/mqtt"
@@ -36,6 +38,7 @@ import (
 	"github.com/influxdata/kapacitor/services/replay"
 	"github.com/influxdata/kapacitor/services/reporting"
 	"github.com/influxdata/kapacitor/services/scraper"
+	"github.com/influxdata/kapacitor/services/secrets"
 	"github.com/influxdata/kapacitor/services/sensu"
 	"github.com/influxdata/kapacitor/services/serverset"
 	"github.com/influxdata/kapacitor/services/slack"
@@ -68,6 +71,9 @@ type Config struct {
 	InfluxDB       []influxdb.Config `toml:"influxdb" override:"influxdb,element-key=name"`
 	Logging        logging.Config    `toml:"logging"`
 	ConfigOverride config.Config     `toml:"config-override"`
+	Auth           localauth.Config  `toml:"auth"`
+	Audit          audit.Config      `toml:"audit"`
+	Secrets        secrets.Config    `toml:"secrets"`
 
 	// Input services
 	Graphite []graphite.Config `toml:"graphite"`
@@ -136,6 +142,9 @@ func NewConfig() *Config {
 	c.InfluxDB = []influxdb.Config{influxdb.NewConfig()}
 	c.Logging = logging.NewConfig()
 	c.ConfigOverride = config.NewConfig()
+	c.Auth = localauth.NewConfig()
+	c.Audit = audit.NewConfig()
+	c.Secrets = secrets.NewConfig()
 
 	c.Collectd = collectd.NewConfig()
 	c.OpenTSDB = opentsdb.NewConfig()
@@ -206,6 +215,15 @@ func (c *Config) Validate() error {
 	if err := c.Task.Validate(); err != nil {
 		return err
 	}
+	if err := c.Auth.Validate(); err != nil {
+		return err
+	}
+	if err := c.Audit.Validate(); err != nil {
+		return errors.Wrap(err, "audit")
+	}
+	if err := c.Secrets.Validate(); err != nil {
+		return errors.Wrap(err, "secrets")
+	}
 	// Validate the set of InfluxDB configs.
 	// All names should be unique.
 	names := make(map[string]bool, len(c.InfluxDB))
@@ -23,8 +23,10 @@ import (
 	"github.com/influxdata/kapacitor/server/vars"
 	"github.com/influxdata/kapacitor/services/alert"
 	"github.com/influxdata/kapacitor/services/alerta"
+	"github.com/influxdata/kapacitor/services/audit"
 	"github.com/influxdata/kapacitor/services/azure"
 	"github.com/influxdata/kapacitor/services/config"
+	"github.com/influxdata/kapacitor/services/config/override"
 	"github.com/influxdata/kapacitor/services/consul"
 	"github.com/influxdata/kapacitor/services/deadman"
 	"github.com/influxdata/kapacitor/services/dns"
@@ -36,9 +38,11 @@ import (
 	"github.com/influxdata/kapacitor/services/httppost"
 	"github.com/influxdata/kapacitor/services/influxdb"
 	"github.com/influxdata/kapacitor/services/k8s"
+	"github.com/influxdata/kapacitor/services/localauth"
 	"github.com/influxdata/kapacitor/services/logging"
 	"github.com/influxdata/kapacitor/services/marathon"
 	"github.com/influxdata/kapacitor/services/mqtt"
+	"github.com/influxdata/kapacitor/services/namespace"
 	"github.com/influxdata/kapacitor/services/nerve"
 	"github.com/influxdata/kapacitor/services/noauth"
 	"github.com/influxdata/kapacitor/services/opsgenie"
@@ -47,6 +51,7 @@ import (
 	"github.com/influxdata/kapacitor/services/replay"
 	"github.com/influxdata/kapacitor/services/reporting"
 	"github.com/influxdata/kapacitor/services/scraper"
+	"github.com/influxdata/kapacitor/services/secrets"
 	"github.com/influxdata/kapacitor/services/sensu"
 	"github.com/influxdata/kapacitor/services/serverset"
 	"github.com/influxdata/kapacitor/services/servicetest"
@@ -87,6 +92,8 @@ type Server struct {
 	hostname string
 
 	config *Config
+	// The config as loaded, referencing secrets instead of their values.
+	rawConfig *Config
 
 	err chan error
 
@@ -108,6 +115,9 @@ type Server struct {
 	ConfigOverrideService *config.Service
 	TesterService         *servicetest.Service
 	StatsService          *stats.Service
+	AuditService          *audit.Service
+	SecretsService        *secrets.Service
+	NamespaceService      *namespace.Service
 
 	ScraperService *scraper.Service
 
@@ -123,6 +133,8 @@ type Server struct {
 	DynamicServices map[string]Updater
 	// Channel of incoming configuration updates.
 	configUpdates chan config.ConfigUpdate
+	// Channel of the names of updated secrets.
+	secretUpdates chan []string
 
 	BuildInfo   BuildInfo
 	clusterIDMu sync.Mutex
@@ -139,13 +151,36 @@ type Server struct {
 
 // New returns a new instance of Server built from a config.
 func New(c *Config, buildInfo BuildInfo, logService logging.Interface) (*Server, error) {
-	err := c.Validate()
+	if err := c.Secrets.Validate(); err != nil {
+		return nil, fmt.Errorf("secrets: %s. To generate a valid configuration file run `kapacitord config > kapacitor.generated.conf`.", err)
+	}
+	// Create the secrets service first so that all secrets referenced by the config can be resolved.
+	secretUpdates := make(chan []string, 100)
+	secretsService, err := secrets.NewService(c.Secrets, logService.NewLogger("[secrets] ", log.LstdFlags), secretUpdates)
+	if err != nil {
+		return nil, errors.Wrap(err, "secrets service")
+	}
+	resolved, err := secretsService.ResolveConfig(c)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve secrets of configuration")
+	}
+	rc := resolved.(*Config)
+	err = rc.Validate()
 	if err != nil {
 		return nil, fmt.Errorf("%s. To generate a valid configuration file run `kapacitord config > kapacitor.generated.conf`.", err)
 	}
+	if rc != c {
+		// Validate applies defaults to the InfluxDB configs, apply them to the raw config as well.
+		for i := range c.InfluxDB {
+			c.InfluxDB[i].ApplyConditionalDefaults()
+		}
+	}
 	l := logService.NewLogger("[srv] ", log.LstdFlags)
 	s := &Server{
-		config:           c,
+		config:           rc,
+		rawConfig:        c,
+		SecretsService:   secretsService,
+		secretUpdates:    secretUpdates,
 		BuildInfo:        buildInfo,
 		dataDir:          c.DataDir,
 		hostname:         c.Hostname,
@@ -190,6 +225,9 @@ func New(c *Config, buildInfo BuildInfo, logService logging.Interface) (*Server,
 	s.initHTTPDService()
 	s.appendStorageService()
 	s.appendAuthService()
+	s.appendAuditService()
+	s.appendSecretsService()
+	s.appendNamespaceService()
 	s.appendConfigOverrideService()
 	s.appendTesterService()
 
@@ -306,9 +344,43 @@ func (s *Server) appendStorageService() {
 	s.AppendService("storage", srv)
 }
 
+func (s *Server) appendAuditService() {
+	c := s.config.Audit
+	if c.Enabled {
+		l := s.LogService.NewLogger("[audit] ", log.LstdFlags)
+		srv := audit.NewService(c, l)
+		srv.HTTPDService = s.HTTPDService
+		srv.TaskMaster = s.TaskMaster
+
+		s.AuditService = srv
+		s.HTTPDService.Handler.AuditService = srv
+		s.AppendService("audit", srv)
+	}
+}
+
+func (s *Server) appendSecretsService() {
+	srv := s.SecretsService
+	srv.HTTPDService = s.HTTPDService
+
+	s.AppendService("secrets", srv)
+}
+
+func (s *Server) appendNamespaceService() {
+	l := s.LogService.NewLogger("[namespace] ", log.LstdFlags)
+	srv := namespace.NewService(l)
+	srv.StorageService = s.StorageService
+	srv.HTTPDService = s.HTTPDService
+	srv.TaskMaster = s.TaskMaster
+
+	s.NamespaceService = srv
+	s.TaskMaster.NamespaceQuotas = srv
+	s.AppendService("namespace", srv)
+}
+
 func (s *Server) appendConfigOverrideService() {
 	l := s.LogService.NewLogger("[config-override] ", log.LstdFlags)
-	srv := config.NewService(s.config.ConfigOverride, s.config, l, s.configUpdates)
+	// Overrides apply to the raw config so that secret references are preserved.
+	srv := config.NewService(s.config.ConfigOverride, s.rawConfig, l, s.configUpdates)
 	srv.HTTPDService = s.HTTPDService
 	srv.StorageService = s.StorageService
 
@@ -323,9 +395,12 @@ func (s *Server) initAlertService() {
 	srv.Commander = s.Commander
 	srv.HTTPDService = s.HTTPDService
 	srv.StorageService = s.StorageService
+	srv.SecretsService = s.SecretsService
+	srv.NamespaceQuotas = s.NamespaceService
 
 	s.AlertService = srv
 	s.TaskMaster.AlertService = srv
+	s.NamespaceService.AlertService = srv
 }
 
 func (s *Server) appendAlertService() {
@@ -404,9 +479,11 @@ func (s *Server) appendTaskStoreService() {
 	srv.StorageService = s.StorageService
 	srv.HTTPDService = s.HTTPDService
 	srv.TaskMasterLookup = s.TaskMasterLookup
+	srv.NamespaceService = s.NamespaceService
 
 	s.TaskStore = srv
 	s.TaskMaster.TaskStore = srv
+	s.NamespaceService.TaskStore = srv
 	s.AppendService("task_store", srv)
 }
 
@@ -468,6 +545,17 @@ func (s *Server) appendUDFService() {
 }
 
 func (s *Server) appendAuthService() {
+	if s.config.Auth.Enabled {
+		l := s.LogService.NewLogger("[auth] ", log.LstdFlags)
+		srv := localauth.NewService(s.config.Auth, l)
+		srv.StorageService = s.StorageService
+		srv.HTTPDService = s.HTTPDService
+
+		s.AuthService = srv
+		s.HTTPDService.Handler.AuthService = srv
+		s.AppendService("auth", srv)
+		return
+	}
 	l := s.LogService.NewLogger("[noauth] ", log.LstdFlags)
 	srv := noauth.NewService(l)
 
@@ -868,6 +956,10 @@ func (s *Server) startServices() error {
 					return fmt.Errorf("found configuration override for unknown service %q", service)
 				} else {
 					s.Logger.Println("D! applying configuration overrides for", service)
+					config, err := s.resolveSection(config)
+					if err != nil {
+						return errors.Wrapf(err, "failed to resolve secrets of service %s", service)
+					}
 					if err := srv.Update(config); err != nil {
 						return errors.Wrapf(err, "failed to update configuration for service %s", service)
 					}
@@ -887,16 +979,84 @@ func (s *Server) watchServices() {
 	s.err <- err
 }
 
+// watchConfigUpdates applies configuration updates and updated secrets to the services.
+// Both are handled by the same goroutine so that updates are applied in order.
 func (s *Server) watchConfigUpdates() {
-	for cu := range s.configUpdates {
-		if srv, ok := s.DynamicServices[cu.Name]; !ok {
-			cu.ErrC <- fmt.Errorf("received configuration update for unknown dynamic service %s", cu.Name)
-		} else {
-			cu.ErrC <- srv.Update(cu.NewConfig)
+	for {
+		select {
+		case cu, ok := <-s.configUpdates:
+			if !ok {
+				return
+			}
+			if srv, ok := s.DynamicServices[cu.Name]; !ok {
+				cu.ErrC <- fmt.Errorf("received configuration update for unknown dynamic service %s", cu.Name)
+			} else if config, err := s.resolveSection(cu.NewConfig); err != nil {
+				cu.ErrC <- errors.Wrap(err, "failed to resolve secrets")
+			} else {
+				cu.ErrC <- srv.Update(config)
+			}
+		case names := <-s.secretUpdates:
+			s.updateSecrets(names)
 		}
 	}
 }
 
+// resolveSection returns a copy of the elements of a configuration section with all secrets resolved.
+func (s *Server) resolveSection(section []interface{}) ([]interface{}, error) {
+	resolved, err := s.SecretsService.ResolveConfig(section)
+	if err != nil {
+		return nil, err
+	}
+	return resolved.([]interface{}), nil
+}
+
+// updateSecrets updates all services and alert handlers that reference any of the named secrets.
+func (s *Server) updateSecrets(names []string) {
+	s.Logger.Println("I! updating references to secrets", names)
+	var sections map[string][]interface{}
+	if !s.config.SkipConfigOverrides && s.config.ConfigOverride.Enabled {
+		var err error
+		sections, err = s.ConfigOverrideService.Config()
+		if err != nil {
+			s.Logger.Println("E! failed to update secrets:", err)
+			return
+		}
+	} else {
+		raw, err := override.OverrideConfig(s.rawConfig, nil)
+		if err != nil {
+			s.Logger.Println("E! failed to update secrets:", err)
+			return
+		}
+		sections = make(map[string][]interface{}, len(raw))
+		for name, section := range raw {
+			for _, element := range section {
+				sections[name] = append(sections[name], element.Value())
+			}
+		}
+	}
+	for name, section := range sections {
+		if !secrets.Referenced(section, names) {
+			continue
+		}
+		srv, ok := s.DynamicServices[name]
+		if !ok {
+			s.Logger.Printf("W! configuration section %s references an updated secret, restart to apply it", name)
+			continue
+		}
+		config, err := s.resolveSection(section)
+		if err != nil {
+			s.Logger.Printf("E! failed to resolve secrets of service %s: %v", name, err)
+			continue
+		}
+		if err := srv.Update(config); err != nil {
+			s.Logger.Printf("E! failed to update service %s with updated secrets: %v", name, err)
+		}
+	}
+	if err := s.AlertService.ReloadHandlerSpecs(names); err != nil {
+		s.Logger.Println("E! failed to update alert handlers with updated secrets:", err)
+	}
+}
+
 // Close shuts down the meta and data stores and all services.
 func (s *Server) Close() error {
 	s.stopProfile()
@@ -911,6 +1071,11 @@ func (s *Server) Close() error {
 			s.Logger.Printf("E! error closing stats service: %v", err)
 		}
 	}
+	if s.AuditService != nil {
+		if err := s.AuditService.Close(); err != nil {
+			s.Logger.Printf("E! error closing audit service: %v", err)
+		}
+	}
 
 	// Drain the in-flight writes and stop all tasks.
 	s.TaskMaster.Drain()
@@ -38,6 +38,7 @@ import (
 	"github.com/influxdata/kapacitor/services/k8s"
 	"github.com/influxdata/kapacitor/services/mqtt"
 	"github.com/influxdata/kapacitor/services/mqtt/mqtttest"
+	"github.com/influxdata/kapacitor/services/namespace"
 	"github.com/influxdata/kapacitor/services/opsgenie"
 	"github.com/influxdata/kapacitor/services/opsgenie/opsgenietest"
 	"github.com/influxdata/kapacitor/services/pagerduty"
@@ -2445,6 +2446,197 @@ func TestServer_UpdateTaskID_Enabled(t *testing.T) {
 	}
 }
 
+func TestServer_ShadowTask_Promote(t *testing.T) {
+	s, cli := OpenDefaultServer()
+	defer s.Close()
+
+	dbrps := []client.DBRP{{
+		Database:        "mydb",
+		RetentionPolicy: "myrp",
+	}}
+	tick := `stream
+    |from()
+        .measurement('test')
+`
+	// The shadowed task must exist.
+	if _, err := cli.CreateTask(client.CreateTaskOptions{
+		ID:         "shadow",
+		Type:       client.StreamTask,
+		DBRPs:      dbrps,
+		TICKscript: tick,
+		ShadowOf:   "live",
+	}); err == nil {
+		t.Fatal("expected error creating shadow of unknown task")
+	}
+
+	if _, err := cli.CreateTask(client.CreateTaskOptions{
+		ID:         "live",
+		Type:       client.StreamTask,
+		DBRPs:      dbrps,
+		TICKscript: tick,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	task, err := cli.CreateTask(client.CreateTaskOptions{
+		ID:         "shadow",
+		Type:       client.StreamTask,
+		DBRPs:      dbrps,
+		TICKscript: tick,
+		ShadowOf:   "live",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := task.ShadowOf, "live"; got != exp {
+		t.Fatalf("unexpected shadow-of got %s exp %s", got, exp)
+	}
+
+	if _, err := cli.UpdateTask(task.Link, client.UpdateTaskOptions{
+		ShadowOf: "unknown",
+	}); err == nil {
+		t.Fatal("expected error updating shadow of unknown task")
+	}
+
+	task, err = cli.UpdateTask(task.Link, client.UpdateTaskOptions{
+		Promote: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.ShadowOf != "" {
+		t.Fatalf("unexpected shadow-of of promoted task got %s exp empty", task.ShadowOf)
+	}
+
+	if _, err := cli.UpdateTask(task.Link, client.UpdateTaskOptions{
+		Promote: true,
+	}); err == nil {
+		t.Fatal("expected error promoting live task")
+	}
+}
+
+func TestServer_ShadowTask_DeleteLive(t *testing.T) {
+	s, cli := OpenDefaultServer()
+	defer s.Close()
+
+	dbrps := []client.DBRP{{
+		Database:        "mydb",
+		RetentionPolicy: "myrp",
+	}}
+	tick := `stream
+    |from()
+        .measurement('test')
+`
+	live, err := cli.CreateTask(client.CreateTaskOptions{
+		ID:         "live",
+		Type:       client.StreamTask,
+		DBRPs:      dbrps,
+		TICKscript: tick,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shadow, err := cli.CreateTask(client.CreateTaskOptions{
+		ID:         "shadow",
+		Type:       client.StreamTask,
+		DBRPs:      dbrps,
+		TICKscript: tick,
+		ShadowOf:   "live",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleting the live task deletes its shadows.
+	if err := cli.DeleteTask(live.Link); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.Task(shadow.Link, nil); err == nil {
+		t.Fatal("expected shadow task to be deleted with the live task")
+	}
+}
+
+func TestServer_NamespaceIsolation(t *testing.T) {
+	s, cli := OpenDefaultServer()
+	defer s.Close()
+
+	if _, err := cli.CreateNamespace(client.CreateNamespaceOptions{ID: "team-a"}); err != nil {
+		t.Fatal(err)
+	}
+	nsCli, err := client.New(client.Config{URL: s.URL(), Namespace: "team-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbrps := []client.DBRP{{
+		Database:        "mydb",
+		RetentionPolicy: "myrp",
+	}}
+	tick := `stream
+    |from()
+        .measurement('test')
+`
+	for _, c := range []*client.Client{cli, nsCli} {
+		if _, err := c.CreateTask(client.CreateTaskOptions{
+			ID:         "cpu",
+			Type:       client.StreamTask,
+			DBRPs:      dbrps,
+			TICKscript: tick,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := nsCli.CreateTopicHandler(nsCli.TopicHandlersLink("cpu"), client.TopicHandlerOptions{
+		ID:   "log",
+		Kind: "log",
+		Options: map[string]interface{}{
+			"path": "/dev/null",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The resources within the namespace cannot be referenced by their qualified IDs outside of it.
+	qualifiedTask := client.Link{Relation: client.Self, Href: "/kapacitor/v1/tasks/cpu@team-a"}
+	if _, err := cli.Task(qualifiedTask, nil); err == nil {
+		t.Error("expected error getting a task within a namespace via the global path")
+	}
+	if err := cli.DeleteTask(qualifiedTask); err == nil {
+		t.Error("expected error deleting a task within a namespace via the global path")
+	}
+	if _, err := nsCli.Task(nsCli.TaskLink("cpu"), nil); err != nil {
+		t.Errorf("unexpected error getting the task within the namespace: %v", err)
+	}
+	qualifiedHandler := client.Link{Relation: client.Self, Href: "/kapacitor/v1preview/alerts/topics/cpu@team-a/handlers/log"}
+	if _, err := cli.TopicHandler(qualifiedHandler); err == nil {
+		t.Error("expected error getting a handler within a namespace via the global path")
+	}
+
+	// The global lists only contain the resources outside the namespaces.
+	tasks, err := cli.ListTasks(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != "cpu" {
+		t.Errorf("unexpected global tasks %v", tasks)
+	}
+	topics, err := cli.ListTopics(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, topic := range topics.Topics {
+		if namespace.Qualified(topic.ID) {
+			t.Errorf("unexpected topic within a namespace in the global list %s", topic.ID)
+		}
+	}
+	nsTasks, err := nsCli.ListTasks(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nsTasks) != 1 || nsTasks[0].ID != "cpu" || nsTasks[0].Link.Href != "/kapacitor/v1/namespaces/team-a/tasks/cpu" {
+		t.Errorf("unexpected tasks within the namespace %v", nsTasks)
+	}
+}
+
 func TestServer_StreamTask_AllMeasurements(t *testing.T) {
 	s, cli := OpenDefaultServer()
 	defer s.Close()
@@ -13,8 +13,11 @@ import (
 
 	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/influxdata/kapacitor/alert"
+	"github.com/influxdata/kapacitor/auth"
 	client "github.com/influxdata/kapacitor/client/v1"
 	"github.com/influxdata/kapacitor/services/httpd"
+	"github.com/influxdata/kapacitor/services/namespace"
+	"github.com/influxdata/kapacitor/services/secrets"
 )
 
 const (
@@ -119,6 +122,14 @@ func (s *apiServer) handleListTopics(w http.ResponseWriter, r *http.Request) {
 		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
 		return
 	}
+	// Only list the topics of the namespace.
+	ns := httpd.RequestNamespace(r)
+	if ns != "" {
+		if pattern == "" {
+			pattern = "*"
+		}
+		pattern = namespace.Qualify(ns, pattern)
+	}
 	states, err := s.Topics.TopicStates(pattern, minLevel)
 	if err != nil {
 		httpd.HttpError(w, fmt.Sprint("failed to get topic states: ", err.Error()), true, http.StatusInternalServerError)
@@ -126,12 +137,16 @@ func (s *apiServer) handleListTopics(w http.ResponseWriter, r *http.Request) {
 	}
 	list := make([]client.Topic, 0, len(states))
 	for topic, state := range states {
-		list = append(list, s.createClientTopic(topic, state))
+		// The topics within namespaces are not listed outside of them.
+		if ns == "" && namespace.Qualified(topic) {
+			continue
+		}
+		list = append(list, s.createClientTopic(ns, topic, state))
 	}
 	sort.Sort(sortedTopics(list))
 
 	topics := client.Topics{
-		Link:   client.Link{Relation: client.Self, Href: r.URL.String()},
+		Link:   client.Link{Relation: client.Self, Href: httpd.NamespacedPath(ns, r.URL.String())},
 		Topics: list,
 	}
 
@@ -148,6 +163,17 @@ func (s *apiServer) topicIDFromPath(p string) (id string) {
 	return
 }
 
+// qualifiedTopic returns the qualified ID of the topic within the namespace of the request.
+// It responds with 404 and reports false if the topic cannot be referenced from the request,
+// i.e. a qualified topic outside a namespace.
+func (s *apiServer) qualifiedTopic(w http.ResponseWriter, r *http.Request, topic string) (string, bool) {
+	qualified, ok := namespace.Resolve(httpd.RequestNamespace(r), topic)
+	if !ok {
+		httpd.HttpError(w, fmt.Sprintf("unknown topic: %q", topic), true, http.StatusNotFound)
+	}
+	return qualified, ok
+}
+
 func (s *apiServer) handlerIDFromPath(p string) (id string) {
 	return path.Base(p)
 }
@@ -171,7 +197,10 @@ func (s *apiServer) handleDeleteTopic(topic string, w http.ResponseWriter, r *ht
 
 func (s *apiServer) handleRouteTopicGet(w http.ResponseWriter, r *http.Request) {
 	p := strings.TrimPrefix(r.URL.Path, topicsBasePathAnchored)
-	id := s.topicIDFromPath(p)
+	id, ok := s.qualifiedTopic(w, r, s.topicIDFromPath(p))
+	if !ok {
+		return
+	}
 
 	switch {
 	case pathMatch(eventsPattern, p):
@@ -189,59 +218,77 @@ func (s *apiServer) handleRouteTopicGet(w http.ResponseWriter, r *http.Request)
 	}
 }
 
-func (s *apiServer) handleRouteTopicPost(w http.ResponseWriter, r *http.Request) {
+func (s *apiServer) handleRouteTopicPost(w http.ResponseWriter, r *http.Request, user auth.User) {
 	p := strings.TrimPrefix(r.URL.Path, topicsBasePathAnchored)
-	topic := s.topicIDFromPath(p)
-	s.handleCreateHandler(topic, w, r)
+	topic, ok := s.qualifiedTopic(w, r, s.topicIDFromPath(p))
+	if !ok {
+		return
+	}
+	s.handleCreateHandler(topic, w, r, user)
 }
 
-func (s *apiServer) handleRouteTopicPut(w http.ResponseWriter, r *http.Request) {
+func (s *apiServer) handleRouteTopicPut(w http.ResponseWriter, r *http.Request, user auth.User) {
 	p := strings.TrimPrefix(r.URL.Path, topicsBasePathAnchored)
-	topic := s.topicIDFromPath(p)
+	topic, ok := s.qualifiedTopic(w, r, s.topicIDFromPath(p))
+	if !ok {
+		return
+	}
 	handler := s.handlerIDFromPath(p)
-	s.handlePutHandler(topic, handler, w, r)
+	s.handlePutHandler(topic, handler, w, r, user)
 }
-func (s *apiServer) handleRouteTopicPatch(w http.ResponseWriter, r *http.Request) {
+func (s *apiServer) handleRouteTopicPatch(w http.ResponseWriter, r *http.Request, user auth.User) {
 	p := strings.TrimPrefix(r.URL.Path, topicsBasePathAnchored)
-	topic := s.topicIDFromPath(p)
+	topic, ok := s.qualifiedTopic(w, r, s.topicIDFromPath(p))
+	if !ok {
+		return
+	}
 	handler := s.handlerIDFromPath(p)
-	s.handlePatchHandler(topic, handler, w, r)
+	s.handlePatchHandler(topic, handler, w, r, user)
 }
 func (s *apiServer) handleRouteTopicDelete(w http.ResponseWriter, r *http.Request) {
 	p := strings.TrimPrefix(r.URL.Path, topicsBasePathAnchored)
-	topic := s.topicIDFromPath(p)
+	topic, ok := s.qualifiedTopic(w, r, s.topicIDFromPath(p))
+	if !ok {
+		return
+	}
 	handler := s.handlerIDFromPath(p)
-	if topic == handler {
+	if s.topicIDFromPath(p) == handler {
 		s.handleDeleteTopic(topic, w, r)
 	} else {
 		s.handleDeleteHandler(topic, handler, w, r)
 	}
 }
 
-func (s *apiServer) topicLink(id string) client.Link {
-	return client.Link{Relation: client.Self, Href: path.Join(topicsBasePath, id)}
+// topicPath returns the path of the qualified topic within the namespace.
+func (s *apiServer) topicPath(ns, topic string, elem ...string) string {
+	p := path.Join(topicsBasePath, namespace.Unqualify(ns, topic), path.Join(elem...))
+	return httpd.NamespacedPath(ns, p)
+}
+
+func (s *apiServer) topicLink(ns, id string) client.Link {
+	return client.Link{Relation: client.Self, Href: s.topicPath(ns, id)}
 }
-func (s *apiServer) topicEventsLink(id string, r client.Relation) client.Link {
-	return client.Link{Relation: r, Href: path.Join(topicsBasePath, id, topicEventsPath)}
+func (s *apiServer) topicEventsLink(ns, id string, r client.Relation) client.Link {
+	return client.Link{Relation: r, Href: s.topicPath(ns, id, topicEventsPath)}
 }
-func (s *apiServer) topicEventLink(topic, event string) client.Link {
-	return client.Link{Relation: client.Self, Href: path.Join(topicsBasePath, topic, topicEventsPath, event)}
+func (s *apiServer) topicEventLink(ns, topic, event string) client.Link {
+	return client.Link{Relation: client.Self, Href: s.topicPath(ns, topic, topicEventsPath, event)}
 }
-func (s *apiServer) topicHandlersLink(id string, r client.Relation) client.Link {
-	return client.Link{Relation: r, Href: path.Join(topicsBasePath, id, topicHandlersPath)}
+func (s *apiServer) topicHandlersLink(ns, id string, r client.Relation) client.Link {
+	return client.Link{Relation: r, Href: s.topicPath(ns, id, topicHandlersPath)}
 }
-func (s *apiServer) topicHandlerLink(topic, handler string) client.Link {
-	return client.Link{Relation: client.Self, Href: path.Join(topicsBasePath, topic, topicHandlersPath, handler)}
+func (s *apiServer) topicHandlerLink(ns, topic, handler string) client.Link {
+	return client.Link{Relation: client.Self, Href: s.topicPath(ns, topic, topicHandlersPath, handler)}
 }
 
-func (s *apiServer) createClientTopic(topic string, state alert.TopicState) client.Topic {
+func (s *apiServer) createClientTopic(ns, topic string, state alert.TopicState) client.Topic {
 	return client.Topic{
-		ID:           topic,
-		Link:         s.topicLink(topic),
+		ID:           namespace.Unqualify(ns, topic),
+		Link:         s.topicLink(ns, topic),
 		Level:        state.Level.String(),
 		Collected:    state.Collected,
-		EventsLink:   s.topicEventsLink(topic, eventsRelation),
-		HandlersLink: s.topicHandlersLink(topic, handlersRelation),
+		EventsLink:   s.topicEventsLink(ns, topic, eventsRelation),
+		HandlersLink: s.topicHandlersLink(ns, topic, handlersRelation),
 	}
 }
 
@@ -255,7 +302,7 @@ func (s *apiServer) handleGetTopic(id string, w http.ResponseWriter, r *http.Req
 		httpd.HttpError(w, fmt.Sprintf("unknown topic: %q", id), true, http.StatusNotFound)
 		return
 	}
-	topic := s.createClientTopic(id, state)
+	topic := s.createClientTopic(httpd.RequestNamespace(r), id, state)
 
 	w.WriteHeader(http.StatusOK)
 	w.Write(httpd.MarshalJSON(topic, true))
@@ -271,9 +318,9 @@ func (s *apiServer) convertEventStateToClient(state alert.EventState) client.Eve
 	}
 }
 
-func (s *apiServer) convertHandlerSpec(spec HandlerSpec) client.TopicHandler {
+func (s *apiServer) convertHandlerSpec(ns string, spec HandlerSpec) client.TopicHandler {
 	return client.TopicHandler{
-		Link:    s.topicHandlerLink(spec.Topic, spec.ID),
+		Link:    s.topicHandlerLink(ns, spec.Topic, spec.ID),
 		ID:      spec.ID,
 		Kind:    spec.Kind,
 		Options: spec.Options,
@@ -293,14 +340,15 @@ func (s *apiServer) handleListEvents(topic string, w http.ResponseWriter, r *htt
 		httpd.HttpError(w, fmt.Sprintf("failed to get topic events: %s", err.Error()), true, http.StatusInternalServerError)
 		return
 	}
+	ns := httpd.RequestNamespace(r)
 	res := client.TopicEvents{
-		Link:   s.topicEventsLink(topic, client.Self),
-		Topic:  topic,
+		Link:   s.topicEventsLink(ns, topic, client.Self),
+		Topic:  namespace.Unqualify(ns, topic),
 		Events: make([]client.TopicEvent, 0, len(events)),
 	}
 	for id, state := range events {
 		res.Events = append(res.Events, client.TopicEvent{
-			Link:  s.topicEventLink(topic, id),
+			Link:  s.topicEventLink(ns, topic, id),
 			ID:    id,
 			State: s.convertEventStateToClient(state),
 		})
@@ -320,7 +368,7 @@ func (s *apiServer) handleGetEvent(topic, eventID string, w http.ResponseWriter,
 		return
 	}
 	event := client.TopicEvent{
-		Link:  s.topicEventLink(topic, eventID),
+		Link:  s.topicEventLink(httpd.RequestNamespace(r), topic, eventID),
 		ID:    eventID,
 		State: s.convertEventStateToClient(state),
 	}
@@ -340,14 +388,15 @@ func (s *apiServer) handleListHandlers(topic string, w http.ResponseWriter, r *h
 		return
 	}
 
+	ns := httpd.RequestNamespace(r)
 	handlers := make([]client.TopicHandler, len(specs))
 	for i, spec := range specs {
-		handlers[i] = s.convertHandlerSpec(spec)
+		handlers[i] = s.convertHandlerSpec(ns, spec)
 	}
 	sort.Sort(sortedHandlers(handlers))
 	th := client.TopicHandlers{
-		Link:     client.Link{Relation: client.Self, Href: r.URL.String()},
-		Topic:    topic,
+		Link:     client.Link{Relation: client.Self, Href: httpd.NamespacedPath(ns, r.URL.String())},
+		Topic:    namespace.Unqualify(ns, topic),
 		Handlers: handlers,
 	}
 	w.WriteHeader(http.StatusOK)
@@ -370,7 +419,7 @@ func (s *apiServer) handlerSpecFromJSON(topic string, r io.Reader) (HandlerSpec,
 	return handlerSpec, nil
 }
 
-func (s *apiServer) handleCreateHandler(topic string, w http.ResponseWriter, r *http.Request) {
+func (s *apiServer) handleCreateHandler(topic string, w http.ResponseWriter, r *http.Request, user auth.User) {
 	handlerSpec, err := s.handlerSpecFromJSON(topic, r.Body)
 	if err != nil {
 		httpd.HttpError(w, fmt.Sprint("invalid handler json: ", err.Error()), true, http.StatusBadRequest)
@@ -380,6 +429,10 @@ func (s *apiServer) handleCreateHandler(topic string, w http.ResponseWriter, r *
 		httpd.HttpError(w, fmt.Sprint("invalid handler spec: ", err.Error()), true, http.StatusBadRequest)
 		return
 	}
+	if err := secrets.Authorize(user, handlerSpec.Options, nil); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusForbidden)
+		return
+	}
 
 	err = s.Registrar.RegisterHandlerSpec(handlerSpec)
 	if err != nil {
@@ -387,12 +440,12 @@ func (s *apiServer) handleCreateHandler(topic string, w http.ResponseWriter, r *
 		return
 	}
 
-	h := s.convertHandlerSpec(handlerSpec)
+	h := s.convertHandlerSpec(httpd.RequestNamespace(r), handlerSpec)
 	w.WriteHeader(http.StatusOK)
 	w.Write(httpd.MarshalJSON(h, true))
 }
 
-func (s *apiServer) handlePatchHandler(topic, handler string, w http.ResponseWriter, r *http.Request) {
+func (s *apiServer) handlePatchHandler(topic, handler string, w http.ResponseWriter, r *http.Request, user auth.User) {
 	spec, ok, err := s.Registrar.HandlerSpec(topic, handler)
 	if err != nil {
 		httpd.HttpError(w, fmt.Sprintf("failed to get handler %q: %v", handler, err), true, http.StatusInternalServerError)
@@ -434,19 +487,24 @@ func (s *apiServer) handlePatchHandler(topic, handler string, w http.ResponseWri
 		httpd.HttpError(w, fmt.Sprint("invalid handler spec: ", err.Error()), true, http.StatusBadRequest)
 		return
 	}
+	// Secrets already referenced by the handler may be kept by users who cannot read them.
+	if err := secrets.Authorize(user, newSpec.Options, spec.Options); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusForbidden)
+		return
+	}
 
 	if err := s.Registrar.UpdateHandlerSpec(spec, newSpec); err != nil {
 		httpd.HttpError(w, fmt.Sprint("failed to update handler: ", err.Error()), true, http.StatusInternalServerError)
 		return
 	}
 
-	ch := s.convertHandlerSpec(newSpec)
+	ch := s.convertHandlerSpec(httpd.RequestNamespace(r), newSpec)
 
 	w.WriteHeader(http.StatusOK)
 	w.Write(httpd.MarshalJSON(ch, true))
 }
 
-func (s *apiServer) handlePutHandler(topic, handler string, w http.ResponseWriter, r *http.Request) {
+func (s *apiServer) handlePutHandler(topic, handler string, w http.ResponseWriter, r *http.Request, user auth.User) {
 	spec, ok, err := s.Registrar.HandlerSpec(topic, handler)
 	if err != nil {
 		httpd.HttpError(w, fmt.Sprintf("failed to get handler %q: %v", handler, err), true, http.StatusInternalServerError)
@@ -466,13 +524,18 @@ func (s *apiServer) handlePutHandler(topic, handler string, w http.ResponseWrite
 		httpd.HttpError(w, fmt.Sprint("invalid handler spec: ", err.Error()), true, http.StatusBadRequest)
 		return
 	}
+	// Secrets already referenced by the handler may be kept by users who cannot read them.
+	if err := secrets.Authorize(user, newSpec.Options, spec.Options); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusForbidden)
+		return
+	}
 
 	if err := s.Registrar.UpdateHandlerSpec(spec, newSpec); err != nil {
 		httpd.HttpError(w, fmt.Sprint("failed to update handler: ", err.Error()), true, http.StatusInternalServerError)
 		return
 	}
 
-	ch := s.convertHandlerSpec(newSpec)
+	ch := s.convertHandlerSpec(httpd.RequestNamespace(r), newSpec)
 
 	w.WriteHeader(http.StatusOK)
 	w.Write(httpd.MarshalJSON(ch, true))
@@ -497,7 +560,7 @@ func (s *apiServer) handleGetHandler(topic, handler string, w http.ResponseWrite
 		return
 	}
 
-	h := s.convertHandlerSpec(spec)
+	h := s.convertHandlerSpec(httpd.RequestNamespace(r), spec)
 
 	w.WriteHeader(http.StatusOK)
 	w.Write(httpd.MarshalJSON(h, true))
@@ -72,7 +72,9 @@ type HandlerSpec struct {
 }
 
 var validHandlerID = regexp.MustCompile(`^[-\._\p{L}0-9]+$`)
-var validTopicID = regexp.MustCompile(`^[-:\._\p{L}0-9]+$`)
+
+// Topic IDs may be qualified with a namespace, i.e. cpu@team-a.
+var validTopicID = regexp.MustCompile(`^[-:\._\p{L}0-9]+(@[-\._\p{L}0-9]+)?$`)
 
 func (h HandlerSpec) Validate() error {
 	if !validTopicID.MatchString(h.Topic) {
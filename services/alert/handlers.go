@@ -409,7 +409,7 @@ func newMatchHandler(match string, h alert.Handler, l *log.Logger) (*matchHandle
 		h:      h,
 		expr:   expr,
 		scope:  stateful.NewScope(),
-		vars:   ast.FindReferenceVariables(lambda),
+		vars:   stateful.FindReferenceVariables(lambda),
 		logger: l,
 	}
 
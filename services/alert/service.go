@@ -8,6 +8,8 @@ import (
 	"path"
 	"reflect"
 	"regexp"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/influxdata/kapacitor/alert"
@@ -17,9 +19,11 @@ import (
 	"github.com/influxdata/kapacitor/services/httpd"
 	"github.com/influxdata/kapacitor/services/httppost"
 	"github.com/influxdata/kapacitor/services/mqtt"
+	"github.com/influxdata/kapacitor/services/namespace"
 	"github.com/influxdata/kapacitor/services/opsgenie"
 	"github.com/influxdata/kapacitor/services/pagerduty"
 	"github.com/influxdata/kapacitor/services/pushover"
+	"github.com/influxdata/kapacitor/services/secrets"
 	"github.com/influxdata/kapacitor/services/sensu"
 	"github.com/influxdata/kapacitor/services/slack"
 	"github.com/influxdata/kapacitor/services/smtp"
@@ -59,6 +63,16 @@ type Service struct {
 
 	Commander command.Commander
 
+	SecretsService interface {
+		ResolveOptions(options map[string]interface{}) (map[string]interface{}, error)
+	}
+
+	// NamespaceQuotas enforces the quotas of the namespaces of the topics.
+	NamespaceQuotas interface {
+		AllowAlertEvent(namespace string) bool
+		AllowHandlerInvocation(namespace string) bool
+	}
+
 	logger *log.Logger
 
 	AlertaService interface {
@@ -120,6 +134,7 @@ func NewService(l *log.Logger) *Service {
 		logger:    l,
 	}
 	s.EventCollector = s
+	s.topics.AllowHandle = s.allowHandle
 	return s
 }
 
@@ -399,7 +414,20 @@ func (s *Service) setTopicHandler(topic, id string, h handler) {
 	s.handlers[topic][id] = h
 }
 
+// allowHandle reports whether the handler invocation quota of the namespace of the topic allows another invocation.
+func (s *Service) allowHandle(topic string) bool {
+	if s.NamespaceQuotas == nil {
+		return true
+	}
+	ns, _ := namespace.Split(topic)
+	return ns == "" || s.NamespaceQuotas.AllowHandlerInvocation(ns)
+}
+
 func (s *Service) Collect(event alert.Event) error {
+	if ns, _ := namespace.Split(event.Topic); ns != "" && s.NamespaceQuotas != nil && !s.NamespaceQuotas.AllowAlertEvent(ns) {
+		// The namespace exceeded its quota, drop the event.
+		return nil
+	}
 	s.mu.RLock()
 	closed := s.closedTopics[event.Topic]
 	s.mu.RUnlock()
@@ -610,6 +638,34 @@ func (s *Service) UpdateHandlerSpec(oldSpec, newSpec HandlerSpec) error {
 	return nil
 }
 
+// ReloadHandlerSpecs recreates all handlers whose options reference any of the named secrets.
+// Handlers that fail to reload keep their previous options and do not prevent reloading the others.
+func (s *Service) ReloadHandlerSpecs(names []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var failed []string
+	for topic, handlers := range s.handlers {
+		for id, oldH := range handlers {
+			if !secrets.Referenced(oldH.Spec.Options, names) {
+				continue
+			}
+			newH, err := s.createHandlerFromSpec(oldH.Spec)
+			if err != nil {
+				failed = append(failed, fmt.Sprintf("handler %s of topic %s: %v", id, topic, err))
+				continue
+			}
+			handlers[id] = newH
+			s.topics.ReplaceHandler(topic, oldH.Handler, newH.Handler)
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("failed to reload %d handlers: %s", len(failed), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
 // TopicState returns the state for the specified topic.
 func (s *Service) TopicState(topic string) (alert.TopicState, bool, error) {
 	t, ok := s.topics.Topic(topic)
@@ -645,6 +701,26 @@ func (s *Service) EventStates(topic string, minLevel alert.Level) (map[string]al
 	return t.EventStates(minLevel), nil
 }
 
+// NamespaceResources returns the topics and handlers within the namespace.
+func (s *Service) NamespaceResources(ns string) ([]string, error) {
+	pattern := namespace.Qualify(ns, "*")
+	var resources []string
+	for topic := range s.topics.TopicState(pattern, alert.OK) {
+		resources = append(resources, "topic "+namespace.Unqualify(ns, topic))
+	}
+	s.mu.RLock()
+	for topic, handlers := range s.handlers {
+		if !alert.PatternMatch(pattern, topic) {
+			continue
+		}
+		for id := range handlers {
+			resources = append(resources, fmt.Sprintf("handler %s of topic %s", id, namespace.Unqualify(ns, topic)))
+		}
+	}
+	s.mu.RUnlock()
+	return resources, nil
+}
+
 func (s *Service) HandlerSpec(topic, handler string) (HandlerSpec, bool, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -717,10 +793,18 @@ func decodeStringToTextUnmarshaler(f, t reflect.Type, data interface{}) (interfa
 func (s *Service) createHandlerFromSpec(spec HandlerSpec) (handler, error) {
 	var h alert.Handler
 	var err error
+	// Resolve secrets into a copy of the options, the spec keeps the references.
+	options := spec.Options
+	if s.SecretsService != nil {
+		options, err = s.SecretsService.ResolveOptions(spec.Options)
+		if err != nil {
+			return handler{}, errors.Wrapf(err, "failed to resolve secrets of handler %s", spec.ID)
+		}
+	}
 	switch spec.Kind {
 	case "aggregate":
 		c := newDefaultAggregateHandlerConfig(s.EventCollector)
-		err = decodeOptions(spec.Options, &c)
+		err = decodeOptions(options, &c)
 		if err != nil {
 			return handler{}, err
 		}
@@ -730,7 +814,7 @@ func (s *Service) createHandlerFromSpec(spec HandlerSpec) (handler, error) {
 		}
 	case "alerta":
 		c := s.AlertaService.DefaultHandlerConfig()
-		err = decodeOptions(spec.Options, &c)
+		err = decodeOptions(options, &c)
 		if err != nil {
 			return handler{}, err
 		}
@@ -743,7 +827,7 @@ func (s *Service) createHandlerFromSpec(spec HandlerSpec) (handler, error) {
 		c := ExecHandlerConfig{
 			Commander: s.Commander,
 		}
-		err = decodeOptions(spec.Options, &c)
+		err = decodeOptions(options, &c)
 		if err != nil {
 			return handler{}, err
 		}
@@ -751,7 +835,7 @@ func (s *Service) createHandlerFromSpec(spec HandlerSpec) (handler, error) {
 		h = newExternalHandler(h)
 	case "hipchat":
 		c := hipchat.HandlerConfig{}
-		err = decodeOptions(spec.Options, &c)
+		err = decodeOptions(options, &c)
 		if err != nil {
 			return handler{}, err
 		}
@@ -759,7 +843,7 @@ func (s *Service) createHandlerFromSpec(spec HandlerSpec) (handler, error) {
 		h = newExternalHandler(h)
 	case "log":
 		c := DefaultLogHandlerConfig()
-		err = decodeOptions(spec.Options, &c)
+		err = decodeOptions(options, &c)
 		if err != nil {
 			return handler{}, err
 		}
@@ -770,7 +854,7 @@ func (s *Service) createHandlerFromSpec(spec HandlerSpec) (handler, error) {
 		h = newExternalHandler(h)
 	case "mqtt":
 		c := mqtt.HandlerConfig{}
-		err = decodeOptions(spec.Options, &c)
+		err = decodeOptions(options, &c)
 		if err != nil {
 			return handler{}, err
 		}
@@ -778,7 +862,7 @@ func (s *Service) createHandlerFromSpec(spec HandlerSpec) (handler, error) {
 		h = newExternalHandler(h)
 	case "opsgenie":
 		c := opsgenie.HandlerConfig{}
-		err = decodeOptions(spec.Options, &c)
+		err = decodeOptions(options, &c)
 		if err != nil {
 			return handler{}, err
 		}
@@ -786,7 +870,7 @@ func (s *Service) createHandlerFromSpec(spec HandlerSpec) (handler, error) {
 		h = newExternalHandler(h)
 	case "pagerduty":
 		c := pagerduty.HandlerConfig{}
-		err = decodeOptions(spec.Options, &c)
+		err = decodeOptions(options, &c)
 		if err != nil {
 			return handler{}, err
 		}
@@ -794,7 +878,7 @@ func (s *Service) createHandlerFromSpec(spec HandlerSpec) (handler, error) {
 		h = newExternalHandler(h)
 	case "pushover":
 		c := pushover.HandlerConfig{}
-		err = decodeOptions(spec.Options, &c)
+		err = decodeOptions(options, &c)
 		if err != nil {
 			return handler{}, err
 		}
@@ -802,7 +886,7 @@ func (s *Service) createHandlerFromSpec(spec HandlerSpec) (handler, error) {
 		h = newExternalHandler(h)
 	case "post":
 		c := httppost.HandlerConfig{}
-		err = decodeOptions(spec.Options, &c)
+		err = decodeOptions(options, &c)
 		if err != nil {
 			return handler{}, err
 		}
@@ -812,14 +896,22 @@ func (s *Service) createHandlerFromSpec(spec HandlerSpec) (handler, error) {
 		c := PublishHandlerConfig{
 			ec: s.EventCollector,
 		}
-		err = decodeOptions(spec.Options, &c)
+		err = decodeOptions(options, &c)
 		if err != nil {
 			return handler{}, err
 		}
+		// Only publish to the topics of the namespace of the handler.
+		ns, _ := namespace.Split(spec.Topic)
+		for i, topic := range c.Topics {
+			if namespace.Qualified(topic) {
+				return handler{}, fmt.Errorf("publish topic %q must not contain %q", topic, namespace.Separator)
+			}
+			c.Topics[i] = namespace.Qualify(ns, topic)
+		}
 		h = NewPublishHandler(c, s.logger)
 	case "sensu":
 		c := sensu.HandlerConfig{}
-		err = decodeOptions(spec.Options, &c)
+		err = decodeOptions(options, &c)
 		if err != nil {
 			return handler{}, err
 		}
@@ -830,7 +922,7 @@ func (s *Service) createHandlerFromSpec(spec HandlerSpec) (handler, error) {
 		h = newExternalHandler(h)
 	case "slack":
 		c := slack.HandlerConfig{}
-		err = decodeOptions(spec.Options, &c)
+		err = decodeOptions(options, &c)
 		if err != nil {
 			return handler{}, err
 		}
@@ -838,7 +930,7 @@ func (s *Service) createHandlerFromSpec(spec HandlerSpec) (handler, error) {
 		h = newExternalHandler(h)
 	case "smtp":
 		c := smtp.HandlerConfig{}
-		err = decodeOptions(spec.Options, &c)
+		err = decodeOptions(options, &c)
 		if err != nil {
 			return handler{}, err
 		}
@@ -846,7 +938,7 @@ func (s *Service) createHandlerFromSpec(spec HandlerSpec) (handler, error) {
 		h = newExternalHandler(h)
 	case "snmptrap":
 		c := snmptrap.HandlerConfig{}
-		err = decodeOptions(spec.Options, &c)
+		err = decodeOptions(options, &c)
 		if err != nil {
 			return handler{}, err
 		}
@@ -860,7 +952,7 @@ func (s *Service) createHandlerFromSpec(spec HandlerSpec) (handler, error) {
 		h = newExternalHandler(h)
 	case "tcp":
 		c := TCPHandlerConfig{}
-		err = decodeOptions(spec.Options, &c)
+		err = decodeOptions(options, &c)
 		if err != nil {
 			return handler{}, err
 		}
@@ -868,7 +960,7 @@ func (s *Service) createHandlerFromSpec(spec HandlerSpec) (handler, error) {
 		h = newExternalHandler(h)
 	case "telegram":
 		c := telegram.HandlerConfig{}
-		err = decodeOptions(spec.Options, &c)
+		err = decodeOptions(options, &c)
 		if err != nil {
 			return handler{}, err
 		}
@@ -876,7 +968,7 @@ func (s *Service) createHandlerFromSpec(spec HandlerSpec) (handler, error) {
 		h = newExternalHandler(h)
 	case "victorops":
 		c := victorops.HandlerConfig{}
-		err = decodeOptions(spec.Options, &c)
+		err = decodeOptions(options, &c)
 		if err != nil {
 			return handler{}, err
 		}
@@ -0,0 +1,65 @@
+package audit
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultPath            = "/var/log/kapacitor/audit.log"
+	DefaultMaxSize         = 100 * 1024 * 1024
+	DefaultMaxBackups      = 5
+	DefaultDatabase        = "_kapacitor"
+	DefaultRetentionPolicy = "autogen"
+	DefaultMeasurement     = "audit"
+)
+
+type Config struct {
+	Enabled bool `toml:"enabled"`
+	// Path of the audit log file.
+	Path string `toml:"path"`
+	// Size in bytes after which the audit log file is rotated.
+	MaxSize int64 `toml:"max-size"`
+	// Number of rotated audit log files to keep.
+	MaxBackups int `toml:"max-backups"`
+
+	// Also write the audit records into the Kapacitor stream.
+	StreamEnabled   bool   `toml:"stream-enabled"`
+	Database        string `toml:"database"`
+	RetentionPolicy string `toml:"retention-policy"`
+	Measurement     string `toml:"measurement"`
+}
+
+func NewConfig() Config {
+	return Config{
+		Path:            DefaultPath,
+		MaxSize:         DefaultMaxSize,
+		MaxBackups:      DefaultMaxBackups,
+		Database:        DefaultDatabase,
+		RetentionPolicy: DefaultRetentionPolicy,
+		Measurement:     DefaultMeasurement,
+	}
+}
+
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Path == "" {
+		return errors.New("must specify audit log path")
+	}
+	if c.MaxSize <= 0 {
+		return errors.New("max-size must be positive")
+	}
+	if c.MaxBackups < 0 {
+		return errors.New("max-backups must not be negative")
+	}
+	if c.StreamEnabled {
+		if c.Database == "" || c.RetentionPolicy == "" {
+			return errors.New("must specify database and retention-policy of the audit stream")
+		}
+		if c.Measurement == "" {
+			return errors.New("must specify measurement of the audit stream")
+		}
+	}
+	return nil
+}
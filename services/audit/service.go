@@ -0,0 +1,401 @@
+// The audit service records all mutating API calls, including those that fail to authenticate.
+//
+// Records are appended as JSON lines to a local file, which is rotated once it reaches its maximum size.
+// The records can be queried via the /kapacitor/v1/audit API
+// and optionally written into the Kapacitor stream so that tasks can alert on them.
+//
+// Example:
+//
+//	stream
+//	    |from()
+//	        .measurement('audit')
+//	        .where(lambda: "result" == 'denied')
+//	    |alert()
+//	        .crit(lambda: TRUE)
+//	        .message('{{ index .Tags "user" }} was denied {{ index .Fields "resource" }}')
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/kapacitor"
+	client "github.com/influxdata/kapacitor/client/v1"
+	"github.com/influxdata/kapacitor/edge"
+	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/services/httpd"
+	"github.com/pkg/errors"
+)
+
+const (
+	auditPath = "/audit"
+
+	// Number of records buffered for the Kapacitor stream.
+	streamBufferSize = 1000
+)
+
+const (
+	SuccessResult = "success"
+	DeniedResult  = "denied"
+	FailureResult = "failure"
+)
+
+type Service struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	streamEnabled bool
+	db            string
+	rp            string
+	measurement   string
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	// Held for writing while the audit log is rotated, so that the files are not rotated while they are listed.
+	// The audit log is listed without holding mu, so that listing does not block recording.
+	rotateMu sync.RWMutex
+
+	stream  kapacitor.StreamCollector
+	pending chan client.AuditRecord
+	dropped bool
+	wg      sync.WaitGroup
+
+	routes []httpd.Route
+
+	HTTPDService interface {
+		AddRoutes([]httpd.Route) error
+		DelRoutes([]httpd.Route)
+	}
+	TaskMaster interface {
+		Stream(name string) (kapacitor.StreamCollector, error)
+	}
+
+	logger *log.Logger
+}
+
+func NewService(c Config, l *log.Logger) *Service {
+	return &Service{
+		path:          c.Path,
+		maxSize:       c.MaxSize,
+		maxBackups:    c.MaxBackups,
+		streamEnabled: c.StreamEnabled,
+		db:            c.Database,
+		rp:            c.RetentionPolicy,
+		measurement:   c.Measurement,
+		logger:        l,
+	}
+}
+
+func (s *Service) Open() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return errors.Wrap(err, "failed to create audit log directory")
+	}
+	if err := s.openFile(); err != nil {
+		return err
+	}
+
+	if s.streamEnabled {
+		stream, err := s.TaskMaster.Stream("audit")
+		if err != nil {
+			s.file.Close()
+			return err
+		}
+		s.stream = stream
+		s.pending = make(chan client.AuditRecord, streamBufferSize)
+		s.wg.Add(1)
+		go s.streamRecords()
+	}
+
+	s.routes = []httpd.Route{
+		{
+			Method:      "GET",
+			Pattern:     auditPath,
+			HandlerFunc: s.handleListRecords,
+		},
+	}
+	return s.HTTPDService.AddRoutes(s.routes)
+}
+
+func (s *Service) Close() error {
+	if s.HTTPDService != nil {
+		s.HTTPDService.DelRoutes(s.routes)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending != nil {
+		close(s.pending)
+		s.wg.Wait()
+		s.stream.Close()
+		s.pending = nil
+	}
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *Service) openFile() error {
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to open audit log")
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.Wrap(err, "failed to stat audit log")
+	}
+	s.file = f
+	s.size = fi.Size()
+	return nil
+}
+
+// rotate moves the current audit log to the first backup,
+// shifting the existing backups and removing the oldest one.
+func (s *Service) rotate() error {
+	s.rotateMu.Lock()
+	defer s.rotateMu.Unlock()
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+	if s.maxBackups == 0 {
+		if err := os.Remove(s.path); err != nil {
+			return err
+		}
+	} else {
+		for i := s.maxBackups - 1; i > 0; i-- {
+			if err := os.Rename(s.backupPath(i), s.backupPath(i+1)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := os.Rename(s.path, s.backupPath(1)); err != nil {
+			return err
+		}
+	}
+	return s.openFile()
+}
+
+func (s *Service) backupPath(i int) string {
+	return s.path + "." + strconv.Itoa(i)
+}
+
+// Audit records the API call.
+func (s *Service) Audit(e httpd.AuditEntry) {
+	r := client.AuditRecord{
+		Time:       e.Time,
+		User:       e.User,
+		Method:     e.Method,
+		Action:     e.Privilege.String(),
+		Resource:   e.Resource,
+		BodyDigest: e.BodyDigest,
+		Status:     e.Status,
+		Result:     result(e.Status),
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		s.logger.Println("E! failed to encode audit record:", err)
+		return
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return
+	}
+	if s.size > 0 && s.size+int64(len(data)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			s.logger.Println("E! failed to rotate audit log:", err)
+			if s.file == nil {
+				return
+			}
+		}
+	}
+	n, err := s.file.Write(data)
+	s.size += int64(n)
+	if err != nil {
+		s.logger.Println("E! failed to write audit record:", err)
+	}
+
+	if s.pending != nil {
+		select {
+		case s.pending <- r:
+			s.dropped = false
+		default:
+			// Only log the first dropped record to avoid flooding the log.
+			if !s.dropped {
+				s.logger.Println("W! audit stream is full, dropping records")
+				s.dropped = true
+			}
+		}
+	}
+}
+
+func result(status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return DeniedResult
+	case status >= 400:
+		return FailureResult
+	default:
+		return SuccessResult
+	}
+}
+
+func (s *Service) streamRecords() {
+	defer s.wg.Done()
+	for r := range s.pending {
+		p := edge.NewPointMessage(
+			s.measurement,
+			s.db,
+			s.rp,
+			models.Dimensions{},
+			models.Fields{
+				"resource":    r.Resource,
+				"status":      int64(r.Status),
+				"body_digest": r.BodyDigest,
+			},
+			models.Tags{
+				"user":   r.User,
+				"method": r.Method,
+				"action": r.Action,
+				"result": r.Result,
+			},
+			r.Time,
+		)
+		if err := s.stream.CollectPoint(p); err != nil {
+			s.logger.Println("E! failed to write audit record to stream:", err)
+		}
+	}
+}
+
+type recordFilter struct {
+	user     string
+	resource string
+	start    time.Time
+	stop     time.Time
+}
+
+func (f recordFilter) match(r client.AuditRecord) bool {
+	if f.user != "" && r.User != f.user {
+		return false
+	}
+	if f.resource != "" && r.Resource != f.resource && !strings.HasPrefix(r.Resource, strings.TrimSuffix(f.resource, "/")+"/") {
+		return false
+	}
+	if !f.start.IsZero() && r.Time.Before(f.start) {
+		return false
+	}
+	if !f.stop.IsZero() && !r.Time.Before(f.stop) {
+		return false
+	}
+	return true
+}
+
+// list returns the matching records newest first.
+// Records being written to the audit log are skipped as incomplete records.
+func (s *Service) list(f recordFilter, offset, limit int) ([]client.AuditRecord, error) {
+	s.rotateMu.RLock()
+	defer s.rotateMu.RUnlock()
+	records := make([]client.AuditRecord, 0)
+	for i := 0; i <= s.maxBackups && len(records) < limit; i++ {
+		p := s.path
+		if i > 0 {
+			p = s.backupPath(i)
+		}
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			if os.IsNotExist(err) {
+				break
+			}
+			return nil, err
+		}
+		lines := bytes.Split(data, []byte{'\n'})
+		for j := len(lines) - 1; j >= 0 && len(records) < limit; j-- {
+			var r client.AuditRecord
+			if err := json.Unmarshal(lines[j], &r); err != nil {
+				// Skip incomplete records
+				continue
+			}
+			if !f.match(r) {
+				continue
+			}
+			if offset > 0 {
+				offset--
+				continue
+			}
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+func (s *Service) handleListRecords(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := recordFilter{
+		user:     q.Get("user"),
+		resource: q.Get("resource"),
+	}
+	var err error
+	if start := q.Get("start"); start != "" {
+		f.start, err = time.Parse(time.RFC3339Nano, start)
+		if err != nil {
+			httpd.HttpError(w, fmt.Sprintf("invalid start parameter %q must be an RFC3339 time: %s", start, err), true, http.StatusBadRequest)
+			return
+		}
+	}
+	if stop := q.Get("stop"); stop != "" {
+		f.stop, err = time.Parse(time.RFC3339Nano, stop)
+		if err != nil {
+			httpd.HttpError(w, fmt.Sprintf("invalid stop parameter %q must be an RFC3339 time: %s", stop, err), true, http.StatusBadRequest)
+			return
+		}
+	}
+
+	offset := int64(0)
+	offsetStr := q.Get("offset")
+	if offsetStr != "" {
+		offset, err = strconv.ParseInt(offsetStr, 10, 64)
+		if err != nil || offset < 0 {
+			httpd.HttpError(w, fmt.Sprintf("invalid offset parameter %q must be a non-negative integer", offsetStr), true, http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := int64(100)
+	limitStr := q.Get("limit")
+	if limitStr != "" {
+		limit, err = strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || limit <= 0 {
+			httpd.HttpError(w, fmt.Sprintf("invalid limit parameter %q must be a positive integer", limitStr), true, http.StatusBadRequest)
+			return
+		}
+	}
+
+	records, err := s.list(f, int(offset), int(limit))
+	if err != nil {
+		httpd.HttpError(w, fmt.Sprint("failed to read audit log: ", err.Error()), true, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(httpd.MarshalJSON(client.AuditRecords{
+		Link:    client.Link{Relation: client.Self, Href: r.URL.String()},
+		Records: records,
+	}, true))
+}
@@ -0,0 +1,227 @@
+package audit_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/influxdata/kapacitor"
+	"github.com/influxdata/kapacitor/auth"
+	client "github.com/influxdata/kapacitor/client/v1"
+	"github.com/influxdata/kapacitor/edge"
+	"github.com/influxdata/kapacitor/services/audit"
+	"github.com/influxdata/kapacitor/services/httpd"
+	"github.com/influxdata/kapacitor/services/httpd/httpdtest"
+)
+
+type stream struct {
+	mu     sync.Mutex
+	points []edge.PointMessage
+}
+
+func (s *stream) CollectPoint(p edge.PointMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.points = append(s.points, p)
+	return nil
+}
+
+func (s *stream) Close() error {
+	return nil
+}
+
+type taskMaster struct {
+	stream *stream
+}
+
+func (tm taskMaster) Stream(name string) (kapacitor.StreamCollector, error) {
+	return tm.stream, nil
+}
+
+func OpenNewService(t *testing.T, c audit.Config) (*audit.Service, *client.Client, *httpdtest.Server, *stream) {
+	service := audit.NewService(c, log.New(os.Stderr, "[audit] ", log.LstdFlags))
+	server := httpdtest.NewServer(testing.Verbose())
+	server.Handler.AuditService = service
+	service.HTTPDService = server
+	st := new(stream)
+	service.TaskMaster = taskMaster{stream: st}
+	if err := service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	// Add a mutating route to audit.
+	if err := server.AddRoutes([]httpd.Route{
+		{
+			Method:  "POST",
+			Pattern: "/things/",
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == httpd.BasePath+"/things/bad" {
+					httpd.HttpError(w, "bad thing", true, http.StatusBadRequest)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	cli, err := client.New(client.Config{URL: server.Server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service, cli, server, st
+}
+
+func post(t *testing.T, server *httpdtest.Server, thing, body string) {
+	resp, err := http.Post(server.Server.URL+httpd.BasePath+"/things/"+thing, "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func digest(body string) string {
+	h := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(h[:])
+}
+
+func TestService_Audit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kapacitor-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := audit.NewConfig()
+	c.Enabled = true
+	c.Path = filepath.Join(dir, "audit.log")
+	c.StreamEnabled = true
+	service, cli, server, st := OpenNewService(t, c)
+	defer server.Close()
+
+	post(t, server, "a", `{"a":1}`)
+	post(t, server, "bad", `{"b":2}`)
+	// Reads are not audited.
+	if _, err := cli.ListAuditRecords(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := cli.ListAuditRecords(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := len(records.Records), 2; got != exp {
+		t.Fatalf("unexpected number of records got %d exp %d", got, exp)
+	}
+	// The test server does not require authentication and uses the admin user for all requests.
+	exp := []client.AuditRecord{
+		{
+			User:       auth.AdminUser.Name(),
+			Method:     "POST",
+			Action:     "write",
+			Resource:   "/api/things/bad",
+			BodyDigest: digest(`{"b":2}`),
+			Status:     http.StatusBadRequest,
+			Result:     audit.FailureResult,
+		},
+		{
+			User:       auth.AdminUser.Name(),
+			Method:     "POST",
+			Action:     "write",
+			Resource:   "/api/things/a",
+			BodyDigest: digest(`{"a":1}`),
+			Status:     http.StatusNoContent,
+			Result:     audit.SuccessResult,
+		},
+	}
+	for i, r := range records.Records {
+		if r.Time.IsZero() {
+			t.Errorf("record %d: expected time to be set", i)
+		}
+		r.Time = exp[i].Time
+		if r != exp[i] {
+			t.Errorf("record %d: unexpected record:\ngot\n%+v\nexp\n%+v", i, r, exp[i])
+		}
+	}
+
+	records, err = cli.ListAuditRecords(&client.ListAuditRecordsOptions{Resource: "/api/things/a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records.Records) != 1 || records.Records[0].Resource != "/api/things/a" {
+		t.Errorf("unexpected filtered records %v", records.Records)
+	}
+	records, err = cli.ListAuditRecords(&client.ListAuditRecordsOptions{Offset: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records.Records) != 1 || records.Records[0].Resource != "/api/things/a" {
+		t.Errorf("unexpected records with offset %v", records.Records)
+	}
+
+	// Closing the service flushes the stream.
+	if err := service.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := len(st.points), 2; got != exp {
+		t.Fatalf("unexpected number of stream points got %d exp %d", got, exp)
+	}
+	if got, exp := st.points[1].Tags()["result"], audit.FailureResult; got != exp {
+		t.Errorf("unexpected result tag got %s exp %s", got, exp)
+	}
+}
+
+func TestService_Rotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kapacitor-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := audit.NewConfig()
+	c.Enabled = true
+	c.Path = filepath.Join(dir, "audit.log")
+	// Each record exceeds the max size, so each record is written to its own file.
+	c.MaxSize = 10
+	c.MaxBackups = 2
+	service, cli, server, _ := OpenNewService(t, c)
+	defer server.Close()
+	defer service.Close()
+
+	for _, thing := range []string{"a", "b", "c", "d"} {
+		post(t, server, thing, "")
+	}
+	for _, name := range []string{"audit.log", "audit.log.1", "audit.log.2"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "audit.log.3")); !os.IsNotExist(err) {
+		t.Error("expected oldest audit log to be removed")
+	}
+
+	records, err := cli.ListAuditRecords(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resources []string
+	for _, r := range records.Records {
+		resources = append(resources, r.Resource)
+	}
+	exp := []string{"/api/things/d", "/api/things/c", "/api/things/b"}
+	if len(resources) != len(exp) {
+		t.Fatalf("unexpected resources got %v exp %v", resources, exp)
+	}
+	for i := range exp {
+		if resources[i] != exp[i] {
+			t.Errorf("unexpected resources got %v exp %v", resources, exp)
+			break
+		}
+	}
+}
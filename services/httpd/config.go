@@ -3,27 +3,55 @@ package httpd
 import (
 	"fmt"
 	"net"
+	"path"
 	"strconv"
 	"time"
 
 	"github.com/influxdata/influxdb/toml"
+	"github.com/influxdata/kapacitor/auth"
+	"github.com/influxdata/kapacitor/tlsconfig"
 	"github.com/pkg/errors"
 )
 
 const (
 	DefaultShutdownTimeout = toml.Duration(time.Second * 10)
+
+	DefaultHttpsClientIdentity = CommonNameIdentity
+
+	DefaultOIDCUsernameClaim   = "sub"
+	DefaultOIDCRoleClaim       = "groups"
+	DefaultOIDCRefreshInterval = toml.Duration(time.Hour)
 )
 
 type Config struct {
-	BindAddress      string        `toml:"bind-address"`
-	AuthEnabled      bool          `toml:"auth-enabled"`
-	LogEnabled       bool          `toml:"log-enabled"`
-	WriteTracing     bool          `toml:"write-tracing"`
-	PprofEnabled     bool          `toml:"pprof-enabled"`
-	HttpsEnabled     bool          `toml:"https-enabled"`
-	HttpsCertificate string        `toml:"https-certificate"`
-	ShutdownTimeout  toml.Duration `toml:"shutdown-timeout"`
-	SharedSecret     string        `toml:"shared-secret"`
+	BindAddress      string `toml:"bind-address"`
+	AuthEnabled      bool   `toml:"auth-enabled"`
+	LogEnabled       bool   `toml:"log-enabled"`
+	WriteTracing     bool   `toml:"write-tracing"`
+	PprofEnabled     bool   `toml:"pprof-enabled"`
+	HttpsEnabled     bool   `toml:"https-enabled"`
+	HttpsCertificate string `toml:"https-certificate"`
+	// Private key of the certificate, defaults to the certificate file.
+	HttpsPrivateKey string `toml:"https-private-key"`
+	// CA used to verify client certificates, clients must present a certificate if set.
+	HttpsClientCA string `toml:"https-client-ca"`
+	// Field of the client certificate used as the name of the user,
+	// one of common-name, dns-san or email-san.
+	HttpsClientIdentity string `toml:"https-client-identity"`
+	// Allowed cipher suites and TLS versions, the Go defaults are used if empty.
+	TLSCiphers      []string      `toml:"tls-ciphers"`
+	TLSMinVersion   string        `toml:"tls-min-version"`
+	TLSMaxVersion   string        `toml:"tls-max-version"`
+	ShutdownTimeout toml.Duration `toml:"shutdown-timeout"`
+	SharedSecret    string        `toml:"shared-secret"`
+
+	// External OpenID Connect issuers whose tokens are accepted as bearer tokens.
+	OIDC []OIDCConfig `toml:"oidc"`
+
+	// Per client limits of the write endpoints.
+	WriteLimits LimitsConfig `toml:"write-limits"`
+	// Per client limits of the other API endpoints.
+	APILimits LimitsConfig `toml:"api-limits"`
 
 	// Enable gzipped encoding
 	// NOTE: this is ignored in toml since it is only consumed by the tests
@@ -32,11 +60,19 @@ type Config struct {
 
 func NewConfig() Config {
 	return Config{
-		BindAddress:      ":9092",
-		LogEnabled:       true,
-		HttpsCertificate: "/etc/ssl/kapacitor.pem",
-		ShutdownTimeout:  DefaultShutdownTimeout,
-		GZIP:             true,
+		BindAddress:         ":9092",
+		LogEnabled:          true,
+		HttpsCertificate:    "/etc/ssl/kapacitor.pem",
+		HttpsClientIdentity: DefaultHttpsClientIdentity,
+		ShutdownTimeout:     DefaultShutdownTimeout,
+		GZIP:                true,
+		WriteLimits: LimitsConfig{
+			ClientKey: DefaultLimitsClientKey,
+		},
+		APILimits: LimitsConfig{
+			ClientKey:   DefaultLimitsClientKey,
+			MaxBodySize: DefaultAPIMaxBodySize,
+		},
 	}
 }
 
@@ -53,10 +89,51 @@ func (c Config) Validate() error {
 	} else if pn > 65535 || pn < 0 {
 		return fmt.Errorf("invalid http bind address port %d: out of range", pn)
 	}
+	switch c.HttpsClientIdentity {
+	case "", CommonNameIdentity, DNSSANIdentity, EmailSANIdentity:
+	default:
+		return fmt.Errorf("invalid https-client-identity %q, must be one of %s, %s or %s", c.HttpsClientIdentity, CommonNameIdentity, DNSSANIdentity, EmailSANIdentity)
+	}
+	if c.HttpsClientCA != "" && !c.HttpsEnabled {
+		return errors.New("https-client-ca requires https-enabled")
+	}
+	if _, err := tlsconfig.ParseCiphers(c.TLSCiphers); err != nil {
+		return err
+	}
+	if _, err := tlsconfig.ParseVersion(c.TLSMinVersion); err != nil {
+		return errors.Wrap(err, "invalid tls-min-version")
+	}
+	if _, err := tlsconfig.ParseVersion(c.TLSMaxVersion); err != nil {
+		return errors.Wrap(err, "invalid tls-max-version")
+	}
+	issuers := make(map[string]bool, len(c.OIDC))
+	for _, o := range c.OIDC {
+		if err := o.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid oidc issuer %s", o.Issuer)
+		}
+		if issuers[o.Issuer] {
+			return fmt.Errorf("duplicate oidc issuer %s", o.Issuer)
+		}
+		issuers[o.Issuer] = true
+	}
+	if err := c.WriteLimits.Validate(); err != nil {
+		return errors.Wrap(err, "invalid write-limits")
+	}
+	if err := c.APILimits.Validate(); err != nil {
+		return errors.Wrap(err, "invalid api-limits")
+	}
 
 	return nil
 }
 
+// Path of the private key of the HTTPS certificate.
+func (c Config) HttpsKey() string {
+	if c.HttpsPrivateKey == "" {
+		return c.HttpsCertificate
+	}
+	return c.HttpsPrivateKey
+}
+
 // Determine HTTP port from BindAddress.
 func (c Config) Port() (int, error) {
 	if err := c.Validate(); err != nil {
@@ -67,3 +144,97 @@ func (c Config) Port() (int, error) {
 	port, _ := strconv.ParseInt(portStr, 10, 64)
 	return int(port), nil
 }
+
+// OIDCConfig configures an external OpenID Connect issuer.
+// Tokens of the issuer are verified with the keys of its JWKS
+// and the privileges of the user are derived from the roles matching the token claims.
+type OIDCConfig struct {
+	// Expected value of the iss claim.
+	Issuer string `toml:"issuer"`
+	// URL of the JSON Web Key Set of the issuer.
+	JWKSURL string `toml:"jwks-url"`
+	// Path to a local JSON Web Key Set file, used instead of the URL.
+	JWKSFile string `toml:"jwks-file"`
+	// How often the key set is reloaded.
+	// Unknown key IDs also trigger a reload.
+	RefreshInterval toml.Duration `toml:"refresh-interval"`
+	// Expected value of the aud claim, required so that tokens issued for other applications are rejected.
+	Audience string `toml:"audience"`
+	// Claim containing the name of the user.
+	UsernameClaim string `toml:"username-claim"`
+	// Mapping of claim values to privileges.
+	Roles []OIDCRoleConfig `toml:"roles"`
+}
+
+// OIDCRoleConfig grants privileges to the users whose token claim contains the value.
+type OIDCRoleConfig struct {
+	// Name of the claim, defaults to groups.
+	// The claim may be a string or a list of strings.
+	Claim string `toml:"claim"`
+	Value string `toml:"value"`
+	Admin bool   `toml:"admin"`
+	// Map of API resource to privileges, i.e. "/api/tasks" = ["read", "write"]
+	Privileges map[string][]string `toml:"privileges"`
+}
+
+func (c OIDCConfig) Validate() error {
+	if c.Issuer == "" {
+		return errors.New("must specify issuer")
+	}
+	if (c.JWKSURL == "") == (c.JWKSFile == "") {
+		return errors.New("must specify exactly one of jwks-url or jwks-file")
+	}
+	if c.RefreshInterval < 0 {
+		return errors.New("refresh-interval must not be negative")
+	}
+	if c.Audience == "" {
+		return errors.New("must specify audience")
+	}
+	for _, r := range c.Roles {
+		if r.Value == "" {
+			return errors.New("must specify a value for each role")
+		}
+		if _, err := r.privileges(); err != nil {
+			return errors.Wrapf(err, "invalid role %s", r.Value)
+		}
+	}
+	return nil
+}
+
+func (c OIDCConfig) usernameClaim() string {
+	if c.UsernameClaim == "" {
+		return DefaultOIDCUsernameClaim
+	}
+	return c.UsernameClaim
+}
+
+func (c OIDCConfig) refreshInterval() time.Duration {
+	if c.RefreshInterval == 0 {
+		return time.Duration(DefaultOIDCRefreshInterval)
+	}
+	return time.Duration(c.RefreshInterval)
+}
+
+func (r OIDCRoleConfig) claim() string {
+	if r.Claim == "" {
+		return DefaultOIDCRoleClaim
+	}
+	return r.Claim
+}
+
+func (r OIDCRoleConfig) privileges() (map[string][]auth.Privilege, error) {
+	privileges := make(map[string][]auth.Privilege, len(r.Privileges))
+	for resource, names := range r.Privileges {
+		if !path.IsAbs(resource) {
+			return nil, fmt.Errorf("resource %q must be an absolute path", resource)
+		}
+		for _, name := range names {
+			p, err := auth.ParsePrivilege(name)
+			if err != nil {
+				return nil, err
+			}
+			privileges[resource] = append(privileges[resource], p)
+		}
+	}
+	return privileges, nil
+}
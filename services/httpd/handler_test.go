@@ -1,9 +1,36 @@
 package httpd
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"expvar"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/dgrijalva/jwt-go"
+	"github.com/influxdata/influxdb/models"
 	"github.com/influxdata/kapacitor/auth"
+	"github.com/influxdata/kapacitor/services/logging/loggingtest"
+	"github.com/influxdata/kapacitor/tlsconfig"
 )
 
 func Test_RequiredPrilegeForHTTPMethod(t *testing.T) {
@@ -103,3 +130,674 @@ func Test_RequiredPrilegeForHTTPMethod(t *testing.T) {
 		}
 	}
 }
+
+func Test_ParseCredentials_Token(t *testing.T) {
+	r, err := http.NewRequest("GET", "http://localhost/kapacitor/v1/tasks", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Authorization", "Token deploy:secret")
+	creds, err := parseCredentials(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := (credentials{Method: TokenAuthentication, Token: "deploy:secret"}); creds != exp {
+		t.Errorf("unexpected credentials got %v exp %v", creds, exp)
+	}
+}
+
+func Test_Authenticate_OIDC(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := func(i *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(i.Bytes())
+	}
+	jwks, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa", "use": "sig", "n": encode(rsaKey.N), "e": encode(big.NewInt(int64(rsaKey.E)))},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": encode(ecKey.X), "y": encode(ecKey.Y)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "kapacitor-oidc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	jwksFile := filepath.Join(dir, "jwks.json")
+	if err := ioutil.WriteFile(jwksFile, jwks, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := OIDCConfig{
+		Issuer:        "https://sso.example.com",
+		JWKSFile:      jwksFile,
+		Audience:      "kapacitor",
+		UsernameClaim: "email",
+		Roles: []OIDCRoleConfig{
+			{
+				Value:      "ops",
+				Privileges: map[string][]string{"/api/tasks": {"read", "write"}},
+			},
+			{
+				Claim: "role",
+				Value: "admin",
+				Admin: true,
+			},
+		},
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	noAudience := c
+	noAudience.Audience = ""
+	if err := noAudience.Validate(); err == nil {
+		t.Error("expected error validating issuer without audience")
+	}
+	statMap := &expvar.Map{}
+	statMap.Init()
+	ls := loggingtest.New()
+	h := NewHandler(true, false, false, false, false, statMap, ls.NewLogger("[httpd] ", log.LstdFlags), ls, "", []OIDCConfig{c}, "")
+
+	sign := func(method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = kid
+		s, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	testCases := []struct {
+		name  string
+		token string
+		// Expected user name, empty if authentication should fail
+		user       string
+		admin      bool
+		authorized []auth.Action
+	}{
+		{
+			name: "rsa",
+			token: sign(jwt.SigningMethodRS256, "rsa", rsaKey, jwt.MapClaims{
+				"iss":    c.Issuer,
+				"aud":    []string{"kapacitor", "other"},
+				"exp":    exp,
+				"email":  "bob@example.com",
+				"groups": []string{"dev", "ops"},
+			}),
+			user: "bob@example.com",
+			authorized: []auth.Action{
+				{Resource: "/api/tasks/cpu", Privilege: auth.WritePrivilege},
+			},
+		},
+		{
+			name: "ecdsa",
+			token: sign(jwt.SigningMethodES256, "ec", ecKey, jwt.MapClaims{
+				"iss":   c.Issuer,
+				"aud":   "kapacitor",
+				"exp":   exp,
+				"email": "alice@example.com",
+				"role":  "admin",
+			}),
+			user:  "alice@example.com",
+			admin: true,
+		},
+		{
+			name: "wrong key",
+			token: sign(jwt.SigningMethodRS256, "rsa", otherKey, jwt.MapClaims{
+				"iss":   c.Issuer,
+				"aud":   "kapacitor",
+				"exp":   exp,
+				"email": "bob@example.com",
+			}),
+		},
+		{
+			name: "unknown issuer",
+			token: sign(jwt.SigningMethodRS256, "rsa", rsaKey, jwt.MapClaims{
+				"iss":   "https://evil.example.com",
+				"aud":   "kapacitor",
+				"exp":   exp,
+				"email": "bob@example.com",
+			}),
+		},
+		{
+			name: "wrong audience",
+			token: sign(jwt.SigningMethodRS256, "rsa", rsaKey, jwt.MapClaims{
+				"iss":   c.Issuer,
+				"aud":   "other",
+				"exp":   exp,
+				"email": "bob@example.com",
+			}),
+		},
+		{
+			name: "missing audience",
+			token: sign(jwt.SigningMethodRS256, "rsa", rsaKey, jwt.MapClaims{
+				"iss":   c.Issuer,
+				"exp":   exp,
+				"email": "bob@example.com",
+			}),
+		},
+		{
+			name: "expired",
+			token: sign(jwt.SigningMethodRS256, "rsa", rsaKey, jwt.MapClaims{
+				"iss":   c.Issuer,
+				"aud":   "kapacitor",
+				"exp":   time.Now().Add(-time.Hour).Unix(),
+				"email": "bob@example.com",
+			}),
+		},
+		{
+			name: "unsupported algorithm",
+			token: sign(jwt.SigningMethodRS512, "rsa", rsaKey, jwt.MapClaims{
+				"iss":   c.Issuer,
+				"aud":   "kapacitor",
+				"exp":   exp,
+				"email": "bob@example.com",
+			}),
+		},
+		{
+			name: "hmac without shared secret",
+			token: sign(jwt.SigningMethodHS256, "", []byte(""), jwt.MapClaims{
+				"iss":      c.Issuer,
+				"exp":      exp,
+				"username": "bob",
+			}),
+		},
+	}
+	for _, tc := range testCases {
+		var user *auth.User
+		handler := authenticate(func(w http.ResponseWriter, r *http.Request, u auth.User) {
+			user = &u
+		}, h, true, true)
+		r, err := http.NewRequest("GET", "http://localhost/kapacitor/v1/tasks", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Authorization", "Bearer "+tc.token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if tc.user == "" {
+			if user != nil {
+				t.Errorf("%s: expected authentication to fail, got user %s", tc.name, user.Name())
+			}
+			continue
+		}
+		if user == nil {
+			t.Errorf("%s: authentication failed: %s", tc.name, w.Body.String())
+			continue
+		}
+		if got := user.Name(); got != tc.user {
+			t.Errorf("%s: unexpected user got %s exp %s", tc.name, got, tc.user)
+		}
+		if got := user.IsAdmin(); got != tc.admin {
+			t.Errorf("%s: unexpected admin got %t exp %t", tc.name, got, tc.admin)
+		}
+		for _, a := range tc.authorized {
+			if err := user.AuthorizeAction(a); err != nil {
+				t.Errorf("%s: %v", tc.name, err)
+			}
+		}
+	}
+}
+
+// certUsers authenticates users with client certificates only.
+type certUsers struct {
+	names map[string]bool
+}
+
+func (a certUsers) Authenticate(username, password string) (auth.User, error) {
+	return auth.User{}, errors.New("not supported")
+}
+func (a certUsers) User(username string) (auth.User, error) {
+	if !a.names[username] {
+		return auth.User{}, errors.New("unknown user")
+	}
+	return auth.NewUser(username, nil, false, map[string][]auth.Privilege{"/api/ping": {auth.ReadPrivilege}}), nil
+}
+func (a certUsers) SubscriptionUser(token string) (auth.User, error) {
+	return auth.User{}, errors.New("not supported")
+}
+func (a certUsers) TokenUser(token string) (auth.User, error) {
+	return auth.User{}, errors.New("not supported")
+}
+func (a certUsers) GrantSubscriptionAccess(token, db, rp string) error {
+	return errors.New("not supported")
+}
+func (a certUsers) ListSubscriptionTokens() ([]string, error) {
+	return nil, errors.New("not supported")
+}
+func (a certUsers) RevokeSubscriptionAccess(token string) error {
+	return errors.New("not supported")
+}
+
+// passwordUsers counts the authentications of its single admin user.
+type passwordUsers struct {
+	certUsers
+	authentications *int
+}
+
+func (a passwordUsers) Authenticate(username, password string) (auth.User, error) {
+	*a.authentications++
+	if username != "bob" || password != "secret" {
+		return auth.User{}, errors.New("authentication failed")
+	}
+	return auth.NewUser(username, nil, true, nil), nil
+}
+
+func Test_ServeNamespaced_AuthenticatesOnce(t *testing.T) {
+	statMap := &expvar.Map{}
+	statMap.Init()
+	ls := loggingtest.New()
+	h := NewHandler(true, false, false, false, false, statMap, ls.NewLogger("[httpd] ", log.LstdFlags), ls, "", nil, "")
+	authentications := 0
+	h.AuthService = passwordUsers{authentications: &authentications}
+	if err := h.AddRoutes([]Route{
+		{
+			Method:  "GET",
+			Pattern: "/namespaces/",
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request, user auth.User) {
+				h.ServeNamespaced(w, r, user, "team-a", "/tasks/cpu")
+			},
+		},
+		{
+			Method:  "GET",
+			Pattern: "/tasks/",
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(RequestNamespace(r)))
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", BasePath+"/namespaces/team-a/tasks/cpu", nil)
+	r.SetBasicAuth("bob", "secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "team-a" {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Body.String())
+	}
+	if authentications != 1 {
+		t.Errorf("unexpected number of authentications got %d exp 1", authentications)
+	}
+	if got, exp := statMap.Get(statRequest).String(), "1"; got != exp {
+		t.Errorf("unexpected request stat got %s exp %s", got, exp)
+	}
+}
+
+type auditEntries struct {
+	entries []AuditEntry
+}
+
+func (a *auditEntries) Audit(e AuditEntry) {
+	a.entries = append(a.entries, e)
+}
+
+func Test_Audit_FailedAuthentication(t *testing.T) {
+	statMap := &expvar.Map{}
+	statMap.Init()
+	ls := loggingtest.New()
+	h := NewHandler(true, false, false, false, false, statMap, ls.NewLogger("[httpd] ", log.LstdFlags), ls, "", nil, "")
+	authentications := 0
+	h.AuthService = passwordUsers{authentications: &authentications}
+	audits := new(auditEntries)
+	h.AuditService = audits
+	if err := h.AddRoutes([]Route{
+		{
+			Method:  "POST",
+			Pattern: "/tasks/",
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+		},
+		{
+			Method:  "GET",
+			Pattern: "/tasks/",
+			HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, method := range []string{"POST", "GET"} {
+		r := httptest.NewRequest(method, BasePath+"/tasks/cpu", nil)
+		r.SetBasicAuth("bob", "wrong")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("unexpected status code of %s got %d exp %d", method, w.Code, http.StatusUnauthorized)
+		}
+	}
+	// Only the mutating request is audited.
+	if got, exp := len(audits.entries), 1; got != exp {
+		t.Fatalf("unexpected number of audit entries got %d exp %d", got, exp)
+	}
+	e := audits.entries[0]
+	e.Time = time.Time{}
+	exp := AuditEntry{
+		User:      "bob",
+		Method:    "POST",
+		Privilege: auth.WritePrivilege,
+		Resource:  "/api/tasks/cpu",
+		Status:    http.StatusUnauthorized,
+	}
+	if e != exp {
+		t.Errorf("unexpected audit entry:\ngot\n%+v\nexp\n%+v", e, exp)
+	}
+}
+
+func Test_OIDCIssuer_ReloadLimit(t *testing.T) {
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer s.Close()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := newOIDCIssuer(OIDCConfig{
+		Issuer:   "https://sso.example.com",
+		JWKSURL:  s.URL,
+		Audience: "kapacitor",
+	})
+	// Known but stale key set
+	i.keys = map[string]interface{}{"rsa": &rsaKey.PublicKey}
+
+	token := func(kid string) *jwt.Token {
+		return &jwt.Token{
+			Method: jwt.SigningMethodRS256,
+			Header: map[string]interface{}{"kid": kid},
+		}
+	}
+	if _, err := i.Key(token("unknown")); err == nil {
+		t.Error("expected error for unknown key")
+	}
+	for n := 0; n < 10; n++ {
+		if _, err := i.Key(token("unknown")); err == nil {
+			t.Error("expected error for unknown key")
+		}
+		if key, err := i.Key(token("rsa")); err != nil {
+			t.Error(err)
+		} else if key != &rsaKey.PublicKey {
+			t.Errorf("unexpected key %v", key)
+		}
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("unexpected number of key set requests: got %d exp 1", got)
+	}
+}
+
+func Test_Authenticate_ClientCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kapacitor-mtls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Create a CA and use it to sign the server and client certificates.
+	serial := int64(0)
+	newCert := func(name string, template *x509.Certificate, parent *x509.Certificate, parentKey *rsa.PrivateKey) (*x509.Certificate, *rsa.PrivateKey, string, string) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		serial++
+		template.SerialNumber = big.NewInt(serial)
+		template.NotBefore = time.Now().Add(-time.Hour)
+		template.NotAfter = time.Now().Add(time.Hour)
+		if parent == nil {
+			parent, parentKey = template, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certFile := filepath.Join(dir, name+".pem")
+		keyFile := filepath.Join(dir, name+"-key.pem")
+		if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return cert, key, certFile, keyFile
+	}
+	ca, caKey, caFile, _ := newCert("ca", &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	_, _, serverCert, serverKey := newCert("server", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	_, _, aliceCert, aliceKey := newCert("alice", &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "alice"},
+		EmailAddresses: []string{"alice@example.com"},
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+	_, _, malloryCert, malloryKey := newCert("mallory", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "mallory"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+
+	serverTLS, err := tlsconfig.CreateServer(caFile, serverCert, serverKey, []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}, "tls1.2", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	statMap := &expvar.Map{}
+	statMap.Init()
+	ls := loggingtest.New()
+	h := NewHandler(true, false, false, false, false, statMap, ls.NewLogger("[httpd] ", log.LstdFlags), ls, "", nil, EmailSANIdentity)
+	h.AuthService = certUsers{names: map[string]bool{"alice@example.com": true}}
+	server := httptest.NewUnstartedServer(h)
+	server.TLS = serverTLS
+	server.StartTLS()
+	defer server.Close()
+
+	get := func(cert, key string) (int, error) {
+		clientTLS, err := tlsconfig.Create(caFile, cert, key, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Limit the client to TLS 1.2 so that handshake errors are reported by the request.
+		clientTLS.MaxVersion = tls.VersionTLS12
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS}}
+		resp, err := client.Get(server.URL + BasePath + "/ping")
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	if code, err := get(aliceCert, aliceKey); err != nil {
+		t.Fatal(err)
+	} else if code != http.StatusNoContent {
+		t.Errorf("unexpected status code for alice got %d exp %d", code, http.StatusNoContent)
+	}
+	if code, err := get(malloryCert, malloryKey); err != nil {
+		t.Fatal(err)
+	} else if code != http.StatusUnauthorized {
+		t.Errorf("unexpected status code for unknown user got %d exp %d", code, http.StatusUnauthorized)
+	}
+	if _, err := get("", ""); err == nil {
+		t.Error("expected request without client certificate to fail")
+	}
+}
+
+type pointsWriter struct {
+	points int
+}
+
+func (w *pointsWriter) WritePoints(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+	w.points += len(points)
+	return nil
+}
+
+func Test_WriteLimits(t *testing.T) {
+	c := LimitsConfig{
+		ClientKey:         IPClientKey,
+		RequestsPerSecond: 1,
+		Burst:             2,
+		MaxBodySize:       100,
+		Clients: []ClientLimitsConfig{{
+			Client:            "10.0.0.2",
+			RequestsPerSecond: 10,
+			MaxBodySize:       1000,
+		}},
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	statMap := &expvar.Map{}
+	statMap.Init()
+	ls := loggingtest.New()
+	h := NewHandler(false, false, false, false, false, statMap, ls.NewLogger("[httpd] ", log.LstdFlags), ls, "", nil, "")
+	pw := new(pointsWriter)
+	h.PointsWriter = pw
+	h.writeLimiter = newClientLimiter(c)
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	h.writeLimiter.now = func() time.Time { return now }
+
+	write := func(ip, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/write?db=db&rp=rp", strings.NewReader(body))
+		r.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	const point = "cpu value=1\n"
+
+	// The burst allows two writes at once.
+	for i := 0; i < 2; i++ {
+		if w := write("10.0.0.1", point); w.Code != http.StatusNoContent {
+			t.Fatalf("unexpected status code of write %d got %d exp %d: %s", i, w.Code, http.StatusNoContent, w.Body.String())
+		}
+	}
+	w := write("10.0.0.1", point)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("unexpected status code exceeding the rate limit got %d exp %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got, exp := w.Header().Get("Retry-After"), "1"; got != exp {
+		t.Errorf("unexpected Retry-After got %q exp %q", got, exp)
+	}
+	// Other clients are not limited.
+	if w := write("10.0.0.3", point); w.Code != http.StatusNoContent {
+		t.Errorf("unexpected status code of other client got %d exp %d", w.Code, http.StatusNoContent)
+	}
+	// The client may write again after waiting.
+	now = now.Add(time.Second)
+	if w := write("10.0.0.1", point); w.Code != http.StatusNoContent {
+		t.Errorf("unexpected status code after waiting got %d exp %d", w.Code, http.StatusNoContent)
+	}
+
+	large := strings.Repeat(point, 10)
+	if w := write("10.0.0.3", large); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("unexpected status code of large body got %d exp %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+	// The client limits override the defaults.
+	if w := write("10.0.0.2", large); w.Code != http.StatusNoContent {
+		t.Errorf("unexpected status code of large body of client with larger limit got %d exp %d", w.Code, http.StatusNoContent)
+	}
+
+	if got, exp := pw.points, 14; got != exp {
+		t.Errorf("unexpected points written got %d exp %d", got, exp)
+	}
+	if got, exp := statMap.Get(statWriteRequestRateLimited).String(), "1"; got != exp {
+		t.Errorf("unexpected rate limited stat got %s exp %s", got, exp)
+	}
+	if got, exp := statMap.Get(statWriteRequestTooLarge).String(), "1"; got != exp {
+		t.Errorf("unexpected too large stat got %s exp %s", got, exp)
+	}
+}
+
+func Test_APILimits(t *testing.T) {
+	statMap := &expvar.Map{}
+	statMap.Init()
+	ls := loggingtest.New()
+	h := NewHandler(false, false, false, false, false, statMap, ls.NewLogger("[httpd] ", log.LstdFlags), ls, "", nil, "")
+	h.PointsWriter = new(pointsWriter)
+	h.apiLimiter = newClientLimiter(LimitsConfig{
+		ClientKey:         IPClientKey,
+		RequestsPerSecond: 1,
+		MaxBodySize:       10,
+	})
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	h.apiLimiter.now = func() time.Time { return now }
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.RemoteAddr = "10.0.0.1:1234"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := serve("GET", BasePath+"/ping", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("unexpected status code of ping got %d exp %d", w.Code, http.StatusNoContent)
+	}
+	w := serve("GET", BasePath+"/ping", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("unexpected status code exceeding the rate limit got %d exp %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got, exp := w.Header().Get("Retry-After"), "1"; got != exp {
+		t.Errorf("unexpected Retry-After got %q exp %q", got, exp)
+	}
+	// Writes are only subject to the write limits.
+	if w := serve("POST", "/write?db=db&rp=rp", "cpu value=1\ncpu value=2\n"); w.Code != http.StatusNoContent {
+		t.Errorf("unexpected status code of write got %d exp %d: %s", w.Code, http.StatusNoContent, w.Body.String())
+	}
+
+	now = now.Add(time.Second)
+	if w := serve("POST", BasePath+"/loglevel", `{"level":"debug-level-too-long"}`); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("unexpected status code of large body got %d exp %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+
+	if got, exp := statMap.Get(statAPIRequestRateLimited).String(), "1"; got != exp {
+		t.Errorf("unexpected rate limited stat got %s exp %s", got, exp)
+	}
+	if got, exp := statMap.Get(statAPIRequestTooLarge).String(), "1"; got != exp {
+		t.Errorf("unexpected too large stat got %s exp %s", got, exp)
+	}
+}
+
+func Test_WriteLimiter_TokenClient(t *testing.T) {
+	l := newClientLimiter(LimitsConfig{ClientKey: TokenClientKey, RequestsPerSecond: 1})
+	r := httptest.NewRequest("POST", "/write?db=db&rp=rp", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("Authorization", "Bearer made-up")
+
+	if got, exp := l.client(r, auth.User{}, true), tokenDigest("made-up"); got != exp {
+		t.Errorf("unexpected client of authenticated request got %q exp %q", got, exp)
+	}
+	// Unauthenticated tokens must not evade the limits of the IP address.
+	if got, exp := l.client(r, auth.User{}, false), "10.0.0.1"; got != exp {
+		t.Errorf("unexpected client of unauthenticated request got %q exp %q", got, exp)
+	}
+}
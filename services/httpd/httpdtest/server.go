@@ -3,8 +3,10 @@ package httpdtest
 import (
 	"expvar"
 	"log"
+	"net/http"
 	"net/http/httptest"
 
+	"github.com/influxdata/kapacitor/auth"
 	"github.com/influxdata/kapacitor/services/httpd"
 	"github.com/influxdata/kapacitor/services/logging/loggingtest"
 )
@@ -29,6 +31,8 @@ func NewServer(verbose bool) *Server {
 			ls.NewLogger("[httpdtest] ", log.LstdFlags),
 			ls,
 			"",
+			nil,
+			"",
 		),
 	}
 
@@ -45,6 +49,14 @@ func (s *Server) AddRoutes(routes []httpd.Route) error {
 	return s.Handler.AddRoutes(routes)
 }
 
+func (s *Server) AddPreviewRoutes(routes []httpd.Route) error {
+	return s.Handler.AddPreviewRoutes(routes)
+}
+
 func (s *Server) DelRoutes(routes []httpd.Route) {
 	s.Handler.DelRoutes(routes)
 }
+
+func (s *Server) ServeNamespaced(w http.ResponseWriter, r *http.Request, user auth.User, namespace, p string) {
+	s.Handler.ServeNamespaced(w, r, user, namespace, p)
+}
@@ -0,0 +1,254 @@
+package httpd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb/toml"
+	"github.com/influxdata/kapacitor/auth"
+	"github.com/pkg/errors"
+)
+
+// Methods of identifying the client of a request.
+const (
+	// Clients are identified by their IP address.
+	IPClientKey = "ip"
+	// Clients are identified by the name of the authenticated user.
+	UserClientKey = "user"
+	// Clients are identified by the token or username they authenticated with.
+	TokenClientKey = "token"
+
+	DefaultLimitsClientKey = IPClientKey
+
+	// Default maximum size of the body of an API request.
+	DefaultAPIMaxBodySize = 10 * 1024 * 1024
+)
+
+// The number of clients tracked before idle clients are forgotten.
+const maxIdleClients = 10000
+
+// LimitsConfig limits the requests of each client.
+type LimitsConfig struct {
+	// How clients are identified, one of ip, user or token.
+	ClientKey string `toml:"client-key"`
+	// Maximum sustained number of requests per second of each client, 0 is unlimited.
+	RequestsPerSecond float64 `toml:"requests-per-second"`
+	// Maximum number of requests a client may make at once, defaults to the requests per second.
+	Burst int `toml:"burst"`
+	// Maximum size of the body of a request, 0 is unlimited.
+	// The limit of write requests applies to the uncompressed body.
+	MaxBodySize toml.Size `toml:"max-body-size"`
+	// Limits of specific clients, overriding the limits above.
+	Clients []ClientLimitsConfig `toml:"client"`
+}
+
+// ClientLimitsConfig limits the requests of a specific client.
+type ClientLimitsConfig struct {
+	// The IP address, user or token of the client, depending on the client key.
+	Client            string    `toml:"client"`
+	RequestsPerSecond float64   `toml:"requests-per-second"`
+	Burst             int       `toml:"burst"`
+	MaxBodySize       toml.Size `toml:"max-body-size"`
+}
+
+func (c LimitsConfig) Validate() error {
+	switch c.ClientKey {
+	case "", IPClientKey, UserClientKey, TokenClientKey:
+	default:
+		return fmt.Errorf("invalid client-key %q, must be one of %s, %s or %s", c.ClientKey, IPClientKey, UserClientKey, TokenClientKey)
+	}
+	if err := validateLimits(c.RequestsPerSecond, c.Burst, c.MaxBodySize); err != nil {
+		return err
+	}
+	clients := make(map[string]bool, len(c.Clients))
+	for _, cc := range c.Clients {
+		if cc.Client == "" {
+			return errors.New("must specify the client of each client limit")
+		}
+		if clients[cc.Client] {
+			return fmt.Errorf("duplicate client limit %s", cc.Client)
+		}
+		clients[cc.Client] = true
+		if err := validateLimits(cc.RequestsPerSecond, cc.Burst, cc.MaxBodySize); err != nil {
+			return errors.Wrapf(err, "invalid client limit %s", cc.Client)
+		}
+	}
+	return nil
+}
+
+// enabled reports whether any limits are configured.
+func (c LimitsConfig) enabled() bool {
+	return c.RequestsPerSecond > 0 || c.MaxBodySize > 0 || len(c.Clients) > 0
+}
+
+func validateLimits(rps float64, burst int, maxBodySize toml.Size) error {
+	if rps < 0 {
+		return errors.New("requests-per-second must not be negative")
+	}
+	if burst < 0 {
+		return errors.New("burst must not be negative")
+	}
+	if maxBodySize < 0 {
+		return errors.New("max-body-size must not be negative")
+	}
+	return nil
+}
+
+// clientLimits are the limits of a client.
+type clientLimits struct {
+	rate        float64
+	burst       float64
+	maxBodySize int64
+}
+
+func newClientLimits(rps float64, burst int, maxBodySize toml.Size) clientLimits {
+	l := clientLimits{
+		rate:        rps,
+		burst:       float64(burst),
+		maxBodySize: int64(maxBodySize),
+	}
+	if l.burst == 0 {
+		l.burst = math.Max(1, math.Ceil(rps))
+	}
+	return l
+}
+
+// bucket is the token bucket of a client.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// fill adds the tokens accumulated since the last request and returns the tokens.
+func (b *bucket) fill(l clientLimits, now time.Time) float64 {
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	return b.tokens
+}
+
+// clientLimiter enforces the limits of each client.
+type clientLimiter struct {
+	clientKey string
+	defaults  clientLimits
+	clients   map[string]clientLimits
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+
+	now func() time.Time
+}
+
+func newClientLimiter(c LimitsConfig) *clientLimiter {
+	l := &clientLimiter{
+		clientKey: c.ClientKey,
+		defaults:  newClientLimits(c.RequestsPerSecond, c.Burst, c.MaxBodySize),
+		clients:   make(map[string]clientLimits, len(c.Clients)),
+		buckets:   make(map[string]*bucket),
+		now:       time.Now,
+	}
+	if l.clientKey == "" {
+		l.clientKey = DefaultLimitsClientKey
+	}
+	for _, cc := range c.Clients {
+		limits := newClientLimits(cc.RequestsPerSecond, cc.Burst, cc.MaxBodySize)
+		l.clients[cc.Client] = limits
+		if l.clientKey == TokenClientKey {
+			// Tokens are identified by their digest.
+			l.clients[tokenDigest(cc.Client)] = limits
+		}
+	}
+	return l
+}
+
+// tokenDigest returns the digest of the token, so that only the digest is kept in memory.
+func tokenDigest(token string) string {
+	digest := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(digest[:])
+}
+
+// client returns the identity of the client of the request.
+// Unauthenticated clients are identified by their IP address,
+// so that made up users or tokens cannot evade the limits.
+func (l *clientLimiter) client(r *http.Request, user auth.User, authenticated bool) string {
+	switch l.clientKey {
+	case UserClientKey:
+		if authenticated {
+			return user.Name()
+		}
+	case TokenClientKey:
+		if !authenticated {
+			break
+		}
+		if creds, err := parseCredentials(r); err == nil {
+			switch {
+			case creds.Token != "":
+				return tokenDigest(creds.Token)
+			case creds.Username != "":
+				return creds.Username
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// limits returns the limits of the client.
+func (l *clientLimiter) limits(client string) clientLimits {
+	if cl, ok := l.clients[client]; ok {
+		return cl
+	}
+	return l.defaults
+}
+
+// allow reports whether the client may make another request,
+// and if not how long it has to wait before retrying.
+func (l *clientLimiter) allow(client string) (bool, time.Duration) {
+	limits := l.limits(client)
+	if limits.rate == 0 {
+		return true, 0
+	}
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[client]
+	if !ok {
+		l.forgetIdle(now)
+		b = &bucket{tokens: limits.burst, last: now}
+		l.buckets[client] = b
+	}
+	if b.fill(limits, now) < 1 {
+		wait := time.Duration((1 - b.tokens) / limits.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// forgetIdle removes the buckets of idle clients once too many clients are tracked.
+// A client is idle if its bucket is full again.
+func (l *clientLimiter) forgetIdle(now time.Time) {
+	if len(l.buckets) < maxIdleClients {
+		return
+	}
+	for client, b := range l.buckets {
+		limits := l.limits(client)
+		if b.fill(limits, now) >= limits.burst {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// retryAfter formats the wait as the value of a Retry-After header in whole seconds.
+func retryAfter(wait time.Duration) string {
+	return strconv.FormatInt(int64(math.Max(1, math.Ceil(wait.Seconds()))), 10)
+}
@@ -0,0 +1,100 @@
+package httpd
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/influxdata/kapacitor/auth"
+)
+
+const namespacesPath = "/namespaces/"
+
+// Paths of the resources that belong to a namespace and the base path they are served on.
+var namespacedPaths = []struct {
+	prefix string
+	base   string
+}{
+	{prefix: "/tasks", base: BasePath},
+	{prefix: "/templates", base: BasePath},
+	{prefix: "/alerts/topics", base: BasePreviewPath},
+}
+
+type contextKey int
+
+const namespaceKey contextKey = iota
+
+type namespaceRequest struct {
+	namespace string
+	// The user authenticated by the route of the namespace.
+	user auth.User
+	// The API resource of the namespaced path, i.e. /api/namespaces/<namespace>/tasks
+	resource string
+}
+
+// ServeNamespaced serves the request for the path p of a resource within the namespace,
+// i.e. /namespaces/<namespace>/tasks/<id> is served by the route of /tasks/<id>.
+// The user has already been authenticated by the route of the namespace and is not authenticated again.
+// The route authorizes the request for the namespaced path, so that privileges can be granted per namespace.
+func (h *Handler) ServeNamespaced(w http.ResponseWriter, r *http.Request, user auth.User, namespace, p string) {
+	// Do not allow leaving the namespaced paths, i.e. /tasks/../users
+	if path.Clean(p) != strings.TrimSuffix(p, "/") {
+		h.serve404(w, r)
+		return
+	}
+	for _, np := range namespacedPaths {
+		if p != np.prefix && !strings.HasPrefix(p, np.prefix+"/") {
+			continue
+		}
+		ctx := context.WithValue(r.Context(), namespaceKey, namespaceRequest{
+			namespace: namespace,
+			user:      user,
+			resource:  auth.APIResource(strings.TrimPrefix(r.URL.Path, BasePath)),
+		})
+		r = r.WithContext(ctx)
+		r.URL.Path = np.base + p
+		// The request has already been counted.
+		h.serve(w, r)
+		return
+	}
+	h.serve404(w, r)
+}
+
+// RequestNamespace returns the namespace of the request, or an empty string if the request is not namespaced.
+func RequestNamespace(r *http.Request) string {
+	if nr, ok := r.Context().Value(namespaceKey).(namespaceRequest); ok {
+		return nr.namespace
+	}
+	return ""
+}
+
+// NamespacedPath returns the path of the API path p within the namespace,
+// all namespaced paths are served on the base path, i.e. /kapacitor/v1/namespaces/<namespace>/alerts/topics.
+// The path is returned unchanged if the namespace is empty.
+func NamespacedPath(namespace, p string) string {
+	if namespace == "" {
+		return p
+	}
+	// The preview path has the base path as prefix, so check it first.
+	for _, base := range []string{BasePreviewPath, BasePath} {
+		if strings.HasPrefix(p, base+"/") {
+			return BasePath + namespacesPath + namespace + p[len(base):]
+		}
+	}
+	return p
+}
+
+// requestResource returns the API resource the request must be authorized for.
+// Managing the namespaces themselves requires privileges on all namespaces,
+// so that privileges on a namespace do not allow changing its restrictions.
+func requestResource(r *http.Request) string {
+	if nr, ok := r.Context().Value(namespaceKey).(namespaceRequest); ok {
+		return nr.resource
+	}
+	p := strings.TrimPrefix(r.URL.Path, BasePath)
+	if strings.HasPrefix(p, namespacesPath) && !strings.Contains(strings.Trim(p[len(namespacesPath):], "/"), "/") {
+		return auth.APIResource(strings.TrimSuffix(namespacesPath, "/"))
+	}
+	return auth.APIResource(p)
+}
@@ -0,0 +1,313 @@
+package httpd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/influxdata/kapacitor/auth"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Minimum time between reloads of a key set.
+	minJWKSReloadInterval = 10 * time.Second
+	// Maximum size of a key set.
+	maxJWKSSize = 1 << 20
+)
+
+// Signing algorithms accepted for tokens of external issuers.
+var oidcSigningMethods = map[string]bool{
+	jwt.SigningMethodRS256.Alg(): true,
+	jwt.SigningMethodES256.Alg(): true,
+}
+
+// oidcIssuer verifies the tokens of an external OpenID Connect issuer
+// and maps their claims to users.
+type oidcIssuer struct {
+	config OIDCConfig
+	roles  []oidcRole
+	client *http.Client
+
+	mu sync.Mutex
+	// Map of key ID -> *rsa.PublicKey or *ecdsa.PublicKey
+	keys       map[string]interface{}
+	loaded     time.Time
+	lastReload time.Time
+	// Closed when the running reload completes, nil if no reload is running.
+	reloading chan struct{}
+	// Error of the last reload.
+	reloadErr error
+}
+
+type oidcRole struct {
+	claim      string
+	value      string
+	admin      bool
+	privileges map[string][]auth.Privilege
+}
+
+func newOIDCIssuer(c OIDCConfig) *oidcIssuer {
+	roles := make([]oidcRole, len(c.Roles))
+	for i, r := range c.Roles {
+		// The privileges have already been validated with the config.
+		privileges, _ := r.privileges()
+		roles[i] = oidcRole{
+			claim:      r.claim(),
+			value:      r.Value,
+			admin:      r.Admin,
+			privileges: privileges,
+		}
+	}
+	return &oidcIssuer{
+		config: c,
+		roles:  roles,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Key returns the public key used to verify the token.
+func (i *oidcIssuer) Key(token *jwt.Token) (interface{}, error) {
+	if alg := token.Method.Alg(); !oidcSigningMethods[alg] {
+		return nil, fmt.Errorf("unexpected signing method: %v", alg)
+	}
+	kid, _ := token.Header["kid"].(string)
+
+	i.mu.Lock()
+	now := time.Now()
+	key, ok := i.lookup(kid)
+	stale := now.Sub(i.loaded) >= i.config.refreshInterval()
+	if ok && !stale {
+		i.mu.Unlock()
+		return key, nil
+	}
+	// Reload the key set if it is stale or the key is unknown,
+	// but limit how often the key set is reloaded.
+	done := i.reloading
+	if done == nil && now.Sub(i.lastReload) >= minJWKSReloadInterval {
+		done = i.reload(now)
+	}
+	if ok || done == nil {
+		i.mu.Unlock()
+		if ok {
+			// Keep using the known key while the key set is reloaded.
+			return key, nil
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	i.mu.Unlock()
+
+	<-done
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if key, ok = i.lookup(kid); ok {
+		return key, nil
+	}
+	if i.reloadErr != nil {
+		return nil, errors.Wrapf(i.reloadErr, "failed to load keys of issuer %s", i.config.Issuer)
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// reload loads the key set in the background and returns a channel
+// that is closed once it completes.
+// It must be called with i.mu held and no reload running.
+func (i *oidcIssuer) reload(now time.Time) chan struct{} {
+	done := make(chan struct{})
+	i.reloading = done
+	i.lastReload = now
+	go func() {
+		keys, err := i.loadKeys()
+		i.mu.Lock()
+		if err == nil {
+			i.keys = keys
+			i.loaded = now
+		}
+		i.reloadErr = err
+		i.reloading = nil
+		i.mu.Unlock()
+		close(done)
+	}()
+	return done
+}
+
+// lookup returns the key with the ID.
+// Tokens without a key ID are only accepted if the key set contains a single key.
+func (i *oidcIssuer) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(i.keys) == 1 {
+		for _, key := range i.keys {
+			return key, true
+		}
+	}
+	key, ok := i.keys[kid]
+	return key, ok
+}
+
+func (i *oidcIssuer) loadKeys() (map[string]interface{}, error) {
+	var r io.Reader
+	if i.config.JWKSFile != "" {
+		f, err := os.Open(i.config.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	} else {
+		resp, err := i.client.Get(i.config.JWKSURL)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected response code %d from %s", resp.StatusCode, i.config.JWKSURL)
+		}
+		r = resp.Body
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxJWKSSize))
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(data)
+}
+
+// User returns the user described by the claims of a verified token.
+func (i *oidcIssuer) User(claims jwt.MapClaims) (auth.User, error) {
+	if !claimContains(claims["aud"], i.config.Audience) {
+		return auth.User{}, errors.New("token audience does not match")
+	}
+	username, _ := claims[i.config.usernameClaim()].(string)
+	if username == "" {
+		return auth.User{}, fmt.Errorf("token must contain a %q claim", i.config.usernameClaim())
+	}
+	admin := false
+	privileges := make(map[string][]auth.Privilege)
+	for _, r := range i.roles {
+		if !claimContains(claims[r.claim], r.value) {
+			continue
+		}
+		admin = admin || r.admin
+		for resource, ps := range r.privileges {
+			privileges[resource] = append(privileges[resource], ps...)
+		}
+	}
+	return auth.NewUser(username, nil, admin, privileges), nil
+}
+
+// claimContains reports whether the claim is the value or a list containing the value.
+func claimContains(claim interface{}, value string) bool {
+	switch c := claim.(type) {
+	case string:
+		return c == value
+	case []interface{}:
+		for _, v := range c {
+			if s, ok := v.(string); ok && s == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonWebKey is a public key of a JSON Web Key Set, see RFC 7517.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS returns the RSA and P-256 signing keys of the key set by key ID.
+// Keys of other types are ignored.
+func parseJWKS(data []byte) (map[string]interface{}, error) {
+	set := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, errors.Wrap(err, "invalid key set")
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var key interface{}
+		var err error
+		switch k.Kty {
+		case "RSA":
+			key, err = k.rsaKey()
+		case "EC":
+			if k.Crv != "P-256" {
+				continue
+			}
+			key, err = k.ecdsaKey()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid key %q", k.Kid)
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) rsaKey() (*rsa.PublicKey, error) {
+	n, err := decodeJWKInt(k.N)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid modulus")
+	}
+	e, err := decodeJWKInt(k.E)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid exponent")
+	}
+	if e.BitLen() > 31 || e.Int64() < 3 {
+		return nil, errors.New("invalid exponent")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (k jsonWebKey) ecdsaKey() (*ecdsa.PublicKey, error) {
+	x, err := decodeJWKInt(k.X)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid x coordinate")
+	}
+	y, err := decodeJWKInt(k.Y)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid y coordinate")
+	}
+	curve := elliptic.P256()
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("point is not on the curve")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func decodeJWKInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -12,7 +12,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/influxdata/kapacitor/auth"
 	"github.com/influxdata/kapacitor/services/logging"
+	"github.com/influxdata/kapacitor/tlsconfig"
 )
 
 type Service struct {
@@ -20,8 +22,15 @@ type Service struct {
 	addr  string
 	https bool
 	cert  string
+	key   string
 	err   chan error
 
+	// TLS settings
+	clientCA      string
+	tlsCiphers    []string
+	tlsMinVersion string
+	tlsMaxVersion string
+
 	externalURL string
 
 	server *http.Server
@@ -56,6 +65,11 @@ func NewService(c Config, hostname string, l *log.Logger, li logging.Interface)
 		addr:            c.BindAddress,
 		https:           c.HttpsEnabled,
 		cert:            c.HttpsCertificate,
+		key:             c.HttpsKey(),
+		clientCA:        c.HttpsClientCA,
+		tlsCiphers:      c.TLSCiphers,
+		tlsMinVersion:   c.TLSMinVersion,
+		tlsMaxVersion:   c.TLSMaxVersion,
 		externalURL:     u.String(),
 		err:             make(chan error, 1),
 		shutdownTimeout: time.Duration(c.ShutdownTimeout),
@@ -69,10 +83,18 @@ func NewService(c Config, hostname string, l *log.Logger, li logging.Interface)
 			l,
 			li,
 			c.SharedSecret,
+			c.OIDC,
+			c.HttpsClientIdentity,
 		),
 		logger:           l,
 		httpServerLogger: li.NewStaticLevelLogger("[httpd]", log.LstdFlags, logging.ERROR),
 	}
+	if c.WriteLimits.enabled() {
+		s.Handler.writeLimiter = newClientLimiter(c.WriteLimits)
+	}
+	if c.APILimits.enabled() {
+		s.Handler.apiLimiter = newClientLimiter(c.APILimits)
+	}
 	return s
 }
 
@@ -85,17 +107,18 @@ func (s *Service) Open() error {
 
 	// Open listener.
 	if s.https {
-		cert, err := tls.LoadX509KeyPair(s.cert, s.cert)
+		tlsConfig, err := tlsconfig.CreateServer(s.clientCA, s.cert, s.key, s.tlsCiphers, s.tlsMinVersion, s.tlsMaxVersion)
 		if err != nil {
 			return err
 		}
 
-		listener, err := tls.Listen("tcp", s.addr, &tls.Config{
-			Certificates: []tls.Certificate{cert},
-		})
+		listener, err := tls.Listen("tcp", s.addr, tlsConfig)
 		if err != nil {
 			return err
 		}
+		if s.clientCA != "" {
+			s.logger.Println("I! Client certificates required")
+		}
 
 		s.logger.Println("I! Listening on HTTPS:", listener.Addr().String())
 		s.ln = listener
@@ -286,3 +309,7 @@ func (s *Service) AddPreviewRoutes(routes []Route) error {
 func (s *Service) DelRoutes(routes []Route) {
 	s.Handler.DelRoutes(routes)
 }
+
+func (s *Service) ServeNamespaced(w http.ResponseWriter, r *http.Request, user auth.User, namespace, p string) {
+	s.Handler.ServeNamespaced(w, r, user, namespace, p)
+}
@@ -0,0 +1,52 @@
+package localauth
+
+import (
+	"time"
+
+	"github.com/influxdata/influxdb/toml"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	DefaultBcryptCost      = bcrypt.DefaultCost
+	DefaultCacheExpiration = toml.Duration(10 * time.Minute)
+)
+
+type Config struct {
+	// Enable the local auth service, otherwise the noauth service is used.
+	Enabled bool `toml:"enabled"`
+	// The cost of the bcrypt password hashes.
+	BcryptCost int `toml:"bcrypt-cost"`
+	// How long a successful authentication is cached,
+	// avoiding the cost of comparing the bcrypt hash on each request.
+	// A value of zero disables the cache.
+	CacheExpiration toml.Duration `toml:"cache-expiration"`
+	// The name and password of an admin user that is created if no users exist.
+	// This allows creating the other users using the API.
+	AdminUsername string `toml:"admin-username"`
+	AdminPassword string `toml:"admin-password"`
+}
+
+func NewConfig() Config {
+	return Config{
+		BcryptCost:      DefaultBcryptCost,
+		CacheExpiration: DefaultCacheExpiration,
+	}
+}
+
+func (c Config) Validate() error {
+	if c.BcryptCost < bcrypt.MinCost || c.BcryptCost > bcrypt.MaxCost {
+		return errors.Errorf("bcrypt-cost must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, c.BcryptCost)
+	}
+	if c.CacheExpiration < 0 {
+		return errors.New("cache-expiration must not be negative")
+	}
+	if (c.AdminUsername == "") != (c.AdminPassword == "") {
+		return errors.New("must provide both admin-username and admin-password or neither")
+	}
+	if c.AdminUsername != "" && !validUsername.MatchString(c.AdminUsername) {
+		return errors.Errorf("invalid admin-username %q", c.AdminUsername)
+	}
+	return nil
+}
@@ -0,0 +1,416 @@
+package localauth
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/influxdata/kapacitor/services/storage"
+)
+
+var (
+	ErrUserExists           = errors.New("user already exists")
+	ErrNoUserExists         = errors.New("no user exists")
+	ErrNoSubscriptionExists = errors.New("no subscription exists")
+	ErrTokenExists          = errors.New("token already exists")
+	ErrNoTokenExists        = errors.New("no token exists")
+)
+
+// Data access object for User data.
+type UserDAO interface {
+	// Retrieve a user
+	Get(name string) (User, error)
+
+	// Create a user.
+	// ErrUserExists is returned if a user already exists with the same name.
+	Create(u User) error
+
+	// Replace an existing user.
+	// ErrNoUserExists is returned if the user does not exist.
+	Replace(u User) error
+
+	// Delete a user.
+	// It is not an error to delete an non-existent user.
+	Delete(name string) error
+
+	// List users matching a pattern.
+	// The pattern is shell/glob matching see https://golang.org/pkg/path/#Match
+	// Offset and limit are pagination bounds. Offset is inclusive starting at index 0.
+	// More results may exist while the number of returned items is equal to limit.
+	List(pattern string, offset, limit int) ([]User, error)
+
+	Rebuild() error
+}
+
+// Data access object for InfluxDB subscription data.
+type SubscriptionDAO interface {
+	// Retrieve a subscription
+	Get(token string) (Subscription, error)
+
+	// Set a subscription.
+	// If it does not already exist it will be created,
+	// otherwise it will be replaced.
+	Set(s Subscription) error
+
+	// Delete a subscription.
+	// It is not an error to delete an non-existent subscription.
+	Delete(token string) error
+
+	// List all subscriptions
+	List() ([]Subscription, error)
+
+	Rebuild() error
+}
+
+// Data access object for API token data.
+type TokenDAO interface {
+	// Retrieve a token
+	Get(name string) (Token, error)
+
+	// Create a token.
+	// ErrTokenExists is returned if a token already exists with the same name.
+	Create(t Token) error
+
+	// Replace an existing token.
+	// ErrNoTokenExists is returned if the token does not exist.
+	Replace(t Token) error
+
+	// Set the last used time of a token within a single transaction.
+	// ErrNoTokenExists is returned if the token does not exist or no longer has the hash.
+	UpdateLastUsed(name string, hash []byte, lastUsed time.Time) error
+
+	// Delete a token.
+	// It is not an error to delete an non-existent token.
+	Delete(name string) error
+
+	// List tokens matching a pattern.
+	// The pattern is shell/glob matching see https://golang.org/pkg/path/#Match
+	// Offset and limit are pagination bounds. Offset is inclusive starting at index 0.
+	// More results may exist while the number of returned items is equal to limit.
+	List(pattern string, offset, limit int) ([]Token, error)
+
+	Rebuild() error
+}
+
+//--------------------------------------------------------------------
+// The following structures are stored in a database via JSON encoding.
+// Changes to the structures could break existing data.
+
+// version is the current version of the User, Subscription and Token structures.
+const version = 1
+
+type User struct {
+	// Unique name of the user
+	Name string `json:"name"`
+
+	// The bcrypt hash of the password of the user
+	Hash []byte `json:"hash"`
+
+	// Whether the user has all privileges on all resources
+	Admin bool `json:"admin"`
+
+	// Map of resource to the names of the privileges the user has on the resource
+	Privileges map[string][]string `json:"privileges"`
+}
+
+func (u User) ObjectID() string {
+	return u.Name
+}
+
+func (u User) MarshalBinary() ([]byte, error) {
+	return storage.VersionJSONEncode(version, u)
+}
+
+func (u *User) UnmarshalBinary(data []byte) error {
+	return storage.VersionJSONDecode(data, func(version int, dec *json.Decoder) error {
+		return dec.Decode(u)
+	})
+}
+
+// A subscription grants an InfluxDB subscription write access to a database.
+type Subscription struct {
+	Token           string `json:"token"`
+	Database        string `json:"db"`
+	RetentionPolicy string `json:"rp"`
+}
+
+func (s Subscription) ObjectID() string {
+	return s.Token
+}
+
+func (s Subscription) MarshalBinary() ([]byte, error) {
+	return storage.VersionJSONEncode(version, s)
+}
+
+func (s *Subscription) UnmarshalBinary(data []byte) error {
+	return storage.VersionJSONDecode(data, func(version int, dec *json.Decoder) error {
+		return dec.Decode(s)
+	})
+}
+
+// An API token grants its privileges to requests authenticated with its secret.
+type Token struct {
+	// Unique name of the token
+	Name string `json:"name"`
+
+	// The SHA-256 hash of the secret of the token
+	Hash []byte `json:"hash"`
+
+	// Map of resource or resource pattern to the names of the privileges the token grants
+	Privileges map[string][]string `json:"privileges"`
+
+	// The user that created the token, or the owner of the token it was created with.
+	// Empty for tokens created before owners were recorded, those can only be managed by admins.
+	Owner string `json:"owner"`
+
+	Created time.Time `json:"created"`
+
+	// The time the token expires, the token does not expire if zero.
+	Expires time.Time `json:"expires"`
+
+	// The last time the token was used, updated at most once per minute.
+	LastUsed time.Time `json:"last-used"`
+}
+
+func (t Token) ObjectID() string {
+	return t.Name
+}
+
+func (t Token) MarshalBinary() ([]byte, error) {
+	return storage.VersionJSONEncode(version, t)
+}
+
+func (t *Token) UnmarshalBinary(data []byte) error {
+	return storage.VersionJSONDecode(data, func(version int, dec *json.Decoder) error {
+		return dec.Decode(t)
+	})
+}
+
+// Key/Value store based implementation of the UserDAO
+type userKV struct {
+	store *storage.IndexedStore
+}
+
+func newUserKV(store storage.Interface) (*userKV, error) {
+	c := storage.DefaultIndexedStoreConfig("users", func() storage.BinaryObject {
+		return new(User)
+	})
+	istore, err := storage.NewIndexedStore(store, c)
+	if err != nil {
+		return nil, err
+	}
+	return &userKV{
+		store: istore,
+	}, nil
+}
+
+func (kv *userKV) error(err error) error {
+	if err == storage.ErrNoObjectExists {
+		return ErrNoUserExists
+	} else if err == storage.ErrObjectExists {
+		return ErrUserExists
+	}
+	return err
+}
+
+func (kv *userKV) Get(name string) (User, error) {
+	obj, err := kv.store.Get(name)
+	if err != nil {
+		return User{}, kv.error(err)
+	}
+	u, ok := obj.(*User)
+	if !ok {
+		return User{}, storage.ImpossibleTypeErr(u, obj)
+	}
+	return *u, nil
+}
+
+func (kv *userKV) Create(u User) error {
+	return kv.error(kv.store.Create(&u))
+}
+
+func (kv *userKV) Replace(u User) error {
+	return kv.error(kv.store.Replace(&u))
+}
+
+func (kv *userKV) Delete(name string) error {
+	return kv.error(kv.store.Delete(name))
+}
+
+func (kv *userKV) List(pattern string, offset, limit int) ([]User, error) {
+	objects, err := kv.store.List(storage.DefaultIDIndex, pattern, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]User, len(objects))
+	for i, object := range objects {
+		u, ok := object.(*User)
+		if !ok {
+			return nil, storage.ImpossibleTypeErr(u, object)
+		}
+		users[i] = *u
+	}
+	return users, nil
+}
+
+func (kv *userKV) Rebuild() error {
+	return kv.store.Rebuild()
+}
+
+// Key/Value store based implementation of the SubscriptionDAO
+type subscriptionKV struct {
+	store *storage.IndexedStore
+}
+
+func newSubscriptionKV(store storage.Interface) (*subscriptionKV, error) {
+	c := storage.DefaultIndexedStoreConfig("subscriptions", func() storage.BinaryObject {
+		return new(Subscription)
+	})
+	istore, err := storage.NewIndexedStore(store, c)
+	if err != nil {
+		return nil, err
+	}
+	return &subscriptionKV{
+		store: istore,
+	}, nil
+}
+
+func (kv *subscriptionKV) error(err error) error {
+	if err == storage.ErrNoObjectExists {
+		return ErrNoSubscriptionExists
+	}
+	return err
+}
+
+func (kv *subscriptionKV) Get(token string) (Subscription, error) {
+	obj, err := kv.store.Get(token)
+	if err != nil {
+		return Subscription{}, kv.error(err)
+	}
+	s, ok := obj.(*Subscription)
+	if !ok {
+		return Subscription{}, storage.ImpossibleTypeErr(s, obj)
+	}
+	return *s, nil
+}
+
+func (kv *subscriptionKV) Set(s Subscription) error {
+	return kv.store.Put(&s)
+}
+
+func (kv *subscriptionKV) Delete(token string) error {
+	return kv.error(kv.store.Delete(token))
+}
+
+func (kv *subscriptionKV) List() ([]Subscription, error) {
+	objects, err := kv.store.List(storage.DefaultIDIndex, "", 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	subscriptions := make([]Subscription, len(objects))
+	for i, object := range objects {
+		s, ok := object.(*Subscription)
+		if !ok {
+			return nil, storage.ImpossibleTypeErr(s, object)
+		}
+		subscriptions[i] = *s
+	}
+	return subscriptions, nil
+}
+
+func (kv *subscriptionKV) Rebuild() error {
+	return kv.store.Rebuild()
+}
+
+// Key/Value store based implementation of the TokenDAO
+type tokenKV struct {
+	raw   storage.Interface
+	store *storage.IndexedStore
+}
+
+func newTokenKV(store storage.Interface) (*tokenKV, error) {
+	c := storage.DefaultIndexedStoreConfig("tokens", func() storage.BinaryObject {
+		return new(Token)
+	})
+	istore, err := storage.NewIndexedStore(store, c)
+	if err != nil {
+		return nil, err
+	}
+	return &tokenKV{
+		raw:   store,
+		store: istore,
+	}, nil
+}
+
+func (kv *tokenKV) error(err error) error {
+	if err == storage.ErrNoObjectExists {
+		return ErrNoTokenExists
+	} else if err == storage.ErrObjectExists {
+		return ErrTokenExists
+	}
+	return err
+}
+
+func (kv *tokenKV) Get(name string) (Token, error) {
+	obj, err := kv.store.Get(name)
+	if err != nil {
+		return Token{}, kv.error(err)
+	}
+	t, ok := obj.(*Token)
+	if !ok {
+		return Token{}, storage.ImpossibleTypeErr(t, obj)
+	}
+	return *t, nil
+}
+
+func (kv *tokenKV) Create(t Token) error {
+	return kv.error(kv.store.Create(&t))
+}
+
+func (kv *tokenKV) Replace(t Token) error {
+	return kv.error(kv.store.Replace(&t))
+}
+
+func (kv *tokenKV) UpdateLastUsed(name string, hash []byte, lastUsed time.Time) error {
+	return kv.error(kv.raw.Update(func(tx storage.Tx) error {
+		obj, err := kv.store.GetTx(tx, name)
+		if err != nil {
+			return err
+		}
+		t, ok := obj.(*Token)
+		if !ok {
+			return storage.ImpossibleTypeErr(t, obj)
+		}
+		if subtle.ConstantTimeCompare(t.Hash, hash) != 1 {
+			// The token was deleted and created again.
+			return storage.ErrNoObjectExists
+		}
+		t.LastUsed = lastUsed
+		return kv.store.ReplaceTx(tx, t)
+	}))
+}
+
+func (kv *tokenKV) Delete(name string) error {
+	return kv.error(kv.store.Delete(name))
+}
+
+func (kv *tokenKV) List(pattern string, offset, limit int) ([]Token, error) {
+	objects, err := kv.store.List(storage.DefaultIDIndex, pattern, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]Token, len(objects))
+	for i, object := range objects {
+		t, ok := object.(*Token)
+		if !ok {
+			return nil, storage.ImpossibleTypeErr(t, object)
+		}
+		tokens[i] = *t
+	}
+	return tokens, nil
+}
+
+func (kv *tokenKV) Rebuild() error {
+	return kv.store.Rebuild()
+}
@@ -0,0 +1,583 @@
+package localauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/kapacitor/auth"
+	client "github.com/influxdata/kapacitor/client/v1"
+	"github.com/influxdata/kapacitor/services/httpd"
+	"github.com/influxdata/kapacitor/services/storage"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	usersPath         = "/users"
+	usersPathAnchored = "/users/"
+	usersBasePath     = httpd.BasePath + usersPath
+
+	// Public names of the stores
+	usersAPIName         = "users"
+	subscriptionsAPIName = "subscriptions"
+	// The storage namespace for all auth data.
+	authNamespace = "auth"
+
+	saltSize = 16
+)
+
+var validUsername = regexp.MustCompile(`^[-\w.@]+$`)
+
+// Provide an implementation of an Authentication service,
+// where users, API tokens and their privileges are stored locally.
+type Service struct {
+	bcryptCost      int
+	cacheExpiration time.Duration
+	adminUsername   string
+	adminPassword   string
+
+	users         UserDAO
+	subscriptions SubscriptionDAO
+	tokens        TokenDAO
+	routes        []httpd.Route
+
+	mu sync.Mutex
+	// Cache of successful authentications by username.
+	cache map[string]cachedAuth
+	// Random salt of the password digests in the cache.
+	salt []byte
+	// A hash that matches no password, compared when a user does not exist.
+	dummyHash []byte
+
+	logger *log.Logger
+
+	StorageService interface {
+		Store(namespace string) storage.Interface
+		Register(name string, store storage.StoreActioner)
+	}
+	HTTPDService interface {
+		AddRoutes([]httpd.Route) error
+		DelRoutes([]httpd.Route)
+	}
+}
+
+// cachedAuth is a successful authentication, the password is only kept as a salted digest.
+type cachedAuth struct {
+	digest  [sha256.Size]byte
+	user    auth.User
+	expires time.Time
+}
+
+func NewService(c Config, l *log.Logger) *Service {
+	return &Service{
+		bcryptCost:      c.BcryptCost,
+		cacheExpiration: time.Duration(c.CacheExpiration),
+		adminUsername:   c.AdminUsername,
+		adminPassword:   c.AdminPassword,
+		cache:           make(map[string]cachedAuth),
+		logger:          l,
+	}
+}
+
+func (s *Service) Open() error {
+	s.salt = make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, s.salt); err != nil {
+		return errors.Wrap(err, "failed to generate salt")
+	}
+	dummyHash, err := bcrypt.GenerateFromPassword(s.salt, s.bcryptCost)
+	if err != nil {
+		return errors.Wrap(err, "failed to generate hash")
+	}
+	s.dummyHash = dummyHash
+
+	store := s.StorageService.Store(authNamespace)
+	users, err := newUserKV(store)
+	if err != nil {
+		return err
+	}
+	s.users = users
+	s.StorageService.Register(usersAPIName, s.users)
+
+	subscriptions, err := newSubscriptionKV(store)
+	if err != nil {
+		return err
+	}
+	s.subscriptions = subscriptions
+	s.StorageService.Register(subscriptionsAPIName, s.subscriptions)
+
+	tokens, err := newTokenKV(store)
+	if err != nil {
+		return err
+	}
+	s.tokens = tokens
+	s.StorageService.Register(tokensAPIName, s.tokens)
+
+	if err := s.createAdminUser(); err != nil {
+		return err
+	}
+
+	// Define API routes
+	s.routes = []httpd.Route{
+		{
+			Method:      "GET",
+			Pattern:     usersPath,
+			HandlerFunc: s.handleListUsers,
+		},
+		{
+			Method:      "POST",
+			Pattern:     usersPath,
+			HandlerFunc: s.handleCreateUser,
+		},
+		{
+			Method:      "GET",
+			Pattern:     usersPathAnchored,
+			HandlerFunc: s.handleGetUser,
+		},
+		{
+			Method:      "PATCH",
+			Pattern:     usersPathAnchored,
+			HandlerFunc: s.handleUpdateUser,
+		},
+		{
+			Method:      "DELETE",
+			Pattern:     usersPathAnchored,
+			HandlerFunc: s.handleDeleteUser,
+		},
+		{
+			// Satisfy CORS checks.
+			Method:      "OPTIONS",
+			Pattern:     usersPathAnchored,
+			HandlerFunc: httpd.ServeOptions,
+		},
+	}
+	s.routes = append(s.routes, s.tokenRoutes()...)
+
+	err = s.HTTPDService.AddRoutes(s.routes)
+	return errors.Wrap(err, "failed to add API routes")
+}
+
+func (s *Service) Close() error {
+	if s.HTTPDService != nil {
+		s.HTTPDService.DelRoutes(s.routes)
+	}
+	return nil
+}
+
+// createAdminUser creates the configured admin user if no users exist.
+func (s *Service) createAdminUser() error {
+	if s.adminUsername == "" {
+		return nil
+	}
+	users, err := s.users.List("", 0, 1)
+	if err != nil {
+		return errors.Wrap(err, "failed to list users")
+	}
+	if len(users) > 0 {
+		return nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(s.adminPassword), s.bcryptCost)
+	if err != nil {
+		return errors.Wrap(err, "failed to hash admin password")
+	}
+	if err := s.users.Create(User{
+		Name:  s.adminUsername,
+		Hash:  hash,
+		Admin: true,
+	}); err != nil {
+		return errors.Wrap(err, "failed to create admin user")
+	}
+	s.logger.Printf("I! created admin user %s", s.adminUsername)
+	return nil
+}
+
+// Authenticate the user with the password and return the user.
+func (s *Service) Authenticate(username, password string) (auth.User, error) {
+	digest := s.digest(password)
+	s.mu.Lock()
+	c, ok := s.cache[username]
+	s.mu.Unlock()
+	if ok && c.digest == digest && time.Now().Before(c.expires) {
+		return c.user, nil
+	}
+
+	u, err := s.users.Get(username)
+	if err != nil {
+		if err == ErrNoUserExists {
+			// Do the same work as for an existing user to not reveal which users exist.
+			bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+			return auth.User{}, errors.New("authentication failed")
+		}
+		return auth.User{}, err
+	}
+	if err := bcrypt.CompareHashAndPassword(u.Hash, []byte(password)); err != nil {
+		return auth.User{}, errors.New("authentication failed")
+	}
+	user, err := convertUser(u)
+	if err != nil {
+		return auth.User{}, err
+	}
+	if s.cacheExpiration > 0 {
+		s.mu.Lock()
+		s.cache[username] = cachedAuth{
+			digest:  digest,
+			user:    user,
+			expires: time.Now().Add(s.cacheExpiration),
+		}
+		s.mu.Unlock()
+	}
+	return user, nil
+}
+
+// Return the user with the given username.
+func (s *Service) User(username string) (auth.User, error) {
+	u, err := s.users.Get(username)
+	if err != nil {
+		return auth.User{}, err
+	}
+	return convertUser(u)
+}
+
+// Return a user with write privileges on the database of the subscription.
+func (s *Service) SubscriptionUser(token string) (auth.User, error) {
+	sub, err := s.subscriptions.Get(token)
+	if err != nil {
+		if err == ErrNoSubscriptionExists {
+			return auth.User{}, errors.New("invalid subscription token")
+		}
+		return auth.User{}, err
+	}
+	return auth.NewUser(httpd.SubscriptionUser, nil, false, map[string][]auth.Privilege{
+		auth.DatabaseResource(sub.Database): {auth.WritePrivilege},
+	}), nil
+}
+
+func (s *Service) GrantSubscriptionAccess(token, db, rp string) error {
+	return s.subscriptions.Set(Subscription{
+		Token:           token,
+		Database:        db,
+		RetentionPolicy: rp,
+	})
+}
+
+func (s *Service) ListSubscriptionTokens() ([]string, error) {
+	subscriptions, err := s.subscriptions.List()
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]string, len(subscriptions))
+	for i, sub := range subscriptions {
+		tokens[i] = sub.Token
+	}
+	return tokens, nil
+}
+
+func (s *Service) RevokeSubscriptionAccess(token string) error {
+	return s.subscriptions.Delete(token)
+}
+
+func (s *Service) digest(password string) [sha256.Size]byte {
+	return sha256.Sum256(append(append([]byte{}, s.salt...), password...))
+}
+
+func (s *Service) invalidate(username string) {
+	s.mu.Lock()
+	delete(s.cache, username)
+	s.mu.Unlock()
+}
+
+// convertUser converts a stored user into an auth.User.
+func convertUser(u User) (auth.User, error) {
+	privileges, err := parsePrivileges(u.Privileges)
+	if err != nil {
+		return auth.User{}, errors.Wrapf(err, "invalid privileges of user %s", u.Name)
+	}
+	return auth.NewUser(u.Name, u.Hash, u.Admin, privileges), nil
+}
+
+func parsePrivileges(names map[string][]string) (map[string][]auth.Privilege, error) {
+	privileges := make(map[string][]auth.Privilege, len(names))
+	for resource, ns := range names {
+		if !path.IsAbs(resource) {
+			return nil, fmt.Errorf("resource %q must be an absolute path", resource)
+		}
+		ps := make([]auth.Privilege, len(ns))
+		for i, n := range ns {
+			p, err := auth.ParsePrivilege(n)
+			if err != nil {
+				return nil, err
+			}
+			ps[i] = p
+		}
+		privileges[resource] = ps
+	}
+	return privileges, nil
+}
+
+// normalizePrivileges cleans the resources and sorts the privilege names.
+func normalizePrivileges(names map[string][]string) (map[string][]string, error) {
+	privileges, err := parsePrivileges(names)
+	if err != nil {
+		return nil, err
+	}
+	normalized := make(map[string][]string, len(privileges))
+	for resource, ps := range auth.NewUser("", nil, false, privileges).Privileges() {
+		ns := make([]string, len(ps))
+		for i, p := range ps {
+			ns[i] = p.String()
+		}
+		normalized[resource] = ns
+	}
+	return normalized, nil
+}
+
+// authorizePrivileges returns an error if the caller does not hold all of the privileges,
+// so that a caller can only grant privileges it already has.
+func authorizePrivileges(caller auth.User, names map[string][]string) error {
+	privileges, err := parsePrivileges(names)
+	if err != nil {
+		return err
+	}
+	for resource, ps := range privileges {
+		for _, p := range ps {
+			if err := caller.AuthorizeAction(auth.Action{Resource: resource, Privilege: p}); err != nil {
+				return errors.Wrap(err, "cannot grant privileges the caller does not hold")
+			}
+		}
+	}
+	return nil
+}
+
+// authorizeUser returns an error if the caller may not modify the user.
+// Only admins may modify admin users and users with privileges the caller does not hold.
+func authorizeUser(caller auth.User, u User) error {
+	if u.Admin && !caller.IsAdmin() {
+		return errors.New("only admins may modify admin users")
+	}
+	return authorizePrivileges(caller, u.Privileges)
+}
+
+func (s *Service) userLink(name string) client.Link {
+	return client.Link{Relation: client.Self, Href: path.Join(usersBasePath, name)}
+}
+
+func (s *Service) convertClientUser(u User) client.User {
+	privileges := u.Privileges
+	if privileges == nil {
+		privileges = make(map[string][]string)
+	}
+	return client.User{
+		Link:       s.userLink(u.Name),
+		Name:       u.Name,
+		Admin:      u.Admin,
+		Privileges: privileges,
+	}
+}
+
+func (s *Service) nameFromPath(p string) string {
+	return strings.TrimPrefix(p, usersBasePath+"/")
+}
+
+func (s *Service) handleListUsers(w http.ResponseWriter, r *http.Request) {
+	pattern := r.URL.Query().Get("pattern")
+
+	var err error
+	offset := int64(0)
+	offsetStr := r.URL.Query().Get("offset")
+	if offsetStr != "" {
+		offset, err = strconv.ParseInt(offsetStr, 10, 64)
+		if err != nil {
+			httpd.HttpError(w, fmt.Sprintf("invalid offset parameter %q must be an integer: %s", offsetStr, err), true, http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := int64(100)
+	limitStr := r.URL.Query().Get("limit")
+	if limitStr != "" {
+		limit, err = strconv.ParseInt(limitStr, 10, 64)
+		if err != nil {
+			httpd.HttpError(w, fmt.Sprintf("invalid limit parameter %q must be an integer: %s", limitStr, err), true, http.StatusBadRequest)
+			return
+		}
+	}
+
+	users, err := s.users.List(pattern, int(offset), int(limit))
+	if err != nil {
+		httpd.HttpError(w, fmt.Sprintf("failed to list users with pattern %q: %s", pattern, err), true, http.StatusBadRequest)
+		return
+	}
+	list := make([]client.User, len(users))
+	for i, u := range users {
+		list[i] = s.convertClientUser(u)
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(httpd.MarshalJSON(client.Users{
+		Link:  client.Link{Relation: client.Self, Href: r.URL.String()},
+		Users: list,
+	}, true))
+}
+
+func (s *Service) handleCreateUser(w http.ResponseWriter, r *http.Request, caller auth.User) {
+	opt := client.CreateUserOptions{}
+	if err := json.NewDecoder(r.Body).Decode(&opt); err != nil {
+		httpd.HttpError(w, fmt.Sprint("invalid user json: ", err.Error()), true, http.StatusBadRequest)
+		return
+	}
+	if !validUsername.MatchString(opt.Name) {
+		httpd.HttpError(w, fmt.Sprintf("invalid user name %q, must contain only letters, numbers, '-', '_', '.' and '@'", opt.Name), true, http.StatusBadRequest)
+		return
+	}
+	if opt.Password == "" {
+		httpd.HttpError(w, "must provide a password", true, http.StatusBadRequest)
+		return
+	}
+	privileges, err := normalizePrivileges(opt.Privileges)
+	if err != nil {
+		httpd.HttpError(w, fmt.Sprint("invalid privileges: ", err.Error()), true, http.StatusBadRequest)
+		return
+	}
+	if opt.Admin && !caller.IsAdmin() {
+		httpd.HttpError(w, "only admins may create admin users", true, http.StatusForbidden)
+		return
+	}
+	if err := authorizePrivileges(caller, privileges); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusForbidden)
+		return
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(opt.Password), s.bcryptCost)
+	if err != nil {
+		httpd.HttpError(w, fmt.Sprint("failed to hash password: ", err.Error()), true, http.StatusInternalServerError)
+		return
+	}
+	u := User{
+		Name:       opt.Name,
+		Hash:       hash,
+		Admin:      opt.Admin,
+		Privileges: privileges,
+	}
+	if err := s.users.Create(u); err != nil {
+		code := http.StatusInternalServerError
+		if err == ErrUserExists {
+			code = http.StatusBadRequest
+		}
+		httpd.HttpError(w, fmt.Sprintf("failed to create user %q: %v", u.Name, err), true, code)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(httpd.MarshalJSON(s.convertClientUser(u), true))
+}
+
+func (s *Service) handleGetUser(w http.ResponseWriter, r *http.Request) {
+	name := s.nameFromPath(r.URL.Path)
+	u, err := s.users.Get(name)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if err == ErrNoUserExists {
+			code = http.StatusNotFound
+		}
+		httpd.HttpError(w, fmt.Sprintf("failed to get user %q: %v", name, err), true, code)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(httpd.MarshalJSON(s.convertClientUser(u), true))
+}
+
+func (s *Service) handleUpdateUser(w http.ResponseWriter, r *http.Request, caller auth.User) {
+	name := s.nameFromPath(r.URL.Path)
+	opt := client.UpdateUserOptions{}
+	if err := json.NewDecoder(r.Body).Decode(&opt); err != nil {
+		httpd.HttpError(w, fmt.Sprint("invalid user json: ", err.Error()), true, http.StatusBadRequest)
+		return
+	}
+	u, err := s.users.Get(name)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if err == ErrNoUserExists {
+			code = http.StatusNotFound
+		}
+		httpd.HttpError(w, fmt.Sprintf("failed to get user %q: %v", name, err), true, code)
+		return
+	}
+	if err := authorizeUser(caller, u); err != nil {
+		httpd.HttpError(w, fmt.Sprintf("cannot update user %q: %v", name, err), true, http.StatusForbidden)
+		return
+	}
+	if opt.Admin != nil && *opt.Admin != u.Admin && !caller.IsAdmin() {
+		httpd.HttpError(w, "only admins may change the admin flag of users", true, http.StatusForbidden)
+		return
+	}
+	if opt.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(opt.Password), s.bcryptCost)
+		if err != nil {
+			httpd.HttpError(w, fmt.Sprint("failed to hash password: ", err.Error()), true, http.StatusInternalServerError)
+			return
+		}
+		u.Hash = hash
+	}
+	if opt.Admin != nil {
+		u.Admin = *opt.Admin
+	}
+	if opt.Privileges != nil {
+		privileges, err := normalizePrivileges(opt.Privileges)
+		if err != nil {
+			httpd.HttpError(w, fmt.Sprint("invalid privileges: ", err.Error()), true, http.StatusBadRequest)
+			return
+		}
+		if err := authorizePrivileges(caller, privileges); err != nil {
+			httpd.HttpError(w, err.Error(), true, http.StatusForbidden)
+			return
+		}
+		u.Privileges = privileges
+	}
+	if err := s.users.Replace(u); err != nil {
+		httpd.HttpError(w, fmt.Sprintf("failed to update user %q: %v", name, err), true, http.StatusInternalServerError)
+		return
+	}
+	s.invalidate(name)
+	user, err := convertUser(u)
+	if err == nil {
+		err = s.revokeTokens(name, &user)
+	}
+	if err != nil {
+		httpd.HttpError(w, fmt.Sprintf("failed to revoke tokens of user %q: %v", name, err), true, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(httpd.MarshalJSON(s.convertClientUser(u), true))
+}
+
+func (s *Service) handleDeleteUser(w http.ResponseWriter, r *http.Request, caller auth.User) {
+	name := s.nameFromPath(r.URL.Path)
+	u, err := s.users.Get(name)
+	if err != nil && err != ErrNoUserExists {
+		httpd.HttpError(w, fmt.Sprintf("failed to get user %q: %v", name, err), true, http.StatusInternalServerError)
+		return
+	}
+	if err == nil {
+		if err := authorizeUser(caller, u); err != nil {
+			httpd.HttpError(w, fmt.Sprintf("cannot delete user %q: %v", name, err), true, http.StatusForbidden)
+			return
+		}
+	}
+	if err := s.users.Delete(name); err != nil {
+		httpd.HttpError(w, fmt.Sprintf("failed to delete user %q: %v", name, err), true, http.StatusInternalServerError)
+		return
+	}
+	s.invalidate(name)
+	if err := s.revokeTokens(name, nil); err != nil {
+		httpd.HttpError(w, fmt.Sprintf("failed to revoke tokens of user %q: %v", name, err), true, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,569 @@
+package localauth_test
+
+import (
+	"expvar"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/kapacitor/auth"
+	client "github.com/influxdata/kapacitor/client/v1"
+	"github.com/influxdata/kapacitor/services/httpd"
+	"github.com/influxdata/kapacitor/services/httpd/httpdtest"
+	"github.com/influxdata/kapacitor/services/localauth"
+	"github.com/influxdata/kapacitor/services/logging/loggingtest"
+	"github.com/influxdata/kapacitor/services/storage/storagetest"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func OpenNewService(c localauth.Config) (*localauth.Service, *client.Client, *httpdtest.Server) {
+	service := localauth.NewService(c, log.New(os.Stderr, "[auth] ", log.LstdFlags))
+	service.StorageService = storagetest.New()
+	server := httpdtest.NewServer(testing.Verbose())
+	service.HTTPDService = server
+	if err := service.Open(); err != nil {
+		panic(err)
+	}
+	cli, err := client.New(client.Config{URL: server.Server.URL})
+	if err != nil {
+		panic(err)
+	}
+	return service, cli, server
+}
+
+func newConfig() localauth.Config {
+	c := localauth.NewConfig()
+	c.Enabled = true
+	c.BcryptCost = bcrypt.MinCost
+	return c
+}
+
+func TestService_Users(t *testing.T) {
+	service, cli, server := OpenNewService(newConfig())
+	defer server.Close()
+	defer service.Close()
+
+	u, err := cli.CreateUser(client.CreateUserOptions{
+		Name:     "bob",
+		Password: "secret",
+		Privileges: map[string][]string{
+			"/api/tasks/": {"write", "read"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := client.User{
+		Link: cli.UserLink("bob"),
+		Name: "bob",
+		Privileges: map[string][]string{
+			"/api/tasks": {"read", "write"},
+		},
+	}
+	if !reflect.DeepEqual(u, exp) {
+		t.Errorf("unexpected user:\ngot\n%v\nexp\n%v", u, exp)
+	}
+	if _, err := cli.CreateUser(client.CreateUserOptions{Name: "bob", Password: "other"}); err == nil {
+		t.Error("expected error creating existing user")
+	}
+	if _, err := cli.CreateUser(client.CreateUserOptions{Name: "alice", Password: "secret", Privileges: map[string][]string{"/api": {"execute"}}}); err == nil {
+		t.Error("expected error creating user with unknown privilege")
+	}
+
+	user, err := service.Authenticate("bob", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := user.AuthorizeAction(auth.Action{Resource: "/api/tasks/cpu", Privilege: auth.WritePrivilege}); err != nil {
+		t.Error(err)
+	}
+	if err := user.AuthorizeAction(auth.Action{Resource: "/api/tasks/cpu", Privilege: auth.DeletePrivilege}); err == nil {
+		t.Error("expected user not to be authorized to delete tasks")
+	}
+	if _, err := service.Authenticate("bob", "wrong"); err == nil {
+		t.Error("expected authentication with wrong password to fail")
+	}
+	if _, err := service.Authenticate("carol", "secret"); err == nil {
+		t.Error("expected authentication of unknown user to fail")
+	}
+
+	// Changing the password invalidates cached authentications.
+	admin := true
+	u, err = cli.UpdateUser(cli.UserLink("bob"), client.UpdateUserOptions{
+		Password:   "new-secret",
+		Admin:      &admin,
+		Privileges: map[string][]string{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp = client.User{
+		Link:       cli.UserLink("bob"),
+		Name:       "bob",
+		Admin:      true,
+		Privileges: map[string][]string{},
+	}
+	if !reflect.DeepEqual(u, exp) {
+		t.Errorf("unexpected updated user:\ngot\n%v\nexp\n%v", u, exp)
+	}
+	if _, err := service.Authenticate("bob", "secret"); err == nil {
+		t.Error("expected authentication with old password to fail")
+	}
+	user, err = service.Authenticate("bob", "new-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.IsAdmin() {
+		t.Error("expected user to be an admin")
+	}
+
+	users, err := cli.ListUsers(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users.Users) != 1 || users.Users[0].Name != "bob" {
+		t.Errorf("unexpected users %v", users.Users)
+	}
+
+	if err := cli.DeleteUser(cli.UserLink("bob")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.User(cli.UserLink("bob")); err == nil {
+		t.Error("expected error getting deleted user")
+	}
+	if _, err := service.Authenticate("bob", "new-secret"); err == nil {
+		t.Error("expected authentication of deleted user to fail")
+	}
+}
+
+func TestService_AdminUser(t *testing.T) {
+	c := newConfig()
+	c.AdminUsername = "admin"
+	c.AdminPassword = "secret"
+	service, _, server := OpenNewService(c)
+	defer server.Close()
+	defer service.Close()
+
+	user, err := service.Authenticate("admin", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.IsAdmin() {
+		t.Error("expected user to be an admin")
+	}
+}
+
+func TestService_Subscriptions(t *testing.T) {
+	service, _, server := OpenNewService(newConfig())
+	defer server.Close()
+	defer service.Close()
+
+	if err := service.GrantSubscriptionAccess("token", "db", "rp"); err != nil {
+		t.Fatal(err)
+	}
+	user, err := service.SubscriptionUser("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := user.Name(), httpd.SubscriptionUser; got != exp {
+		t.Errorf("unexpected user name got %s exp %s", got, exp)
+	}
+	if err := user.AuthorizeAction(auth.Action{Resource: auth.DatabaseResource("db"), Privilege: auth.WritePrivilege}); err != nil {
+		t.Error(err)
+	}
+	if err := user.AuthorizeAction(auth.Action{Resource: auth.DatabaseResource("other"), Privilege: auth.WritePrivilege}); err == nil {
+		t.Error("expected subscription user not to be authorized to write to other databases")
+	}
+
+	tokens, err := service.ListSubscriptionTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []string{"token"}; !reflect.DeepEqual(tokens, exp) {
+		t.Errorf("unexpected tokens got %v exp %v", tokens, exp)
+	}
+	if err := service.RevokeSubscriptionAccess("token"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.SubscriptionUser("token"); err == nil {
+		t.Error("expected error for revoked token")
+	}
+}
+
+func TestService_Tokens(t *testing.T) {
+	service, cli, server := OpenNewService(newConfig())
+	defer server.Close()
+	defer service.Close()
+
+	token, err := cli.CreateToken(client.CreateTokenOptions{
+		Name: "deploy",
+		Privileges: map[string][]string{
+			"/api/tasks/*":                       {"read"},
+			"/api/preview/alerts/topics/team-x*": {"write"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(token.Token, "deploy:") {
+		t.Fatalf("unexpected token %q", token.Token)
+	}
+	if _, err := cli.CreateToken(client.CreateTokenOptions{Name: "deploy", Privileges: map[string][]string{"/api": {"read"}}}); err == nil {
+		t.Error("expected error creating existing token")
+	}
+	if _, err := cli.CreateToken(client.CreateTokenOptions{Name: "old", Privileges: map[string][]string{"/api": {"read"}}, Expires: time.Now().Add(-time.Hour)}); err == nil {
+		t.Error("expected error creating expired token")
+	}
+
+	user, err := service.TokenUser(token.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorized := []auth.Action{
+		{Resource: "/api/tasks/cpu", Privilege: auth.ReadPrivilege},
+		{Resource: "/api/preview/alerts/topics/team-x-db/handlers", Privilege: auth.WritePrivilege},
+	}
+	for _, a := range authorized {
+		if err := user.AuthorizeAction(a); err != nil {
+			t.Error(err)
+		}
+	}
+	unauthorized := []auth.Action{
+		{Resource: "/api/tasks/cpu", Privilege: auth.WritePrivilege},
+		{Resource: "/api/tasks", Privilege: auth.ReadPrivilege},
+		{Resource: "/api/preview/alerts/topics/team-y", Privilege: auth.WritePrivilege},
+	}
+	for _, a := range unauthorized {
+		if err := user.AuthorizeAction(a); err == nil {
+			t.Errorf("expected token not to be authorized for %v", a)
+		}
+	}
+	if _, err := service.TokenUser("deploy:wrong"); err == nil {
+		t.Error("expected error for wrong secret")
+	}
+
+	got, err := cli.Token(cli.TokenLink("deploy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Token != "" {
+		t.Error("expected secret not to be returned")
+	}
+	if got.LastUsed.IsZero() {
+		t.Error("expected last used time to be set")
+	}
+
+	if err := cli.DeleteToken(cli.TokenLink("deploy")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.TokenUser(token.Token); err == nil {
+		t.Error("expected error for revoked token")
+	}
+}
+
+// OpenNewAuthService returns a service whose API requires authentication with the users of the service.
+func OpenNewAuthService(c localauth.Config) (*localauth.Service, *httpdtest.Server) {
+	service := localauth.NewService(c, log.New(os.Stderr, "[auth] ", log.LstdFlags))
+	service.StorageService = storagetest.New()
+	statMap := &expvar.Map{}
+	statMap.Init()
+	ls := loggingtest.New()
+	h := httpd.NewHandler(true, false, false, false, false, statMap, ls.NewLogger("[httpd] ", log.LstdFlags), ls, "", nil, "")
+	h.AuthService = service
+	server := &httpdtest.Server{
+		Handler: h,
+		Server:  httptest.NewServer(h),
+	}
+	service.HTTPDService = server
+	if err := service.Open(); err != nil {
+		panic(err)
+	}
+	return service, server
+}
+
+func TestService_Users_NoPrivilegeEscalation(t *testing.T) {
+	c := newConfig()
+	c.AdminUsername = "admin"
+	c.AdminPassword = "admin-secret"
+	service, server := OpenNewAuthService(c)
+	defer server.Close()
+	defer service.Close()
+
+	newClient := func(username, password string) *client.Client {
+		cli, err := client.New(client.Config{
+			URL: server.Server.URL,
+			Credentials: &client.Credentials{
+				Method:   client.UserAuthentication,
+				Username: username,
+				Password: password,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cli
+	}
+	admin := newClient("admin", "admin-secret")
+	if _, err := admin.CreateUser(client.CreateUserOptions{
+		Name:       "bob",
+		Password:   "secret",
+		Privileges: map[string][]string{"/api/users": {"read", "write", "delete"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(method, p, body string) int {
+		req, err := http.NewRequest(method, server.Server.URL+p, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("bob", "secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		code   int
+	}{
+		{
+			name:   "create admin",
+			method: "POST",
+			path:   "/kapacitor/v1/users",
+			body:   `{"name":"mallory","password":"secret","admin":true}`,
+			code:   http.StatusForbidden,
+		},
+		{
+			name:   "create user with privileges the caller does not hold",
+			method: "POST",
+			path:   "/kapacitor/v1/users",
+			body:   `{"name":"mallory","password":"secret","privileges":{"/api":["all"]}}`,
+			code:   http.StatusForbidden,
+		},
+		{
+			name:   "grant self admin",
+			method: "PATCH",
+			path:   "/kapacitor/v1/users/bob",
+			body:   `{"admin":true}`,
+			code:   http.StatusForbidden,
+		},
+		{
+			name:   "grant self privileges",
+			method: "PATCH",
+			path:   "/kapacitor/v1/users/bob",
+			body:   `{"privileges":{"/":["all"]}}`,
+			code:   http.StatusForbidden,
+		},
+		{
+			name:   "change password of admin",
+			method: "PATCH",
+			path:   "/kapacitor/v1/users/admin",
+			body:   `{"password":"taken"}`,
+			code:   http.StatusForbidden,
+		},
+		{
+			name:   "delete admin",
+			method: "DELETE",
+			path:   "/kapacitor/v1/users/admin",
+			code:   http.StatusForbidden,
+		},
+		{
+			name:   "create user with held privileges",
+			method: "POST",
+			path:   "/kapacitor/v1/users",
+			body:   `{"name":"carol","password":"secret","privileges":{"/api/users":["read"]}}`,
+			code:   http.StatusOK,
+		},
+	}
+	for _, tc := range testCases {
+		if code := do(tc.method, tc.path, tc.body); code != tc.code {
+			t.Errorf("%s: unexpected status code got %d exp %d", tc.name, code, tc.code)
+		}
+	}
+	if u, err := service.User("bob"); err != nil {
+		t.Fatal(err)
+	} else if u.IsAdmin() {
+		t.Error("expected bob not to be an admin")
+	}
+	if _, err := service.Authenticate("admin", "admin-secret"); err != nil {
+		t.Error("expected admin password to be unchanged")
+	}
+}
+
+func TestService_Tokens_NoPrivilegeEscalation(t *testing.T) {
+	c := newConfig()
+	c.AdminUsername = "admin"
+	c.AdminPassword = "admin-secret"
+	service, server := OpenNewAuthService(c)
+	defer server.Close()
+	defer service.Close()
+
+	admin, err := client.New(client.Config{
+		URL: server.Server.URL,
+		Credentials: &client.Credentials{
+			Method:   client.UserAuthentication,
+			Username: "admin",
+			Password: "admin-secret",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := admin.CreateToken(client.CreateTokenOptions{
+		Name: "deploy",
+		Privileges: map[string][]string{
+			"/api/tokens": {"write"},
+			"/api/tasks":  {"read"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	create := func(body string) int {
+		req, err := http.NewRequest("POST", server.Server.URL+"/kapacitor/v1/tokens", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Token "+token.Token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := create(`{"name":"root","privileges":{"/":["all"]}}`); code != http.StatusForbidden {
+		t.Errorf("unexpected status code creating token with all privileges got %d exp %d", code, http.StatusForbidden)
+	}
+	if code := create(`{"name":"tasks","privileges":{"/api/tasks":["read","write"]}}`); code != http.StatusForbidden {
+		t.Errorf("unexpected status code creating token with more privileges got %d exp %d", code, http.StatusForbidden)
+	}
+	if code := create(`{"name":"tasks","privileges":{"/api/tasks/cpu":["read"]}}`); code != http.StatusOK {
+		t.Errorf("unexpected status code creating token with a subset of privileges got %d exp %d", code, http.StatusOK)
+	}
+}
+
+func TestService_Tokens_Owner(t *testing.T) {
+	c := newConfig()
+	c.AdminUsername = "admin"
+	c.AdminPassword = "admin-secret"
+	service, server := OpenNewAuthService(c)
+	defer server.Close()
+	defer service.Close()
+
+	newClient := func(credentials client.Credentials) *client.Client {
+		cli, err := client.New(client.Config{
+			URL:         server.Server.URL,
+			Credentials: &credentials,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cli
+	}
+	admin := newClient(client.Credentials{Method: client.UserAuthentication, Username: "admin", Password: "admin-secret"})
+	if _, err := admin.CreateUser(client.CreateUserOptions{
+		Name:     "bob",
+		Password: "secret",
+		Privileges: map[string][]string{
+			"/api/tokens": {"read", "write", "delete"},
+			"/api/tasks":  {"read"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.CreateToken(client.CreateTokenOptions{Name: "admin-token", Privileges: map[string][]string{"/api": {"read"}}}); err != nil {
+		t.Fatal(err)
+	}
+	bob := newClient(client.Credentials{Method: client.UserAuthentication, Username: "bob", Password: "secret"})
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	token, err := bob.CreateToken(client.CreateTokenOptions{
+		Name:       "bob-tokens",
+		Privileges: map[string][]string{"/api/tokens": {"read", "write"}},
+		Expires:    expires,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Owner != "bob" {
+		t.Errorf("unexpected owner got %q exp %q", token.Owner, "bob")
+	}
+
+	// Tokens created with a token belong to its owner and expire with it.
+	bobToken := newClient(client.Credentials{Method: client.TokenAuthentication, Token: token.Token})
+	if _, err := bobToken.CreateToken(client.CreateTokenOptions{
+		Name:       "bob-later",
+		Privileges: map[string][]string{"/api/tokens": {"read"}},
+		Expires:    expires.Add(time.Hour),
+	}); err == nil {
+		t.Error("expected error creating token expiring after the token of the caller")
+	}
+	tasks, err := bobToken.CreateToken(client.CreateTokenOptions{
+		Name:       "bob-tasks",
+		Privileges: map[string][]string{"/api/tokens": {"read"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tasks.Owner != "bob" || !tasks.Expires.Equal(expires) {
+		t.Errorf("unexpected owner %q or expiry %v of token created with a token", tasks.Owner, tasks.Expires)
+	}
+
+	// Only the owner and admins may get, list or delete tokens.
+	if _, err := bob.Token(bob.TokenLink("admin-token")); err == nil {
+		t.Error("expected error getting token of another user")
+	}
+	if err := bob.DeleteToken(bob.TokenLink("admin-token")); err == nil {
+		t.Error("expected error deleting token of another user")
+	}
+	if _, err := admin.Token(admin.TokenLink("bob-tasks")); err != nil {
+		t.Errorf("unexpected error getting token as admin: %v", err)
+	}
+	list, err := bob.ListTokens(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tok := range list.Tokens {
+		names = append(names, tok.Name)
+	}
+	if exp := []string{"bob-tasks", "bob-tokens"}; !reflect.DeepEqual(names, exp) {
+		t.Errorf("unexpected tokens listed got %v exp %v", names, exp)
+	}
+
+	// Tokens with privileges the owner lost are revoked.
+	if _, err := admin.UpdateUser(admin.UserLink("bob"), client.UpdateUserOptions{
+		Privileges: map[string][]string{"/api/tokens": {"read"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.TokenUser(token.Token); err == nil {
+		t.Error("expected token with lost privileges to be revoked")
+	}
+	if _, err := service.TokenUser(tasks.Token); err != nil {
+		t.Errorf("unexpected error for token with held privileges: %v", err)
+	}
+
+	// All tokens of a deleted user are revoked.
+	if err := admin.DeleteUser(admin.UserLink("bob")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.TokenUser(tasks.Token); err == nil {
+		t.Error("expected token of deleted user to be revoked")
+	}
+	if _, err := admin.Token(admin.TokenLink("admin-token")); err != nil {
+		t.Errorf("unexpected error getting token of other user: %v", err)
+	}
+}
@@ -0,0 +1,339 @@
+package localauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/kapacitor/auth"
+	client "github.com/influxdata/kapacitor/client/v1"
+	"github.com/influxdata/kapacitor/services/httpd"
+	"github.com/pkg/errors"
+)
+
+const (
+	tokensPath         = "/tokens"
+	tokensPathAnchored = "/tokens/"
+	tokensBasePath     = httpd.BasePath + tokensPath
+
+	// Public name of the tokens store
+	tokensAPIName = "tokens"
+
+	// The separator of the name and the secret of a token.
+	tokenSeparator = ":"
+	// The prefix of the names of users authenticated with a token.
+	tokenUserPrefix = "token:"
+	secretSize      = 32
+
+	// How often the last used time of a token is stored.
+	lastUsedResolution = time.Minute
+)
+
+// Return a user with the privileges of the API token.
+// The token has the form <name>:<secret>.
+func (s *Service) TokenUser(token string) (auth.User, error) {
+	i := strings.Index(token, tokenSeparator)
+	if i <= 0 {
+		return auth.User{}, errors.New("invalid token")
+	}
+	name, secret := token[:i], token[i+1:]
+	t, err := s.tokens.Get(name)
+	if err != nil {
+		if err == ErrNoTokenExists {
+			return auth.User{}, errors.New("invalid token")
+		}
+		return auth.User{}, err
+	}
+	hash := sha256.Sum256([]byte(secret))
+	if subtle.ConstantTimeCompare(hash[:], t.Hash) != 1 {
+		return auth.User{}, errors.New("invalid token")
+	}
+	now := time.Now().UTC()
+	if !t.Expires.IsZero() && !now.Before(t.Expires) {
+		return auth.User{}, errors.New("token expired")
+	}
+	if now.Sub(t.LastUsed) >= lastUsedResolution {
+		if err := s.tokens.UpdateLastUsed(name, t.Hash, now); err != nil {
+			s.logger.Printf("E! failed to store last used time of token %s: %v", name, err)
+		}
+	}
+	privileges, err := parsePrivileges(t.Privileges)
+	if err != nil {
+		return auth.User{}, errors.Wrapf(err, "invalid privileges of token %s", name)
+	}
+	return auth.NewUser(tokenUserPrefix+name, nil, false, privileges), nil
+}
+
+// tokenOwner returns the owner of the tokens created by the caller
+// and the time the token of the caller expires if it is authenticated with a token.
+// Tokens created with a token belong to the owner of that token.
+func (s *Service) tokenOwner(caller auth.User) (string, time.Time, error) {
+	name := caller.Name()
+	if !strings.HasPrefix(name, tokenUserPrefix) {
+		return name, time.Time{}, nil
+	}
+	t, err := s.tokens.Get(strings.TrimPrefix(name, tokenUserPrefix))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return t.Owner, t.Expires, nil
+}
+
+// authorizeToken returns an error unless the caller is an admin or owns the token.
+func (s *Service) authorizeToken(caller auth.User, t Token) error {
+	if caller.IsAdmin() {
+		return nil
+	}
+	owner, _, err := s.tokenOwner(caller)
+	if err != nil {
+		return err
+	}
+	if owner == "" || owner != t.Owner {
+		return fmt.Errorf("token %q is not owned by %s", t.Name, caller.Name())
+	}
+	return nil
+}
+
+// revokeTokens deletes the tokens owned by the user that grant privileges the user no longer holds.
+// All tokens of the owner are deleted if the user is nil, i.e. it was deleted.
+func (s *Service) revokeTokens(owner string, user *auth.User) error {
+	tokens, err := s.tokens.List("", 0, -1)
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		if t.Owner != owner {
+			continue
+		}
+		if user != nil && authorizePrivileges(*user, t.Privileges) == nil {
+			continue
+		}
+		if err := s.tokens.Delete(t.Name); err != nil {
+			return errors.Wrapf(err, "failed to revoke token %s", t.Name)
+		}
+		s.logger.Printf("I! revoked token %s of user %s", t.Name, owner)
+	}
+	return nil
+}
+
+func (s *Service) tokenRoutes() []httpd.Route {
+	return []httpd.Route{
+		{
+			Method:      "GET",
+			Pattern:     tokensPath,
+			HandlerFunc: s.handleListTokens,
+		},
+		{
+			Method:      "POST",
+			Pattern:     tokensPath,
+			HandlerFunc: s.handleCreateToken,
+		},
+		{
+			Method:      "GET",
+			Pattern:     tokensPathAnchored,
+			HandlerFunc: s.handleGetToken,
+		},
+		{
+			Method:      "DELETE",
+			Pattern:     tokensPathAnchored,
+			HandlerFunc: s.handleDeleteToken,
+		},
+		{
+			// Satisfy CORS checks.
+			Method:      "OPTIONS",
+			Pattern:     tokensPathAnchored,
+			HandlerFunc: httpd.ServeOptions,
+		},
+	}
+}
+
+func (s *Service) tokenLink(name string) client.Link {
+	return client.Link{Relation: client.Self, Href: path.Join(tokensBasePath, name)}
+}
+
+func (s *Service) convertClientToken(t Token) client.Token {
+	privileges := t.Privileges
+	if privileges == nil {
+		privileges = make(map[string][]string)
+	}
+	return client.Token{
+		Link:       s.tokenLink(t.Name),
+		Name:       t.Name,
+		Privileges: privileges,
+		Owner:      t.Owner,
+		Created:    t.Created,
+		Expires:    t.Expires,
+		LastUsed:   t.LastUsed,
+	}
+}
+
+func (s *Service) handleListTokens(w http.ResponseWriter, r *http.Request, caller auth.User) {
+	pattern := r.URL.Query().Get("pattern")
+
+	var err error
+	offset := int64(0)
+	offsetStr := r.URL.Query().Get("offset")
+	if offsetStr != "" {
+		offset, err = strconv.ParseInt(offsetStr, 10, 64)
+		if err != nil {
+			httpd.HttpError(w, fmt.Sprintf("invalid offset parameter %q must be an integer: %s", offsetStr, err), true, http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := int64(100)
+	limitStr := r.URL.Query().Get("limit")
+	if limitStr != "" {
+		limit, err = strconv.ParseInt(limitStr, 10, 64)
+		if err != nil {
+			httpd.HttpError(w, fmt.Sprintf("invalid limit parameter %q must be an integer: %s", limitStr, err), true, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Non admins only see their own tokens, so all tokens are filtered before paging.
+	tokens, err := s.tokens.List(pattern, 0, -1)
+	if err != nil {
+		httpd.HttpError(w, fmt.Sprintf("failed to list tokens with pattern %q: %s", pattern, err), true, http.StatusBadRequest)
+		return
+	}
+	list := make([]client.Token, 0, len(tokens))
+	for _, t := range tokens {
+		if s.authorizeToken(caller, t) == nil {
+			list = append(list, s.convertClientToken(t))
+		}
+	}
+	if offset < 0 || offset > int64(len(list)) {
+		offset = int64(len(list))
+	}
+	list = list[offset:]
+	if limit >= 0 && limit < int64(len(list)) {
+		list = list[:limit]
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(httpd.MarshalJSON(client.Tokens{
+		Link:   client.Link{Relation: client.Self, Href: r.URL.String()},
+		Tokens: list,
+	}, true))
+}
+
+func (s *Service) handleCreateToken(w http.ResponseWriter, r *http.Request, caller auth.User) {
+	opt := client.CreateTokenOptions{}
+	if err := json.NewDecoder(r.Body).Decode(&opt); err != nil {
+		httpd.HttpError(w, fmt.Sprint("invalid token json: ", err.Error()), true, http.StatusBadRequest)
+		return
+	}
+	if !validUsername.MatchString(opt.Name) {
+		httpd.HttpError(w, fmt.Sprintf("invalid token name %q, must contain only letters, numbers, '-', '_', '.' and '@'", opt.Name), true, http.StatusBadRequest)
+		return
+	}
+	if len(opt.Privileges) == 0 {
+		httpd.HttpError(w, "must provide at least one privilege", true, http.StatusBadRequest)
+		return
+	}
+	privileges, err := normalizePrivileges(opt.Privileges)
+	if err != nil {
+		httpd.HttpError(w, fmt.Sprint("invalid privileges: ", err.Error()), true, http.StatusBadRequest)
+		return
+	}
+	// A token has a subset of the privileges of its creator.
+	if err := authorizePrivileges(caller, privileges); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusForbidden)
+		return
+	}
+	now := time.Now().UTC()
+	if !opt.Expires.IsZero() && !opt.Expires.After(now) {
+		httpd.HttpError(w, "expires must be in the future", true, http.StatusBadRequest)
+		return
+	}
+	owner, callerExpires, err := s.tokenOwner(caller)
+	if err != nil {
+		httpd.HttpError(w, fmt.Sprint("failed to get the token of the caller: ", err.Error()), true, http.StatusInternalServerError)
+		return
+	}
+	// A token does not outlive the token it was created with.
+	if !callerExpires.IsZero() {
+		if opt.Expires.IsZero() {
+			opt.Expires = callerExpires
+		} else if opt.Expires.After(callerExpires) {
+			httpd.HttpError(w, "expires must not be after the token of the caller expires", true, http.StatusForbidden)
+			return
+		}
+	}
+
+	secretBytes := make([]byte, secretSize)
+	if _, err := io.ReadFull(rand.Reader, secretBytes); err != nil {
+		httpd.HttpError(w, fmt.Sprint("failed to generate token: ", err.Error()), true, http.StatusInternalServerError)
+		return
+	}
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+	hash := sha256.Sum256([]byte(secret))
+	t := Token{
+		Name:       opt.Name,
+		Hash:       hash[:],
+		Privileges: privileges,
+		Owner:      owner,
+		Created:    now,
+		Expires:    opt.Expires.UTC(),
+	}
+	if err := s.tokens.Create(t); err != nil {
+		code := http.StatusInternalServerError
+		if err == ErrTokenExists {
+			code = http.StatusBadRequest
+		}
+		httpd.HttpError(w, fmt.Sprintf("failed to create token %q: %v", t.Name, err), true, code)
+		return
+	}
+	ct := s.convertClientToken(t)
+	ct.Token = t.Name + tokenSeparator + secret
+	w.WriteHeader(http.StatusOK)
+	w.Write(httpd.MarshalJSON(ct, true))
+}
+
+func (s *Service) handleGetToken(w http.ResponseWriter, r *http.Request, caller auth.User) {
+	name := strings.TrimPrefix(r.URL.Path, tokensBasePath+"/")
+	t, err := s.tokens.Get(name)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if err == ErrNoTokenExists {
+			code = http.StatusNotFound
+		}
+		httpd.HttpError(w, fmt.Sprintf("failed to get token %q: %v", name, err), true, code)
+		return
+	}
+	if err := s.authorizeToken(caller, t); err != nil {
+		httpd.HttpError(w, fmt.Sprintf("cannot get token %q: %v", name, err), true, http.StatusForbidden)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(httpd.MarshalJSON(s.convertClientToken(t), true))
+}
+
+func (s *Service) handleDeleteToken(w http.ResponseWriter, r *http.Request, caller auth.User) {
+	name := strings.TrimPrefix(r.URL.Path, tokensBasePath+"/")
+	t, err := s.tokens.Get(name)
+	if err != nil && err != ErrNoTokenExists {
+		httpd.HttpError(w, fmt.Sprintf("failed to get token %q: %v", name, err), true, http.StatusInternalServerError)
+		return
+	}
+	if err == nil {
+		if err := s.authorizeToken(caller, t); err != nil {
+			httpd.HttpError(w, fmt.Sprintf("cannot delete token %q: %v", name, err), true, http.StatusForbidden)
+			return
+		}
+	}
+	if err := s.tokens.Delete(name); err != nil {
+		httpd.HttpError(w, fmt.Sprintf("failed to delete token %q: %v", name, err), true, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
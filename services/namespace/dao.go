@@ -0,0 +1,166 @@
+package namespace
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/influxdata/kapacitor/services/storage"
+)
+
+var (
+	ErrNamespaceExists   = errors.New("namespace already exists")
+	ErrNoNamespaceExists = errors.New("no namespace exists")
+)
+
+// Data access object for Namespace data.
+type NamespaceDAO interface {
+	// Retrieve a namespace
+	Get(id string) (Namespace, error)
+
+	// Create a namespace.
+	// ErrNamespaceExists is returned if a namespace already exists with the same ID.
+	Create(n Namespace) error
+
+	// Replace an existing namespace.
+	// ErrNoNamespaceExists is returned if the namespace does not exist.
+	Replace(n Namespace) error
+
+	// Delete a namespace.
+	// It is not an error to delete an non-existent namespace.
+	Delete(id string) error
+
+	// List namespaces matching a pattern.
+	// The pattern is shell/glob matching see https://golang.org/pkg/path/#Match
+	// Offset and limit are pagination bounds. Offset is inclusive starting at index 0.
+	// More results may exist while the number of returned items is equal to limit.
+	List(pattern string, offset, limit int) ([]Namespace, error)
+
+	Rebuild() error
+}
+
+//--------------------------------------------------------------------
+// The following structures are stored in a database via JSON encoding.
+// Changes to the structures could break existing data.
+
+// version is the current version of the Namespace structure.
+const version = 1
+
+type Namespace struct {
+	// Unique ID of the namespace
+	ID string `json:"id"`
+
+	// The database and retention policy pairs the tasks of the namespace may use.
+	// An empty retention policy allows all retention policies of the database.
+	// Tasks may use all DBRPs if empty.
+	DBRPs []DBRP `json:"dbrps"`
+
+	// The quotas of the namespace.
+	Quotas Quotas `json:"quotas"`
+
+	Created  time.Time `json:"created"`
+	Modified time.Time `json:"modified"`
+}
+
+type DBRP struct {
+	Database        string `json:"db"`
+	RetentionPolicy string `json:"rp"`
+}
+
+// AllowsDBRP reports whether tasks of the namespace may use the database and retention policy.
+func (n Namespace) AllowsDBRP(db, rp string) bool {
+	if len(n.DBRPs) == 0 {
+		return true
+	}
+	for _, dbrp := range n.DBRPs {
+		if dbrp.Database == db && (dbrp.RetentionPolicy == "" || dbrp.RetentionPolicy == rp) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n Namespace) ObjectID() string {
+	return n.ID
+}
+
+func (n Namespace) MarshalBinary() ([]byte, error) {
+	return storage.VersionJSONEncode(version, n)
+}
+
+func (n *Namespace) UnmarshalBinary(data []byte) error {
+	return storage.VersionJSONDecode(data, func(version int, dec *json.Decoder) error {
+		return dec.Decode(n)
+	})
+}
+
+// Key/Value store based implementation of the NamespaceDAO
+type namespaceKV struct {
+	store *storage.IndexedStore
+}
+
+func newNamespaceKV(store storage.Interface) (*namespaceKV, error) {
+	c := storage.DefaultIndexedStoreConfig("namespaces", func() storage.BinaryObject {
+		return new(Namespace)
+	})
+	istore, err := storage.NewIndexedStore(store, c)
+	if err != nil {
+		return nil, err
+	}
+	return &namespaceKV{
+		store: istore,
+	}, nil
+}
+
+func (kv *namespaceKV) error(err error) error {
+	if err == storage.ErrNoObjectExists {
+		return ErrNoNamespaceExists
+	} else if err == storage.ErrObjectExists {
+		return ErrNamespaceExists
+	}
+	return err
+}
+
+func (kv *namespaceKV) Get(id string) (Namespace, error) {
+	obj, err := kv.store.Get(id)
+	if err != nil {
+		return Namespace{}, kv.error(err)
+	}
+	n, ok := obj.(*Namespace)
+	if !ok {
+		return Namespace{}, storage.ImpossibleTypeErr(n, obj)
+	}
+	return *n, nil
+}
+
+func (kv *namespaceKV) Create(n Namespace) error {
+	return kv.error(kv.store.Create(&n))
+}
+
+func (kv *namespaceKV) Replace(n Namespace) error {
+	return kv.error(kv.store.Replace(&n))
+}
+
+func (kv *namespaceKV) Delete(id string) error {
+	return kv.error(kv.store.Delete(id))
+}
+
+func (kv *namespaceKV) List(pattern string, offset, limit int) ([]Namespace, error) {
+	objects, err := kv.store.List(storage.DefaultIDIndex, pattern, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	namespaces := make([]Namespace, len(objects))
+	for i, object := range objects {
+		n, ok := object.(*Namespace)
+		if !ok {
+			return nil, storage.ImpossibleTypeErr(n, object)
+		}
+		namespaces[i] = *n
+	}
+	return namespaces, nil
+}
+
+func (kv *namespaceKV) Rebuild() error {
+	return kv.store.Rebuild()
+}
@@ -0,0 +1,65 @@
+// The namespace service manages namespaces, which separate the tasks, templates,
+// alert topics and handlers of several teams sharing a Kapacitor server.
+//
+// A resource within a namespace is stored with a qualified ID of the form <id>@<namespace>
+// and is managed via the namespaced API paths, i.e. /kapacitor/v1/namespaces/<namespace>/tasks/<id>.
+// Privileges are granted per namespace on the resource /api/namespaces/<namespace>.
+//
+// Each namespace may limit its tasks, points, alert events and handler invocations via quotas.
+// Exceeding a quota raises an alert on the QuotasTopic topic.
+package namespace
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Separator separates the ID of a resource from its namespace within a qualified ID, i.e. cpu@team-a.
+// IDs of tasks, templates, topics and handlers cannot contain the separator.
+const Separator = "@"
+
+var validID = regexp.MustCompile(`^[-\._\p{L}0-9]+$`)
+
+// Qualify returns the qualified ID of the resource with the ID within the namespace.
+// The ID is returned unchanged if the namespace is empty.
+func Qualify(namespace, id string) string {
+	if namespace == "" {
+		return id
+	}
+	return id + Separator + namespace
+}
+
+// Resolve returns the qualified ID of a resource referenced by the ID from within the namespace.
+// It reports false if the namespace is empty and the ID is qualified,
+// since resources outside a namespace cannot refer to resources within a namespace.
+func Resolve(namespace, id string) (string, bool) {
+	if namespace == "" && Qualified(id) {
+		return "", false
+	}
+	return Qualify(namespace, id), true
+}
+
+// Qualified reports whether the ID is qualified with a namespace.
+// Resources outside a namespace cannot refer to resources within a namespace via qualified IDs.
+func Qualified(id string) bool {
+	return strings.Contains(id, Separator)
+}
+
+// Split returns the namespace and the ID of a qualified ID.
+// The namespace is empty if the ID is not qualified.
+func Split(qualified string) (namespace, id string) {
+	i := strings.LastIndex(qualified, Separator)
+	if i < 0 {
+		return "", qualified
+	}
+	return qualified[i+len(Separator):], qualified[:i]
+}
+
+// Unqualify returns the ID within the namespace of a qualified ID of the namespace.
+// The qualified ID is returned unchanged if the namespace is empty.
+func Unqualify(namespace, qualified string) string {
+	if namespace == "" {
+		return qualified
+	}
+	return strings.TrimSuffix(qualified, Separator+namespace)
+}
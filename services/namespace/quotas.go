@@ -0,0 +1,337 @@
+package namespace
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/influxdata/kapacitor/alert"
+	kexpvar "github.com/influxdata/kapacitor/expvar"
+	"github.com/influxdata/kapacitor/server/vars"
+)
+
+const (
+	// QuotasTopic is the topic of the alerts raised when a namespace exceeds one of its quotas.
+	QuotasTopic = "namespace_quotas"
+
+	quotasStatsName = "namespace_quotas"
+
+	statTasks                      = "tasks"
+	statPoints                     = "points"
+	statPointsRejected             = "points_rejected"
+	statAlertEvents                = "alert_events"
+	statAlertEventsRejected        = "alert_events_rejected"
+	statHandlerInvocations         = "handler_invocations"
+	statHandlerInvocationsRejected = "handler_invocations_rejected"
+
+	// How often exceeded quotas are checked for recovery.
+	recoveryInterval = time.Second
+)
+
+// Quotas limit the resources used by the tasks and alert topics of a namespace.
+// A zero limit is unlimited.
+type Quotas struct {
+	// Maximum number of executing tasks.
+	MaxTasks int `json:"max-tasks"`
+	// Maximum number of points per second written into the tasks.
+	MaxPointsPerSecond int64 `json:"max-points-per-second"`
+	// Maximum number of alert events per minute collected by the topics.
+	MaxAlertEventsPerMinute int64 `json:"max-alert-events-per-minute"`
+	// Maximum number of events per minute handled by the handlers of the topics.
+	MaxHandlerInvocationsPerMinute int64 `json:"max-handler-invocations-per-minute"`
+}
+
+// counter counts the uses of a rate quota within fixed windows.
+type counter struct {
+	period time.Duration
+	start  time.Time
+	count  int64
+	// Whether a use was rejected within the current window.
+	rejected bool
+	// Whether the quota is exceeded, it recovers after a window without rejected uses.
+	exceeded bool
+
+	uses     *kexpvar.Int
+	rejects  *kexpvar.Int
+	quota    string
+	unitName string
+}
+
+// advance starts a new window if the current one ended and reports whether the quota recovered.
+func (c *counter) advance(now time.Time) bool {
+	if now.Sub(c.start) < c.period {
+		return false
+	}
+	recovered := c.exceeded && !c.rejected
+	if recovered {
+		c.exceeded = false
+	}
+	c.start = now
+	c.count = 0
+	c.rejected = false
+	return recovered
+}
+
+// use counts a use, reporting whether it is within the limit and whether the quota changed to or from exceeded.
+func (c *counter) use(limit int64, now time.Time) (ok, changed bool) {
+	changed = c.advance(now)
+	if limit > 0 && c.count >= limit {
+		c.rejected = true
+		c.rejects.Add(1)
+		if !c.exceeded {
+			c.exceeded = true
+			changed = true
+		}
+		return false, changed
+	}
+	c.count++
+	c.uses.Add(1)
+	return true, changed
+}
+
+// usage tracks the usage of the quotas of a namespace.
+type usage struct {
+	mu     sync.Mutex
+	quotas Quotas
+
+	tasksExceeded bool
+	points        counter
+	events        counter
+	invocations   counter
+
+	statsKey string
+}
+
+// quotaEvent is a change of a quota of a namespace to or from exceeded.
+type quotaEvent struct {
+	namespace string
+	quota     string
+	exceeded  bool
+	message   string
+}
+
+// quotaTracker enforces the quotas of all namespaces.
+type quotaTracker struct {
+	mu         sync.RWMutex
+	namespaces map[string]*usage
+
+	events chan quotaEvent
+
+	// Returns the number of executing tasks of a namespace.
+	numTasks func(namespace string) int
+}
+
+func newQuotaTracker() *quotaTracker {
+	return &quotaTracker{
+		namespaces: make(map[string]*usage),
+		events:     make(chan quotaEvent, 100),
+	}
+}
+
+func newCounter(period time.Duration, quota, unitName string, statMap *kexpvar.Map, usesStat, rejectsStat string) counter {
+	c := counter{
+		period:   period,
+		uses:     new(kexpvar.Int),
+		rejects:  new(kexpvar.Int),
+		quota:    quota,
+		unitName: unitName,
+	}
+	statMap.Set(usesStat, c.uses)
+	statMap.Set(rejectsStat, c.rejects)
+	return c
+}
+
+// set sets the quotas of the namespace, creating its usage statistics.
+func (t *quotaTracker) set(namespace string, quotas Quotas) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if u, ok := t.namespaces[namespace]; ok {
+		u.mu.Lock()
+		u.quotas = quotas
+		u.mu.Unlock()
+		return
+	}
+	statsKey, statMap := vars.NewStatistic(quotasStatsName, map[string]string{
+		"namespace": namespace,
+	})
+	statMap.Set(statTasks, kexpvar.NewIntFuncGauge(func() int64 {
+		if t.numTasks == nil {
+			return 0
+		}
+		return int64(t.numTasks(namespace))
+	}))
+	t.namespaces[namespace] = &usage{
+		quotas:      quotas,
+		points:      newCounter(time.Second, "points", "points per second", statMap, statPoints, statPointsRejected),
+		events:      newCounter(time.Minute, "alert-events", "alert events per minute", statMap, statAlertEvents, statAlertEventsRejected),
+		invocations: newCounter(time.Minute, "handler-invocations", "handler invocations per minute", statMap, statHandlerInvocations, statHandlerInvocationsRejected),
+		statsKey:    statsKey,
+	}
+}
+
+// remove removes the quotas and usage statistics of the namespace.
+func (t *quotaTracker) remove(namespace string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if u, ok := t.namespaces[namespace]; ok {
+		vars.DeleteStatistic(u.statsKey)
+		delete(t.namespaces, namespace)
+	}
+}
+
+func (t *quotaTracker) removeAll() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for namespace, u := range t.namespaces {
+		vars.DeleteStatistic(u.statsKey)
+		delete(t.namespaces, namespace)
+	}
+}
+
+func (t *quotaTracker) usage(namespace string) *usage {
+	t.mu.RLock()
+	u := t.namespaces[namespace]
+	t.mu.RUnlock()
+	return u
+}
+
+// notify queues the change of the quota, dropping it if the queue is full.
+func (t *quotaTracker) notify(e quotaEvent) {
+	select {
+	case t.events <- e:
+	default:
+	}
+}
+
+func (t *quotaTracker) use(namespace string, c func(u *usage) (*counter, int64)) bool {
+	u := t.usage(namespace)
+	if u == nil {
+		// The namespace does not exist, nothing to enforce.
+		return true
+	}
+	u.mu.Lock()
+	counter, limit := c(u)
+	ok, changed := counter.use(limit, time.Now())
+	exceeded := counter.exceeded
+	u.mu.Unlock()
+	if changed {
+		e := quotaEvent{
+			namespace: namespace,
+			quota:     counter.quota,
+			exceeded:  exceeded,
+		}
+		if exceeded {
+			e.message = fmt.Sprintf("namespace %s exceeded its quota of %d %s", namespace, limit, counter.unitName)
+		} else {
+			e.message = fmt.Sprintf("namespace %s is within its quota of %s again", namespace, counter.unitName)
+		}
+		t.notify(e)
+	}
+	return ok
+}
+
+// AllowTask returns an error if the namespace may not execute the number of tasks.
+func (t *quotaTracker) AllowTask(namespace string, tasks int) error {
+	u := t.usage(namespace)
+	if u == nil {
+		return nil
+	}
+	u.mu.Lock()
+	limit := u.quotas.MaxTasks
+	exceeded := limit > 0 && tasks > limit
+	changed := exceeded != u.tasksExceeded
+	u.tasksExceeded = exceeded
+	u.mu.Unlock()
+	if changed {
+		e := quotaEvent{
+			namespace: namespace,
+			quota:     "tasks",
+			exceeded:  exceeded,
+		}
+		if exceeded {
+			e.message = fmt.Sprintf("namespace %s exceeded its quota of %d tasks", namespace, limit)
+		} else {
+			e.message = fmt.Sprintf("namespace %s is within its quota of tasks again", namespace)
+		}
+		t.notify(e)
+	}
+	if exceeded {
+		return fmt.Errorf("namespace %s exceeded its quota of %d tasks", namespace, limit)
+	}
+	return nil
+}
+
+// AllowPoint reports whether a point may be written into a task of the namespace.
+func (t *quotaTracker) AllowPoint(namespace string) bool {
+	return t.use(namespace, func(u *usage) (*counter, int64) {
+		return &u.points, u.quotas.MaxPointsPerSecond
+	})
+}
+
+// AllowAlertEvent reports whether an alert event may be collected by a topic of the namespace.
+func (t *quotaTracker) AllowAlertEvent(namespace string) bool {
+	return t.use(namespace, func(u *usage) (*counter, int64) {
+		return &u.events, u.quotas.MaxAlertEventsPerMinute
+	})
+}
+
+// AllowHandlerInvocation reports whether a handler of a topic of the namespace may handle an event.
+func (t *quotaTracker) AllowHandlerInvocation(namespace string) bool {
+	return t.use(namespace, func(u *usage) (*counter, int64) {
+		return &u.invocations, u.quotas.MaxHandlerInvocationsPerMinute
+	})
+}
+
+// checkRecovered notifies the recovery of the quotas whose windows ended without rejected uses,
+// and of the task quotas of namespaces that may start another task again.
+func (t *quotaTracker) checkRecovered(now time.Time) {
+	t.mu.RLock()
+	namespaces := make(map[string]*usage, len(t.namespaces))
+	for namespace, u := range t.namespaces {
+		namespaces[namespace] = u
+	}
+	t.mu.RUnlock()
+	for namespace, u := range namespaces {
+		// The tasks are counted without holding any locks, since the task master checks the quotas while holding its own lock.
+		tasks := -1
+		if t.numTasks != nil {
+			tasks = t.numTasks(namespace)
+		}
+		u.mu.Lock()
+		if limit := u.quotas.MaxTasks; u.tasksExceeded && tasks >= 0 && (limit == 0 || tasks < limit) {
+			u.tasksExceeded = false
+			t.notify(quotaEvent{
+				namespace: namespace,
+				quota:     "tasks",
+				message:   fmt.Sprintf("namespace %s is within its quota of tasks again", namespace),
+			})
+		}
+		for _, c := range []*counter{&u.points, &u.events, &u.invocations} {
+			if c.advance(now) {
+				t.notify(quotaEvent{
+					namespace: namespace,
+					quota:     c.quota,
+					message:   fmt.Sprintf("namespace %s is within its quota of %s again", namespace, c.unitName),
+				})
+			}
+		}
+		u.mu.Unlock()
+	}
+}
+
+// alertEvent returns the internal alert event of the quota change.
+func (e quotaEvent) alertEvent() alert.Event {
+	level := alert.OK
+	if e.exceeded {
+		level = alert.Critical
+	}
+	return alert.Event{
+		Topic: QuotasTopic,
+		State: alert.EventState{
+			ID:      e.namespace + ":" + e.quota,
+			Message: e.message,
+			Time:    time.Now().UTC(),
+			Level:   level,
+		},
+	}
+}
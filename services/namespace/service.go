@@ -0,0 +1,489 @@
+package namespace
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/kapacitor/alert"
+	"github.com/influxdata/kapacitor/auth"
+	client "github.com/influxdata/kapacitor/client/v1"
+	"github.com/influxdata/kapacitor/services/httpd"
+	"github.com/influxdata/kapacitor/services/storage"
+	"github.com/pkg/errors"
+)
+
+const (
+	namespacesPath         = "/namespaces"
+	namespacesPathAnchored = "/namespaces/"
+	namespacesBasePath     = httpd.BasePath + namespacesPath
+
+	// Public name of the namespaces store
+	namespacesAPIName = "namespaces"
+	// The storage namespace for all namespace data.
+	namespaceNamespace = "namespace"
+)
+
+type Service struct {
+	namespaces NamespaceDAO
+	routes     []httpd.Route
+
+	quotas  *quotaTracker
+	closing chan struct{}
+	wg      sync.WaitGroup
+
+	logger *log.Logger
+
+	StorageService interface {
+		Store(namespace string) storage.Interface
+		Register(name string, store storage.StoreActioner)
+	}
+	HTTPDService interface {
+		AddRoutes([]httpd.Route) error
+		DelRoutes([]httpd.Route)
+		ServeNamespaced(w http.ResponseWriter, r *http.Request, user auth.User, namespace, p string)
+	}
+	TaskMaster interface {
+		NumExecutingTasks(namespace string) int
+	}
+	// A namespace cannot be deleted while the task store or the alert service own resources within it.
+	TaskStore interface {
+		NamespaceResources(namespace string) ([]string, error)
+	}
+	AlertService interface {
+		Collect(event alert.Event) error
+		NamespaceResources(namespace string) ([]string, error)
+	}
+}
+
+func NewService(l *log.Logger) *Service {
+	return &Service{
+		quotas: newQuotaTracker(),
+		logger: l,
+	}
+}
+
+func (s *Service) Open() error {
+	namespaces, err := newNamespaceKV(s.StorageService.Store(namespaceNamespace))
+	if err != nil {
+		return err
+	}
+	s.namespaces = namespaces
+	s.StorageService.Register(namespacesAPIName, s.namespaces)
+
+	// Load the quotas of all namespaces
+	if s.TaskMaster != nil {
+		s.quotas.numTasks = s.TaskMaster.NumExecutingTasks
+	}
+	all, err := s.namespaces.List("", 0, -1)
+	if err != nil {
+		return errors.Wrap(err, "failed to list namespaces")
+	}
+	for _, n := range all {
+		s.quotas.set(n.ID, n.Quotas)
+	}
+	s.closing = make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.raiseQuotaAlerts()
+	}()
+
+	// Define API routes
+	s.routes = []httpd.Route{
+		{
+			Method:      "GET",
+			Pattern:     namespacesPath,
+			HandlerFunc: s.handleListNamespaces,
+		},
+		{
+			Method:      "POST",
+			Pattern:     namespacesPath,
+			HandlerFunc: s.handleCreateNamespace,
+		},
+	}
+	// The anchored path serves both the namespaces and the resources within them.
+	for _, method := range []string{"GET", "POST", "PATCH", "PUT", "DELETE"} {
+		s.routes = append(s.routes, httpd.Route{
+			Method:      method,
+			Pattern:     namespacesPathAnchored,
+			HandlerFunc: s.handleNamespacePath,
+		})
+	}
+	s.routes = append(s.routes, httpd.Route{
+		// Satisfy CORS checks.
+		Method:      "OPTIONS",
+		Pattern:     namespacesPathAnchored,
+		HandlerFunc: httpd.ServeOptions,
+	})
+
+	err = s.HTTPDService.AddRoutes(s.routes)
+	return errors.Wrap(err, "failed to add API routes")
+}
+
+func (s *Service) Close() error {
+	if s.HTTPDService != nil {
+		s.HTTPDService.DelRoutes(s.routes)
+	}
+	if s.closing != nil {
+		close(s.closing)
+		s.wg.Wait()
+	}
+	s.quotas.removeAll()
+	return nil
+}
+
+// raiseQuotaAlerts raises an internal alert when a namespace exceeds one of its quotas,
+// and recovers it once the namespace is within the quota again.
+func (s *Service) raiseQuotaAlerts() {
+	ticker := time.NewTicker(recoveryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.closing:
+			return
+		case now := <-ticker.C:
+			s.quotas.checkRecovered(now)
+		case e := <-s.quotas.events:
+			if e.exceeded {
+				s.logger.Println("W!", e.message)
+			} else {
+				s.logger.Println("I!", e.message)
+			}
+			if s.AlertService == nil {
+				continue
+			}
+			if err := s.AlertService.Collect(e.alertEvent()); err != nil {
+				s.logger.Println("E! failed to raise namespace quota alert:", err)
+			}
+		}
+	}
+}
+
+// AllowTask returns an error if the namespace may not execute the number of tasks.
+func (s *Service) AllowTask(namespace string, tasks int) error {
+	return s.quotas.AllowTask(namespace, tasks)
+}
+
+// AllowPoint reports whether a point may be written into a task of the namespace.
+func (s *Service) AllowPoint(namespace string) bool {
+	return s.quotas.AllowPoint(namespace)
+}
+
+// AllowAlertEvent reports whether an alert event may be collected by a topic of the namespace.
+func (s *Service) AllowAlertEvent(namespace string) bool {
+	return s.quotas.AllowAlertEvent(namespace)
+}
+
+// AllowHandlerInvocation reports whether a handler of a topic of the namespace may handle an event.
+func (s *Service) AllowHandlerInvocation(namespace string) bool {
+	return s.quotas.AllowHandlerInvocation(namespace)
+}
+
+// Namespace returns the namespace with the ID.
+func (s *Service) Namespace(id string) (Namespace, error) {
+	return s.namespaces.Get(id)
+}
+
+// AllowedDBRP returns an error if tasks of the namespace may not use the database and retention policy.
+// Tasks of the global namespace may use all DBRPs.
+func (s *Service) AllowedDBRP(namespace, db, rp string) error {
+	if namespace == "" {
+		return nil
+	}
+	n, err := s.namespaces.Get(namespace)
+	if err != nil {
+		return err
+	}
+	if !n.AllowsDBRP(db, rp) {
+		return fmt.Errorf("namespace %q does not allow using %q.%q", namespace, db, rp)
+	}
+	return nil
+}
+
+// resources returns the resources within the namespace.
+func (s *Service) resources(id string) ([]string, error) {
+	var resources []string
+	if s.TaskStore != nil {
+		r, err := s.TaskStore.NamespaceResources(id)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, r...)
+	}
+	if s.AlertService != nil {
+		r, err := s.AlertService.NamespaceResources(id)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, r...)
+	}
+	sort.Strings(resources)
+	return resources, nil
+}
+
+func (s *Service) namespaceLink(id string) client.Link {
+	return client.Link{Relation: client.Self, Href: path.Join(namespacesBasePath, id)}
+}
+
+func (s *Service) convertClientNamespace(n Namespace) client.Namespace {
+	dbrps := make([]client.DBRP, len(n.DBRPs))
+	for i, dbrp := range n.DBRPs {
+		dbrps[i] = client.DBRP{
+			Database:        dbrp.Database,
+			RetentionPolicy: dbrp.RetentionPolicy,
+		}
+	}
+	return client.Namespace{
+		Link:  s.namespaceLink(n.ID),
+		ID:    n.ID,
+		DBRPs: dbrps,
+		Quotas: client.NamespaceQuotas{
+			MaxTasks:                       n.Quotas.MaxTasks,
+			MaxPointsPerSecond:             n.Quotas.MaxPointsPerSecond,
+			MaxAlertEventsPerMinute:        n.Quotas.MaxAlertEventsPerMinute,
+			MaxHandlerInvocationsPerMinute: n.Quotas.MaxHandlerInvocationsPerMinute,
+		},
+		Created:  n.Created,
+		Modified: n.Modified,
+	}
+}
+
+func convertQuotas(q client.NamespaceQuotas) (Quotas, error) {
+	if q.MaxTasks < 0 || q.MaxPointsPerSecond < 0 || q.MaxAlertEventsPerMinute < 0 || q.MaxHandlerInvocationsPerMinute < 0 {
+		return Quotas{}, errors.New("quotas must not be negative")
+	}
+	return Quotas{
+		MaxTasks:                       q.MaxTasks,
+		MaxPointsPerSecond:             q.MaxPointsPerSecond,
+		MaxAlertEventsPerMinute:        q.MaxAlertEventsPerMinute,
+		MaxHandlerInvocationsPerMinute: q.MaxHandlerInvocationsPerMinute,
+	}, nil
+}
+
+// updateQuotas returns the quotas with the set quotas of the update applied.
+func updateQuotas(q Quotas, u client.UpdateNamespaceQuotas) (Quotas, error) {
+	if u.MaxTasks != nil {
+		q.MaxTasks = *u.MaxTasks
+	}
+	if u.MaxPointsPerSecond != nil {
+		q.MaxPointsPerSecond = *u.MaxPointsPerSecond
+	}
+	if u.MaxAlertEventsPerMinute != nil {
+		q.MaxAlertEventsPerMinute = *u.MaxAlertEventsPerMinute
+	}
+	if u.MaxHandlerInvocationsPerMinute != nil {
+		q.MaxHandlerInvocationsPerMinute = *u.MaxHandlerInvocationsPerMinute
+	}
+	if q.MaxTasks < 0 || q.MaxPointsPerSecond < 0 || q.MaxAlertEventsPerMinute < 0 || q.MaxHandlerInvocationsPerMinute < 0 {
+		return Quotas{}, errors.New("quotas must not be negative")
+	}
+	return q, nil
+}
+
+func convertDBRPs(dbrps []client.DBRP) ([]DBRP, error) {
+	converted := make([]DBRP, len(dbrps))
+	for i, dbrp := range dbrps {
+		if dbrp.Database == "" {
+			return nil, errors.New("must specify the database of each DBRP")
+		}
+		converted[i] = DBRP{
+			Database:        dbrp.Database,
+			RetentionPolicy: dbrp.RetentionPolicy,
+		}
+	}
+	return converted, nil
+}
+
+// handleNamespacePath handles requests for a namespace, i.e. /namespaces/<namespace>,
+// and forwards requests for the resources within a namespace, i.e. /namespaces/<namespace>/tasks.
+func (s *Service) handleNamespacePath(w http.ResponseWriter, r *http.Request, user auth.User) {
+	p := strings.TrimPrefix(r.URL.Path, namespacesBasePath+"/")
+	id, rest := p, ""
+	if i := strings.Index(p, "/"); i >= 0 {
+		id, rest = p[:i], p[i:]
+	}
+	if rest == "" {
+		switch r.Method {
+		case "GET":
+			s.handleGetNamespace(w, r, id)
+		case "PATCH":
+			s.handleUpdateNamespace(w, r, id)
+		case "DELETE":
+			s.handleDeleteNamespace(w, r, id)
+		default:
+			httpd.HttpError(w, fmt.Sprintf("method %s not allowed on namespace", r.Method), true, http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	if _, err := s.namespaces.Get(id); err != nil {
+		code := http.StatusInternalServerError
+		if err == ErrNoNamespaceExists {
+			code = http.StatusNotFound
+		}
+		httpd.HttpError(w, fmt.Sprintf("failed to get namespace %q: %v", id, err), true, code)
+		return
+	}
+	s.HTTPDService.ServeNamespaced(w, r, user, id, rest)
+}
+
+func (s *Service) handleListNamespaces(w http.ResponseWriter, r *http.Request) {
+	pattern := r.URL.Query().Get("pattern")
+
+	var err error
+	offset := int64(0)
+	offsetStr := r.URL.Query().Get("offset")
+	if offsetStr != "" {
+		offset, err = strconv.ParseInt(offsetStr, 10, 64)
+		if err != nil {
+			httpd.HttpError(w, fmt.Sprintf("invalid offset parameter %q must be an integer: %s", offsetStr, err), true, http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := int64(100)
+	limitStr := r.URL.Query().Get("limit")
+	if limitStr != "" {
+		limit, err = strconv.ParseInt(limitStr, 10, 64)
+		if err != nil {
+			httpd.HttpError(w, fmt.Sprintf("invalid limit parameter %q must be an integer: %s", limitStr, err), true, http.StatusBadRequest)
+			return
+		}
+	}
+
+	namespaces, err := s.namespaces.List(pattern, int(offset), int(limit))
+	if err != nil {
+		httpd.HttpError(w, fmt.Sprintf("failed to list namespaces with pattern %q: %s", pattern, err), true, http.StatusBadRequest)
+		return
+	}
+	list := make([]client.Namespace, len(namespaces))
+	for i, n := range namespaces {
+		list[i] = s.convertClientNamespace(n)
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(httpd.MarshalJSON(client.Namespaces{
+		Link:       client.Link{Relation: client.Self, Href: r.URL.String()},
+		Namespaces: list,
+	}, true))
+}
+
+func (s *Service) handleCreateNamespace(w http.ResponseWriter, r *http.Request) {
+	opt := client.CreateNamespaceOptions{}
+	if err := json.NewDecoder(r.Body).Decode(&opt); err != nil {
+		httpd.HttpError(w, fmt.Sprint("invalid namespace json: ", err.Error()), true, http.StatusBadRequest)
+		return
+	}
+	if !validID.MatchString(opt.ID) {
+		httpd.HttpError(w, fmt.Sprintf("namespace ID must contain only letters, numbers, '-', '.' and '_'. %q", opt.ID), true, http.StatusBadRequest)
+		return
+	}
+	dbrps, err := convertDBRPs(opt.DBRPs)
+	if err != nil {
+		httpd.HttpError(w, fmt.Sprint("invalid dbrps: ", err.Error()), true, http.StatusBadRequest)
+		return
+	}
+	quotas, err := convertQuotas(opt.Quotas)
+	if err != nil {
+		httpd.HttpError(w, fmt.Sprint("invalid quotas: ", err.Error()), true, http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	n := Namespace{
+		ID:       opt.ID,
+		DBRPs:    dbrps,
+		Quotas:   quotas,
+		Created:  now,
+		Modified: now,
+	}
+	if err := s.namespaces.Create(n); err != nil {
+		code := http.StatusInternalServerError
+		if err == ErrNamespaceExists {
+			code = http.StatusBadRequest
+		}
+		httpd.HttpError(w, fmt.Sprintf("failed to create namespace %q: %v", n.ID, err), true, code)
+		return
+	}
+	s.quotas.set(n.ID, n.Quotas)
+	w.WriteHeader(http.StatusOK)
+	w.Write(httpd.MarshalJSON(s.convertClientNamespace(n), true))
+}
+
+func (s *Service) handleGetNamespace(w http.ResponseWriter, r *http.Request, id string) {
+	n, err := s.namespaces.Get(id)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if err == ErrNoNamespaceExists {
+			code = http.StatusNotFound
+		}
+		httpd.HttpError(w, fmt.Sprintf("failed to get namespace %q: %v", id, err), true, code)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(httpd.MarshalJSON(s.convertClientNamespace(n), true))
+}
+
+func (s *Service) handleUpdateNamespace(w http.ResponseWriter, r *http.Request, id string) {
+	opt := client.UpdateNamespaceOptions{}
+	if err := json.NewDecoder(r.Body).Decode(&opt); err != nil {
+		httpd.HttpError(w, fmt.Sprint("invalid namespace json: ", err.Error()), true, http.StatusBadRequest)
+		return
+	}
+	n, err := s.namespaces.Get(id)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if err == ErrNoNamespaceExists {
+			code = http.StatusNotFound
+		}
+		httpd.HttpError(w, fmt.Sprintf("failed to get namespace %q: %v", id, err), true, code)
+		return
+	}
+	if opt.DBRPs != nil {
+		dbrps, err := convertDBRPs(opt.DBRPs)
+		if err != nil {
+			httpd.HttpError(w, fmt.Sprint("invalid dbrps: ", err.Error()), true, http.StatusBadRequest)
+			return
+		}
+		n.DBRPs = dbrps
+	}
+	if opt.Quotas != nil {
+		quotas, err := updateQuotas(n.Quotas, *opt.Quotas)
+		if err != nil {
+			httpd.HttpError(w, fmt.Sprint("invalid quotas: ", err.Error()), true, http.StatusBadRequest)
+			return
+		}
+		n.Quotas = quotas
+	}
+	n.Modified = time.Now()
+	if err := s.namespaces.Replace(n); err != nil {
+		httpd.HttpError(w, fmt.Sprintf("failed to update namespace %q: %v", id, err), true, http.StatusInternalServerError)
+		return
+	}
+	s.quotas.set(n.ID, n.Quotas)
+	w.WriteHeader(http.StatusOK)
+	w.Write(httpd.MarshalJSON(s.convertClientNamespace(n), true))
+}
+
+func (s *Service) handleDeleteNamespace(w http.ResponseWriter, r *http.Request, id string) {
+	resources, err := s.resources(id)
+	if err != nil {
+		httpd.HttpError(w, fmt.Sprintf("failed to list resources of namespace %q: %v", id, err), true, http.StatusInternalServerError)
+		return
+	}
+	if len(resources) > 0 {
+		httpd.HttpError(w, fmt.Sprintf("cannot delete namespace %q, it still contains %s", id, strings.Join(resources, ", ")), true, http.StatusBadRequest)
+		return
+	}
+	if err := s.namespaces.Delete(id); err != nil {
+		httpd.HttpError(w, fmt.Sprintf("failed to delete namespace %q: %v", id, err), true, http.StatusInternalServerError)
+		return
+	}
+	s.quotas.remove(id)
+	w.WriteHeader(http.StatusNoContent)
+}
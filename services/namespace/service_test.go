@@ -0,0 +1,349 @@
+package namespace_test
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/kapacitor/alert"
+	client "github.com/influxdata/kapacitor/client/v1"
+	"github.com/influxdata/kapacitor/services/httpd"
+	"github.com/influxdata/kapacitor/services/httpd/httpdtest"
+	"github.com/influxdata/kapacitor/services/namespace"
+	"github.com/influxdata/kapacitor/services/storage/storagetest"
+)
+
+func OpenNewService() (*namespace.Service, *client.Client, *httpdtest.Server) {
+	service := namespace.NewService(log.New(os.Stderr, "[namespace] ", log.LstdFlags))
+	service.StorageService = storagetest.New()
+	server := httpdtest.NewServer(testing.Verbose())
+	service.HTTPDService = server
+	if err := service.Open(); err != nil {
+		panic(err)
+	}
+	cli, err := client.New(client.Config{URL: server.Server.URL})
+	if err != nil {
+		panic(err)
+	}
+	return service, cli, server
+}
+
+func TestQualify(t *testing.T) {
+	if got, exp := namespace.Qualify("team-a", "cpu"), "cpu@team-a"; got != exp {
+		t.Errorf("unexpected qualified ID got %q exp %q", got, exp)
+	}
+	if got, exp := namespace.Qualify("", "cpu"), "cpu"; got != exp {
+		t.Errorf("unexpected qualified ID in the global namespace got %q exp %q", got, exp)
+	}
+	if ns, id := namespace.Split("cpu@team-a"); ns != "team-a" || id != "cpu" {
+		t.Errorf("unexpected split got %q %q", ns, id)
+	}
+	if ns, id := namespace.Split("cpu"); ns != "" || id != "cpu" {
+		t.Errorf("unexpected split of unqualified ID got %q %q", ns, id)
+	}
+	if got, exp := namespace.Unqualify("team-a", "cpu@team-a"), "cpu"; got != exp {
+		t.Errorf("unexpected unqualified ID got %q exp %q", got, exp)
+	}
+	if got, exp := namespace.Unqualify("", "cpu@team-a"), "cpu@team-a"; got != exp {
+		t.Errorf("unexpected unqualified ID in the global namespace got %q exp %q", got, exp)
+	}
+	if got, ok := namespace.Resolve("team-a", "cpu"); !ok || got != "cpu@team-a" {
+		t.Errorf("unexpected resolved ID got %q %t exp %q", got, ok, "cpu@team-a")
+	}
+	if got, ok := namespace.Resolve("", "cpu"); !ok || got != "cpu" {
+		t.Errorf("unexpected resolved ID in the global namespace got %q %t exp %q", got, ok, "cpu")
+	}
+	// Qualified IDs cannot be referenced outside a namespace.
+	if _, ok := namespace.Resolve("", "cpu@team-a"); ok {
+		t.Error("expected qualified ID not to resolve in the global namespace")
+	}
+}
+
+func TestService_Namespaces(t *testing.T) {
+	service, cli, server := OpenNewService()
+	defer server.Close()
+	defer service.Close()
+
+	n, err := cli.CreateNamespace(client.CreateNamespaceOptions{
+		ID:    "team-a",
+		DBRPs: []client.DBRP{{Database: "telegraf", RetentionPolicy: "autogen"}, {Database: "app"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Link != cli.NamespaceLink("team-a") || n.ID != "team-a" || len(n.DBRPs) != 2 {
+		t.Errorf("unexpected namespace %v", n)
+	}
+	if _, err := cli.CreateNamespace(client.CreateNamespaceOptions{ID: "team-a"}); err == nil {
+		t.Error("expected error creating existing namespace")
+	}
+	if _, err := cli.CreateNamespace(client.CreateNamespaceOptions{ID: "team@b"}); err == nil {
+		t.Error("expected error creating namespace with invalid ID")
+	}
+
+	testCases := []struct {
+		db, rp  string
+		allowed bool
+	}{
+		{db: "telegraf", rp: "autogen", allowed: true},
+		{db: "telegraf", rp: "other", allowed: false},
+		{db: "app", rp: "any", allowed: true},
+		{db: "other", rp: "autogen", allowed: false},
+	}
+	for _, tc := range testCases {
+		if err := service.AllowedDBRP("team-a", tc.db, tc.rp); (err == nil) != tc.allowed {
+			t.Errorf("unexpected result for %s.%s got %v exp allowed %v", tc.db, tc.rp, err, tc.allowed)
+		}
+	}
+	if err := service.AllowedDBRP("", "other", "autogen"); err != nil {
+		t.Errorf("expected global namespace to allow all DBRPs, got %v", err)
+	}
+
+	// Removing all DBRPs allows all DBRPs.
+	n, err = cli.UpdateNamespace(n.Link, client.UpdateNamespaceOptions{DBRPs: []client.DBRP{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(n.DBRPs) != 0 {
+		t.Errorf("unexpected DBRPs after update %v", n.DBRPs)
+	}
+	if err := service.AllowedDBRP("team-a", "other", "autogen"); err != nil {
+		t.Error(err)
+	}
+
+	namespaces, err := cli.ListNamespaces(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(namespaces.Namespaces) != 1 || namespaces.Namespaces[0].ID != "team-a" {
+		t.Errorf("unexpected namespaces %v", namespaces.Namespaces)
+	}
+
+	if err := cli.DeleteNamespace(n.Link); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.Namespace(n.Link); err == nil {
+		t.Error("expected error getting deleted namespace")
+	}
+}
+
+func TestService_DeleteNamespaceWithResources(t *testing.T) {
+	service, cli, server := OpenNewService()
+	defer server.Close()
+	defer service.Close()
+	ts := &taskStore{resources: map[string][]string{"team-a": {"task cpu"}}}
+	service.TaskStore = ts
+
+	n, err := cli.CreateNamespace(client.CreateNamespaceOptions{ID: "team-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.DeleteNamespace(n.Link); err == nil || !strings.Contains(err.Error(), "task cpu") {
+		t.Fatalf("expected error deleting namespace with tasks, got %v", err)
+	}
+	if _, err := cli.Namespace(n.Link); err != nil {
+		t.Errorf("expected namespace to still exist: %v", err)
+	}
+
+	delete(ts.resources, "team-a")
+	if err := cli.DeleteNamespace(n.Link); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestService_ServeNamespaced(t *testing.T) {
+	service, cli, server := OpenNewService()
+	defer server.Close()
+	defer service.Close()
+
+	type taskResponse struct {
+		ID   string `json:"id"`
+		Link string `json:"link"`
+	}
+	// A task route returning the qualified ID of the task and its link.
+	server.AddRoutes([]httpd.Route{{
+		Method:  "GET",
+		Pattern: "/tasks/",
+		HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+			ns := httpd.RequestNamespace(r)
+			id := strings.TrimPrefix(r.URL.Path, httpd.BasePath+"/tasks/")
+			w.Write(httpd.MarshalJSON(taskResponse{
+				ID:   namespace.Qualify(ns, id),
+				Link: httpd.NamespacedPath(ns, r.URL.Path),
+			}, false))
+		},
+	}})
+
+	if _, err := cli.CreateNamespace(client.CreateNamespaceOptions{ID: "team-a"}); err != nil {
+		t.Fatal(err)
+	}
+	nsCli, err := client.New(client.Config{URL: server.Server.URL, Namespace: "team-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(p string) (int, taskResponse) {
+		resp, err := http.Get(server.Server.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var tr taskResponse
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode, tr
+	}
+
+	link := nsCli.TaskLink("cpu")
+	if exp := "/kapacitor/v1/namespaces/team-a/tasks/cpu"; link.Href != exp {
+		t.Errorf("unexpected namespaced task link got %q exp %q", link.Href, exp)
+	}
+	code, tr := get(link.Href)
+	if code != http.StatusOK {
+		t.Fatalf("unexpected status code %d", code)
+	}
+	if tr.ID != "cpu@team-a" || tr.Link != link.Href {
+		t.Errorf("unexpected response %v", tr)
+	}
+
+	// The global path is served unchanged.
+	if code, tr := get("/kapacitor/v1/tasks/cpu"); code != http.StatusOK || tr.ID != "cpu" {
+		t.Errorf("unexpected response of global path %d %v", code, tr)
+	}
+	if code, _ := get("/kapacitor/v1/namespaces/team-b/tasks/cpu"); code != http.StatusNotFound {
+		t.Errorf("unexpected status code for unknown namespace got %d exp %d", code, http.StatusNotFound)
+	}
+	if code, _ := get("/kapacitor/v1/namespaces/team-a/users/bob"); code != http.StatusNotFound {
+		t.Errorf("unexpected status code for path that is not namespaced got %d exp %d", code, http.StatusNotFound)
+	}
+}
+
+type alertCollector struct {
+	events chan alert.Event
+}
+
+func (c alertCollector) Collect(event alert.Event) error {
+	c.events <- event
+	return nil
+}
+
+func (c alertCollector) NamespaceResources(string) ([]string, error) { return nil, nil }
+
+type taskStore struct {
+	resources map[string][]string
+}
+
+func (ts *taskStore) NamespaceResources(namespace string) ([]string, error) {
+	return ts.resources[namespace], nil
+}
+
+type taskMaster struct{}
+
+func (taskMaster) NumExecutingTasks(string) int { return 1 }
+
+func TestService_Quotas(t *testing.T) {
+	service := namespace.NewService(log.New(os.Stderr, "[namespace] ", log.LstdFlags))
+	service.StorageService = storagetest.New()
+	server := httpdtest.NewServer(testing.Verbose())
+	defer server.Close()
+	service.HTTPDService = server
+	service.TaskMaster = taskMaster{}
+	collector := alertCollector{events: make(chan alert.Event, 10)}
+	service.AlertService = collector
+	if err := service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer service.Close()
+	cli, err := client.New(client.Config{URL: server.Server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := cli.CreateNamespace(client.CreateNamespaceOptions{
+		ID: "team-a",
+		Quotas: client.NamespaceQuotas{
+			MaxTasks:                2,
+			MaxAlertEventsPerMinute: 3,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Quotas.MaxTasks != 2 || n.Quotas.MaxAlertEventsPerMinute != 3 {
+		t.Errorf("unexpected quotas %v", n.Quotas)
+	}
+	if _, err := cli.CreateNamespace(client.CreateNamespaceOptions{
+		ID:     "team-b",
+		Quotas: client.NamespaceQuotas{MaxTasks: -1},
+	}); err == nil {
+		t.Error("expected error creating namespace with negative quota")
+	}
+
+	if err := service.AllowTask("team-a", 2); err != nil {
+		t.Error(err)
+	}
+	if err := service.AllowTask("team-a", 3); err == nil {
+		t.Error("expected error exceeding task quota")
+	}
+	select {
+	case e := <-collector.events:
+		if e.Topic != namespace.QuotasTopic || e.State.ID != "team-a:tasks" || e.State.Level != alert.Critical {
+			t.Errorf("unexpected quota alert %v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected quota alert")
+	}
+	// The namespace executes a single task and may start another one, the quota recovers.
+	select {
+	case e := <-collector.events:
+		if e.State.ID != "team-a:tasks" || e.State.Level != alert.OK {
+			t.Errorf("unexpected recovery alert %v", e)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("expected recovery alert")
+	}
+
+	for i := 0; i < 3; i++ {
+		if !service.AllowAlertEvent("team-a") {
+			t.Errorf("expected alert event %d to be allowed", i)
+		}
+	}
+	if service.AllowAlertEvent("team-a") {
+		t.Error("expected alert event to exceed quota")
+	}
+	// Unlimited quotas and the global namespace are not enforced.
+	for i := 0; i < 10; i++ {
+		if !service.AllowPoint("team-a") || !service.AllowHandlerInvocation("team-a") || !service.AllowAlertEvent("") {
+			t.Fatal("expected unlimited quota to allow")
+		}
+	}
+
+	// Raising the quota allows more events.
+	// Only the updated quotas change.
+	maxEvents := int64(10)
+	n, err = cli.UpdateNamespace(n.Link, client.UpdateNamespaceOptions{
+		Quotas: &client.UpdateNamespaceQuotas{MaxAlertEventsPerMinute: &maxEvents},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Quotas.MaxTasks != 2 || n.Quotas.MaxAlertEventsPerMinute != 10 {
+		t.Errorf("unexpected quotas after update %v", n.Quotas)
+	}
+	maxTasks := -1
+	if _, err := cli.UpdateNamespace(n.Link, client.UpdateNamespaceOptions{
+		Quotas: &client.UpdateNamespaceQuotas{MaxTasks: &maxTasks},
+	}); err == nil {
+		t.Error("expected error updating namespace with negative quota")
+	}
+	if !service.AllowAlertEvent("team-a") {
+		t.Error("expected alert event to be allowed after raising quota")
+	}
+}
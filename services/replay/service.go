@@ -1187,6 +1187,7 @@ func (r *Service) doLiveQueryReplay(id string, task *kapacitor.Task, clk clock.C
 func (r *Service) doReplay(id string, task *kapacitor.Task, runReplay func(tm *kapacitor.TaskMaster) error) error {
 	// Create new isolated task master
 	tm := r.TaskMaster.New(id)
+	tm.Replay = true
 	r.TaskMasterLookup.Set(tm)
 	defer r.TaskMasterLookup.Delete(tm)
 
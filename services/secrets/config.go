@@ -0,0 +1,51 @@
+package secrets
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultPath      = "/var/lib/kapacitor/secrets.db"
+	DefaultKeyEnv    = "KAPACITOR_SECRETS_KEY"
+	DefaultEnvPrefix = "KAPACITOR_SECRET_"
+)
+
+type Config struct {
+	// Enable the encrypted secrets store and the /kapacitor/v1/secrets API.
+	Enabled bool `toml:"enabled"`
+	// Path of the encrypted secrets store.
+	Path string `toml:"path"`
+	// File containing the base64 encoded 32 byte key of the store.
+	KeyFile string `toml:"key-file"`
+	// Environment variable containing the base64 encoded key, used if no key file is set.
+	KeyEnv string `toml:"key-env"`
+	// Prefix of the environment variables that can be referenced as secret://env/NAME.
+	EnvPrefix string `toml:"env-prefix"`
+	// Directory of the files that can be referenced as secret://file/NAME.
+	// File references are disabled if empty.
+	FileDir string `toml:"file-dir"`
+}
+
+func NewConfig() Config {
+	return Config{
+		Path:      DefaultPath,
+		KeyEnv:    DefaultKeyEnv,
+		EnvPrefix: DefaultEnvPrefix,
+	}
+}
+
+func (c Config) Validate() error {
+	if c.EnvPrefix == "" {
+		return errors.New("env-prefix must not be empty")
+	}
+	if !c.Enabled {
+		return nil
+	}
+	if c.Path == "" {
+		return errors.New("must specify path of the secrets store")
+	}
+	if c.KeyFile == "" && c.KeyEnv == "" {
+		return errors.New("must specify key-file or key-env")
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package secrets
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/influxdata/kapacitor/auth"
+)
+
+const (
+	// Prefix of values referencing a secret.
+	Scheme = "secret://"
+
+	envProvider  = "env/"
+	fileProvider = "file/"
+)
+
+// Ref returns the reference of the value, i.e. name for secret://name.
+func Ref(value string) (string, bool) {
+	if !strings.HasPrefix(value, Scheme) {
+		return "", false
+	}
+	return strings.TrimPrefix(value, Scheme), true
+}
+
+// References returns the references to secrets of all strings within v,
+// including the fields of structs and the elements of slices and maps.
+func References(v interface{}) []string {
+	var refs []string
+	walkStrings(reflect.ValueOf(v), func(s string) (string, error) {
+		if ref, ok := Ref(s); ok {
+			refs = append(refs, ref)
+		}
+		return s, nil
+	})
+	return refs
+}
+
+// Authorize returns an error unless the user has the read privilege on all secrets
+// referenced by v that are not already referenced by prev, i.e. on /api/secrets/name for secret://name.
+// Otherwise anyone allowed to define handlers could send any secret to an endpoint of their choice.
+func Authorize(user auth.User, v, prev interface{}) error {
+	known := make(map[string]bool)
+	for _, ref := range References(prev) {
+		known[ref] = true
+	}
+	for _, ref := range References(v) {
+		if known[ref] {
+			continue
+		}
+		resource := path.Join(secretsPath, ref)
+		if !strings.HasPrefix(resource, secretsPathAnchored) {
+			return fmt.Errorf("invalid secret reference %q", ref)
+		}
+		if err := user.AuthorizeAction(auth.Action{
+			Resource:  auth.APIResource(resource),
+			Privilege: auth.ReadPrivilege,
+		}); err != nil {
+			return fmt.Errorf("cannot reference secret %q: %v", ref, err)
+		}
+	}
+	return nil
+}
+
+// Referenced reports whether v references any of the secrets of the store.
+func Referenced(v interface{}, names []string) bool {
+	for _, ref := range References(v) {
+		for _, name := range names {
+			if ref == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// walkStrings calls fn for all strings reachable from v and stores the returned value if it changed.
+// Values that are not addressable, i.e. within maps and interfaces, are replaced with a modified copy.
+// Unexported struct fields are skipped.
+func walkStrings(v reflect.Value, fn func(string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return walkStrings(v.Elem(), fn)
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		c, changed, err := walkCopy(v.Elem(), fn)
+		if err != nil {
+			return err
+		}
+		if changed && v.CanSet() {
+			v.Set(c)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if t.Field(i).PkgPath != "" {
+				continue
+			}
+			if err := walkStrings(v.Field(i), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := walkStrings(v.Index(i), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			c, changed, err := walkCopy(v.MapIndex(k), fn)
+			if err != nil {
+				return err
+			}
+			if changed {
+				v.SetMapIndex(k, c)
+			}
+		}
+	case reflect.String:
+		s, err := fn(v.String())
+		if err != nil {
+			return err
+		}
+		if s != v.String() && v.CanSet() {
+			v.SetString(s)
+		}
+	}
+	return nil
+}
+
+// walkCopy walks an addressable copy of v and reports whether any string was changed.
+func walkCopy(v reflect.Value, fn func(string) (string, error)) (reflect.Value, bool, error) {
+	changed := false
+	c := reflect.New(v.Type()).Elem()
+	c.Set(v)
+	err := walkStrings(c, func(s string) (string, error) {
+		n, err := fn(s)
+		if n != s {
+			changed = true
+		}
+		return n, err
+	})
+	return c, changed, err
+}
@@ -0,0 +1,269 @@
+// The secrets service resolves references to secrets in configuration values and handler options.
+//
+// A string value of the form secret://<reference> is replaced with the value of the secret:
+//
+//	secret://name        the secret with the name from the encrypted secrets store
+//	secret://env/NAME    the environment variable <env-prefix>NAME
+//	secret://file/NAME   the content of the file NAME in the file-dir directory
+//
+// Secrets of the store are managed via the /kapacitor/v1/secrets API.
+// Changing a secret updates all services and handlers referencing it without a restart.
+// Handler options may only reference secrets the author has the read privilege for,
+// e.g. /api/secrets/env/NAME for secret://env/NAME.
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	client "github.com/influxdata/kapacitor/client/v1"
+	"github.com/influxdata/kapacitor/services/httpd"
+	"github.com/mitchellh/copystructure"
+	"github.com/pkg/errors"
+)
+
+const (
+	secretsPath         = "/secrets"
+	secretsPathAnchored = "/secrets/"
+	secretsBasePath     = httpd.BasePath + secretsPath
+)
+
+var validName = regexp.MustCompile(`^[-\w.]+$`)
+
+type Service struct {
+	enabled   bool
+	envPrefix string
+	fileDir   string
+	store     *store
+	updates   chan<- []string
+	routes    []httpd.Route
+	logger    *log.Logger
+
+	HTTPDService interface {
+		AddRoutes([]httpd.Route) error
+		DelRoutes([]httpd.Route)
+	}
+}
+
+// NewService creates the secrets service and loads the encrypted store if enabled,
+// so that secrets can be resolved before any other service is created.
+// The names of changed secrets are sent on the updates channel.
+func NewService(c Config, l *log.Logger, updates chan<- []string) (*Service, error) {
+	s := &Service{
+		enabled:   c.Enabled,
+		envPrefix: c.EnvPrefix,
+		fileDir:   c.FileDir,
+		updates:   updates,
+		logger:    l,
+	}
+	if c.Enabled {
+		key, err := loadKey(c.KeyFile, c.KeyEnv)
+		if err != nil {
+			return nil, err
+		}
+		s.store, err = openStore(c.Path, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *Service) Open() error {
+	if !s.enabled {
+		return nil
+	}
+	s.routes = []httpd.Route{
+		{
+			Method:      "GET",
+			Pattern:     secretsPath,
+			HandlerFunc: s.handleListSecrets,
+		},
+		{
+			Method:      "POST",
+			Pattern:     secretsPath,
+			HandlerFunc: s.handleSetSecret,
+		},
+		{
+			Method:      "GET",
+			Pattern:     secretsPathAnchored,
+			HandlerFunc: s.handleGetSecret,
+		},
+		{
+			Method:      "DELETE",
+			Pattern:     secretsPathAnchored,
+			HandlerFunc: s.handleDeleteSecret,
+		},
+		{
+			// Satisfy CORS checks.
+			Method:      "OPTIONS",
+			Pattern:     secretsPathAnchored,
+			HandlerFunc: httpd.ServeOptions,
+		},
+	}
+	return s.HTTPDService.AddRoutes(s.routes)
+}
+
+func (s *Service) Close() error {
+	if s.HTTPDService != nil {
+		s.HTTPDService.DelRoutes(s.routes)
+	}
+	return nil
+}
+
+// Resolve returns the value of a secret reference.
+// Values that are not references are returned unchanged.
+func (s *Service) Resolve(value string) (string, error) {
+	ref, ok := Ref(value)
+	if !ok {
+		return value, nil
+	}
+	switch {
+	case strings.HasPrefix(ref, envProvider):
+		name := s.envPrefix + strings.TrimPrefix(ref, envProvider)
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s of secret %q is not set", name, ref)
+		}
+		return v, nil
+	case strings.HasPrefix(ref, fileProvider):
+		name := strings.TrimPrefix(ref, fileProvider)
+		if s.fileDir == "" {
+			return "", fmt.Errorf("cannot resolve secret %q, file references are disabled", ref)
+		}
+		if !validName.MatchString(name) {
+			return "", fmt.Errorf("invalid secret file name %q", name)
+		}
+		data, err := ioutil.ReadFile(filepath.Join(s.fileDir, name))
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to read secret %q", ref)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	default:
+		if s.store == nil {
+			return "", fmt.Errorf("cannot resolve secret %q, the secrets store is not enabled", ref)
+		}
+		v, err := s.store.Get(ref)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to resolve secret %q", ref)
+		}
+		return v, nil
+	}
+}
+
+// ResolveConfig returns a copy of the config with all secret references resolved.
+// The config itself is returned if it does not reference any secrets.
+func (s *Service) ResolveConfig(config interface{}) (interface{}, error) {
+	if len(References(config)) == 0 {
+		return config, nil
+	}
+	c, err := copystructure.Copy(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to copy config")
+	}
+	v := reflect.New(reflect.TypeOf(c)).Elem()
+	v.Set(reflect.ValueOf(c))
+	if err := walkStrings(v, s.Resolve); err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// ResolveOptions returns a copy of the handler options with all secret references resolved.
+func (s *Service) ResolveOptions(options map[string]interface{}) (map[string]interface{}, error) {
+	c, err := s.ResolveConfig(options)
+	if err != nil {
+		return nil, err
+	}
+	return c.(map[string]interface{}), nil
+}
+
+func (s *Service) secretLink(name string) client.Link {
+	return client.Link{Relation: client.Self, Href: path.Join(secretsBasePath, name)}
+}
+
+func (s *Service) convertSecret(info SecretInfo) client.Secret {
+	return client.Secret{
+		Link:    s.secretLink(info.Name),
+		Name:    info.Name,
+		Updated: info.Updated,
+	}
+}
+
+func (s *Service) handleListSecrets(w http.ResponseWriter, r *http.Request) {
+	infos := s.store.List()
+	secrets := make([]client.Secret, len(infos))
+	for i, info := range infos {
+		secrets[i] = s.convertSecret(info)
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(httpd.MarshalJSON(client.Secrets{
+		Link:    client.Link{Relation: client.Self, Href: r.URL.String()},
+		Secrets: secrets,
+	}, true))
+}
+
+func (s *Service) handleSetSecret(w http.ResponseWriter, r *http.Request) {
+	opt := client.SetSecretOptions{}
+	if err := json.NewDecoder(r.Body).Decode(&opt); err != nil {
+		httpd.HttpError(w, fmt.Sprint("invalid secret json: ", err.Error()), true, http.StatusBadRequest)
+		return
+	}
+	if !validName.MatchString(opt.Name) {
+		httpd.HttpError(w, fmt.Sprintf("invalid secret name %q, must contain only letters, numbers, '-', '_' and '.'", opt.Name), true, http.StatusBadRequest)
+		return
+	}
+	info, err := s.store.Set(opt.Name, opt.Value)
+	if err != nil {
+		httpd.HttpError(w, fmt.Sprintf("failed to set secret %q: %v", opt.Name, err), true, http.StatusInternalServerError)
+		return
+	}
+	s.notify(opt.Name)
+	w.WriteHeader(http.StatusOK)
+	w.Write(httpd.MarshalJSON(s.convertSecret(info), true))
+}
+
+func (s *Service) handleGetSecret(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, secretsBasePath+"/")
+	info, err := s.store.Info(name)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if err == ErrNoSecretExists {
+			code = http.StatusNotFound
+		}
+		httpd.HttpError(w, fmt.Sprintf("failed to get secret %q: %v", name, err), true, code)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(httpd.MarshalJSON(s.convertSecret(info), true))
+}
+
+func (s *Service) handleDeleteSecret(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, secretsBasePath+"/")
+	if err := s.store.Delete(name); err != nil && err != ErrNoSecretExists {
+		httpd.HttpError(w, fmt.Sprintf("failed to delete secret %q: %v", name, err), true, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// notify sends the name of the changed secret to the updates channel without blocking.
+func (s *Service) notify(name string) {
+	if s.updates == nil {
+		return
+	}
+	select {
+	case s.updates <- []string{name}:
+	default:
+		s.logger.Printf("E! dropped update of secret %s, services referencing it must be updated manually", name)
+	}
+}
@@ -0,0 +1,224 @@
+package secrets_test
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/influxdata/kapacitor/auth"
+	client "github.com/influxdata/kapacitor/client/v1"
+	"github.com/influxdata/kapacitor/services/httpd/httpdtest"
+	"github.com/influxdata/kapacitor/services/secrets"
+)
+
+const keyEnv = "KAPACITOR_SECRETS_TEST_KEY"
+
+type testConfig struct {
+	URL      string
+	Password string
+	Headers  map[string]string
+	Servers  []string
+	Options  map[string]interface{}
+	private  string
+}
+
+func newConfig(t *testing.T, dir string, key byte) secrets.Config {
+	k := make([]byte, 32)
+	for i := range k {
+		k[i] = key
+	}
+	os.Setenv(keyEnv, base64.StdEncoding.EncodeToString(k))
+	c := secrets.NewConfig()
+	c.Enabled = true
+	c.Path = filepath.Join(dir, "secrets.db")
+	c.KeyEnv = keyEnv
+	c.FileDir = dir
+	return c
+}
+
+func OpenNewService(t *testing.T, c secrets.Config) (*secrets.Service, *client.Client, *httpdtest.Server, chan []string) {
+	updates := make(chan []string, 10)
+	service, err := secrets.NewService(c, log.New(os.Stderr, "[secrets] ", log.LstdFlags), updates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httpdtest.NewServer(testing.Verbose())
+	service.HTTPDService = server
+	if err := service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	cli, err := client.New(client.Config{URL: server.Server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service, cli, server, updates
+}
+
+func TestService_Secrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kapacitor-secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := newConfig(t, dir, 1)
+	service, cli, server, updates := OpenNewService(t, c)
+	defer server.Close()
+	defer service.Close()
+
+	if _, err := cli.SetSecret(client.SetSecretOptions{Name: "slack", Value: "https://hooks.slack.com/a"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.SetSecret(client.SetSecretOptions{Name: "bad/name", Value: "x"}); err == nil {
+		t.Error("expected error setting secret with invalid name")
+	}
+	if got, exp := <-updates, []string{"slack"}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("unexpected update got %v exp %v", got, exp)
+	}
+	if v, err := service.Resolve("secret://slack"); err != nil {
+		t.Fatal(err)
+	} else if exp := "https://hooks.slack.com/a"; v != exp {
+		t.Errorf("unexpected secret value got %q exp %q", v, exp)
+	}
+
+	// Rotate the secret.
+	if _, err := cli.SetSecret(client.SetSecretOptions{Name: "slack", Value: "https://hooks.slack.com/b"}); err != nil {
+		t.Fatal(err)
+	}
+	<-updates
+	if v, err := service.Resolve("secret://slack"); err != nil {
+		t.Fatal(err)
+	} else if exp := "https://hooks.slack.com/b"; v != exp {
+		t.Errorf("unexpected secret value got %q exp %q", v, exp)
+	}
+
+	secret, err := cli.Secret(cli.SecretLink("slack"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Name != "slack" || secret.Updated.IsZero() {
+		t.Errorf("unexpected secret %+v", secret)
+	}
+	list, err := cli.ListSecrets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Secrets) != 1 || list.Secrets[0].Name != "slack" {
+		t.Errorf("unexpected secrets %v", list.Secrets)
+	}
+
+	// The store can only be opened with the same key.
+	if _, err := secrets.NewService(c, log.New(os.Stderr, "[secrets] ", log.LstdFlags), nil); err != nil {
+		t.Errorf("failed to reopen store: %v", err)
+	}
+	if _, err := secrets.NewService(newConfig(t, dir, 2), log.New(os.Stderr, "[secrets] ", log.LstdFlags), nil); err == nil {
+		t.Error("expected error opening store with the wrong key")
+	}
+
+	if err := cli.DeleteSecret(cli.SecretLink("slack")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.Secret(cli.SecretLink("slack")); err == nil {
+		t.Error("expected error getting deleted secret")
+	}
+	if _, err := service.Resolve("secret://slack"); err == nil {
+		t.Error("expected error resolving deleted secret")
+	}
+}
+
+func TestService_ResolveConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kapacitor-secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := newConfig(t, dir, 1)
+	service, cli, server, _ := OpenNewService(t, c)
+	defer server.Close()
+	defer service.Close()
+
+	if _, err := cli.SetSecret(client.SetSecretOptions{Name: "password", Value: "p4ss"}); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv(secrets.DefaultEnvPrefix+"TOKEN", "t0ken")
+	defer os.Unsetenv(secrets.DefaultEnvPrefix + "TOKEN")
+	if err := ioutil.WriteFile(filepath.Join(dir, "server"), []byte("example.com:9092\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &testConfig{
+		URL:      "http://example.com",
+		Password: "secret://password",
+		Headers:  map[string]string{"Authorization": "secret://env/TOKEN"},
+		Servers:  []string{"secret://file/server", "localhost:9092"},
+		Options: map[string]interface{}{
+			"nested": []interface{}{"secret://password"},
+		},
+		private: "secret://password",
+	}
+	if got, exp := secrets.References(config), 4; len(got) != exp {
+		t.Errorf("unexpected references got %v exp %d", got, exp)
+	}
+	resolved, err := service.ResolveConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := &testConfig{
+		URL:      "http://example.com",
+		Password: "p4ss",
+		Headers:  map[string]string{"Authorization": "t0ken"},
+		Servers:  []string{"example.com:9092", "localhost:9092"},
+		Options: map[string]interface{}{
+			"nested": []interface{}{"p4ss"},
+		},
+	}
+	if !reflect.DeepEqual(resolved, exp) {
+		t.Errorf("unexpected resolved config:\ngot\n%+v\nexp\n%+v", resolved, exp)
+	}
+	// The original config keeps its references.
+	if config.Password != "secret://password" || config.Headers["Authorization"] != "secret://env/TOKEN" || config.Options["nested"].([]interface{})[0] != "secret://password" {
+		t.Errorf("original config was modified %+v", config)
+	}
+	if !secrets.Referenced(config, []string{"env/TOKEN"}) || secrets.Referenced(config, []string{"other"}) {
+		t.Error("unexpected result of Referenced")
+	}
+
+	for _, ref := range []string{"secret://missing", "secret://env/MISSING", "secret://file/../secrets.db"} {
+		if _, err := service.Resolve(ref); err == nil {
+			t.Errorf("expected error resolving %s", ref)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	user := auth.NewUser("bob", nil, false, map[string][]auth.Privilege{
+		"/api/secrets/smtp": {auth.ReadPrivilege},
+	})
+	options := map[string]interface{}{
+		"password": "secret://smtp/password",
+		"to":       []interface{}{"oncall@example.com"},
+	}
+	if err := secrets.Authorize(user, options, nil); err != nil {
+		t.Errorf("unexpected error authorizing readable secret: %v", err)
+	}
+	options["token"] = "secret://env/TOKEN"
+	if err := secrets.Authorize(user, options, nil); err == nil {
+		t.Error("expected error authorizing unreadable secret")
+	}
+	// References kept from the previous options do not require the privilege.
+	prev := map[string]interface{}{"token": "secret://env/TOKEN"}
+	if err := secrets.Authorize(user, options, prev); err != nil {
+		t.Errorf("unexpected error authorizing kept secret: %v", err)
+	}
+	if err := secrets.Authorize(user, map[string]interface{}{"password": "secret://smtp/../../kapacitor/v1/tasks"}, nil); err == nil {
+		t.Error("expected error authorizing reference outside of the secrets")
+	}
+	if err := secrets.Authorize(auth.AdminUser, options, nil); err != nil {
+		t.Errorf("unexpected error authorizing admin: %v", err)
+	}
+}
@@ -0,0 +1,237 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	keySize = 32
+
+	// Current version of the store file format.
+	storeVersion = 1
+)
+
+var ErrNoSecretExists = errors.New("no secret exists")
+
+// store is a file of secrets encrypted with AES-256-GCM.
+// Each value is sealed with its own nonce and the name of the secret as additional data.
+type store struct {
+	path string
+	aead cipher.AEAD
+
+	mu      sync.RWMutex
+	secrets map[string]sealedSecret
+}
+
+type storeFile struct {
+	Version int                     `json:"version"`
+	Secrets map[string]sealedSecret `json:"secrets"`
+}
+
+type sealedSecret struct {
+	Nonce   []byte    `json:"nonce"`
+	Value   []byte    `json:"value"`
+	Updated time.Time `json:"updated"`
+}
+
+// SecretInfo describes a secret without its value.
+type SecretInfo struct {
+	Name    string
+	Updated time.Time
+}
+
+func openStore(path string, key []byte) (*store, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	s := &store{
+		path:    path,
+		aead:    aead,
+		secrets: make(map[string]sealedSecret),
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, errors.Wrap(err, "failed to read secrets store")
+	}
+	f := storeFile{}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, errors.Wrap(err, "invalid secrets store")
+	}
+	if f.Version != storeVersion {
+		return nil, fmt.Errorf("unsupported secrets store version %d", f.Version)
+	}
+	// Verify the key by opening all secrets.
+	for name, sealed := range f.Secrets {
+		if _, err := aead.Open(nil, sealed.Nonce, sealed.Value, []byte(name)); err != nil {
+			return nil, fmt.Errorf("failed to decrypt secret %q, wrong key?", name)
+		}
+		s.secrets[name] = sealed
+	}
+	return s, nil
+}
+
+func (s *store) Get(name string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sealed, ok := s.secrets[name]
+	if !ok {
+		return "", ErrNoSecretExists
+	}
+	value, err := s.aead.Open(nil, sealed.Nonce, sealed.Value, []byte(name))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to decrypt secret %q", name)
+	}
+	return string(value), nil
+}
+
+func (s *store) Info(name string) (SecretInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sealed, ok := s.secrets[name]
+	if !ok {
+		return SecretInfo{}, ErrNoSecretExists
+	}
+	return SecretInfo{Name: name, Updated: sealed.Updated}, nil
+}
+
+// List returns the secrets sorted by name.
+func (s *store) List() []SecretInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]SecretInfo, 0, len(s.secrets))
+	for name, sealed := range s.secrets {
+		list = append(list, SecretInfo{Name: name, Updated: sealed.Updated})
+	}
+	sort.Sort(secretInfos(list))
+	return list
+}
+
+// Set creates or replaces the secret.
+func (s *store) Set(name, value string) (SecretInfo, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return SecretInfo{}, err
+	}
+	sealed := sealedSecret{
+		Nonce:   nonce,
+		Value:   s.aead.Seal(nil, nonce, []byte(value), []byte(name)),
+		Updated: time.Now().UTC(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, existed := s.secrets[name]
+	s.secrets[name] = sealed
+	if err := s.save(); err != nil {
+		if existed {
+			s.secrets[name] = old
+		} else {
+			delete(s.secrets, name)
+		}
+		return SecretInfo{}, err
+	}
+	return SecretInfo{Name: name, Updated: sealed.Updated}, nil
+}
+
+func (s *store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.secrets[name]
+	if !ok {
+		return ErrNoSecretExists
+	}
+	delete(s.secrets, name)
+	if err := s.save(); err != nil {
+		s.secrets[name] = old
+		return err
+	}
+	return nil
+}
+
+// save atomically replaces the store file.
+// Caller must have the write lock.
+func (s *store) save() error {
+	data, err := json.Marshal(storeFile{
+		Version: storeVersion,
+		Secrets: s.secrets,
+	})
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.Wrap(err, "failed to create secrets store directory")
+	}
+	f, err := ioutil.TempFile(dir, filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to write secrets store")
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return errors.Wrap(err, "failed to write secrets store")
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return errors.Wrap(err, "failed to write secrets store")
+	}
+	if err := os.Rename(f.Name(), s.path); err != nil {
+		os.Remove(f.Name())
+		return errors.Wrap(err, "failed to replace secrets store")
+	}
+	return nil
+}
+
+// loadKey reads the base64 encoded key from the file or environment variable.
+func loadKey(keyFile, keyEnv string) ([]byte, error) {
+	var encoded string
+	if keyFile != "" {
+		data, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read secrets key")
+		}
+		encoded = string(data)
+	} else {
+		encoded = os.Getenv(keyEnv)
+		if encoded == "" {
+			return nil, fmt.Errorf("secrets key environment variable %s is not set", keyEnv)
+		}
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, errors.Wrap(err, "secrets key must be base64 encoded")
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("secrets key must be %d bytes, got %d", keySize, len(key))
+	}
+	return key, nil
+}
+
+type secretInfos []SecretInfo
+
+func (s secretInfos) Len() int           { return len(s) }
+func (s secretInfos) Less(i, j int) bool { return s[i].Name < s[j].Name }
+func (s secretInfos) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
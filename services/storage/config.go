@@ -2,14 +2,32 @@ package storage
 
 import "fmt"
 
+const (
+	DefaultEncryptionKeyEnv = "KAPACITOR_STORAGE_KEY"
+)
+
 type Config struct {
 	// Path to a boltdb database file.
 	BoltDBPath string `toml:"boltdb"`
+
+	// Encrypt all values written to the database.
+	Encrypt bool `toml:"encrypt"`
+	// File containing the base64 encoded 32 byte encryption key.
+	KeyFile string `toml:"key-file"`
+	// Environment variable containing the encryption key, used if no key file is set.
+	KeyEnv string `toml:"key-env"`
+	// Files containing previous encryption keys.
+	// Values encrypted with a previous key can still be read after rotating the key.
+	PreviousKeyFiles []string `toml:"previous-key-files"`
+	// Compact the database on startup, re-encrypting all values with the current key.
+	// The database is always compacted on startup if it has values that are not encrypted with the current key.
+	CompactOnStartup bool `toml:"compact-on-startup"`
 }
 
 func NewConfig() Config {
 	return Config{
 		BoltDBPath: "./kapacitor.db",
+		KeyEnv:     DefaultEncryptionKeyEnv,
 	}
 }
 
@@ -17,5 +35,31 @@ func (c Config) Validate() error {
 	if c.BoltDBPath == "" {
 		return fmt.Errorf("must specify storage 'boltdb' path")
 	}
+	if c.Encrypt && c.KeyFile == "" && c.KeyEnv == "" {
+		return fmt.Errorf("must specify storage 'key-file' or 'key-env' to encrypt the database")
+	}
 	return nil
 }
+
+// Keyring loads the encryption keys.
+// Without encryption the previous keys are still loaded so that existing encrypted values can be read,
+// compacting the database then decrypts them.
+func (c Config) Keyring() (*Keyring, error) {
+	var current []byte
+	if c.Encrypt {
+		key, err := LoadEncryptionKey(c.KeyFile, c.KeyEnv)
+		if err != nil {
+			return nil, err
+		}
+		current = key
+	}
+	previous := make([][]byte, len(c.PreviousKeyFiles))
+	for i, f := range c.PreviousKeyFiles {
+		key, err := LoadEncryptionKey(f, "")
+		if err != nil {
+			return nil, err
+		}
+		previous[i] = key
+	}
+	return NewKeyring(current, previous...)
+}
@@ -0,0 +1,316 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	encryptionKeySize = 32
+	keyIDSize         = 8
+)
+
+// Prefix of encrypted values, the last byte is the version of the format.
+// Values are JSON or plain strings and never start with a zero byte,
+// so values without the prefix are values written before encryption was enabled.
+var encryptedPrefix = []byte{0, 'k', 'e', 1}
+
+var ErrNoEncryptionKey = errors.New("value is encrypted with an unknown key")
+
+// Keyring encrypts values using envelope encryption.
+// Each value is encrypted with its own random data key using AES-256-GCM,
+// the data key is encrypted with the current master key and stored alongside the value.
+//
+// Values encrypted with previous master keys can still be decrypted, which allows rotating the master key.
+// If the keyring has no current key, values are not encrypted.
+type Keyring struct {
+	current *masterKey
+	keys    map[string]*masterKey
+}
+
+type masterKey struct {
+	id   []byte
+	aead cipher.AEAD
+}
+
+// NewKeyring creates a keyring which encrypts with the current key and decrypts with any of the keys.
+// The current key may be nil to disable encryption while still decrypting existing values.
+func NewKeyring(current []byte, previous ...[]byte) (*Keyring, error) {
+	k := &Keyring{
+		keys: make(map[string]*masterKey),
+	}
+	if current != nil {
+		mk, err := newMasterKey(current)
+		if err != nil {
+			return nil, err
+		}
+		k.current = mk
+		k.keys[string(mk.id)] = mk
+	}
+	for _, key := range previous {
+		mk, err := newMasterKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := k.keys[string(mk.id)]; !ok {
+			k.keys[string(mk.id)] = mk
+		}
+	}
+	return k, nil
+}
+
+func newMasterKey(key []byte) (*masterKey, error) {
+	if len(key) != encryptionKeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", encryptionKeySize, len(key))
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(key)
+	return &masterKey{
+		id:   sum[:keyIDSize],
+		aead: aead,
+	}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Enabled reports whether values are encrypted.
+func (k *Keyring) Enabled() bool {
+	return k.current != nil
+}
+
+// Encrypt encrypts the value with a new data key.
+// The additional data is authenticated but not stored, it must be passed to Decrypt.
+//
+// Format: prefix | master key ID | key nonce | encrypted data key | value nonce | encrypted value
+func (k *Keyring) Encrypt(value, additionalData []byte) ([]byte, error) {
+	if k.current == nil {
+		return value, nil
+	}
+	dataKey := make([]byte, encryptionKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	keyNonce := make([]byte, k.current.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, keyNonce); err != nil {
+		return nil, err
+	}
+	valueNonce := make([]byte, dataAEAD.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, valueNonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(encryptedPrefix)+keyIDSize+len(keyNonce)+len(dataKey)+k.current.aead.Overhead()+len(valueNonce)+len(value)+dataAEAD.Overhead())
+	out = append(out, encryptedPrefix...)
+	out = append(out, k.current.id...)
+	out = append(out, keyNonce...)
+	out = k.current.aead.Seal(out, keyNonce, dataKey, k.current.id)
+	out = append(out, valueNonce...)
+	out = dataAEAD.Seal(out, valueNonce, value, additionalData)
+	return out, nil
+}
+
+// Decrypt decrypts a value returned by Encrypt.
+// Values that are not encrypted are returned unchanged.
+func (k *Keyring) Decrypt(value, additionalData []byte) ([]byte, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	data := value[len(encryptedPrefix):]
+	if len(data) < keyIDSize {
+		return nil, errors.New("invalid encrypted value")
+	}
+	mk, ok := k.keys[string(data[:keyIDSize])]
+	if !ok {
+		return nil, ErrNoEncryptionKey
+	}
+	data = data[keyIDSize:]
+
+	keyNonceSize := mk.aead.NonceSize()
+	sealedKeySize := encryptionKeySize + mk.aead.Overhead()
+	if len(data) < keyNonceSize+sealedKeySize {
+		return nil, errors.New("invalid encrypted value")
+	}
+	dataKey, err := mk.aead.Open(nil, data[:keyNonceSize], data[keyNonceSize:keyNonceSize+sealedKeySize], mk.id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt data key")
+	}
+	data = data[keyNonceSize+sealedKeySize:]
+
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	valueNonceSize := dataAEAD.NonceSize()
+	if len(data) < valueNonceSize {
+		return nil, errors.New("invalid encrypted value")
+	}
+	plain, err := dataAEAD.Open(nil, data[:valueNonceSize], data[valueNonceSize:], additionalData)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt value")
+	}
+	return plain, nil
+}
+
+// Reencrypt decrypts the value and encrypts it with the current key.
+// If the keyring has no current key the value is decrypted.
+func (k *Keyring) Reencrypt(value, additionalData []byte) ([]byte, error) {
+	plain, err := k.Decrypt(value, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	return k.Encrypt(plain, additionalData)
+}
+
+// IsCurrent reports whether the value is encrypted with the current key,
+// or not encrypted if the keyring has no current key.
+func (k *Keyring) IsCurrent(value []byte) bool {
+	if !IsEncrypted(value) {
+		return k.current == nil
+	}
+	if k.current == nil {
+		return false
+	}
+	id := value[len(encryptedPrefix):]
+	return len(id) >= keyIDSize && bytes.Equal(id[:keyIDSize], k.current.id)
+}
+
+// IsEncrypted reports whether the value was encrypted by a Keyring.
+func IsEncrypted(value []byte) bool {
+	return bytes.HasPrefix(value, encryptedPrefix)
+}
+
+// LoadEncryptionKey reads a base64 encoded key from the file or, if empty, the environment variable.
+func LoadEncryptionKey(keyFile, keyEnv string) ([]byte, error) {
+	var encoded string
+	if keyFile != "" {
+		data, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read encryption key")
+		}
+		encoded = string(data)
+	} else {
+		encoded = os.Getenv(keyEnv)
+		if encoded == "" {
+			return nil, fmt.Errorf("encryption key environment variable %s is not set", keyEnv)
+		}
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, errors.Wrap(err, "encryption key must be base64 encoded")
+	}
+	if len(key) != encryptionKeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", encryptionKeySize, len(key))
+	}
+	return key, nil
+}
+
+// additionalData binds an encrypted value to its namespace and key,
+// so that encrypted values cannot be swapped.
+func additionalData(namespace, key string) []byte {
+	return []byte(namespace + "\x00" + key)
+}
+
+// Encrypted wraps a store and transparently encrypts and decrypts all values.
+type Encrypted struct {
+	store     Interface
+	namespace string
+	keyring   *Keyring
+}
+
+func NewEncrypted(store Interface, namespace string, keyring *Keyring) *Encrypted {
+	return &Encrypted{
+		store:     store,
+		namespace: namespace,
+		keyring:   keyring,
+	}
+}
+
+func (e *Encrypted) View(f func(tx ReadOnlyTx) error) error {
+	return e.store.View(func(tx ReadOnlyTx) error {
+		return f(&encryptedTx{e: e, ReadOnlyTx: tx})
+	})
+}
+
+func (e *Encrypted) Update(f func(tx Tx) error) error {
+	return e.store.Update(func(tx Tx) error {
+		return f(&encryptedTx{e: e, ReadOnlyTx: tx, tx: tx})
+	})
+}
+
+func (e *Encrypted) decrypt(kv *KeyValue) (*KeyValue, error) {
+	value, err := e.keyring.Decrypt(kv.Value, additionalData(e.namespace, kv.Key))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decrypt %s/%s", e.namespace, kv.Key)
+	}
+	return &KeyValue{Key: kv.Key, Value: value}, nil
+}
+
+// encryptedTx wraps a transaction of the underlying store.
+// The write methods must only be called for transactions created by Update.
+type encryptedTx struct {
+	ReadOnlyTx
+	e  *Encrypted
+	tx Tx
+}
+
+func (t *encryptedTx) Get(key string) (*KeyValue, error) {
+	kv, err := t.ReadOnlyTx.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return t.e.decrypt(kv)
+}
+
+func (t *encryptedTx) List(prefix string) ([]*KeyValue, error) {
+	kvs, err := t.ReadOnlyTx.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+	for i, kv := range kvs {
+		kvs[i], err = t.e.decrypt(kv)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return kvs, nil
+}
+
+func (t *encryptedTx) Put(key string, value []byte) error {
+	encrypted, err := t.e.keyring.Encrypt(value, additionalData(t.e.namespace, key))
+	if err != nil {
+		return errors.Wrapf(err, "failed to encrypt %s/%s", t.e.namespace, key)
+	}
+	return t.tx.Put(key, encrypted)
+}
+
+func (t *encryptedTx) Delete(key string) error {
+	return t.tx.Delete(key)
+}
+
+func (t *encryptedTx) Commit() error {
+	return t.tx.Commit()
+}
@@ -0,0 +1,218 @@
+package storage_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/influxdata/kapacitor/services/httpd/httpdtest"
+	"github.com/influxdata/kapacitor/services/storage"
+)
+
+func TestKeyring(t *testing.T) {
+	k1, err := storage.NewKeyring(testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := []byte(`{"password":"secret"}`)
+	encrypted, err := k1.Encrypt(value, []byte("ns\x00key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !storage.IsEncrypted(encrypted) || bytes.Contains(encrypted, []byte("secret")) {
+		t.Fatalf("value is not encrypted: %q", encrypted)
+	}
+	if decrypted, err := k1.Decrypt(encrypted, []byte("ns\x00key")); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(decrypted, value) {
+		t.Errorf("unexpected decrypted value got %q exp %q", decrypted, value)
+	}
+	if !k1.IsCurrent(encrypted) || k1.IsCurrent(value) {
+		t.Error("expected only the encrypted value to be current")
+	}
+	if _, err := k1.Decrypt(encrypted, []byte("ns\x00other")); err == nil {
+		t.Error("expected error decrypting value of another key")
+	}
+
+	// Values written before encryption was enabled are returned unchanged.
+	if decrypted, err := k1.Decrypt(value, nil); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(decrypted, value) {
+		t.Errorf("unexpected plain value got %q exp %q", decrypted, value)
+	}
+
+	// After a rotation values encrypted with the previous key can still be decrypted.
+	k2, err := storage.NewKeyring(testKey(2), testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reencrypted, err := k2.Reencrypt(encrypted, []byte("ns\x00key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k2.IsCurrent(encrypted) || !k2.IsCurrent(reencrypted) {
+		t.Error("expected only the re-encrypted value to be current")
+	}
+	onlyK2, err := storage.NewKeyring(testKey(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := onlyK2.Decrypt(encrypted, []byte("ns\x00key")); err != storage.ErrNoEncryptionKey {
+		t.Errorf("unexpected error decrypting with unknown key got %v exp %v", err, storage.ErrNoEncryptionKey)
+	}
+	if decrypted, err := onlyK2.Decrypt(reencrypted, []byte("ns\x00key")); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(decrypted, value) {
+		t.Errorf("unexpected decrypted value got %q exp %q", decrypted, value)
+	}
+}
+
+func writeKey(t *testing.T, dir, name string, b byte) string {
+	p := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(p, []byte(base64.StdEncoding.EncodeToString(testKey(b))+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func openService(t *testing.T, c storage.Config) *storage.Service {
+	s := storage.NewService(c, log.New(os.Stderr, "[storage] ", log.LstdFlags))
+	s.HTTPDService = httpdtest.NewServer(testing.Verbose())
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func readValue(t *testing.T, c storage.Config) string {
+	s := openService(t, c)
+	defer s.Close()
+	var value string
+	if err := s.Store("test").View(func(tx storage.ReadOnlyTx) error {
+		kv, err := tx.Get("a")
+		if err != nil {
+			return err
+		}
+		value = string(kv.Value)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func rawValue(t *testing.T, path string) []byte {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var value []byte
+	db.View(func(tx *bolt.Tx) error {
+		value = append(value, tx.Bucket([]byte("test")).Get([]byte("a"))...)
+		return nil
+	})
+	return value
+}
+
+func TestService_EncryptionRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage-encryption")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	key1 := writeKey(t, dir, "key1", 1)
+	key2 := writeKey(t, dir, "key2", 2)
+
+	c := storage.NewConfig()
+	c.BoltDBPath = filepath.Join(dir, "kapacitor.db")
+	c.Encrypt = true
+	c.KeyFile = key1
+
+	s := openService(t, c)
+	if err := s.Store("test").Update(func(tx storage.Tx) error {
+		return tx.Put("a", []byte("value"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	raw := rawValue(t, c.BoltDBPath)
+	if !storage.IsEncrypted(raw) {
+		t.Fatalf("expected value to be encrypted on disk, got %q", raw)
+	}
+
+	// Nested buckets are copied unchanged.
+	putNested(t, c.BoltDBPath)
+
+	// Rotating the key re-encrypts all values on startup.
+	c.KeyFile = key2
+	c.PreviousKeyFiles = []string{key1}
+	if got, exp := readValue(t, c), "value"; got != exp {
+		t.Errorf("unexpected value after rotation got %q exp %q", got, exp)
+	}
+	if bytes.Equal(raw, rawValue(t, c.BoltDBPath)) {
+		t.Error("expected value to be re-encrypted after rotation")
+	}
+	if got, exp := nestedValue(t, c.BoltDBPath), "nested"; got != exp {
+		t.Errorf("unexpected nested value got %q exp %q", got, exp)
+	}
+
+	// The previous key is no longer needed.
+	c.PreviousKeyFiles = nil
+	if got, exp := readValue(t, c), "value"; got != exp {
+		t.Errorf("unexpected value without previous key got %q exp %q", got, exp)
+	}
+
+	// Disabling encryption decrypts all values on startup.
+	c.Encrypt = false
+	c.PreviousKeyFiles = []string{key2}
+	if got, exp := readValue(t, c), "value"; got != exp {
+		t.Errorf("unexpected value after disabling encryption got %q exp %q", got, exp)
+	}
+	if got, exp := string(rawValue(t, c.BoltDBPath)), "value"; got != exp {
+		t.Errorf("unexpected value on disk got %q exp %q", got, exp)
+	}
+	if got, exp := nestedValue(t, c.BoltDBPath), "nested"; got != exp {
+		t.Errorf("unexpected nested value got %q exp %q", got, exp)
+	}
+}
+
+func putNested(t *testing.T, path string) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket([]byte("test")).CreateBucket([]byte("parent"))
+		if err != nil {
+			return err
+		}
+		b, err = b.CreateBucket([]byte("child"))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("b"), []byte("nested"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func nestedValue(t *testing.T, path string) string {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var value string
+	db.View(func(tx *bolt.Tx) error {
+		value = string(tx.Bucket([]byte("test")).Bucket([]byte("parent")).Bucket([]byte("child")).Get([]byte("b")))
+		return nil
+	})
+	return value
+}
@@ -346,15 +346,7 @@ func (s *IndexedStore) list(tx ReadOnlyTx, index, pattern string, offset, limit
 	} else {
 		match = func([]byte) bool { return true }
 	}
-	var matches []string
-	if limit >= 0 {
-		matches = DoListFunc(ids, match, offset, limit)
-	} else {
-		matches = make([]string, len(ids))
-		for i := range ids {
-			matches[i] = string(ids[i].Value)
-		}
-	}
+	matches := DoListFunc(ids, match, offset, limit)
 
 	objects := make([]BinaryObject, len(matches))
 	for i, id := range matches {
@@ -218,3 +218,58 @@ func TestIndexedStore_CRUD(t *testing.T) {
 		})
 	}
 }
+
+func TestIndexedStore_ListPattern(t *testing.T) {
+	for name, sc := range stores {
+		t.Run(name, func(t *testing.T) {
+			db, err := sc()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			s := db.Store("pattern")
+			c := storage.DefaultIndexedStoreConfig("pattern", func() storage.BinaryObject {
+				return new(object)
+			})
+			is, err := storage.NewIndexedStore(s, c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			objects := []*object{
+				{ID: "a1", Value: "obj1"},
+				{ID: "a2", Value: "obj2"},
+				{ID: "b1", Value: "obj3"},
+			}
+			for _, o := range objects {
+				if err := is.Create(o); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			testCases := []struct {
+				pattern       string
+				offset, limit int
+				exp           []storage.BinaryObject
+			}{
+				{pattern: "a*", limit: 100, exp: []storage.BinaryObject{objects[0], objects[1]}},
+				// The pattern also applies without a limit.
+				{pattern: "a*", limit: -1, exp: []storage.BinaryObject{objects[0], objects[1]}},
+				{pattern: "a*", offset: 1, limit: -1, exp: []storage.BinaryObject{objects[1]}},
+				{pattern: "", limit: -1, exp: []storage.BinaryObject{objects[0], objects[1], objects[2]}},
+			}
+			for _, tc := range testCases {
+				got, err := is.List("id", tc.pattern, tc.offset, tc.limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(got) == 0 {
+					got = nil
+				}
+				if !reflect.DeepEqual(got, tc.exp) {
+					t.Errorf("unexpected list with pattern %q offset %d limit %d:\ngot\n%s\nexp\n%s\n", tc.pattern, tc.offset, tc.limit, spew.Sdump(got), spew.Sdump(tc.exp))
+				}
+			}
+		})
+	}
+}
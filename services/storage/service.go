@@ -12,7 +12,9 @@ import (
 )
 
 type Service struct {
-	dbpath string
+	dbpath  string
+	config  Config
+	keyring *Keyring
 
 	boltdb *bolt.DB
 	stores map[string]Interface
@@ -34,6 +36,7 @@ type Service struct {
 func NewService(conf Config, l *log.Logger) *Service {
 	return &Service{
 		dbpath: conf.BoltDBPath,
+		config: conf,
 		logger: l,
 		stores: make(map[string]Interface),
 	}
@@ -50,6 +53,24 @@ func (s *Service) Open() error {
 	if err != nil {
 		return errors.Wrapf(err, "mkdir dirs %q", s.dbpath)
 	}
+	keyring, err := s.config.Keyring()
+	if err != nil {
+		return errors.Wrap(err, "failed to load storage encryption keys")
+	}
+	s.keyring = keyring
+	compact := s.config.CompactOnStartup
+	if !compact {
+		changed, err := s.keyChanged()
+		if err != nil {
+			return errors.Wrapf(err, "read boltdb @ %q", s.dbpath)
+		}
+		compact = changed
+	}
+	if compact {
+		if err := s.compact(); err != nil {
+			return errors.Wrapf(err, "compact boltdb @ %q", s.dbpath)
+		}
+	}
 	db, err := bolt.Open(s.dbpath, 0600, nil)
 	if err != nil {
 		return errors.Wrapf(err, "open boltdb @ %q", s.dbpath)
@@ -99,7 +120,7 @@ func (s *Service) store(name string) Interface {
 	if store, ok := s.stores[name]; ok {
 		return store
 	} else {
-		store = NewBolt(s.boltdb, name)
+		store = NewEncrypted(NewBolt(s.boltdb, name), name, s.keyring)
 		s.stores[name] = store
 		return store
 	}
@@ -112,3 +133,123 @@ func (s *Service) Versions() Versions {
 func (s *Service) Register(name string, store StoreActioner) {
 	s.registrar.Register(name, store)
 }
+
+// compact copies the database into a new file and replaces the database with the copy.
+// All values are re-encrypted with the current key, or decrypted if encryption is disabled,
+// so that previous keys are no longer needed afterwards.
+func (s *Service) compact() error {
+	if _, err := os.Stat(s.dbpath); os.IsNotExist(err) {
+		return nil
+	}
+	src, err := bolt.Open(s.dbpath, 0600, nil)
+	if err != nil {
+		return err
+	}
+	tmpPath := s.dbpath + ".compact"
+	os.Remove(tmpPath)
+	dst, err := bolt.Open(tmpPath, 0600, nil)
+	if err != nil {
+		src.Close()
+		return err
+	}
+
+	count, err := s.copyReencrypted(src, dst)
+	srcSize, _ := fileSize(s.dbpath)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := src.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	dstSize, _ := fileSize(tmpPath)
+	if err := os.Rename(tmpPath, s.dbpath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	s.logger.Printf("I! compacted %d values from %d to %d bytes, encrypted: %v", count, srcSize, dstSize, s.keyring.Enabled())
+	return nil
+}
+
+// keyChanged reports whether the database has values that are not encrypted with the current key,
+// or encrypted values if encryption is disabled.
+func (s *Service) keyChanged() (bool, error) {
+	if _, err := os.Stat(s.dbpath); os.IsNotExist(err) {
+		return false, nil
+	}
+	db, err := bolt.Open(s.dbpath, 0600, &bolt.Options{ReadOnly: true})
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+	err = db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			return b.ForEach(func(k, v []byte) error {
+				// Values of nested buckets are not written by the stores.
+				if v != nil && !s.keyring.IsCurrent(v) {
+					return errKeyChanged
+				}
+				return nil
+			})
+		})
+	})
+	if err == errKeyChanged {
+		return true, nil
+	}
+	return false, err
+}
+
+var errKeyChanged = errors.New("key changed")
+
+// copyReencrypted copies all buckets of src into dst, re-encrypting each value.
+func (s *Service) copyReencrypted(src, dst *bolt.DB) (count int, err error) {
+	err = src.View(func(stx *bolt.Tx) error {
+		return dst.Update(func(dtx *bolt.Tx) error {
+			return stx.ForEach(func(name []byte, sb *bolt.Bucket) error {
+				db, err := dtx.CreateBucket(name)
+				if err != nil {
+					return err
+				}
+				namespace := string(name)
+				return sb.ForEach(func(k, v []byte) error {
+					if v == nil {
+						// Values of nested buckets are not written by the stores, copy them unchanged.
+						return copyBucket(sb.Bucket(k), db, k)
+					}
+					value, err := s.keyring.Reencrypt(v, additionalData(namespace, string(k)))
+					if err != nil {
+						return errors.Wrapf(err, "failed to re-encrypt %s/%s", namespace, k)
+					}
+					count++
+					return db.Put(k, value)
+				})
+			})
+		})
+	})
+	return
+}
+
+// copyBucket recursively copies the bucket src into a new bucket of parent named name.
+func copyBucket(src, parent *bolt.Bucket, name []byte) error {
+	dst, err := parent.CreateBucket(name)
+	if err != nil {
+		return err
+	}
+	return src.ForEach(func(k, v []byte) error {
+		if v == nil {
+			return copyBucket(src.Bucket(k), dst, k)
+		}
+		return dst.Put(k, v)
+	})
+}
+
+func fileSize(name string) (int64, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
@@ -100,10 +100,11 @@ type KeyValue struct {
 }
 
 // Return a list of values from a list of KeyValues using an offset/limit bound and a match function.
+// If limit < 0, then no limit is enforced.
 func DoListFunc(list []*KeyValue, match func(value []byte) bool, offset, limit int) []string {
 	l := len(list)
 	upper := offset + limit
-	if upper > l {
+	if upper > l || limit < 0 {
 		upper = l
 	}
 	size := upper - offset
@@ -21,8 +21,9 @@ type createStoreCloser func() (storeCloser, error)
 // stores is a map of all storage implementations,
 // each test will be run against the stores found in this map.
 var stores = map[string]createStoreCloser{
-	"bolt": newBolt,
-	"mem":  newMemStore,
+	"bolt":           newBolt,
+	"mem":            newMemStore,
+	"encrypted-bolt": newEncryptedBolt,
 }
 
 type storeCloser interface {
@@ -56,6 +57,38 @@ func (b boltDB) Store(bucket string) storage.Interface {
 	return storage.NewBolt(b.db, bucket)
 }
 
+type encryptedBoltDB struct {
+	boltDB
+	keyring *storage.Keyring
+}
+
+func newEncryptedBolt() (storeCloser, error) {
+	b, err := newBolt()
+	if err != nil {
+		return nil, err
+	}
+	keyring, err := storage.NewKeyring(testKey(1))
+	if err != nil {
+		return nil, err
+	}
+	return encryptedBoltDB{
+		boltDB:  b.(boltDB),
+		keyring: keyring,
+	}, nil
+}
+
+func (b encryptedBoltDB) Store(bucket string) storage.Interface {
+	return storage.NewEncrypted(b.boltDB.Store(bucket), bucket, b.keyring)
+}
+
+func testKey(b byte) []byte {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = b
+	}
+	return key
+}
+
 type memStore struct {
 	stores map[string]storage.Interface
 }
@@ -10,6 +10,13 @@ type Config struct {
 	// Deprecated, only needed to find old db and migrate
 	Dir              string        `toml:"dir"`
 	SnapshotInterval toml.Duration `toml:"snapshot-interval"`
+
+	// Default resource limits of tasks, each task may override them.
+	// A zero value means the limit is not enforced.
+	QueueSize       int    `toml:"queue-size"`
+	QueuePolicy     string `toml:"queue-policy"`
+	MaxGroups       int    `toml:"max-groups"`
+	MaxWindowPoints int    `toml:"max-window-points"`
 }
 
 func NewConfig() Config {
@@ -20,5 +27,14 @@ func NewConfig() Config {
 }
 
 func (c Config) Validate() error {
-	return nil
+	return c.limits().Validate()
+}
+
+func (c Config) limits() TaskLimits {
+	return TaskLimits{
+		QueueSize:       c.QueueSize,
+		QueuePolicy:     c.QueuePolicy,
+		MaxGroups:       c.MaxGroups,
+		MaxWindowPoints: c.MaxWindowPoints,
+	}
 }
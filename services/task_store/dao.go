@@ -8,6 +8,7 @@ import (
 	"path"
 	"time"
 
+	"github.com/influxdata/kapacitor/edge"
 	"github.com/influxdata/kapacitor/services/storage"
 )
 
@@ -137,6 +138,57 @@ type Task struct {
 	Modified time.Time
 	// The time the task was last changed to status Enabled.
 	LastEnabled time.Time
+	// The time the task was last changed to status Disabled.
+	LastDisabled time.Time
+	// ID of the live task this task shadows, if any.
+	ShadowOf string
+	// Resource limits of the task, zero values use the configured defaults.
+	Limits TaskLimits
+}
+
+// Resource limits of a task.
+type TaskLimits struct {
+	// Size of the input queue of a stream task.
+	QueueSize int
+	// Policy applied when the input queue is full, one of block, drop-oldest or drop-newest.
+	QueuePolicy string
+	// Maximum number of groups per node.
+	MaxGroups int
+	// Maximum number of points buffered by each group of a window node.
+	MaxWindowPoints int
+}
+
+func (l TaskLimits) Validate() error {
+	if l.QueueSize < 0 {
+		return fmt.Errorf("queue size must be non negative, got %d", l.QueueSize)
+	}
+	if _, err := edge.ParseOverflowPolicy(l.QueuePolicy); err != nil {
+		return err
+	}
+	if l.MaxGroups < 0 {
+		return fmt.Errorf("max groups must be non negative, got %d", l.MaxGroups)
+	}
+	if l.MaxWindowPoints < 0 {
+		return fmt.Errorf("max window points must be non negative, got %d", l.MaxWindowPoints)
+	}
+	return nil
+}
+
+// Merge returns the limits with any zero values replaced by the values of defaults.
+func (l TaskLimits) Merge(defaults TaskLimits) TaskLimits {
+	if l.QueueSize == 0 {
+		l.QueueSize = defaults.QueueSize
+	}
+	if l.QueuePolicy == "" {
+		l.QueuePolicy = defaults.QueuePolicy
+	}
+	if l.MaxGroups == 0 {
+		l.MaxGroups = defaults.MaxGroups
+	}
+	if l.MaxWindowPoints == 0 {
+		l.MaxWindowPoints = defaults.MaxWindowPoints
+	}
+	return l
 }
 
 type rawTask Task
@@ -179,6 +231,10 @@ type DBRP struct {
 
 type Snapshot struct {
 	NodeSnapshots map[string][]byte
+	// Fingerprint of the pipeline of the task when the snapshot was taken.
+	Fingerprint string
+	// Time the snapshot was taken.
+	Time time.Time
 }
 
 // Key/Value store based implementation of the TaskDAO
@@ -16,8 +16,10 @@ import (
 	"github.com/boltdb/bolt"
 	"github.com/influxdata/kapacitor"
 	"github.com/influxdata/kapacitor/client/v1"
+	"github.com/influxdata/kapacitor/edge"
 	"github.com/influxdata/kapacitor/server/vars"
 	"github.com/influxdata/kapacitor/services/httpd"
+	"github.com/influxdata/kapacitor/services/namespace"
 	"github.com/influxdata/kapacitor/services/storage"
 	"github.com/influxdata/kapacitor/tick"
 	"github.com/influxdata/kapacitor/tick/ast"
@@ -40,6 +42,7 @@ type Service struct {
 	snapshots        SnapshotDAO
 	routes           []httpd.Route
 	snapshotInterval time.Duration
+	defaultLimits    TaskLimits
 	StorageService   interface {
 		Store(namespace string) storage.Interface
 		Register(name string, store storage.StoreActioner)
@@ -54,6 +57,9 @@ type Service struct {
 		Set(*kapacitor.TaskMaster)
 		Delete(*kapacitor.TaskMaster)
 	}
+	NamespaceService interface {
+		AllowedDBRP(namespace, db, rp string) error
+	}
 
 	logger *log.Logger
 }
@@ -67,6 +73,7 @@ type taskStore struct {
 func NewService(conf Config, l *log.Logger) *Service {
 	return &Service{
 		snapshotInterval: time.Duration(conf.SnapshotInterval),
+		defaultLimits:    conf.limits(),
 		logger:           l,
 		oldDBDir:         conf.Dir,
 	}
@@ -373,9 +380,32 @@ func (ts *Service) Load(id string) (*kapacitor.Task, error) {
 	return ts.newKapacitorTask(t)
 }
 
+// NamespaceResources returns the tasks and templates within the namespace.
+func (ts *Service) NamespaceResources(ns string) ([]string, error) {
+	pattern := namespace.Qualify(ns, "*")
+	tasks, err := ts.tasks.List(pattern, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	templates, err := ts.templates.List(pattern, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]string, 0, len(tasks)+len(templates))
+	for _, t := range tasks {
+		resources = append(resources, "task "+namespace.Unqualify(ns, t.ID))
+	}
+	for _, t := range templates {
+		resources = append(resources, "template "+namespace.Unqualify(ns, t.ID))
+	}
+	return resources, nil
+}
+
 func (ts *Service) SaveSnapshot(id string, snapshot *kapacitor.TaskSnapshot) error {
 	s := &Snapshot{
 		NodeSnapshots: snapshot.NodeSnapshots,
+		Fingerprint:   snapshot.Fingerprint,
+		Time:          snapshot.Time,
 	}
 	return ts.snapshots.Put(id, s)
 }
@@ -396,6 +426,8 @@ func (ts *Service) LoadSnapshot(id string) (*kapacitor.TaskSnapshot, error) {
 	}
 	s := &kapacitor.TaskSnapshot{
 		NodeSnapshots: snapshot.NodeSnapshots,
+		Fingerprint:   snapshot.Fingerprint,
+		Time:          snapshot.Time,
 	}
 	return s, nil
 }
@@ -413,9 +445,9 @@ type TaskInfo struct {
 }
 
 func (ts *Service) handleTask(w http.ResponseWriter, r *http.Request) {
-	id, err := ts.taskIDFromPath(r.URL.Path)
+	id, err := ts.taskIDFromPath(r)
 	if err != nil {
-		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		httpd.HttpError(w, err.Error(), true, pathErrorCode(err))
 		return
 	}
 
@@ -459,7 +491,7 @@ func (ts *Service) handleTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	t, err := ts.convertTask(raw, scriptFormat, dotView, tm)
+	t, err := ts.convertTask(httpd.RequestNamespace(r), raw, scriptFormat, dotView, tm)
 	if err != nil {
 		httpd.HttpError(w, fmt.Sprintf("invalid task stored in db: %s", err.Error()), true, http.StatusInternalServerError)
 		return
@@ -483,20 +515,55 @@ var allTaskFields = []string{
 	"modified",
 	"last-enabled",
 	"vars",
+	"shadow-of",
+	"limits",
 }
 
 const tasksBasePathAnchored = httpd.BasePath + tasksPathAnchored
 
-func (ts *Service) taskIDFromPath(path string) (string, error) {
-	if len(path) <= len(tasksBasePathAnchored) {
+// notFoundError is returned for IDs on the path that cannot exist,
+// i.e. qualified IDs outside a namespace.
+type notFoundError struct {
+	error
+}
+
+// pathErrorCode returns the status code of an error returned by taskIDFromPath or templateIDFromPath.
+func pathErrorCode(err error) int {
+	if _, ok := err.(notFoundError); ok {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// taskIDFromPath returns the qualified ID of the task on the path of the request.
+func (ts *Service) taskIDFromPath(r *http.Request) (string, error) {
+	if len(r.URL.Path) <= len(tasksBasePathAnchored) {
 		return "", errors.New("must specify task id on path")
 	}
-	id := path[len(tasksBasePathAnchored):]
-	return id, nil
+	id := r.URL.Path[len(tasksBasePathAnchored):]
+	qualified, ok := namespace.Resolve(httpd.RequestNamespace(r), id)
+	if !ok {
+		return "", notFoundError{ErrNoTaskExists}
+	}
+	return qualified, nil
+}
+
+func (ts *Service) taskLink(ns, id string) client.Link {
+	return client.Link{Relation: client.Self, Href: httpd.NamespacedPath(ns, path.Join(httpd.BasePath, tasksPath, namespace.Unqualify(ns, id)))}
 }
 
-func (ts *Service) taskLink(id string) client.Link {
-	return client.Link{Relation: client.Self, Href: path.Join(httpd.BasePath, tasksPath, id)}
+// checkDBRPs returns an error if the namespace of the task does not allow one of its DBRPs.
+func (ts *Service) checkDBRPs(task Task) error {
+	if ts.NamespaceService == nil {
+		return nil
+	}
+	ns, _ := namespace.Split(task.ID)
+	for _, dbrp := range task.DBRPs {
+		if err := ts.NamespaceService.AllowedDBRP(ns, dbrp.Database, dbrp.RetentionPolicy); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (ts *Service) handleListTasks(w http.ResponseWriter, r *http.Request) {
@@ -553,7 +620,29 @@ func (ts *Service) handleListTasks(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	rawTasks, err := ts.tasks.List(pattern, int(offset), int(limit))
+	// Only list the tasks of the namespace.
+	ns := httpd.RequestNamespace(r)
+	if ns != "" {
+		if pattern == "" {
+			pattern = "*"
+		}
+		pattern = namespace.Qualify(ns, pattern)
+	}
+	var rawTasks []Task
+	if ns != "" {
+		rawTasks, err = ts.tasks.List(pattern, int(offset), int(limit))
+	} else {
+		// The tasks within namespaces are not listed outside of them.
+		rawTasks, err = ts.tasks.List(pattern, 0, -1)
+		unqualified := rawTasks[:0]
+		for _, task := range rawTasks {
+			if !namespace.Qualified(task.ID) {
+				unqualified = append(unqualified, task)
+			}
+		}
+		lower, upper := pageBounds(len(unqualified), int(offset), int(limit))
+		rawTasks = unqualified[lower:upper]
+	}
 	if err != nil {
 		httpd.HttpError(w, fmt.Sprintf("failed to list tasks with pattern %q: %s", pattern, err), true, http.StatusBadRequest)
 		return
@@ -569,9 +658,9 @@ func (ts *Service) handleListTasks(w http.ResponseWriter, r *http.Request) {
 			var value interface{}
 			switch field {
 			case "id":
-				value = task.ID
+				value = namespace.Unqualify(ns, task.ID)
 			case "link":
-				value = ts.taskLink(task.ID)
+				value = ts.taskLink(ns, task.ID)
 			case "type":
 				switch task.Type {
 				case StreamTask:
@@ -619,11 +708,17 @@ func (ts *Service) handleListTasks(w http.ResponseWriter, r *http.Request) {
 						value = client.ExecutionStats{
 							TaskStats: s.TaskStats,
 							NodeStats: s.NodeStats,
+							TopGroups: convertTopGroups(s.TopGroups),
 						}
 					}
 				}
 			case "error":
 				value = task.Error
+				if task.Error == "" && executing {
+					if err := tm.TaskLimitError(task.ID); err != nil {
+						value = err.Error()
+					}
+				}
 			case "status":
 				switch task.Status {
 				case Disabled:
@@ -637,6 +732,10 @@ func (ts *Service) handleListTasks(w http.ResponseWriter, r *http.Request) {
 				value = task.Modified
 			case "last-enabled":
 				value = task.LastEnabled
+			case "shadow-of":
+				value = namespace.Unqualify(ns, task.ShadowOf)
+			case "limits":
+				value = convertToClientLimits(task.Limits)
 			case "vars":
 				vars, err := ts.convertToClientVars(task.Vars)
 				if err != nil {
@@ -659,6 +758,22 @@ func (ts *Service) handleListTasks(w http.ResponseWriter, r *http.Request) {
 	w.Write(httpd.MarshalJSON(response{tasks}, true))
 }
 
+// pageBounds returns the bounds of the page of n results starting at the offset with at most limit results.
+// If limit < 0, then no limit is enforced.
+func pageBounds(n, offset, limit int) (int, int) {
+	switch {
+	case offset < 0:
+		offset = 0
+	case offset > n:
+		offset = n
+	}
+	upper := offset + limit
+	if upper > n || limit < 0 {
+		upper = n
+	}
+	return offset, upper
+}
+
 var validTaskID = regexp.MustCompile(`^[-\._\p{L}0-9]+$`)
 
 func (ts *Service) handleCreateTask(w http.ResponseWriter, r *http.Request) {
@@ -677,6 +792,26 @@ func (ts *Service) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Qualify the IDs with the namespace of the request
+	ns := httpd.RequestNamespace(r)
+	task.ID = namespace.Qualify(ns, task.ID)
+	if task.TemplateID != "" {
+		id, ok := namespace.Resolve(ns, task.TemplateID)
+		if !ok {
+			httpd.HttpError(w, fmt.Sprintf("unknown template %s: err: %s", task.TemplateID, ErrNoTemplateExists), true, http.StatusBadRequest)
+			return
+		}
+		task.TemplateID = id
+	}
+	if task.ShadowOf != "" {
+		id, ok := namespace.Resolve(ns, task.ShadowOf)
+		if !ok {
+			httpd.HttpError(w, fmt.Sprintf("unknown task %s to shadow: err: %s", task.ShadowOf, ErrNoTaskExists), true, http.StatusBadRequest)
+			return
+		}
+		task.ShadowOf = id
+	}
+
 	newTask := Task{
 		ID: task.ID,
 	}
@@ -741,6 +876,10 @@ func (ts *Service) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 		httpd.HttpError(w, fmt.Sprintf("must provide at least one database and retention policy."), true, http.StatusBadRequest)
 		return
 	}
+	if err := ts.checkDBRPs(newTask); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusForbidden)
+		return
+	}
 
 	// Set status
 	switch task.Status {
@@ -759,6 +898,28 @@ func (ts *Service) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Set shadow
+	if task.ShadowOf == newTask.ID {
+		httpd.HttpError(w, "task cannot shadow itself", true, http.StatusBadRequest)
+		return
+	}
+	if task.ShadowOf != "" {
+		if _, err := ts.tasks.Get(task.ShadowOf); err != nil {
+			httpd.HttpError(w, fmt.Sprintf("unknown task %s to shadow: err: %s", task.ShadowOf, err), true, http.StatusBadRequest)
+			return
+		}
+	}
+	newTask.ShadowOf = task.ShadowOf
+
+	// Set limits
+	if task.Limits != nil {
+		newTask.Limits = convertToServiceLimits(*task.Limits)
+		if err := newTask.Limits.Validate(); err != nil {
+			httpd.HttpError(w, "invalid limits: "+err.Error(), true, http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Validate task
 	_, err = ts.newKapacitorTask(newTask)
 	if err != nil {
@@ -794,7 +955,7 @@ func (ts *Service) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Return task info
-	t, err := ts.convertTask(newTask, "formatted", "attributes", ts.TaskMasterLookup.Main())
+	t, err := ts.convertTask(ns, newTask, "formatted", "attributes", ts.TaskMasterLookup.Main())
 	if err != nil {
 		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
 		return
@@ -804,9 +965,9 @@ func (ts *Service) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 }
 
 func (ts *Service) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
-	id, err := ts.taskIDFromPath(r.URL.Path)
+	id, err := ts.taskIDFromPath(r)
 	if err != nil {
-		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		httpd.HttpError(w, err.Error(), true, pathErrorCode(err))
 		return
 	}
 	task := client.UpdateTaskOptions{}
@@ -817,6 +978,32 @@ func (ts *Service) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Qualify the IDs with the namespace of the request
+	ns := httpd.RequestNamespace(r)
+	if task.ID != "" {
+		if !validTaskID.MatchString(task.ID) {
+			httpd.HttpError(w, fmt.Sprintf("task ID must contain only letters, numbers, '-', '.' and '_'. %q", task.ID), true, http.StatusBadRequest)
+			return
+		}
+		task.ID = namespace.Qualify(ns, task.ID)
+	}
+	if task.TemplateID != "" {
+		id, ok := namespace.Resolve(ns, task.TemplateID)
+		if !ok {
+			httpd.HttpError(w, fmt.Sprintf("unknown template %s: err: %s", task.TemplateID, ErrNoTemplateExists), true, http.StatusBadRequest)
+			return
+		}
+		task.TemplateID = id
+	}
+	if task.ShadowOf != "" {
+		id, ok := namespace.Resolve(ns, task.ShadowOf)
+		if !ok {
+			httpd.HttpError(w, fmt.Sprintf("unknown task %s to shadow: err: %s", task.ShadowOf, ErrNoTaskExists), true, http.StatusBadRequest)
+			return
+		}
+		task.ShadowOf = id
+	}
+
 	// Check for existing task
 	original, err := ts.tasks.Get(id)
 	if err != nil {
@@ -881,6 +1068,10 @@ func (ts *Service) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
+	if err := ts.checkDBRPs(updated); err != nil {
+		httpd.HttpError(w, err.Error(), true, http.StatusForbidden)
+		return
+	}
 
 	// Set status
 	previousStatus := updated.Status
@@ -901,6 +1092,40 @@ func (ts *Service) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Set shadow
+	switch {
+	case task.Promote && task.ShadowOf != "":
+		httpd.HttpError(w, "cannot set shadow-of and promote the task at once", true, http.StatusBadRequest)
+		return
+	case task.Promote:
+		if updated.ShadowOf == "" {
+			httpd.HttpError(w, "task is not a shadow task, cannot promote", true, http.StatusBadRequest)
+			return
+		}
+		updated.ShadowOf = ""
+	case task.ShadowOf != "":
+		if _, err := ts.tasks.Get(task.ShadowOf); err != nil {
+			httpd.HttpError(w, fmt.Sprintf("unknown task %s to shadow: err: %s", task.ShadowOf, err), true, http.StatusBadRequest)
+			return
+		}
+		updated.ShadowOf = task.ShadowOf
+	}
+	if updated.ShadowOf == updated.ID {
+		httpd.HttpError(w, "task cannot shadow itself", true, http.StatusBadRequest)
+		return
+	}
+	// A running task has to be reloaded to start or stop capturing its side effects.
+	shadowChanged := original.ShadowOf != updated.ShadowOf
+
+	// Set limits
+	if task.Limits != nil {
+		updated.Limits = convertToServiceLimits(*task.Limits)
+		if err := updated.Limits.Validate(); err != nil {
+			httpd.HttpError(w, "invalid limits: "+err.Error(), true, http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Validate task
 	_, err = ts.newKapacitorTask(updated)
 	if err != nil {
@@ -913,6 +1138,9 @@ func (ts *Service) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 	if statusChanged && updated.Status == Enabled {
 		updated.LastEnabled = now
 	}
+	if statusChanged && updated.Status == Disabled {
+		updated.LastDisabled = now
+	}
 	if original.ID != updated.ID {
 		// Task ID changed delete and re-create.
 		if err := ts.tasks.Create(updated); err != nil {
@@ -935,6 +1163,12 @@ func (ts *Service) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 			httpd.HttpError(w, fmt.Sprintf("failed to replace task definition: %s", err.Error()), true, http.StatusInternalServerError)
 			return
 		}
+		if (task.Reload || shadowChanged) && !statusChanged && updated.Status == Enabled {
+			if err := ts.reloadTask(updated); err != nil {
+				httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+				return
+			}
+		}
 	}
 
 	if statusChanged {
@@ -953,7 +1187,7 @@ func (ts *Service) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	t, err := ts.convertTask(updated, "formatted", "attributes", ts.TaskMasterLookup.Main())
+	t, err := ts.convertTask(ns, updated, "formatted", "attributes", ts.TaskMasterLookup.Main())
 	if err != nil {
 		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
 		return
@@ -962,7 +1196,7 @@ func (ts *Service) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 	w.Write(httpd.MarshalJSON(t, true))
 }
 
-func (ts *Service) convertTask(t Task, scriptFormat, dotView string, tm *kapacitor.TaskMaster) (client.Task, error) {
+func (ts *Service) convertTask(ns string, t Task, scriptFormat, dotView string, tm *kapacitor.TaskMaster) (client.Task, error) {
 	script := t.TICKscript
 	if scriptFormat == "formatted" {
 		// Format TICKscript
@@ -976,6 +1210,11 @@ func (ts *Service) convertTask(t Task, scriptFormat, dotView string, tm *kapacit
 
 	executing := tm.IsExecuting(t.ID)
 	errMsg := t.Error
+	if errMsg == "" && executing {
+		if err := tm.TaskLimitError(t.ID); err != nil {
+			errMsg = err.Error()
+		}
+	}
 	dot := ""
 	stats := client.ExecutionStats{}
 	task, err := ts.newKapacitorTask(t)
@@ -988,6 +1227,7 @@ func (ts *Service) convertTask(t Task, scriptFormat, dotView string, tm *kapacit
 			} else {
 				stats.TaskStats = s.TaskStats
 				stats.NodeStats = s.NodeStats
+				stats.TopGroups = convertTopGroups(s.TopGroups)
 			}
 		} else {
 			dot = string(task.Dot())
@@ -1030,9 +1270,9 @@ func (ts *Service) convertTask(t Task, scriptFormat, dotView string, tm *kapacit
 	}
 
 	return client.Task{
-		Link:           ts.taskLink(t.ID),
-		ID:             t.ID,
-		TemplateID:     t.TemplateID,
+		Link:           ts.taskLink(ns, t.ID),
+		ID:             namespace.Unqualify(ns, t.ID),
+		TemplateID:     namespace.Unqualify(ns, t.TemplateID),
 		Type:           typ,
 		DBRPs:          dbrps,
 		TICKscript:     script,
@@ -1044,6 +1284,8 @@ func (ts *Service) convertTask(t Task, scriptFormat, dotView string, tm *kapacit
 		Created:        t.Created,
 		Modified:       t.Modified,
 		LastEnabled:    t.LastEnabled,
+		ShadowOf:       namespace.Unqualify(ns, t.ShadowOf),
+		Limits:         convertToClientLimits(t.Limits),
 		Error:          errMsg,
 	}, nil
 }
@@ -1298,9 +1540,9 @@ func (ts *Service) convertToTickVarsFromService(svars map[string]Var) (map[strin
 }
 
 func (ts *Service) handleDeleteTask(w http.ResponseWriter, r *http.Request) {
-	id, err := ts.taskIDFromPath(r.URL.Path)
+	id, err := ts.taskIDFromPath(r)
 	if err != nil {
-		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		httpd.HttpError(w, err.Error(), true, pathErrorCode(err))
 		return
 	}
 
@@ -1334,10 +1576,40 @@ func (ts *Service) deleteTask(id string) error {
 		vars.NumEnabledTasksVar.Add(-1)
 		ts.TaskMasterLookup.Main().DeleteTask(id)
 	}
-	return ts.tasks.Delete(id)
+	if err := ts.tasks.Delete(id); err != nil {
+		return err
+	}
+
+	// Delete the shadows of the task, they cannot run without it.
+	// The task is deleted first so that tasks shadowing each other are deleted once.
+	shadows, err := ts.shadowsOf(id)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list shadows of task %s", id)
+	}
+	for _, shadow := range shadows {
+		if err := ts.deleteTask(shadow); err != nil {
+			return errors.Wrapf(err, "failed to delete shadow task %s", shadow)
+		}
+	}
+	return nil
 }
 
-func (ts *Service) convertTemplate(t Template, scriptFormat string) (client.Template, error) {
+// shadowsOf returns the IDs of the tasks shadowing the task.
+func (ts *Service) shadowsOf(id string) ([]string, error) {
+	tasks, err := ts.tasks.List("*", 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	var shadows []string
+	for _, t := range tasks {
+		if t.ShadowOf == id {
+			shadows = append(shadows, t.ID)
+		}
+	}
+	return shadows, nil
+}
+
+func (ts *Service) convertTemplate(ns string, t Template, scriptFormat string) (client.Template, error) {
 	script := t.TICKscript
 	if scriptFormat == "formatted" {
 		// Format TICKscript
@@ -1371,8 +1643,8 @@ func (ts *Service) convertTemplate(t Template, scriptFormat string) (client.Temp
 	}
 
 	return client.Template{
-		Link:       ts.templateLink(t.ID),
-		ID:         t.ID,
+		Link:       ts.templateLink(ns, t.ID),
+		ID:         namespace.Unqualify(ns, t.ID),
 		Type:       typ,
 		TICKscript: script,
 		Dot:        string(task.Dot()),
@@ -1384,9 +1656,9 @@ func (ts *Service) convertTemplate(t Template, scriptFormat string) (client.Temp
 }
 
 func (ts *Service) handleTemplate(w http.ResponseWriter, r *http.Request) {
-	id, err := ts.templateIDFromPath(r.URL.Path)
+	id, err := ts.templateIDFromPath(r)
 	if err != nil {
-		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		httpd.HttpError(w, err.Error(), true, pathErrorCode(err))
 		return
 	}
 
@@ -1406,7 +1678,7 @@ func (ts *Service) handleTemplate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	t, err := ts.convertTemplate(raw, scriptFormat)
+	t, err := ts.convertTemplate(httpd.RequestNamespace(r), raw, scriptFormat)
 	if err != nil {
 		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
 		return
@@ -1428,16 +1700,21 @@ var allTemplateFields = []string{
 
 const templatesBasePathAnchored = httpd.BasePath + templatesPathAnchored
 
-func (ts *Service) templateIDFromPath(path string) (string, error) {
-	if len(path) <= len(templatesBasePathAnchored) {
+// templateIDFromPath returns the qualified ID of the template on the path of the request.
+func (ts *Service) templateIDFromPath(r *http.Request) (string, error) {
+	if len(r.URL.Path) <= len(templatesBasePathAnchored) {
 		return "", errors.New("must specify template id on path")
 	}
-	id := path[len(templatesBasePathAnchored):]
-	return id, nil
+	id := r.URL.Path[len(templatesBasePathAnchored):]
+	qualified, ok := namespace.Resolve(httpd.RequestNamespace(r), id)
+	if !ok {
+		return "", notFoundError{ErrNoTemplateExists}
+	}
+	return qualified, nil
 }
 
-func (ts *Service) templateLink(id string) client.Link {
-	return client.Link{Relation: client.Self, Href: path.Join(httpd.BasePath, templatesPath, id)}
+func (ts *Service) templateLink(ns, id string) client.Link {
+	return client.Link{Relation: client.Self, Href: httpd.NamespacedPath(ns, path.Join(httpd.BasePath, templatesPath, namespace.Unqualify(ns, id)))}
 }
 
 func (ts *Service) handleListTemplates(w http.ResponseWriter, r *http.Request) {
@@ -1483,7 +1760,29 @@ func (ts *Service) handleListTemplates(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	rawTemplates, err := ts.templates.List(pattern, int(offset), int(limit))
+	// Only list the templates of the namespace.
+	ns := httpd.RequestNamespace(r)
+	if ns != "" {
+		if pattern == "" {
+			pattern = "*"
+		}
+		pattern = namespace.Qualify(ns, pattern)
+	}
+	var rawTemplates []Template
+	if ns != "" {
+		rawTemplates, err = ts.templates.List(pattern, int(offset), int(limit))
+	} else {
+		// The templates within namespaces are not listed outside of them.
+		rawTemplates, err = ts.templates.List(pattern, 0, -1)
+		unqualified := rawTemplates[:0]
+		for _, template := range rawTemplates {
+			if !namespace.Qualified(template.ID) {
+				unqualified = append(unqualified, template)
+			}
+		}
+		lower, upper := pageBounds(len(unqualified), int(offset), int(limit))
+		rawTemplates = unqualified[lower:upper]
+	}
 	if err != nil {
 		httpd.HttpError(w, fmt.Sprintf("failed to list templates with pattern %q: %s", pattern, err), true, http.StatusBadRequest)
 		return
@@ -1500,9 +1799,9 @@ func (ts *Service) handleListTemplates(w http.ResponseWriter, r *http.Request) {
 			var value interface{}
 			switch field {
 			case "id":
-				value = template.ID
+				value = namespace.Unqualify(ns, template.ID)
 			case "link":
-				value = ts.templateLink(template.ID)
+				value = ts.templateLink(ns, template.ID)
 			case "type":
 				switch template.Type {
 				case StreamTask:
@@ -1567,6 +1866,8 @@ func (ts *Service) handleCreateTemplate(w http.ResponseWriter, r *http.Request)
 		httpd.HttpError(w, fmt.Sprintf("template ID must contain only letters, numbers, '-', '.' and '_'. %q", template.ID), true, http.StatusBadRequest)
 		return
 	}
+	ns := httpd.RequestNamespace(r)
+	template.ID = namespace.Qualify(ns, template.ID)
 
 	newTemplate := Template{
 		ID: template.ID,
@@ -1616,7 +1917,7 @@ func (ts *Service) handleCreateTemplate(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Return template definition
-	t, err := ts.convertTemplate(newTemplate, "formatted")
+	t, err := ts.convertTemplate(ns, newTemplate, "formatted")
 	if err != nil {
 		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
 		return
@@ -1626,9 +1927,9 @@ func (ts *Service) handleCreateTemplate(w http.ResponseWriter, r *http.Request)
 }
 
 func (ts *Service) handleUpdateTemplate(w http.ResponseWriter, r *http.Request) {
-	id, err := ts.templateIDFromPath(r.URL.Path)
+	id, err := ts.templateIDFromPath(r)
 	if err != nil {
-		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		httpd.HttpError(w, err.Error(), true, pathErrorCode(err))
 		return
 	}
 	template := client.UpdateTemplateOptions{}
@@ -1648,8 +1949,13 @@ func (ts *Service) handleUpdateTemplate(w http.ResponseWriter, r *http.Request)
 	updated := original
 
 	// Set ID
+	ns := httpd.RequestNamespace(r)
 	if template.ID != "" {
-		updated.ID = template.ID
+		if !validTemplateID.MatchString(template.ID) {
+			httpd.HttpError(w, fmt.Sprintf("template ID must contain only letters, numbers, '-', '.' and '_'. %q", template.ID), true, http.StatusBadRequest)
+			return
+		}
+		updated.ID = namespace.Qualify(ns, template.ID)
 	}
 
 	// Set template type
@@ -1706,7 +2012,7 @@ func (ts *Service) handleUpdateTemplate(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Return template definition
-	t, err := ts.convertTemplate(updated, "formatted")
+	t, err := ts.convertTemplate(ns, updated, "formatted")
 	if err != nil {
 		httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
 		return
@@ -1743,8 +2049,7 @@ func (ts *Service) updateAllAssociatedTasks(old, new Template, taskIds []string)
 				ts.logger.Printf("E! error rolling back associated task %s: %s", taskId, err)
 			}
 			if task.Status == Enabled {
-				ts.stopTask(taskId)
-				err := ts.startTask(task)
+				err := ts.reloadTask(task)
 				if err != nil {
 					ts.logger.Printf("E! error rolling back associated task %s: %s", taskId, err)
 				}
@@ -1774,8 +2079,7 @@ func (ts *Service) updateAllAssociatedTasks(old, new Template, taskIds []string)
 			return fmt.Errorf("error updating associated task %s: %s", taskId, err)
 		}
 		if task.Status == Enabled {
-			ts.stopTask(taskId)
-			err := ts.startTask(task)
+			err := ts.reloadTask(task)
 			if err != nil {
 				return fmt.Errorf("error reloading associated task %s: %s", taskId, err)
 			}
@@ -1785,9 +2089,9 @@ func (ts *Service) updateAllAssociatedTasks(old, new Template, taskIds []string)
 }
 
 func (ts *Service) handleDeleteTemplate(w http.ResponseWriter, r *http.Request) {
-	id, err := ts.templateIDFromPath(r.URL.Path)
+	id, err := ts.templateIDFromPath(r)
 	if err != nil {
-		httpd.HttpError(w, err.Error(), true, http.StatusBadRequest)
+		httpd.HttpError(w, err.Error(), true, pathErrorCode(err))
 		return
 	}
 	err = ts.templates.Delete(id)
@@ -1817,13 +2121,71 @@ func (ts *Service) newKapacitorTask(task Task) (*kapacitor.Task, error) {
 	if err != nil {
 		return nil, err
 	}
-	return ts.TaskMasterLookup.Main().NewTask(task.ID,
+	t, err := ts.TaskMasterLookup.Main().NewTask(task.ID,
 		task.TICKscript,
 		tt,
 		dbrps,
 		ts.snapshotInterval,
 		vars,
 	)
+	if err != nil {
+		return nil, err
+	}
+	t.ShadowOf = task.ShadowOf
+	t.Stopped = task.Created
+	if task.LastDisabled.After(t.Stopped) {
+		t.Stopped = task.LastDisabled
+	}
+
+	limits := task.Limits.Merge(ts.defaultLimits)
+	policy, err := edge.ParseOverflowPolicy(limits.QueuePolicy)
+	if err != nil {
+		return nil, err
+	}
+	t.Limits = kapacitor.TaskLimits{
+		QueueSize:       limits.QueueSize,
+		QueuePolicy:     policy,
+		MaxGroups:       limits.MaxGroups,
+		MaxWindowPoints: limits.MaxWindowPoints,
+	}
+	return t, nil
+}
+
+func convertToServiceLimits(l client.TaskLimits) TaskLimits {
+	return TaskLimits{
+		QueueSize:       l.QueueSize,
+		QueuePolicy:     l.QueuePolicy,
+		MaxGroups:       l.MaxGroups,
+		MaxWindowPoints: l.MaxWindowPoints,
+	}
+}
+
+func convertToClientLimits(l TaskLimits) client.TaskLimits {
+	return client.TaskLimits{
+		QueueSize:       l.QueueSize,
+		QueuePolicy:     l.QueuePolicy,
+		MaxGroups:       l.MaxGroups,
+		MaxWindowPoints: l.MaxWindowPoints,
+	}
+}
+
+func convertTopGroups(topGroups map[string][]kapacitor.GroupVolume) map[string][]client.GroupVolume {
+	if len(topGroups) == 0 {
+		return nil
+	}
+	converted := make(map[string][]client.GroupVolume, len(topGroups))
+	for node, groups := range topGroups {
+		cgroups := make([]client.GroupVolume, len(groups))
+		for i, g := range groups {
+			cgroups[i] = client.GroupVolume{
+				Group:  string(g.Group),
+				Tags:   g.Tags,
+				Points: g.Points,
+			}
+		}
+		converted[node] = cgroups
+	}
+	return converted
 }
 
 func (ts *Service) templateTask(template Template) (*kapacitor.Template, error) {
@@ -1845,6 +2207,15 @@ func (ts *Service) templateTask(template Template) (*kapacitor.Template, error)
 }
 
 func (ts *Service) startTask(task Task) error {
+	return ts.runTask(task, ts.TaskMasterLookup.Main().StartTask)
+}
+
+// reloadTask replaces the executing task keeping the state of its unchanged nodes.
+func (ts *Service) reloadTask(task Task) error {
+	return ts.runTask(task, ts.TaskMasterLookup.Main().ReloadTask)
+}
+
+func (ts *Service) runTask(task Task, start func(*kapacitor.Task) (*kapacitor.ExecutingTask, error)) error {
 	t, err := ts.newKapacitorTask(task)
 	if err != nil {
 		return err
@@ -1854,7 +2225,7 @@ func (ts *Service) startTask(task Task) error {
 
 	tm := ts.TaskMasterLookup.Main()
 	// Start the task
-	et, err := tm.StartTask(t)
+	et, err := start(t)
 	if err != nil {
 		ts.saveLastError(t.ID, err.Error())
 		return err
@@ -0,0 +1,158 @@
+package kapacitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/influxdata/kapacitor/services/httpd"
+)
+
+// Maximum number of side effects retained per shadow task.
+const shadowBufferSize = 1000
+
+// The output name and endpoint path of the shadow inspection buffer.
+const shadowOutputName = "shadow"
+
+// Kinds of side effects captured by shadow tasks.
+const (
+	ShadowAlert         = "alert"
+	ShadowInfluxDBWrite = "influxdb_write"
+	ShadowHTTPPost      = "http_post"
+	ShadowAutoscale     = "autoscale"
+	ShadowLoopback      = "loopback"
+)
+
+// A side effect of a shadow task that was captured instead of being executed.
+type ShadowEffect struct {
+	Node string      `json:"node"`
+	Kind string      `json:"kind"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// The inspection report of a shadow task.
+type ShadowReport struct {
+	TaskID   string `json:"task"`
+	ShadowOf string `json:"shadow-of"`
+	// Total number of captured effects, including those no longer retained.
+	Captured int64          `json:"captured"`
+	Effects  []ShadowEffect `json:"effects"`
+	// Stats of the nodes of the shadow task,
+	// paired with the stats of the node with the same name in the live task if it is executing.
+	NodeStats map[string]ShadowNodeStats `json:"node-stats"`
+}
+
+type ShadowNodeStats struct {
+	Shadow map[string]interface{} `json:"shadow"`
+	Live   map[string]interface{} `json:"live,omitempty"`
+}
+
+// shadowBuffer is a bounded ring buffer of captured side effects.
+// It is exposed over the HTTP API as an output of the task.
+type shadowBuffer struct {
+	et *ExecutingTask
+
+	mu       sync.RWMutex
+	effects  []ShadowEffect
+	next     int
+	captured int64
+
+	endpoint string
+	routes   []httpd.Route
+}
+
+func newShadowBuffer(et *ExecutingTask, size int) *shadowBuffer {
+	return &shadowBuffer{
+		et:      et,
+		effects: make([]ShadowEffect, 0, size),
+	}
+}
+
+func (b *shadowBuffer) Endpoint() string {
+	return b.endpoint
+}
+
+func (b *shadowBuffer) record(node, kind string, t time.Time, data interface{}) {
+	e := ShadowEffect{
+		Node: node,
+		Kind: kind,
+		Time: t,
+		Data: data,
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.captured++
+	if len(b.effects) < cap(b.effects) {
+		b.effects = append(b.effects, e)
+		return
+	}
+	b.effects[b.next] = e
+	b.next = (b.next + 1) % len(b.effects)
+}
+
+// Effects returns the retained effects, oldest first, and the total number of captured effects.
+func (b *shadowBuffer) Effects() ([]ShadowEffect, int64) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	effects := make([]ShadowEffect, 0, len(b.effects))
+	effects = append(effects, b.effects[b.next:]...)
+	effects = append(effects, b.effects[:b.next]...)
+	return effects, b.captured
+}
+
+func (b *shadowBuffer) report() (ShadowReport, error) {
+	effects, captured := b.Effects()
+	r := ShadowReport{
+		TaskID:    b.et.Task.ID,
+		ShadowOf:  b.et.Task.ShadowOf,
+		Captured:  captured,
+		Effects:   effects,
+		NodeStats: make(map[string]ShadowNodeStats),
+	}
+	shadow, err := b.et.ExecutionStats()
+	if err != nil {
+		return r, err
+	}
+	live, err := b.et.tm.ExecutionStats(b.et.Task.ShadowOf)
+	if err != nil {
+		return r, err
+	}
+	for name, stats := range shadow.NodeStats {
+		r.NodeStats[name] = ShadowNodeStats{
+			Shadow: stats,
+			Live:   live.NodeStats[name],
+		}
+	}
+	return r, nil
+}
+
+func (b *shadowBuffer) open() error {
+	hndl := func(w http.ResponseWriter, req *http.Request) {
+		r, err := b.report()
+		if err != nil {
+			httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+			return
+		}
+		if data, err := json.Marshal(r); err != nil {
+			httpd.HttpError(w, err.Error(), true, http.StatusInternalServerError)
+		} else {
+			_, _ = w.Write(data)
+		}
+	}
+
+	p := path.Join("/tasks/", b.et.Task.ID, shadowOutputName)
+	b.routes = []httpd.Route{{
+		Method:      "GET",
+		Pattern:     p,
+		HandlerFunc: hndl,
+	}}
+	b.endpoint = b.et.tm.HTTPDService.URL() + p
+	return b.et.tm.HTTPDService.AddRoutes(b.routes)
+}
+
+func (b *shadowBuffer) close() {
+	b.et.tm.HTTPDService.DelRoutes(b.routes)
+}
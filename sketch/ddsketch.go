@@ -0,0 +1,370 @@
+// Package sketch provides mergeable summaries of streams of values.
+package sketch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	// DefaultRelativeAccuracy is the relative accuracy of the quantiles of a DDSketch unless specified otherwise.
+	DefaultRelativeAccuracy = 0.01
+	// DefaultMaxBins is the maximum number of bins per sign of a DDSketch unless specified otherwise.
+	DefaultMaxBins = 2048
+
+	ddSketchVersion byte = 1
+
+	// Values closer to zero than this are counted as zero.
+	minIndexableValue = 1e-300
+)
+
+// DDSketch is a quantile sketch with relative error guarantees.
+// A value v is reported as a value within v*(1 +/- relative accuracy).
+//
+// Values are counted in logarithmically sized bins, the number of bins is bounded.
+// When the bound is reached the bins of the values closest to zero are collapsed,
+// which keeps the accuracy of the higher quantiles.
+//
+// See https://arxiv.org/abs/1908.10693.
+type DDSketch struct {
+	relativeAccuracy float64
+	gamma            float64
+	logGamma         float64
+	maxBins          int
+
+	positive  store
+	negative  store
+	zeroCount float64
+
+	count float64
+	sum   float64
+	min   float64
+	max   float64
+}
+
+// NewDDSketch creates a sketch with the given relative accuracy, which must be between 0 and 1,
+// and the maximum number of bins per sign.
+func NewDDSketch(relativeAccuracy float64, maxBins int) (*DDSketch, error) {
+	if relativeAccuracy <= 0 || relativeAccuracy >= 1 {
+		return nil, fmt.Errorf("relative accuracy must be between 0 and 1, got %v", relativeAccuracy)
+	}
+	if maxBins <= 0 {
+		return nil, fmt.Errorf("max bins must be positive, got %d", maxBins)
+	}
+	gamma := (1 + relativeAccuracy) / (1 - relativeAccuracy)
+	return &DDSketch{
+		relativeAccuracy: relativeAccuracy,
+		gamma:            gamma,
+		logGamma:         math.Log(gamma),
+		maxBins:          maxBins,
+		positive:         store{maxBins: maxBins},
+		negative:         store{maxBins: maxBins},
+		min:              math.Inf(1),
+		max:              math.Inf(-1),
+	}, nil
+}
+
+// RelativeAccuracy returns the relative accuracy of the sketch.
+func (s *DDSketch) RelativeAccuracy() float64 {
+	return s.relativeAccuracy
+}
+
+// Add a value to the sketch.
+func (s *DDSketch) Add(v float64) {
+	s.AddWithCount(v, 1)
+}
+
+// AddWithCount adds a value with the given weight to the sketch.
+func (s *DDSketch) AddWithCount(v, count float64) {
+	if count <= 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return
+	}
+	switch {
+	case v > minIndexableValue:
+		s.positive.add(s.index(v), count)
+	case v < -minIndexableValue:
+		s.negative.add(s.index(-v), count)
+	default:
+		s.zeroCount += count
+	}
+	s.count += count
+	s.sum += v * count
+	if v < s.min {
+		s.min = v
+	}
+	if v > s.max {
+		s.max = v
+	}
+}
+
+// Count returns the total weight of the values added to the sketch.
+func (s *DDSketch) Count() float64 {
+	return s.count
+}
+
+// Sum returns the exact sum of the values added to the sketch.
+func (s *DDSketch) Sum() float64 {
+	return s.sum
+}
+
+// Min returns the exact minimum of the values added to the sketch, or NaN if the sketch is empty.
+func (s *DDSketch) Min() float64 {
+	if s.count == 0 {
+		return math.NaN()
+	}
+	return s.min
+}
+
+// Max returns the exact maximum of the values added to the sketch, or NaN if the sketch is empty.
+func (s *DDSketch) Max() float64 {
+	if s.count == 0 {
+		return math.NaN()
+	}
+	return s.max
+}
+
+// Quantile returns the approximate value at the quantile q, which must be between 0 and 1.
+// Returns NaN if the sketch is empty.
+func (s *DDSketch) Quantile(q float64) float64 {
+	if s.count == 0 || q < 0 || q > 1 {
+		return math.NaN()
+	}
+	if q == 0 {
+		return s.min
+	}
+	if q == 1 {
+		return s.max
+	}
+	rank := q * (s.count - 1)
+	var v float64
+	switch {
+	case rank < s.negative.count:
+		// Negative values are ordered from the largest index down.
+		idx := s.negative.reverseIndexAt(rank)
+		v = -s.value(idx)
+	case rank < s.negative.count+s.zeroCount:
+		v = 0
+	default:
+		idx := s.positive.indexAt(rank - s.negative.count - s.zeroCount)
+		v = s.value(idx)
+	}
+	// The exact extremes are known, keep the estimate within them.
+	return math.Max(s.min, math.Min(s.max, v))
+}
+
+// Merge adds the values of the other sketch to this sketch.
+// Both sketches must have the same relative accuracy.
+func (s *DDSketch) Merge(o *DDSketch) error {
+	if s.gamma != o.gamma {
+		return fmt.Errorf("cannot merge sketches with different relative accuracies %v and %v", s.relativeAccuracy, o.relativeAccuracy)
+	}
+	if o.count == 0 {
+		return nil
+	}
+	s.positive.merge(&o.positive)
+	s.negative.merge(&o.negative)
+	s.zeroCount += o.zeroCount
+	s.count += o.count
+	s.sum += o.sum
+	if o.min < s.min {
+		s.min = o.min
+	}
+	if o.max > s.max {
+		s.max = o.max
+	}
+	return nil
+}
+
+// Reset removes all values from the sketch.
+func (s *DDSketch) Reset() {
+	s.positive = store{maxBins: s.maxBins}
+	s.negative = store{maxBins: s.maxBins}
+	s.zeroCount = 0
+	s.count = 0
+	s.sum = 0
+	s.min = math.Inf(1)
+	s.max = math.Inf(-1)
+}
+
+// Copy returns an independent copy of the sketch.
+func (s *DDSketch) Copy() *DDSketch {
+	c := *s
+	c.positive = s.positive.copy()
+	c.negative = s.negative.copy()
+	return &c
+}
+
+func (s *DDSketch) index(v float64) int {
+	return int(math.Ceil(math.Log(v) / s.logGamma))
+}
+
+func (s *DDSketch) value(idx int) float64 {
+	return 2 * math.Pow(s.gamma, float64(idx)) / (s.gamma + 1)
+}
+
+// MarshalBinary encodes the sketch.
+// The encoding can be decoded with UnmarshalBinary and is stable across versions.
+func (s *DDSketch) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(ddSketchVersion)
+	w := func(v interface{}) {
+		// Writes to a bytes.Buffer do not fail.
+		_ = binary.Write(&buf, binary.LittleEndian, v)
+	}
+	w(s.relativeAccuracy)
+	w(int64(s.maxBins))
+	w(s.zeroCount)
+	w(s.count)
+	w(s.sum)
+	w(s.min)
+	w(s.max)
+	for _, st := range []*store{&s.positive, &s.negative} {
+		w(int64(st.offset))
+		w(int64(len(st.counts)))
+		w(st.counts)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a sketch encoded with MarshalBinary.
+func (s *DDSketch) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return errors.New("empty sketch data")
+	}
+	if data[0] != ddSketchVersion {
+		return fmt.Errorf("unsupported sketch version %d", data[0])
+	}
+	r := bytes.NewReader(data[1:])
+	var err error
+	read := func(v interface{}) {
+		if err == nil {
+			err = binary.Read(r, binary.LittleEndian, v)
+		}
+	}
+	var relativeAccuracy float64
+	var maxBins int64
+	read(&relativeAccuracy)
+	read(&maxBins)
+	if err != nil {
+		return err
+	}
+	d, err := NewDDSketch(relativeAccuracy, int(maxBins))
+	if err != nil {
+		return err
+	}
+	read(&d.zeroCount)
+	read(&d.count)
+	read(&d.sum)
+	read(&d.min)
+	read(&d.max)
+	for _, st := range []*store{&d.positive, &d.negative} {
+		var offset, n int64
+		read(&offset)
+		read(&n)
+		if err != nil {
+			return err
+		}
+		if n < 0 || n > int64(r.Len()/8) {
+			return fmt.Errorf("invalid sketch bin count %d", n)
+		}
+		st.offset = int(offset)
+		st.counts = make([]float64, n)
+		read(st.counts)
+		for _, c := range st.counts {
+			st.count += c
+		}
+	}
+	if err != nil {
+		return err
+	}
+	*s = *d
+	return nil
+}
+
+// store counts values in contiguous bins.
+type store struct {
+	// counts[i] is the count of the bin with index offset+i.
+	counts  []float64
+	offset  int
+	count   float64
+	maxBins int
+}
+
+func (st *store) add(idx int, count float64) {
+	if len(st.counts) == 0 {
+		st.counts = append(st.counts, 0)
+		st.offset = idx
+	}
+	if idx < st.offset {
+		if st.offset-idx+len(st.counts) > st.maxBins {
+			// Collapse the bins closest to zero into the lowest bin.
+			idx = st.offset
+		} else {
+			grown := make([]float64, st.offset-idx+len(st.counts))
+			copy(grown[st.offset-idx:], st.counts)
+			st.counts = grown
+			st.offset = idx
+		}
+	} else if last := st.offset + len(st.counts) - 1; idx > last {
+		for i := last; i < idx; i++ {
+			st.counts = append(st.counts, 0)
+		}
+		if len(st.counts) > st.maxBins {
+			st.collapse(len(st.counts) - st.maxBins)
+		}
+	}
+	st.counts[idx-st.offset] += count
+	st.count += count
+}
+
+// collapse merges the n lowest bins into the bin above them.
+func (st *store) collapse(n int) {
+	var collapsed float64
+	for _, c := range st.counts[:n] {
+		collapsed += c
+	}
+	st.counts = append(st.counts[:0:0], st.counts[n:]...)
+	st.counts[0] += collapsed
+	st.offset += n
+}
+
+func (st *store) merge(o *store) {
+	for i, c := range o.counts {
+		if c != 0 {
+			st.add(o.offset+i, c)
+		}
+	}
+}
+
+func (st *store) copy() store {
+	c := *st
+	c.counts = append([]float64(nil), st.counts...)
+	return c
+}
+
+// indexAt returns the index of the bin containing the value at the rank, counting from the lowest bin.
+func (st *store) indexAt(rank float64) int {
+	var n float64
+	for i, c := range st.counts {
+		n += c
+		if n > rank {
+			return st.offset + i
+		}
+	}
+	return st.offset + len(st.counts) - 1
+}
+
+// reverseIndexAt returns the index of the bin containing the value at the rank, counting from the highest bin.
+func (st *store) reverseIndexAt(rank float64) int {
+	var n float64
+	for i := len(st.counts) - 1; i >= 0; i-- {
+		n += st.counts[i]
+		if n > rank {
+			return st.offset + i
+		}
+	}
+	return st.offset
+}
@@ -0,0 +1,120 @@
+package sketch_test
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/influxdata/kapacitor/sketch"
+)
+
+func newSketch(t *testing.T) *sketch.DDSketch {
+	s, err := sketch.NewDDSketch(sketch.DefaultRelativeAccuracy, sketch.DefaultMaxBins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func exactQuantile(values []float64, q float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return sorted[int(q*float64(len(sorted)-1))]
+}
+
+func checkQuantiles(t *testing.T, s *sketch.DDSketch, values []float64) {
+	for _, q := range []float64{0, 0.1, 0.5, 0.9, 0.99, 0.999, 1} {
+		exp := exactQuantile(values, q)
+		got := s.Quantile(q)
+		if math.Abs(got-exp) > math.Abs(exp)*sketch.DefaultRelativeAccuracy+1e-9 {
+			t.Errorf("unexpected quantile %v: got %v exp %v", q, got, exp)
+		}
+	}
+}
+
+func TestDDSketch_Quantile(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	s := newSketch(t)
+	values := make([]float64, 10000)
+	for i := range values {
+		values[i] = r.NormFloat64()*100 + 20
+		s.Add(values[i])
+	}
+	if got, exp := s.Count(), float64(len(values)); got != exp {
+		t.Errorf("unexpected count got %v exp %v", got, exp)
+	}
+	checkQuantiles(t, s, values)
+}
+
+func TestDDSketch_Merge(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	a, b := newSketch(t), newSketch(t)
+	var values []float64
+	for i := 0; i < 5000; i++ {
+		v := r.ExpFloat64() * 1000
+		values = append(values, v)
+		if i%2 == 0 {
+			a.Add(v)
+		} else {
+			b.Add(v)
+		}
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	checkQuantiles(t, a, values)
+
+	c, err := sketch.NewDDSketch(0.05, sketch.DefaultMaxBins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Merge(c); err == nil {
+		t.Error("expected error merging sketches with different accuracies")
+	}
+}
+
+func TestDDSketch_Binary(t *testing.T) {
+	s := newSketch(t)
+	var values []float64
+	for i := -100; i <= 1000; i++ {
+		values = append(values, float64(i))
+		s.Add(float64(i))
+	}
+	data, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d sketch.DDSketch
+	if err := d.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := d.Count(), s.Count(); got != exp {
+		t.Errorf("unexpected count got %v exp %v", got, exp)
+	}
+	if got, exp := d.Sum(), s.Sum(); got != exp {
+		t.Errorf("unexpected sum got %v exp %v", got, exp)
+	}
+	checkQuantiles(t, &d, values)
+
+	if err := d.UnmarshalBinary(data[:len(data)-3]); err == nil {
+		t.Error("expected error decoding truncated data")
+	}
+}
+
+func TestDDSketch_MaxBins(t *testing.T) {
+	s, err := sketch.NewDDSketch(0.01, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values []float64
+	for i := 1; i <= 100000; i++ {
+		values = append(values, float64(i))
+		s.Add(float64(i))
+	}
+	// The bins of the lowest values are collapsed, the high quantiles stay accurate.
+	exp := exactQuantile(values, 0.99)
+	if got := s.Quantile(0.99); math.Abs(got-exp) > exp*0.01 {
+		t.Errorf("unexpected p99 got %v exp %v", got, exp)
+	}
+}
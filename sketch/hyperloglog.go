@@ -0,0 +1,174 @@
+package sketch
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	// DefaultPrecision is the precision of a HyperLogLog unless specified otherwise.
+	// It uses 2^14 registers and has a standard error of about 0.8%.
+	DefaultPrecision = 14
+
+	// MinPrecision and MaxPrecision bound the precision of a HyperLogLog.
+	MinPrecision = 4
+	MaxPrecision = 18
+
+	hyperLogLogVersion byte = 1
+)
+
+// HyperLogLog estimates the number of distinct values using a fixed amount of memory.
+// A HyperLogLog with precision p uses 2^p one byte registers
+// and has a standard error of about 1.04/sqrt(2^p).
+//
+// See http://algo.inria.fr/flajolet/Publications/FlFuGaMe07.pdf.
+type HyperLogLog struct {
+	precision uint8
+	registers []uint8
+}
+
+// NewHyperLogLog creates a HyperLogLog with the given precision,
+// which must be between MinPrecision and MaxPrecision.
+func NewHyperLogLog(precision int) (*HyperLogLog, error) {
+	if precision < MinPrecision || precision > MaxPrecision {
+		return nil, fmt.Errorf("precision must be between %d and %d, got %d", MinPrecision, MaxPrecision, precision)
+	}
+	return &HyperLogLog{
+		precision: uint8(precision),
+		registers: make([]uint8, 1<<uint(precision)),
+	}, nil
+}
+
+// Precision returns the precision of the HyperLogLog.
+func (h *HyperLogLog) Precision() int {
+	return int(h.precision)
+}
+
+// Add a value to the HyperLogLog.
+func (h *HyperLogLog) Add(v []byte) {
+	h.AddHash(hash64(v))
+}
+
+// AddHash adds a value by its 64 bit hash, the hash must be uniformly distributed.
+func (h *HyperLogLog) AddHash(x uint64) {
+	p := uint(h.precision)
+	idx := x >> (64 - p)
+	// Guard bit so the rank is at most 64-p+1.
+	w := x<<p | 1<<(p-1)
+	rank := uint8(leadingZeros64(w) + 1)
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+}
+
+// Count returns the estimated number of distinct values added to the HyperLogLog.
+func (h *HyperLogLog) Count() uint64 {
+	m := float64(len(h.registers))
+	var sum float64
+	zeros := 0
+	for _, r := range h.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+	e := alpha(len(h.registers)) * m * m / sum
+	if e <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate for small cardinalities.
+		e = m * math.Log(m/float64(zeros))
+	}
+	return uint64(e + 0.5)
+}
+
+// Merge adds the values of the other HyperLogLog to this HyperLogLog.
+// Both must have the same precision.
+func (h *HyperLogLog) Merge(o *HyperLogLog) error {
+	if h.precision != o.precision {
+		return fmt.Errorf("cannot merge HyperLogLogs with different precisions %d and %d", h.precision, o.precision)
+	}
+	for i, r := range o.registers {
+		if r > h.registers[i] {
+			h.registers[i] = r
+		}
+	}
+	return nil
+}
+
+// Reset removes all values from the HyperLogLog.
+func (h *HyperLogLog) Reset() {
+	for i := range h.registers {
+		h.registers[i] = 0
+	}
+}
+
+// MarshalBinary encodes the HyperLogLog.
+// The encoding can be decoded with UnmarshalBinary and is stable across versions.
+func (h *HyperLogLog) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 2+len(h.registers))
+	data[0] = hyperLogLogVersion
+	data[1] = h.precision
+	copy(data[2:], h.registers)
+	return data, nil
+}
+
+// UnmarshalBinary decodes a HyperLogLog encoded with MarshalBinary.
+func (h *HyperLogLog) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 {
+		return errors.New("invalid HyperLogLog data")
+	}
+	if data[0] != hyperLogLogVersion {
+		return fmt.Errorf("unsupported HyperLogLog version %d", data[0])
+	}
+	d, err := NewHyperLogLog(int(data[1]))
+	if err != nil {
+		return err
+	}
+	if len(data)-2 != len(d.registers) {
+		return fmt.Errorf("invalid HyperLogLog register count %d", len(data)-2)
+	}
+	copy(d.registers, data[2:])
+	*h = *d
+	return nil
+}
+
+func alpha(m int) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	}
+	return 0.7213 / (1 + 1.079/float64(m))
+}
+
+func leadingZeros64(x uint64) int {
+	n := 0
+	for n < 64 && x&(1<<63) == 0 {
+		x <<= 1
+		n++
+	}
+	return n
+}
+
+// hash64 is the 64 bit FNV-1a hash followed by the murmur3 finalizer,
+// which spreads the hash over all bits.
+func hash64(data []byte) uint64 {
+	const (
+		offset = 14695981039346656037
+		prime  = 1099511628211
+	)
+	x := uint64(offset)
+	for _, b := range data {
+		x ^= uint64(b)
+		x *= prime
+	}
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb3f99e9f8f9b
+	x ^= x >> 33
+	return x
+}
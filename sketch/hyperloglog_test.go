@@ -0,0 +1,86 @@
+package sketch_test
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/influxdata/kapacitor/sketch"
+)
+
+func newHyperLogLog(t *testing.T) *sketch.HyperLogLog {
+	h, err := sketch.NewHyperLogLog(sketch.DefaultPrecision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func checkCount(t *testing.T, h *sketch.HyperLogLog, exp int) {
+	got := h.Count()
+	if math.Abs(float64(got)-float64(exp)) > float64(exp)*0.03 {
+		t.Errorf("unexpected count got %d exp %d", got, exp)
+	}
+}
+
+func TestHyperLogLog_Count(t *testing.T) {
+	for _, n := range []int{0, 1, 10, 1000, 100000} {
+		h := newHyperLogLog(t)
+		for i := 0; i < n; i++ {
+			// Add every value twice, duplicates are not counted.
+			h.Add([]byte(strconv.Itoa(i)))
+			h.Add([]byte(strconv.Itoa(i)))
+		}
+		checkCount(t, h, n)
+	}
+}
+
+func TestHyperLogLog_Merge(t *testing.T) {
+	a, b := newHyperLogLog(t), newHyperLogLog(t)
+	for i := 0; i < 20000; i++ {
+		a.Add([]byte(strconv.Itoa(i)))
+	}
+	for i := 10000; i < 30000; i++ {
+		b.Add([]byte(strconv.Itoa(i)))
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	checkCount(t, a, 30000)
+
+	c, err := sketch.NewHyperLogLog(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Merge(c); err == nil {
+		t.Error("expected error merging HyperLogLogs with different precisions")
+	}
+}
+
+func TestHyperLogLog_Text(t *testing.T) {
+	h := newHyperLogLog(t)
+	for i := 0; i < 5000; i++ {
+		h.Add([]byte(strconv.Itoa(i)))
+	}
+	text, err := h.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d sketch.HyperLogLog
+	if err := d.UnmarshalText(text); err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := d.Count(), h.Count(); got != exp {
+		t.Errorf("unexpected count got %d exp %d", got, exp)
+	}
+
+	s := newSketch(t)
+	s.Add(1)
+	text, err = s.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.UnmarshalText(text); err == nil {
+		t.Error("expected error decoding a DDSketch as a HyperLogLog")
+	}
+}
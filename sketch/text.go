@@ -0,0 +1,70 @@
+package sketch
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// Prefixes of the text encodings of the sketches.
+// The prefix identifies the kind of sketch, so encoded sketches can be told apart from other strings.
+const (
+	DDSketchTextPrefix    = "ddsketch:"
+	HyperLogLogTextPrefix = "hll:"
+)
+
+// MarshalText encodes the sketch as a string, for example to store it as a field.
+func (s *DDSketch) MarshalText() ([]byte, error) {
+	data, err := s.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return encodeText(DDSketchTextPrefix, data), nil
+}
+
+// UnmarshalText decodes a sketch encoded with MarshalText.
+func (s *DDSketch) UnmarshalText(text []byte) error {
+	data, err := decodeText(DDSketchTextPrefix, text)
+	if err != nil {
+		return err
+	}
+	return s.UnmarshalBinary(data)
+}
+
+// MarshalText encodes the HyperLogLog as a string, for example to store it as a field.
+func (h *HyperLogLog) MarshalText() ([]byte, error) {
+	data, err := h.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return encodeText(HyperLogLogTextPrefix, data), nil
+}
+
+// UnmarshalText decodes a HyperLogLog encoded with MarshalText.
+func (h *HyperLogLog) UnmarshalText(text []byte) error {
+	data, err := decodeText(HyperLogLogTextPrefix, text)
+	if err != nil {
+		return err
+	}
+	return h.UnmarshalBinary(data)
+}
+
+func encodeText(prefix string, data []byte) []byte {
+	text := make([]byte, len(prefix)+base64.StdEncoding.EncodedLen(len(data)))
+	copy(text, prefix)
+	base64.StdEncoding.Encode(text[len(prefix):], data)
+	return text
+}
+
+func decodeText(prefix string, text []byte) ([]byte, error) {
+	if !strings.HasPrefix(string(text), prefix) {
+		return nil, fmt.Errorf("encoded sketch must start with %q", prefix)
+	}
+	text = text[len(prefix):]
+	data := make([]byte, base64.StdEncoding.DecodedLen(len(text)))
+	n, err := base64.StdEncoding.Decode(data, text)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
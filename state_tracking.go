@@ -7,7 +7,6 @@ import (
 
 	"github.com/influxdata/kapacitor/edge"
 	"github.com/influxdata/kapacitor/pipeline"
-	"github.com/influxdata/kapacitor/tick/ast"
 	"github.com/influxdata/kapacitor/tick/stateful"
 )
 
@@ -33,11 +32,7 @@ type StateTrackingNode struct {
 }
 
 func (n *StateTrackingNode) runStateTracking(_ []byte) error {
-	consumer := edge.NewGroupedConsumer(
-		n.ins[0],
-		n,
-	)
-	n.statMap.Set(statCardinalityGauge, consumer.CardinalityVar())
+	consumer := n.newGroupedConsumer(n)
 	return consumer.Consume()
 }
 
@@ -146,7 +141,7 @@ func newStateDurationNode(et *ExecutingTask, sd *pipeline.StateDurationNode, l *
 		as:         sd.As,
 		newTracker: func() stateTracker { return &stateDurationTracker{sd: sd} },
 		expr:       expr,
-		scopePool:  stateful.NewScopePool(ast.FindReferenceVariables(sd.Lambda.Expression)),
+		scopePool:  stateful.NewScopePool(stateful.FindReferenceVariables(sd.Lambda.Expression)),
 	}
 	n.node.runF = n.runStateTracking
 	return n, nil
@@ -184,7 +179,7 @@ func newStateCountNode(et *ExecutingTask, sc *pipeline.StateCountNode, l *log.Lo
 		as:         sc.As,
 		newTracker: func() stateTracker { return &stateCountTracker{} },
 		expr:       expr,
-		scopePool:  stateful.NewScopePool(ast.FindReferenceVariables(sc.Lambda.Expression)),
+		scopePool:  stateful.NewScopePool(stateful.FindReferenceVariables(sc.Lambda.Expression)),
 	}
 	n.node.runF = n.runStateTracking
 	return n, nil
@@ -4,11 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/influxdata/kapacitor/edge"
 	"github.com/influxdata/kapacitor/models"
 	"github.com/influxdata/kapacitor/pipeline"
-	"github.com/influxdata/kapacitor/tick/ast"
 	"github.com/influxdata/kapacitor/tick/stateful"
 )
 
@@ -28,7 +28,21 @@ func newStreamNode(et *ExecutingTask, n *pipeline.StreamNode, l *log.Logger) (*S
 }
 
 func (n *StreamNode) runSourceStream([]byte) error {
+	late := n.newLateHandler()
+	// Event watermark of all data of the task.
+	var watermark time.Time
 	for m, ok := n.ins[0].Emit(); ok; m, ok = n.ins[0].Emit() {
+		if p, ok := m.(edge.PointMessage); ok && late != nil {
+			if late.isLate(watermark, p.Time()) {
+				if err := late.late(p); err != nil {
+					return err
+				}
+				continue
+			}
+			if p.Time().After(watermark) {
+				watermark = p.Time()
+			}
+		}
 		for _, child := range n.outs {
 			err := child.Collect(m)
 			if err != nil {
@@ -70,7 +84,7 @@ func newFromNode(et *ExecutingTask, n *pipeline.FromNode, l *log.Logger) (*FromN
 		}
 
 		sn.expression = expr
-		sn.scopePool = stateful.NewScopePool(ast.FindReferenceVariables(n.Lambda.Expression))
+		sn.scopePool = stateful.NewScopePool(stateful.FindReferenceVariables(n.Lambda.Expression))
 	}
 
 	return sn, nil
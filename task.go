@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/influxdata/kapacitor/edge"
+	"github.com/influxdata/kapacitor/expvar"
 	"github.com/influxdata/kapacitor/pipeline"
 )
 
@@ -72,6 +74,29 @@ type Task struct {
 	Type             TaskType
 	DBRPs            []DBRP
 	SnapshotInterval time.Duration
+	// ID of the live task this task shadows.
+	// If set, the task is executed in shadow mode,
+	// side effects of its nodes are captured instead of executed.
+	ShadowOf string
+	// Resource limits of the task.
+	Limits TaskLimits
+	// The time the task was created or last stopped by disabling it.
+	// The window and alert state of snapshots taken before is not restored.
+	Stopped time.Time
+}
+
+// Resource limits of a task.
+// A zero value for any limit means the limit is not enforced.
+type TaskLimits struct {
+	// Size of the input queue of a stream task.
+	// If zero the default edge buffer size is used.
+	QueueSize int
+	// Policy applied when the input queue is full.
+	QueuePolicy edge.OverflowPolicy
+	// Maximum number of groups per node.
+	MaxGroups int
+	// Maximum number of points buffered by each group of a window node.
+	MaxWindowPoints int
 }
 
 func (t *Task) Dot() []byte {
@@ -105,9 +130,12 @@ type ExecutingTask struct {
 	// node lookup from pipeline.ID -> Node
 	lookup   map[pipeline.ID]Node
 	nodes    []Node
+	shadow   *shadowBuffer
 	stopping chan struct{}
-	wg       sync.WaitGroup
-	logger   *log.Logger
+	// Number of points dropped by the input queue
+	queueDropped *expvar.Int
+	wg           sync.WaitGroup
+	logger       *log.Logger
 
 	// Mutex for throughput var
 	tmu        sync.RWMutex
@@ -118,16 +146,24 @@ type ExecutingTask struct {
 func NewExecutingTask(tm *TaskMaster, t *Task) (*ExecutingTask, error) {
 	l := tm.LogService.NewLogger(fmt.Sprintf("[task:%s] ", t.ID), log.LstdFlags)
 	et := &ExecutingTask{
-		tm:      tm,
-		Task:    t,
-		outputs: make(map[string]Output),
-		lookup:  make(map[pipeline.ID]Node),
-		logger:  l,
+		tm:           tm,
+		Task:         t,
+		outputs:      make(map[string]Output),
+		lookup:       make(map[pipeline.ID]Node),
+		queueDropped: new(expvar.Int),
+		logger:       l,
 	}
 	err := et.link()
 	if err != nil {
 		return nil, err
 	}
+	if t.ShadowOf != "" {
+		if _, ok := et.outputs[shadowOutputName]; ok {
+			return nil, fmt.Errorf("output name %q is reserved for shadow tasks", shadowOutputName)
+		}
+		et.shadow = newShadowBuffer(et, shadowBufferSize)
+		et.registerOutput(shadowOutputName, et.shadow)
+	}
 	return et, nil
 }
 
@@ -217,6 +253,11 @@ func (et *ExecutingTask) start(ins []edge.StatsEdge, snapshot *TaskSnapshot) err
 	if err != nil {
 		return err
 	}
+	if et.shadow != nil {
+		if err := et.shadow.open(); err != nil {
+			return err
+		}
+	}
 	et.stopping = make(chan struct{})
 	if et.Task.SnapshotInterval > 0 {
 		et.wg.Add(1)
@@ -238,6 +279,9 @@ func (et *ExecutingTask) stop() (err error) {
 		}
 		return nil
 	})
+	if et.shadow != nil {
+		et.shadow.close()
+	}
 	et.wg.Wait()
 	return
 }
@@ -332,19 +376,29 @@ func (et *ExecutingTask) registerOutput(name string, o Output) {
 	et.outputs[name] = o
 }
 
+// Maximum number of groups reported per node in the execution stats.
+const topGroupsCount = 10
+
 type ExecutionStats struct {
 	TaskStats map[string]interface{}
 	NodeStats map[string]map[string]interface{}
+	// TopGroups contains, for each node that keeps state per group,
+	// the groups that received the most points.
+	TopGroups map[string][]GroupVolume
 }
 
 func (et *ExecutingTask) ExecutionStats() (ExecutionStats, error) {
 	executionStats := ExecutionStats{
 		TaskStats: make(map[string]interface{}),
 		NodeStats: make(map[string]map[string]interface{}),
+		TopGroups: make(map[string][]GroupVolume),
 	}
 
 	// Fill the task stats
 	executionStats.TaskStats["throughput"] = et.getThroughput()
+	if et.Task.Type == StreamTask {
+		executionStats.TaskStats[statQueueDropped] = et.queueDropped.IntValue()
+	}
 
 	// Fill the nodes stats
 	err := et.walk(func(node Node) error {
@@ -355,6 +409,9 @@ func (et *ExecutingTask) ExecutionStats() (ExecutionStats, error) {
 		nodeStats["emitted"] = node.emittedCount()
 
 		executionStats.NodeStats[node.Name()] = nodeStats
+		if _, ok := nodeStats[statCardinalityGauge]; ok {
+			executionStats.TopGroups[node.Name()] = node.topGroups(topGroupsCount)
+		}
 
 		return nil
 	})
@@ -366,6 +423,37 @@ func (et *ExecutingTask) ExecutionStats() (ExecutionStats, error) {
 	return executionStats, nil
 }
 
+// LimitError returns an error describing the resource limits of the task that have been exceeded,
+// or nil if no limit has been exceeded.
+func (et *ExecutingTask) LimitError() error {
+	var violations []string
+	if dropped := et.queueDropped.IntValue(); dropped > 0 {
+		violations = append(violations, fmt.Sprintf("input queue of size %d was full, dropped %d points", et.queueSize(), dropped))
+	}
+	_ = et.walk(func(n Node) error {
+		stats := n.stats()
+		if dropped, ok := stats[statGroupLimitDrops].(int64); ok && dropped > 0 {
+			violations = append(violations, fmt.Sprintf("%s reached the limit of %d groups, dropped %d messages", n.Name(), et.Task.Limits.MaxGroups, dropped))
+		}
+		if dropped, ok := stats[statWindowLimitDrops].(int64); ok && dropped > 0 {
+			violations = append(violations, fmt.Sprintf("%s reached the limit of %d points per window, dropped %d points", n.Name(), et.Task.Limits.MaxWindowPoints, dropped))
+		}
+		return nil
+	})
+	if len(violations) == 0 {
+		return nil
+	}
+	return fmt.Errorf("task limits exceeded: %s", strings.Join(violations, "; "))
+}
+
+// queueSize returns the size of the input queue of the task.
+func (et *ExecutingTask) queueSize() int {
+	if et.Task.Limits.QueueSize > 0 {
+		return et.Task.Limits.QueueSize
+	}
+	return defaultEdgeBufferSize
+}
+
 // Return a graphviz .dot formatted byte array.
 // Label edges with relavant execution information.
 func (et *ExecutingTask) EDot(labels bool) []byte {
@@ -479,6 +567,14 @@ func (et *ExecutingTask) createNode(p pipeline.Node, l *log.Logger) (n Node, err
 		n, err = newSampleNode(et, t, l)
 	case *pipeline.DerivativeNode:
 		n, err = newDerivativeNode(et, t, l)
+	case *pipeline.AggregateNode:
+		n, err = newAggregateNode(et, t, l)
+	case *pipeline.DedupNode:
+		n, err = newDedupNode(et, t, l)
+	case *pipeline.ThrottleNode:
+		n, err = newThrottleNode(et, t, l)
+	case *pipeline.ForecastNode:
+		n, err = newForecastNode(et, t, l)
 	case *pipeline.UDFNode:
 		n, err = newUDFNode(et, t, l)
 	case *pipeline.StatsNode:
@@ -487,6 +583,8 @@ func (et *ExecutingTask) createNode(p pipeline.Node, l *log.Logger) (n Node, err
 		n, err = newShiftNode(et, t, l)
 	case *pipeline.NoOpNode:
 		n, err = newNoOpNode(et, t, l)
+	case *pipeline.LateNode:
+		n, err = newLateNode(et, t, l)
 	case *pipeline.InfluxQLNode:
 		n, err = newInfluxQLNode(et, t, l)
 	case *pipeline.LogNode:
@@ -516,11 +614,18 @@ func (et *ExecutingTask) createNode(p pipeline.Node, l *log.Logger) (n Node, err
 
 type TaskSnapshot struct {
 	NodeSnapshots map[string][]byte
+	// Fingerprint of the pipeline of the task, see pipeline.Fingerprint.
+	// The window and alert state of snapshots of a different pipeline is not restored.
+	Fingerprint string
+	// Time the snapshot was taken, zero for snapshots passed on by a reload.
+	Time time.Time
 }
 
 func (et *ExecutingTask) Snapshot() (*TaskSnapshot, error) {
 	snapshot := &TaskSnapshot{
 		NodeSnapshots: make(map[string][]byte),
+		Fingerprint:   pipeline.Fingerprint(et.Task.Pipeline),
+		Time:          time.Now().UTC(),
 	}
 	err := et.walk(func(n Node) error {
 		data, err := n.snapshot()
@@ -536,6 +641,66 @@ func (et *ExecutingTask) Snapshot() (*TaskSnapshot, error) {
 	return snapshot, nil
 }
 
+// reloadSnapshot returns the snapshot to start task t with when it replaces the stopped task old.
+// The state of a node is kept if a node with the same name, parents and properties exists in both tasks.
+// The names of the nodes whose state is kept are returned, if no state is kept or the tasks are incompatible nil is returned.
+func reloadSnapshot(old *ExecutingTask, t *Task) (*TaskSnapshot, []string) {
+	if old.Task.Type != t.Type {
+		return nil, nil
+	}
+	oldPipeline := make(map[string]pipeline.Node)
+	_ = old.Task.Pipeline.Walk(func(n pipeline.Node) error {
+		oldPipeline[n.Name()] = n
+		return nil
+	})
+	oldNodes := make(map[string]Node, len(old.nodes))
+	for _, n := range old.nodes {
+		oldNodes[n.Name()] = n
+	}
+	snapshot := &TaskSnapshot{
+		NodeSnapshots: make(map[string][]byte),
+		Fingerprint:   pipeline.Fingerprint(t.Pipeline),
+	}
+	var kept []string
+	_ = t.Pipeline.Walk(func(n pipeline.Node) error {
+		snapshot.NodeSnapshots[n.Name()] = nil
+		o, ok := oldNodes[n.Name()]
+		p := oldPipeline[n.Name()]
+		if !ok || p == nil || !pipeline.Equivalent(p, n) || !sameParents(p, n) {
+			return nil
+		}
+		data, err := o.snapshot()
+		if err != nil {
+			old.logger.Printf("E! failed to snapshot node %s during reload, the node starts without state: %v", n.Name(), err)
+			return nil
+		}
+		if len(data) == 0 {
+			// The node has no state.
+			return nil
+		}
+		snapshot.NodeSnapshots[n.Name()] = data
+		kept = append(kept, n.Name())
+		return nil
+	})
+	if len(kept) == 0 {
+		return nil, nil
+	}
+	return snapshot, kept
+}
+
+func sameParents(a, b pipeline.Node) bool {
+	ap, bp := a.Parents(), b.Parents()
+	if len(ap) != len(bp) {
+		return false
+	}
+	for i := range ap {
+		if ap[i].Name() != bp[i].Name() {
+			return false
+		}
+	}
+	return true
+}
+
 func (et *ExecutingTask) runSnapshotter() {
 	defer et.wg.Done()
 	// Wait random duration to splay snapshot events across interval
@@ -23,6 +23,7 @@ import (
 	"github.com/influxdata/kapacitor/services/httppost"
 	k8s "github.com/influxdata/kapacitor/services/k8s/client"
 	"github.com/influxdata/kapacitor/services/mqtt"
+	"github.com/influxdata/kapacitor/services/namespace"
 	"github.com/influxdata/kapacitor/services/opsgenie"
 	"github.com/influxdata/kapacitor/services/pagerduty"
 	"github.com/influxdata/kapacitor/services/pushover"
@@ -155,10 +156,20 @@ type TaskMaster struct {
 	}
 	LogService LogService
 
+	// NamespaceQuotas enforces the quotas of the namespaces of the tasks.
+	NamespaceQuotas interface {
+		AllowTask(namespace string, tasks int) error
+		AllowPoint(namespace string) bool
+	}
+
 	Commander command.Commander
 
 	DefaultRetentionPolicy string
 
+	// Replay is set if the task master runs replayed data.
+	// Clock watermarks then track the time of the data, since replayed data does not arrive in real time.
+	Replay bool
+
 	// Incoming streams
 	writePointsIn StreamCollector
 	writesClosed  bool
@@ -432,7 +443,80 @@ func (tm *TaskMaster) StartTask(t *Task) (*ExecutingTask, error) {
 	if tm.closed {
 		return nil, errors.New("task master is closed cannot start a task")
 	}
+	var snapshot *TaskSnapshot
+	if tm.TaskStore.HasSnapshot(t.ID) {
+		var err error
+		snapshot, err = tm.TaskStore.LoadSnapshot(t.ID)
+		if err != nil {
+			return nil, err
+		}
+		// Snapshots without a fingerprint predate fingerprints, they only contain the state of UDFs.
+		if snapshot.Fingerprint != "" && snapshot.Fingerprint != pipeline.Fingerprint(t.Pipeline) {
+			tm.logger.Printf("I! task %s changed since its last snapshot, starting windows and alerts without state", t.ID)
+			dropNodeState(t, snapshot)
+		} else if snapshot.Time.Before(t.Stopped) {
+			tm.logger.Printf("I! task %s was stopped after its last snapshot, starting windows and alerts without state", t.ID)
+			dropNodeState(t, snapshot)
+		}
+	}
+	return tm.startTask(t, snapshot)
+}
+
+// dropNodeState removes the state of the window and alert nodes from the snapshot.
+// Their state is only valid for the pipeline and the run of the task it was taken from,
+// the state of UDFs is managed by the UDFs themselves and kept.
+func dropNodeState(t *Task, snapshot *TaskSnapshot) {
+	_ = t.Pipeline.Walk(func(n pipeline.Node) error {
+		switch n.(type) {
+		case *pipeline.WindowNode, *pipeline.AlertNode:
+			if _, ok := snapshot.NodeSnapshots[n.Name()]; ok {
+				// Keep the entry, a snapshot missing a node is not restored at all.
+				snapshot.NodeSnapshots[n.Name()] = nil
+			}
+		}
+		return nil
+	})
+}
+
+// ReloadTask replaces the executing task with the same ID with t.
+// The state of the nodes that are unchanged in t is carried over to the new task,
+// all other nodes start without state.
+// If the task is not executing it is started.
+func (tm *TaskMaster) ReloadTask(t *Task) (*ExecutingTask, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if tm.closed {
+		return nil, errors.New("task master is closed cannot reload a task")
+	}
+	old, ok := tm.tasks[t.ID]
+	if !ok {
+		return tm.startTask(t, nil)
+	}
+	tm.logger.Println("D! Reloading task:", t.ID)
+	// Stop the task first so that all data in flight is part of the snapshot.
+	if err := tm.stopTask(t.ID); err != nil {
+		return nil, err
+	}
+	snapshot, kept := reloadSnapshot(old, t)
+	if snapshot == nil {
+		tm.logger.Printf("I! task %s changed incompatibly, restarting without state", t.ID)
+	} else {
+		tm.logger.Printf("I! reloading task %s keeping the state of nodes %v", t.ID, kept)
+	}
+	return tm.startTask(t, snapshot)
+}
+
+func (tm *TaskMaster) startTask(t *Task, snapshot *TaskSnapshot) (*ExecutingTask, error) {
 	tm.logger.Println("D! Starting task:", t.ID)
+	if ns, _ := namespace.Split(t.ID); ns != "" && tm.NamespaceQuotas != nil {
+		tasks := tm.numExecutingTasks(ns)
+		if _, executing := tm.tasks[t.ID]; !executing {
+			tasks++
+		}
+		if err := tm.NamespaceQuotas.AllowTask(ns, tasks); err != nil {
+			return nil, err
+		}
+	}
 	et, err := NewExecutingTask(tm, t)
 	if err != nil {
 		return nil, err
@@ -441,7 +525,7 @@ func (tm *TaskMaster) StartTask(t *Task) (*ExecutingTask, error) {
 	var ins []edge.StatsEdge
 	switch et.Task.Type {
 	case StreamTask:
-		e, err := tm.newFork(et.Task.ID, et.Task.DBRPs, et.Task.Measurements())
+		e, err := tm.newFork(et.Task.ID, et.Task.DBRPs, et.Task.Measurements(), et.queueSize(), et.Task.Limits.QueuePolicy, et.queueDropped)
 		if err != nil {
 			return nil, err
 		}
@@ -455,15 +539,11 @@ func (tm *TaskMaster) StartTask(t *Task) (*ExecutingTask, error) {
 		for i := 0; i < count; i++ {
 			in := newEdge(t.ID, "batch", fmt.Sprintf("batch%d", i), pipeline.BatchEdge, defaultEdgeBufferSize, tm.LogService)
 			ins[i] = in
-			tm.batches[t.ID] = append(tm.batches[t.ID], &batchCollector{edge: in})
-		}
-	}
-
-	var snapshot *TaskSnapshot
-	if tm.TaskStore.HasSnapshot(t.ID) {
-		snapshot, err = tm.TaskStore.LoadSnapshot(t.ID)
-		if err != nil {
-			return nil, err
+			id := t.ID
+			tm.batches[t.ID] = append(tm.batches[t.ID], &batchCollector{
+				edge:       in,
+				allowPoint: func() bool { return tm.allowPoint(id) },
+			})
 		}
 	}
 
@@ -545,6 +625,23 @@ func (tm *TaskMaster) IsExecuting(id string) bool {
 	return executing
 }
 
+// NumExecutingTasks returns the number of executing tasks of the namespace.
+func (tm *TaskMaster) NumExecutingTasks(ns string) int {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	return tm.numExecutingTasks(ns)
+}
+
+func (tm *TaskMaster) numExecutingTasks(ns string) int {
+	count := 0
+	for id := range tm.tasks {
+		if taskNS, _ := namespace.Split(id); taskNS == ns {
+			count++
+		}
+	}
+	return count
+}
+
 func (tm *TaskMaster) ExecutionStats(id string) (ExecutionStats, error) {
 	tm.mu.RLock()
 	defer tm.mu.RUnlock()
@@ -556,6 +653,17 @@ func (tm *TaskMaster) ExecutionStats(id string) (ExecutionStats, error) {
 	return task.ExecutionStats()
 }
 
+// TaskLimitError returns the error describing the exceeded resource limits of an executing task.
+func (tm *TaskMaster) TaskLimitError(id string) error {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	task, executing := tm.tasks[id]
+	if !executing {
+		return nil
+	}
+	return task.LimitError()
+}
+
 func (tm *TaskMaster) ExecutingDot(id string, labels bool) string {
 	tm.mu.RLock()
 	defer tm.mu.RUnlock()
@@ -625,6 +733,15 @@ func (tm *TaskMaster) runForking(in StreamEdge) {
 	}
 }
 
+// allowPoint reports whether the point quota of the namespace of the task allows another point.
+func (tm *TaskMaster) allowPoint(id string) bool {
+	if tm.NamespaceQuotas == nil {
+		return true
+	}
+	ns, _ := namespace.Split(id)
+	return ns == "" || tm.NamespaceQuotas.AllowPoint(ns)
+}
+
 func (tm *TaskMaster) forkPoint(p edge.PointMessage) {
 	tm.mu.RLock()
 	locked := true
@@ -650,12 +767,16 @@ func (tm *TaskMaster) forkPoint(p edge.PointMessage) {
 	}
 
 	// Merge the results to the forks map
-	for _, edge := range tm.forks[key] {
-		_ = edge.Collect(p)
+	for id, edge := range tm.forks[key] {
+		if tm.allowPoint(id) {
+			_ = edge.Collect(p)
+		}
 	}
 
-	for _, edge := range tm.forks[emptyMeasurementKey] {
-		_ = edge.Collect(p)
+	for id, edge := range tm.forks[emptyMeasurementKey] {
+		if tm.allowPoint(id) {
+			_ = edge.Collect(p)
+		}
 	}
 
 	c, ok := tm.forkStats[key]
@@ -728,7 +849,7 @@ func (tm *TaskMaster) WriteKapacitorPoint(p edge.PointMessage) error {
 func (tm *TaskMaster) NewFork(taskName string, dbrps []DBRP, measurements []string) (edge.StatsEdge, error) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
-	return tm.newFork(taskName, dbrps, measurements)
+	return tm.newFork(taskName, dbrps, measurements, defaultEdgeBufferSize, edge.BlockPolicy, nil)
 }
 
 func forkKeys(dbrps []DBRP, measurements []string) []forkKey {
@@ -750,12 +871,13 @@ func forkKeys(dbrps []DBRP, measurements []string) []forkKey {
 }
 
 // internal newFork, must have acquired lock before calling.
-func (tm *TaskMaster) newFork(taskName string, dbrps []DBRP, measurements []string) (edge.StatsEdge, error) {
+// Points dropped because the fork is full are counted in dropped, which may be nil.
+func (tm *TaskMaster) newFork(taskName string, dbrps []DBRP, measurements []string, size int, policy edge.OverflowPolicy, dropped *expvar.Int) (edge.StatsEdge, error) {
 	if tm.closed {
 		return nil, ErrTaskMasterClosed
 	}
 
-	e := newEdge(taskName, "stream", "stream0", pipeline.StreamEdge, defaultEdgeBufferSize, tm.LogService)
+	e := newBoundedEdge(taskName, "stream", "stream0", pipeline.StreamEdge, size, policy, dropped, tm.LogService)
 
 	for _, key := range forkKeys(dbrps, measurements) {
 		tm.taskToForkKeys[taskName] = append(tm.taskToForkKeys[taskName], key)
@@ -868,9 +990,27 @@ type BatchCollector interface {
 
 type batchCollector struct {
 	edge edge.Edge
+	// Reports whether the point quota of the namespace of the task allows another point.
+	allowPoint func() bool
 }
 
+// CollectBatch collects the points of the batch the point quota of the namespace of the task allows.
 func (c *batchCollector) CollectBatch(batch edge.BufferedBatchMessage) error {
+	if c.allowPoint != nil {
+		points := batch.Points()
+		allowed := make([]edge.BatchPointMessage, 0, len(points))
+		for _, p := range points {
+			if c.allowPoint() {
+				allowed = append(allowed, p)
+			}
+		}
+		if len(allowed) < len(points) {
+			batch = batch.ShallowCopy()
+			batch.SetBegin(batch.Begin().ShallowCopy())
+			batch.Begin().SetSizeHint(len(allowed))
+			batch.SetPoints(allowed)
+		}
+	}
 	return c.edge.Collect(batch)
 }
 func (c *batchCollector) Close() error {
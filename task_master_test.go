@@ -0,0 +1,96 @@
+package kapacitor
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdata/kapacitor/edge"
+	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/pipeline"
+	"github.com/influxdata/kapacitor/tick/stateful"
+)
+
+type noDeadman struct{}
+
+func (noDeadman) Interval() time.Duration { return 0 }
+func (noDeadman) Threshold() float64      { return 0 }
+func (noDeadman) Id() string              { return "" }
+func (noDeadman) Message() string         { return "" }
+func (noDeadman) Global() bool            { return false }
+
+func TestDropNodeState(t *testing.T) {
+	p, err := pipeline.CreatePipeline(`
+stream
+	|from()
+	|window()
+		.period(10s)
+		.every(10s)
+	|alert()
+		.crit(lambda: TRUE)
+`, pipeline.StreamEdge, stateful.NewScope(), noDeadman{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := &TaskSnapshot{
+		NodeSnapshots: map[string][]byte{
+			"stream0":  nil,
+			"from1":    nil,
+			"window2":  []byte("window"),
+			"alert3":   []byte("alert"),
+			"myudf4":   []byte("udf"),
+			"unknown5": []byte("other"),
+		},
+	}
+	dropNodeState(&Task{Pipeline: p}, snapshot)
+	exp := map[string][]byte{
+		"stream0":  nil,
+		"from1":    nil,
+		"window2":  nil,
+		"alert3":   nil,
+		"myudf4":   []byte("udf"),
+		"unknown5": []byte("other"),
+	}
+	if !reflect.DeepEqual(snapshot.NodeSnapshots, exp) {
+		t.Errorf("unexpected node snapshots got %q exp %q", snapshot.NodeSnapshots, exp)
+	}
+}
+
+func TestBatchCollector_PointQuota(t *testing.T) {
+	e := edge.NewChannelEdge(pipeline.BatchEdge, 1)
+	quota := 2
+	c := &batchCollector{
+		edge: e,
+		allowPoint: func() bool {
+			quota--
+			return quota >= 0
+		},
+	}
+	points := make([]edge.BatchPointMessage, 3)
+	for i := range points {
+		points[i] = edge.NewBatchPointMessage(models.Fields{"value": float64(i)}, nil, time.Unix(int64(i), 0))
+	}
+	batch := edge.NewBufferedBatchMessage(
+		edge.NewBeginBatchMessage("cpu", nil, false, time.Unix(0, 0), len(points)),
+		points,
+		edge.NewEndBatchMessage(),
+	)
+	if err := c.CollectBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+	m, ok := e.Emit()
+	if !ok {
+		t.Fatal("expected batch to be collected")
+	}
+	b := m.(edge.BufferedBatchMessage)
+	if got, exp := len(b.Points()), 2; got != exp {
+		t.Errorf("unexpected number of collected points got %d exp %d", got, exp)
+	}
+	if got, exp := b.Begin().SizeHint(), 2; got != exp {
+		t.Errorf("unexpected size hint got %d exp %d", got, exp)
+	}
+	// The original batch is not changed.
+	if got, exp := len(batch.Points()), 3; got != exp {
+		t.Errorf("unexpected number of points of the original batch got %d exp %d", got, exp)
+	}
+}
@@ -0,0 +1,100 @@
+package tasktest
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/influxdata/kapacitor/alert"
+	"github.com/influxdata/kapacitor/influxdb"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// diff returns a unified diff of the expected and actual text.
+func diff(exp, got string) string {
+	d, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(exp),
+		B:        difflib.SplitLines(got),
+		FromFile: "expected",
+		ToFile:   "actual",
+		Context:  3,
+	})
+	if err != nil {
+		return fmt.Sprintf("expected:\n%s\nactual:\n%s", exp, got)
+	}
+	return d
+}
+
+// compareAlerts compares the events in order.
+// Fields left empty in an expected alert are not compared.
+func compareAlerts(exp []ExpectedAlert, got []alert.Event) *Failure {
+	var expBuf, gotBuf bytes.Buffer
+	for i, e := range exp {
+		var g *alert.EventState
+		if i < len(got) {
+			g = &got[i].State
+		}
+		writeAlert(&expBuf, e, g)
+	}
+	for i := range got {
+		writeAlert(&gotBuf, ExpectedAlert{}, &got[i].State)
+	}
+	if expBuf.String() == gotBuf.String() {
+		return nil
+	}
+	return &Failure{
+		Output: "alerts",
+		Diff:   diff(expBuf.String(), gotBuf.String()),
+	}
+}
+
+// writeAlert writes a single line describing the alert.
+// Any field the expected alert omits is filled from state, if state is not nil.
+func writeAlert(buf *bytes.Buffer, e ExpectedAlert, state *alert.EventState) {
+	if state != nil {
+		if e.ID == "" {
+			e.ID = state.ID
+		}
+		if e.Level == "" {
+			e.Level = state.Level.String()
+		}
+		if e.Message == "" {
+			e.Message = state.Message
+		}
+		if e.Time == "" {
+			e.Time = state.Time.UTC().Format(time.RFC3339Nano)
+		}
+	}
+	if t, err := time.Parse(time.RFC3339Nano, e.Time); err == nil {
+		e.Time = t.UTC().Format(time.RFC3339Nano)
+	}
+	fmt.Fprintf(buf, "id=%q level=%s time=%s message=%q\n", e.ID, strings.ToUpper(e.Level), e.Time, e.Message)
+}
+
+// compareInfluxDBOut compares the written points ignoring order.
+func compareInfluxDBOut(exp []ExpectedWrite, got []influxdb.BatchPoints, precision string) *Failure {
+	var expLines, gotLines []string
+	for _, w := range exp {
+		for _, p := range w.Points {
+			expLines = append(expLines, fmt.Sprintf("%s.%s %s", w.Database, w.RetentionPolicy, strings.TrimSpace(p)))
+		}
+	}
+	for _, bp := range got {
+		for _, p := range bp.Points() {
+			gotLines = append(gotLines, fmt.Sprintf("%s.%s %s", bp.Database(), bp.RetentionPolicy(), p.Bytes(precision)))
+		}
+	}
+	sort.Strings(expLines)
+	sort.Strings(gotLines)
+	expText := strings.Join(expLines, "\n") + "\n"
+	gotText := strings.Join(gotLines, "\n") + "\n"
+	if expText == gotText {
+		return nil
+	}
+	return &Failure{
+		Output: "influxdbOut",
+		Diff:   diff(expText, gotText),
+	}
+}
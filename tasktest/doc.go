@@ -0,0 +1,41 @@
+// Package tasktest provides an offline runner for unit testing TICKscripts.
+//
+// A test is described by a YAML spec file which names a TICKscript, a fixture of input data
+// and the expected outputs of the task.
+// The task is executed in an isolated TaskMaster using a fast clock,
+// so no running Kapacitor server or InfluxDB instance is required.
+//
+// Example spec:
+//
+//	tick: cpu_alert.tick
+//	type: stream
+//	dbrp:
+//	  - telegraf.autogen
+//	data: cpu.lp
+//	precision: s
+//	expect:
+//	  alerts:
+//	    - id: cpu
+//	      level: CRITICAL
+//	  httpOut:
+//	    mean:
+//	      series:
+//	        - name: cpu
+//	          columns: [time, mean]
+//	          values:
+//	            - ["1971-01-01T00:00:10Z", 91]
+//	  influxdbOut:
+//	    - database: telegraf
+//	      retentionPolicy: autogen
+//	      points:
+//	        - cpu_mean mean=91 31536010
+//
+// For stream tasks the data file contains plain line protocol,
+// all points are written to the first DBRP of the spec.
+// For batch tasks the batches list names one recording file (.brpl) per query node.
+//
+// Alerts are captured from alert nodes that have at least one handler or a topic,
+// handlers are never executed.
+// Expected alerts are compared in order, empty fields of an expected alert are not compared.
+// Expected InfluxDBOut points are compared ignoring order, using the precision of the spec.
+package tasktest
@@ -0,0 +1,281 @@
+package tasktest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	dbmodels "github.com/influxdata/influxdb/models"
+	"github.com/influxdata/kapacitor"
+	"github.com/influxdata/kapacitor/clock"
+	"github.com/influxdata/kapacitor/edge"
+	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/pipeline"
+)
+
+// Result of running a single spec.
+type Result struct {
+	Name     string
+	Failures []Failure
+}
+
+// Passed reports whether all expectations were met.
+func (r *Result) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// Failure describes an output that did not match its expectation.
+type Failure struct {
+	// Output that failed, i.e. "alerts", "httpOut top10" or "influxdbOut".
+	Output string
+	// Unified diff of the expected and the actual output.
+	Diff string
+}
+
+func (f Failure) String() string {
+	return fmt.Sprintf("%s:\n%s", f.Output, f.Diff)
+}
+
+// Run executes the task described by the spec and compares its outputs against the expectations.
+// The logs of the executing task are written to logs, which may be nil.
+// An error is returned if the task could not be executed.
+func Run(s *Spec, logs io.Writer) (*Result, error) {
+	if logs == nil {
+		logs = ioutil.Discard
+	}
+	tt, err := s.taskType()
+	if err != nil {
+		return nil, err
+	}
+	dbrps, err := s.dbrps()
+	if err != nil {
+		return nil, err
+	}
+	script, err := ioutil.ReadFile(s.path(s.TICKscript))
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := newEnvironment(logs)
+	if err != nil {
+		return nil, err
+	}
+	tm := env.tm
+	if err := tm.Open(); err != nil {
+		return nil, err
+	}
+	closed := false
+	defer func() {
+		if !closed {
+			tm.Close()
+		}
+	}()
+
+	task, err := tm.NewTask(s.taskID(), string(script), tt, dbrps, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSupported(task.Pipeline); err != nil {
+		return nil, err
+	}
+
+	// Load all data before starting the task so that invalid fixtures fail fast.
+	var points []edge.PointMessage
+	var batches []io.ReadCloser
+	switch tt {
+	case kapacitor.StreamTask:
+		points, err = readLineProtocol(s.path(s.Data), dbrps[0], s.precision())
+		if err != nil {
+			return nil, err
+		}
+	case kapacitor.BatchTask:
+		for _, b := range s.Batches {
+			f, err := os.Open(s.path(b))
+			if err != nil {
+				for _, f := range batches {
+					f.Close()
+				}
+				return nil, err
+			}
+			batches = append(batches, f)
+		}
+	}
+
+	et, err := tm.StartTask(task)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use a fast clock and keep the recorded times so that results are deterministic.
+	clck := clock.Fast()
+	var replayErr <-chan error
+	switch tt {
+	case kapacitor.StreamTask:
+		stream, err := tm.Stream(task.ID)
+		if err != nil {
+			return nil, err
+		}
+		pointsC := make(chan edge.PointMessage)
+		go func() {
+			defer close(pointsC)
+			for _, p := range points {
+				pointsC <- p
+			}
+		}()
+		replayErr = kapacitor.ReplayStreamFromChan(clck, pointsC, stream, true)
+	case kapacitor.BatchTask:
+		collectors := tm.BatchCollectors(task.ID)
+		if len(collectors) != len(batches) {
+			for _, f := range batches {
+				f.Close()
+			}
+			return nil, fmt.Errorf("task has %d queries but %d batch files were provided", len(collectors), len(batches))
+		}
+		replayErr = kapacitor.ReplayBatchFromIO(clck, batches, collectors, true)
+	}
+
+	if err := <-replayErr; err != nil {
+		return nil, err
+	}
+	tm.Drain()
+	et.StopStats()
+	if err := et.Wait(); err != nil {
+		return nil, err
+	}
+
+	result := &Result{Name: s.Name}
+
+	// HTTPOut results must be read before the task is stopped, stopping removes the routes.
+	names := make([]string, 0, len(s.Expect.HTTPOut))
+	for name := range s.Expect.HTTPOut {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		output, err := et.GetOutput(name)
+		if err != nil {
+			return nil, err
+		}
+		got, err := env.routes.get(output.Endpoint())
+		if err != nil {
+			return nil, err
+		}
+		if f, err := compareHTTPOut(name, s.Expect.HTTPOut[name], got); err != nil {
+			return nil, err
+		} else if f != nil {
+			result.Failures = append(result.Failures, *f)
+		}
+	}
+
+	// Stopping the task flushes any buffered InfluxDBOut writes.
+	closed = true
+	if err := tm.Close(); err != nil {
+		return nil, err
+	}
+
+	if s.Expect.Alerts != nil {
+		if f := compareAlerts(s.Expect.Alerts, env.alerts.Events()); f != nil {
+			result.Failures = append(result.Failures, *f)
+		}
+	}
+	if s.Expect.InfluxDBOut != nil {
+		if f := compareInfluxDBOut(s.Expect.InfluxDBOut, env.influxdb.Writes(), s.precision()); f != nil {
+			result.Failures = append(result.Failures, *f)
+		}
+	}
+	return result, nil
+}
+
+// checkSupported returns an error if the pipeline contains nodes
+// whose side effects cannot be captured offline.
+func checkSupported(p *pipeline.Pipeline) error {
+	return p.Walk(func(n pipeline.Node) error {
+		switch n.(type) {
+		case *pipeline.HTTPPostNode,
+			*pipeline.K8sAutoscaleNode,
+			*pipeline.SwarmAutoscaleNode,
+			*pipeline.UDFNode:
+			return fmt.Errorf("node %s is not supported in tests", n.Name())
+		}
+		return nil
+	})
+}
+
+// readLineProtocol reads all points from a line protocol file.
+func readLineProtocol(path string, dbrp kapacitor.DBRP, precision string) ([]edge.PointMessage, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	mps, err := dbmodels.ParsePointsWithPrecision(data, time.Time{}, precision)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data file %s: %v", path, err)
+	}
+	points := make([]edge.PointMessage, len(mps))
+	for i, mp := range mps {
+		points[i] = edge.NewPointMessage(
+			mp.Name(),
+			dbrp.Database,
+			dbrp.RetentionPolicy,
+			models.Dimensions{},
+			models.Fields(mp.Fields()),
+			models.Tags(mp.Tags().Map()),
+			mp.Time().UTC(),
+		)
+	}
+	return points, nil
+}
+
+func compareHTTPOut(name string, exp interface{}, got []byte) (*Failure, error) {
+	expJSON, err := json.Marshal(exp)
+	if err != nil {
+		return nil, err
+	}
+	// Decode both into a models.Result so that only meaningful fields are compared.
+	var expResult, gotResult models.Result
+	if err := json.Unmarshal(expJSON, &expResult); err != nil {
+		return nil, fmt.Errorf("invalid expected httpOut result %s: %v", name, err)
+	}
+	if err := json.Unmarshal(got, &gotResult); err != nil {
+		return nil, err
+	}
+	expText, err := indentJSON(expResult)
+	if err != nil {
+		return nil, err
+	}
+	gotText, err := indentJSON(gotResult)
+	if err != nil {
+		return nil, err
+	}
+	if expText == gotText {
+		return nil, nil
+	}
+	return &Failure{
+		Output: "httpOut " + name,
+		Diff:   diff(expText, gotText),
+	}, nil
+}
+
+// indentJSON returns a normalized indented JSON representation of v.
+func indentJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	var o interface{}
+	if err := json.Unmarshal(data, &o); err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(o); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
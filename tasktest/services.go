@@ -0,0 +1,223 @@
+package tasktest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/influxdata/kapacitor"
+	"github.com/influxdata/kapacitor/alert"
+	"github.com/influxdata/kapacitor/influxdb"
+	"github.com/influxdata/kapacitor/server/vars"
+	"github.com/influxdata/kapacitor/services/alerta"
+	"github.com/influxdata/kapacitor/services/deadman"
+	"github.com/influxdata/kapacitor/services/hipchat"
+	"github.com/influxdata/kapacitor/services/httpd"
+	"github.com/influxdata/kapacitor/services/httppost"
+	"github.com/influxdata/kapacitor/services/mqtt"
+	"github.com/influxdata/kapacitor/services/opsgenie"
+	"github.com/influxdata/kapacitor/services/pagerduty"
+	"github.com/influxdata/kapacitor/services/pushover"
+	"github.com/influxdata/kapacitor/services/sensu"
+	"github.com/influxdata/kapacitor/services/slack"
+	"github.com/influxdata/kapacitor/services/smtp"
+	"github.com/influxdata/kapacitor/services/snmptrap"
+	"github.com/influxdata/kapacitor/services/talk"
+	"github.com/influxdata/kapacitor/services/telegram"
+	"github.com/influxdata/kapacitor/services/victorops"
+	"github.com/influxdata/wlog"
+)
+
+// environment is an isolated TaskMaster with all of its side effects captured in memory.
+type environment struct {
+	tm       *kapacitor.TaskMaster
+	alerts   *alertCapture
+	influxdb *influxDBCapture
+	routes   *routes
+}
+
+func newEnvironment(w io.Writer) (*environment, error) {
+	ls := logService{w: w}
+	env := &environment{
+		alerts:   new(alertCapture),
+		influxdb: new(influxDBCapture),
+		routes:   &routes{routes: make(map[string]httpd.Route)},
+	}
+
+	tm := kapacitor.NewTaskMaster("tasktest", vars.Info, ls)
+	tm.HTTPDService = env.routes
+	tm.TaskStore = taskStore{}
+	tm.DeadmanService = deadman.NewService(deadman.NewConfig(), ls.NewLogger("[deadman] ", log.LstdFlags))
+	tm.AlertService = env.alerts
+	tm.InfluxDBService = env.influxdb
+
+	// Handler services are only used to construct the handlers of alert nodes.
+	// The alert capture never registers the handlers so they are never executed.
+	tm.SMTPService = smtp.NewService(smtp.NewConfig(), ls.NewLogger("[smtp] ", log.LstdFlags))
+	tm.OpsGenieService = opsgenie.NewService(opsgenie.NewConfig(), ls.NewLogger("[opsgenie] ", log.LstdFlags))
+	tm.VictorOpsService = victorops.NewService(victorops.NewConfig(), ls.NewLogger("[victorops] ", log.LstdFlags))
+	tm.PagerDutyService = pagerduty.NewService(pagerduty.NewConfig(), ls.NewLogger("[pagerduty] ", log.LstdFlags))
+	tm.PushoverService = pushover.NewService(pushover.NewConfig(), ls.NewLogger("[pushover] ", log.LstdFlags))
+	tm.HTTPPostService = httppost.NewService(nil, ls.NewLogger("[httppost] ", log.LstdFlags))
+	tm.SNMPTrapService = snmptrap.NewService(snmptrap.NewConfig(), ls.NewLogger("[snmptrap] ", log.LstdFlags))
+	tm.TelegramService = telegram.NewService(telegram.NewConfig(), ls.NewLogger("[telegram] ", log.LstdFlags))
+	tm.HipChatService = hipchat.NewService(hipchat.NewConfig(), ls.NewLogger("[hipchat] ", log.LstdFlags))
+	tm.AlertaService = alerta.NewService(alerta.NewConfig(), ls.NewLogger("[alerta] ", log.LstdFlags))
+	tm.SensuService = sensu.NewService(sensu.NewConfig(), ls.NewLogger("[sensu] ", log.LstdFlags))
+	tm.TalkService = talk.NewService(talk.NewConfig(), ls.NewLogger("[talk] ", log.LstdFlags))
+	slackService, err := slack.NewService(slack.NewConfig(), ls.NewLogger("[slack] ", log.LstdFlags))
+	if err != nil {
+		return nil, err
+	}
+	tm.SlackService = slackService
+	mqttService, err := mqtt.NewService(nil, ls.NewLogger("[mqtt] ", log.LstdFlags))
+	if err != nil {
+		return nil, err
+	}
+	tm.MQTTService = mqttService
+
+	env.tm = tm
+	return env, nil
+}
+
+type logService struct {
+	w io.Writer
+}
+
+func (l logService) NewLogger(prefix string, flag int) *log.Logger {
+	return wlog.New(l.w, prefix, flag)
+}
+
+type taskStore struct{}
+
+func (taskStore) SaveSnapshot(string, *kapacitor.TaskSnapshot) error { return nil }
+func (taskStore) HasSnapshot(string) bool                            { return false }
+func (taskStore) LoadSnapshot(string) (*kapacitor.TaskSnapshot, error) {
+	return nil, errors.New("snapshots are not supported")
+}
+
+// routes is an in memory replacement of the HTTPD service.
+type routes struct {
+	mu     sync.RWMutex
+	routes map[string]httpd.Route
+}
+
+func (r *routes) AddRoutes(routes []httpd.Route) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, route := range routes {
+		r.routes[route.Method+" "+route.Pattern] = route
+	}
+	return nil
+}
+
+func (r *routes) DelRoutes(routes []httpd.Route) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, route := range routes {
+		delete(r.routes, route.Method+" "+route.Pattern)
+	}
+}
+
+func (r *routes) URL() string {
+	return ""
+}
+
+// get performs a GET request against the registered route for path.
+func (r *routes) get(path string) ([]byte, error) {
+	r.mu.RLock()
+	route, ok := r.routes["GET "+path]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no route registered for %s", path)
+	}
+	h, ok := route.HandlerFunc.(func(http.ResponseWriter, *http.Request))
+	if !ok {
+		return nil, fmt.Errorf("unsupported handler type %T for %s", route.HandlerFunc, path)
+	}
+	req, err := http.NewRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	rec := httptest.NewRecorder()
+	h(rec, req)
+	if rec.Code != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d for %s", rec.Code, path)
+	}
+	return rec.Body.Bytes(), nil
+}
+
+// alertCapture records all alert events and never executes any handlers.
+type alertCapture struct {
+	mu     sync.Mutex
+	events []alert.Event
+}
+
+func (a *alertCapture) RegisterAnonHandler(string, alert.Handler)   {}
+func (a *alertCapture) DeregisterAnonHandler(string, alert.Handler) {}
+
+func (a *alertCapture) Collect(event alert.Event) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// An alert node with both handlers and a topic collects each event twice, once per topic.
+	if l := len(a.events); l > 0 && a.events[l-1].State == event.State {
+		return nil
+	}
+	a.events = append(a.events, event)
+	return nil
+}
+
+func (a *alertCapture) UpdateEvent(string, alert.EventState) error { return nil }
+func (a *alertCapture) EventState(topic, event string) (alert.EventState, bool, error) {
+	return alert.EventState{}, false, nil
+}
+func (a *alertCapture) CloseTopic(string) error   { return nil }
+func (a *alertCapture) DeleteTopic(string) error  { return nil }
+func (a *alertCapture) RestoreTopic(string) error { return nil }
+
+func (a *alertCapture) Events() []alert.Event {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	events := make([]alert.Event, len(a.events))
+	copy(events, a.events)
+	return events
+}
+
+// influxDBCapture records all writes instead of sending them to InfluxDB.
+type influxDBCapture struct {
+	mu     sync.Mutex
+	writes []influxdb.BatchPoints
+}
+
+func (c *influxDBCapture) NewNamedClient(string) (influxdb.Client, error) {
+	return c, nil
+}
+
+func (c *influxDBCapture) Ping(context.Context) (time.Duration, string, error) {
+	return 0, "", nil
+}
+
+func (c *influxDBCapture) Write(bp influxdb.BatchPoints) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writes = append(c.writes, bp)
+	return nil
+}
+
+func (c *influxDBCapture) Query(influxdb.Query) (*influxdb.Response, error) {
+	return &influxdb.Response{}, nil
+}
+
+func (c *influxDBCapture) Writes() []influxdb.BatchPoints {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writes := make([]influxdb.BatchPoints, len(c.writes))
+	copy(writes, c.writes)
+	return writes
+}
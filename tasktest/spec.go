@@ -0,0 +1,161 @@
+package tasktest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/influxdata/kapacitor"
+)
+
+// Spec describes a single TICKscript unit test.
+type Spec struct {
+	// Name of the test, defaults to the base name of the spec file.
+	Name string `json:"name"`
+	// ID of the task, defaults to the name of the test.
+	Task string `json:"task"`
+	// Path to the TICKscript.
+	TICKscript string `json:"tick"`
+	// Type of the task, either stream or batch.
+	Type string `json:"type"`
+	// DBRPs of the task in the form db.rp
+	DBRPs []string `json:"dbrp"`
+	// Path to a line protocol fixture file, used for stream tasks.
+	Data string `json:"data"`
+	// Paths to batch recording files, one per query, used for batch tasks.
+	Batches []string `json:"batches"`
+	// Precision of the times in the data file and in the expected InfluxDBOut points.
+	Precision string `json:"precision"`
+
+	Expect Expectations `json:"expect"`
+
+	// Directory relative paths are resolved against.
+	dir string
+}
+
+// Expectations of the outputs of a task.
+type Expectations struct {
+	// Alerts lists the expected alert events in order.
+	Alerts []ExpectedAlert `json:"alerts"`
+	// HTTPOut maps an httpOut endpoint name to its expected final result.
+	HTTPOut map[string]interface{} `json:"httpOut"`
+	// InfluxDBOut lists the expected writes of all InfluxDBOut nodes.
+	InfluxDBOut []ExpectedWrite `json:"influxdbOut"`
+}
+
+type ExpectedAlert struct {
+	ID      string `json:"id"`
+	Level   string `json:"level"`
+	Message string `json:"message"`
+	Time    string `json:"time"`
+}
+
+type ExpectedWrite struct {
+	Database        string   `json:"database"`
+	RetentionPolicy string   `json:"retentionPolicy"`
+	Points          []string `json:"points"`
+}
+
+// LoadSpec reads a spec from a YAML file.
+// Relative paths in the spec are resolved against the directory of the file.
+func LoadSpec(path string) (*Spec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := new(Spec)
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("invalid spec %s: %v", path, err)
+	}
+	if s.Name == "" {
+		base := filepath.Base(path)
+		s.Name = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	s.dir = filepath.Dir(path)
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid spec %s: %v", path, err)
+	}
+	return s, nil
+}
+
+// Validate checks that the spec is complete.
+func (s *Spec) Validate() error {
+	if s.TICKscript == "" {
+		return fmt.Errorf("must provide a tick file")
+	}
+	if _, err := s.taskType(); err != nil {
+		return err
+	}
+	if _, err := s.dbrps(); err != nil {
+		return err
+	}
+	switch s.Type {
+	case "stream":
+		if s.Data == "" {
+			return fmt.Errorf("must provide a data file for stream tasks")
+		}
+	case "batch":
+		if len(s.Batches) == 0 {
+			return fmt.Errorf("must provide batches for batch tasks")
+		}
+	}
+	for i, a := range s.Expect.Alerts {
+		if a.Time == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339Nano, a.Time); err != nil {
+			return fmt.Errorf("invalid time for alert %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func (s *Spec) path(p string) string {
+	if filepath.IsAbs(p) || s.dir == "" {
+		return p
+	}
+	return filepath.Join(s.dir, p)
+}
+
+func (s *Spec) taskID() string {
+	if s.Task != "" {
+		return s.Task
+	}
+	return s.Name
+}
+
+func (s *Spec) taskType() (kapacitor.TaskType, error) {
+	var tt kapacitor.TaskType
+	if err := tt.UnmarshalText([]byte(s.Type)); err != nil {
+		return 0, err
+	}
+	return tt, nil
+}
+
+func (s *Spec) dbrps() ([]kapacitor.DBRP, error) {
+	if len(s.DBRPs) == 0 {
+		return nil, fmt.Errorf("must provide at least one dbrp")
+	}
+	dbrps := make([]kapacitor.DBRP, len(s.DBRPs))
+	for i, v := range s.DBRPs {
+		parts := strings.SplitN(v, ".", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid dbrp %q, must be in the form db.rp", v)
+		}
+		dbrps[i] = kapacitor.DBRP{
+			Database:        strings.Trim(parts[0], `"`),
+			RetentionPolicy: strings.Trim(parts[1], `"`),
+		}
+	}
+	return dbrps, nil
+}
+
+func (s *Spec) precision() string {
+	if s.Precision == "" {
+		return "ns"
+	}
+	return s.Precision
+}
@@ -0,0 +1,75 @@
+package tasktest_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/influxdata/kapacitor/tasktest"
+)
+
+func TestRun(t *testing.T) {
+	s, err := tasktest.LoadSpec("testdata/cpu.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := s.Name, "cpu"; got != exp {
+		t.Errorf("unexpected name got %s exp %s", got, exp)
+	}
+	r, err := tasktest.Run(s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Passed() {
+		t.Errorf("expected test to pass, got failures:\n%v", r.Failures)
+	}
+}
+
+func TestRun_Failures(t *testing.T) {
+	s, err := tasktest.LoadSpec("testdata/cpu.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Expect.Alerts[0].Level = "WARNING"
+	s.Expect.InfluxDBOut[0].Points = s.Expect.InfluxDBOut[0].Points[:1]
+	r, err := tasktest.Run(s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := len(r.Failures), 2; got != exp {
+		t.Fatalf("unexpected number of failures got %d exp %d: %v", got, exp, r.Failures)
+	}
+	testCases := []struct {
+		output string
+		diff   string
+	}{
+		{
+			output: "alerts",
+			diff:   `+id="cpu" level=CRITICAL`,
+		},
+		{
+			output: "influxdbOut",
+			diff:   `+out.autogen cpu_mean mean=50 31536020`,
+		},
+	}
+	for i, tc := range testCases {
+		f := r.Failures[i]
+		if f.Output != tc.output {
+			t.Errorf("%d: unexpected output got %s exp %s", i, f.Output, tc.output)
+		}
+		if !strings.Contains(f.Diff, tc.diff) {
+			t.Errorf("%d: expected diff to contain %q, got:\n%s", i, tc.diff, f.Diff)
+		}
+	}
+}
+
+func TestLoadSpec_Invalid(t *testing.T) {
+	s := &tasktest.Spec{
+		TICKscript: "cpu.tick",
+		Type:       "stream",
+		DBRPs:      []string{"telegraf"},
+		Data:       "cpu.lp",
+	}
+	if err := s.Validate(); err == nil {
+		t.Error("expected error for invalid dbrp")
+	}
+}
@@ -0,0 +1,196 @@
+package kapacitor
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/influxdata/kapacitor/edge"
+	"github.com/influxdata/kapacitor/expvar"
+	"github.com/influxdata/kapacitor/models"
+	"github.com/influxdata/kapacitor/pipeline"
+	"github.com/influxdata/kapacitor/tick/stateful"
+)
+
+const (
+	statsPointsThrottled = "points_throttled"
+
+	// Full buckets are removed once a group has at least this many buckets.
+	throttleSweepSize = 1024
+)
+
+type ThrottleNode struct {
+	node
+	t *pipeline.ThrottleNode
+
+	burst float64
+	// Tokens added per nanosecond.
+	refill float64
+
+	expression stateful.Expression
+	scopePool  stateful.ScopePool
+
+	pointsThrottled *expvar.Int
+}
+
+// Create a new ThrottleNode which limits the rate of points.
+func newThrottleNode(et *ExecutingTask, n *pipeline.ThrottleNode, l *log.Logger) (*ThrottleNode, error) {
+	tn := &ThrottleNode{
+		node:            node{Node: n, et: et, logger: l},
+		t:               n,
+		burst:           float64(n.Burst),
+		refill:          float64(n.Rate) / float64(n.Per),
+		pointsThrottled: new(expvar.Int),
+	}
+	if n.Burst == 0 {
+		tn.burst = float64(n.Rate)
+	}
+	if n.Key != nil {
+		expr, err := stateful.NewExpression(n.Key.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to compile key expression: %v", err)
+		}
+		tn.expression = expr
+		tn.scopePool = stateful.NewScopePool(stateful.FindReferenceVariables(n.Key.Expression))
+	}
+	tn.node.runF = tn.runThrottle
+	return tn, nil
+}
+
+func (n *ThrottleNode) runThrottle([]byte) error {
+	n.statMap.Set(statsPointsThrottled, n.pointsThrottled)
+	consumer := n.newGroupedConsumer(n)
+	return consumer.Consume()
+}
+
+func (n *ThrottleNode) NewGroup(group edge.GroupInfo, first edge.PointMeta) (edge.Receiver, error) {
+	g := &throttleGroup{
+		n:       n,
+		buckets: make(map[string]*tokenBucket),
+		sweepAt: throttleSweepSize,
+	}
+	if n.expression != nil {
+		g.expr = n.expression.CopyReset()
+	}
+	return edge.NewReceiverFromForwardReceiverWithStats(
+		n.outs,
+		edge.NewTimedForwardReceiver(n.timer, g),
+	), nil
+}
+
+// tokenBucket holds the tokens of a key as of the last time tokens were taken.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type throttleGroup struct {
+	n    *ThrottleNode
+	expr stateful.Expression
+
+	buckets map[string]*tokenBucket
+	// The number of buckets at which full buckets are removed.
+	sweepAt int
+}
+
+func (g *throttleGroup) BeginBatch(begin edge.BeginBatchMessage) (edge.Message, error) {
+	begin = begin.ShallowCopy()
+	begin.SetSizeHint(0)
+	return begin, nil
+}
+
+func (g *throttleGroup) BatchPoint(bp edge.BatchPointMessage) (edge.Message, error) {
+	if g.allow(bp) {
+		return bp, nil
+	}
+	if g.n.t.ThrottledTag == "" {
+		return nil, nil
+	}
+	bp = bp.ShallowCopy()
+	bp.SetTags(g.throttledTags(bp.Tags()))
+	return bp, nil
+}
+
+func (g *throttleGroup) EndBatch(end edge.EndBatchMessage) (edge.Message, error) {
+	return end, nil
+}
+
+func (g *throttleGroup) Point(p edge.PointMessage) (edge.Message, error) {
+	if g.allow(p) {
+		return p, nil
+	}
+	if g.n.t.ThrottledTag == "" {
+		return nil, nil
+	}
+	p = p.ShallowCopy()
+	p.SetTags(g.throttledTags(p.Tags()))
+	return p, nil
+}
+
+func (g *throttleGroup) Barrier(b edge.BarrierMessage) (edge.Message, error) {
+	return b, nil
+}
+
+func (g *throttleGroup) DeleteGroup(d edge.DeleteGroupMessage) (edge.Message, error) {
+	return d, nil
+}
+
+// allow takes a token from the bucket of the point and reports whether there was one.
+func (g *throttleGroup) allow(p edge.FieldsTagsTimeGetter) bool {
+	var key string
+	if g.expr != nil {
+		k, err := evalKey(g.expr, g.n.scopePool, p)
+		if err != nil {
+			g.n.incrementErrorCount()
+			g.n.logger.Println("E! failed to compute throttle key:", err)
+			return true
+		}
+		key = k
+	}
+	t := p.Time()
+	b, ok := g.buckets[key]
+	if !ok {
+		if len(g.buckets) >= g.sweepAt {
+			g.sweep(t)
+		}
+		b = &tokenBucket{tokens: g.n.burst, last: t}
+		g.buckets[key] = b
+	}
+	b.refill(t, g.n.refill, g.n.burst)
+	if b.tokens < 1 {
+		g.n.pointsThrottled.Add(1)
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// sweep removes the buckets that are full at time t, they are the same as new buckets.
+func (g *throttleGroup) sweep(t time.Time) {
+	for key, b := range g.buckets {
+		if b.tokens+float64(t.Sub(b.last))*g.n.refill >= g.n.burst {
+			delete(g.buckets, key)
+		}
+	}
+	// Do not sweep again until the number of buckets has doubled.
+	g.sweepAt = 2 * len(g.buckets)
+	if g.sweepAt < throttleSweepSize {
+		g.sweepAt = throttleSweepSize
+	}
+}
+
+func (g *throttleGroup) throttledTags(tags models.Tags) models.Tags {
+	tags = tags.Copy()
+	tags[g.n.t.ThrottledTag] = "true"
+	return tags
+}
+
+func (b *tokenBucket) refill(t time.Time, rate, burst float64) {
+	if !t.After(b.last) {
+		// Points out of order do not refill the bucket.
+		return
+	}
+	b.tokens = math.Min(burst, b.tokens+float64(t.Sub(b.last))*rate)
+	b.last = t
+}
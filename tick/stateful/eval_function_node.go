@@ -29,6 +29,11 @@ func NewEvalFunctionNode(funcNode *ast.FunctionNode) (*EvalFunctionNode, error)
 
 		evalFuncNode.argsEvaluators = append(evalFuncNode.argsEvaluators, argEvaluator)
 	}
+	if ImplicitTime(funcNode) {
+		evalFuncNode.argsEvaluators = append(evalFuncNode.argsEvaluators, &EvalReferenceNode{
+			Node: &ast.ReferenceNode{Reference: "time"},
+		})
+	}
 
 	return evalFuncNode, nil
 }
@@ -3,7 +3,9 @@ package stateful_test
 import (
 	"errors"
 	"fmt"
+	"reflect"
 	"regexp"
+	"sort"
 	"testing"
 	"time"
 
@@ -48,6 +50,70 @@ func TestExpression_EvalNum_KeepsFunctionsState(t *testing.T) {
 
 }
 
+func TestExpression_EvalBool_Cusum(t *testing.T) {
+	se := mustCompileExpression(&ast.BinaryNode{
+		Operator: ast.TokenGreater,
+		Left: &ast.FunctionNode{
+			Func: "cusum",
+			Args: []ast.Node{
+				&ast.ReferenceNode{Reference: "value"},
+				&ast.NumberNode{IsFloat: true, Float64: 0.5},
+				&ast.NumberNode{IsInt: true, Int64: 3},
+			},
+		},
+		Right: &ast.NumberNode{IsInt: true, Int64: 0},
+	})
+
+	scope := stateful.NewScope()
+	for i, v := range []float64{10, 11, 9, 10, 12, 14} {
+		scope.Set("value", v)
+		result, err := se.EvalBool(scope)
+		if err != nil {
+			t.Fatalf("%d: Got unexpected error: %v", i, err)
+		}
+		if exp := i == 5; result != exp {
+			t.Errorf("%d: expected %t but got %t", i, exp, result)
+		}
+	}
+}
+
+func TestExpression_EvalFloat_RateImplicitTime(t *testing.T) {
+	node := &ast.FunctionNode{
+		Func: "rate",
+		Args: []ast.Node{
+			&ast.ReferenceNode{Reference: "value"},
+		},
+	}
+	got := stateful.FindReferenceVariables(node)
+	sort.Strings(got)
+	if exp := []string{"time", "value"}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("unexpected reference variables got %v exp %v", got, exp)
+	}
+	se := mustCompileExpression(node)
+
+	t0 := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	scope := stateful.NewScope()
+	for i, p := range []struct {
+		value float64
+		time  time.Time
+		exp   float64
+	}{
+		{value: 10, time: t0, exp: 0},
+		{value: 20, time: t0.Add(2 * time.Second), exp: 5},
+		{value: 10, time: t0.Add(4 * time.Second), exp: -5},
+	} {
+		scope.Set("value", p.value)
+		scope.Set("time", p.time)
+		result, err := se.EvalFloat(scope)
+		if err != nil {
+			t.Fatalf("%d: Got unexpected error: %v", i, err)
+		}
+		if result != p.exp {
+			t.Errorf("%d: expected %v but got %v", i, p.exp, result)
+		}
+	}
+}
+
 func TestExpression_EvalBool_BinaryNodeWithDurationNode(t *testing.T) {
 	leftValues := []interface{}{time.Duration(5), time.Duration(10)}
 	rightValues := []interface{}{time.Duration(5), time.Duration(10), int64(5)}
@@ -0,0 +1,28 @@
+package stateful
+
+import "github.com/influxdata/kapacitor/tick/ast"
+
+// FindReferenceVariables walks all nodes and returns a list of name from reference variables,
+// including "time" when a function call takes the time of the point implicitly.
+func FindReferenceVariables(nodes ...ast.Node) []string {
+	variables := ast.FindReferenceVariables(nodes...)
+	for _, v := range variables {
+		if v == "time" {
+			return variables
+		}
+	}
+
+	implicit := false
+	for _, node := range nodes {
+		ast.Walk(node, func(n ast.Node) (ast.Node, error) {
+			if fn, ok := n.(*ast.FunctionNode); ok && ImplicitTime(fn) {
+				implicit = true
+			}
+			return n, nil
+		})
+	}
+	if implicit {
+		variables = append(variables, "time")
+	}
+	return variables
+}
@@ -132,6 +132,22 @@ func FuncDomains(f Func) Domains {
 // Lookup for functions
 type Funcs map[string]Func
 
+// implicitTimeFunc is implemented by functions that can take the time of the point as an implicit last argument.
+type implicitTimeFunc interface {
+	// ImplicitTime reports whether the time argument is omitted when called with nargs arguments.
+	ImplicitTime(nargs int) bool
+}
+
+// ImplicitTime reports whether the time argument of the function call is omitted,
+// in which case it is the time of the point, i.e. rate("value") is rate("value", "time").
+func ImplicitTime(n *ast.FunctionNode) bool {
+	if n.Type != ast.GlobalFunc {
+		return false
+	}
+	f, ok := builtinFuncs[n.Func].(implicitTimeFunc)
+	return ok && f.ImplicitTime(len(n.Args))
+}
+
 var statelessFuncs Funcs
 
 var builtinFuncs Funcs
@@ -238,7 +254,7 @@ func init() {
 
 // Return set of built-in Funcs
 func NewFunctions() Funcs {
-	funcs := make(Funcs, len(statelessFuncs)+3)
+	funcs := make(Funcs, len(statelessFuncs)+9)
 	for n, f := range statelessFuncs {
 		funcs[n] = f
 	}
@@ -247,6 +263,12 @@ func NewFunctions() Funcs {
 	funcs["sigma"] = &sigma{}
 	funcs["count"] = &count{}
 	funcs["spread"] = &spread{min: math.Inf(+1), max: math.Inf(-1)}
+	funcs["ewma"] = &ewma{}
+	funcs["rate"] = &rate{}
+	funcs["delta"] = &delta{}
+	funcs["changed"] = &changed{}
+	funcs["cusum"] = &cusum{}
+	funcs["slope"] = &slope{}
 
 	return funcs
 }
@@ -1176,6 +1198,382 @@ func (s *spread) Signature() map[Domain]ast.ValueType {
 	return spreadFuncSignature
 }
 
+type ewma struct {
+	value float64
+	n     int64
+}
+
+func (e *ewma) Reset() {
+	e.value = 0
+	e.n = 0
+}
+
+// Computes the exponentially weighted moving average of the values, using the given smoothing factor.
+// The first value is the initial average.
+func (e *ewma) Call(args ...interface{}) (interface{}, error) {
+	if len(args) != 2 {
+		return 0, errors.New("ewma expects exactly two arguments")
+	}
+	x, ok := args[0].(float64)
+	if !ok {
+		return nil, ErrNotFloat
+	}
+	alpha, ok := args[1].(float64)
+	if !ok {
+		return nil, ErrNotFloat
+	}
+	if alpha <= 0 || alpha > 1 {
+		return nil, fmt.Errorf("ewma smoothing factor must be in (0, 1], got %v", alpha)
+	}
+	if e.n == 0 {
+		e.value = x
+	} else {
+		e.value = alpha*x + (1-alpha)*e.value
+	}
+	e.n++
+	return e.value, nil
+}
+
+var ewmaFuncSignature = map[Domain]ast.ValueType{}
+
+// Initialize Ewma Function Signature
+func init() {
+	d := Domain{}
+	d[0] = ast.TFloat
+	d[1] = ast.TFloat
+	ewmaFuncSignature[d] = ast.TFloat
+}
+
+func (e *ewma) Signature() map[Domain]ast.ValueType {
+	return ewmaFuncSignature
+}
+
+type rate struct {
+	prev     float64
+	prevTime time.Time
+	n        int64
+}
+
+func (r *rate) Reset() {
+	r.prev = 0
+	r.prevTime = time.Time{}
+	r.n = 0
+}
+
+// Computes the per second rate of change of the value since the previous value,
+// given the value and its time, i.e. rate("value", "time").
+// The time defaults to the time of the point, i.e. rate("value").
+// Returns 0 for the first value and for values with the same time as the previous value.
+func (r *rate) Call(args ...interface{}) (interface{}, error) {
+	if len(args) != 2 {
+		return 0, errors.New("rate expects exactly two arguments")
+	}
+	var x float64
+	switch a := args[0].(type) {
+	case float64:
+		x = a
+	case int64:
+		x = float64(a)
+	default:
+		return nil, fmt.Errorf("cannot pass %T to rate, must be float64 or int64", args[0])
+	}
+	t, ok := args[1].(time.Time)
+	if !ok {
+		return nil, fmt.Errorf("cannot pass %T to rate, must be time.Time", args[1])
+	}
+	v := 0.0
+	if r.n > 0 && t.After(r.prevTime) {
+		v = (x - r.prev) / t.Sub(r.prevTime).Seconds()
+	}
+	r.prev = x
+	r.prevTime = t
+	r.n++
+	return v, nil
+}
+
+var rateFuncSignature = map[Domain]ast.ValueType{}
+
+// Initialize Rate Function Signature
+func init() {
+	d := Domain{}
+	d[0] = ast.TFloat
+	d[1] = ast.TTime
+	rateFuncSignature[d] = ast.TFloat
+	d[0] = ast.TInt
+	rateFuncSignature[d] = ast.TFloat
+}
+
+func (r *rate) Signature() map[Domain]ast.ValueType {
+	return rateFuncSignature
+}
+
+func (r *rate) ImplicitTime(nargs int) bool {
+	return nargs == 1
+}
+
+type delta struct {
+	prev interface{}
+}
+
+func (d *delta) Reset() {
+	d.prev = nil
+}
+
+// Computes the difference between the value and the previous value.
+// Returns 0 for the first value.
+func (d *delta) Call(args ...interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return 0, errors.New("delta expects exactly one argument")
+	}
+	prev := d.prev
+	d.prev = args[0]
+	switch x := args[0].(type) {
+	case float64:
+		p, ok := prev.(float64)
+		if !ok {
+			return float64(0), nil
+		}
+		return x - p, nil
+	case int64:
+		p, ok := prev.(int64)
+		if !ok {
+			return int64(0), nil
+		}
+		return x - p, nil
+	default:
+		return nil, fmt.Errorf("cannot pass %T to delta, must be float64 or int64", args[0])
+	}
+}
+
+var deltaFuncSignature = map[Domain]ast.ValueType{}
+
+// Initialize Delta Function Signature
+func init() {
+	d := Domain{}
+	d[0] = ast.TFloat
+	deltaFuncSignature[d] = ast.TFloat
+	d[0] = ast.TInt
+	deltaFuncSignature[d] = ast.TInt
+}
+
+func (d *delta) Signature() map[Domain]ast.ValueType {
+	return deltaFuncSignature
+}
+
+type changed struct {
+	prev interface{}
+	n    int64
+}
+
+func (c *changed) Reset() {
+	c.prev = nil
+	c.n = 0
+}
+
+// Reports whether the value differs from the previous value.
+// Returns false for the first value.
+func (c *changed) Call(args ...interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return 0, errors.New("changed expects exactly one argument")
+	}
+	switch args[0].(type) {
+	case float64, int64, string, bool:
+	default:
+		return nil, fmt.Errorf("cannot pass %T to changed", args[0])
+	}
+	v := c.n > 0 && args[0] != c.prev
+	c.prev = args[0]
+	c.n++
+	return v, nil
+}
+
+var changedFuncSignature = map[Domain]ast.ValueType{}
+
+// Initialize Changed Function Signature
+func init() {
+	for _, t := range []ast.ValueType{ast.TFloat, ast.TInt, ast.TString, ast.TBool} {
+		d := Domain{}
+		d[0] = t
+		changedFuncSignature[d] = ast.TBool
+	}
+}
+
+func (c *changed) Signature() map[Domain]ast.ValueType {
+	return changedFuncSignature
+}
+
+type cusum struct {
+	// Running statistics of the values since the last change.
+	mean float64
+	m2   float64
+	n    float64
+	// Cumulative sums of the upward and downward deviations.
+	high float64
+	low  float64
+}
+
+func (c *cusum) Reset() {
+	c.mean = 0
+	c.m2 = 0
+	c.n = 0
+	c.high = 0
+	c.low = 0
+}
+
+// Detects a shift of the mean of the values using the cumulative sum control chart.
+// The deviations of the values from the running mean are measured in standard deviations,
+// deviations smaller than k are ignored and a shift is detected once the sum of the deviations exceeds h.
+// The optional fourth argument is the minimum standard deviation, used while the values have less variance,
+// e.g. while they are constant. Without it any deviation from constant values is detected as a shift.
+// Returns 1 if an upward shift is detected, -1 if a downward shift is detected and 0 otherwise.
+// After a shift is detected the running mean is computed from the values after the shift.
+func (c *cusum) Call(args ...interface{}) (interface{}, error) {
+	if len(args) != 3 && len(args) != 4 {
+		return 0, errors.New("cusum expects three or four arguments")
+	}
+	x, ok := args[0].(float64)
+	if !ok {
+		return nil, ErrNotFloat
+	}
+	// The thresholds and the minimum standard deviation may be written as integers.
+	var f [3]float64
+	for i, a := range args[1:] {
+		switch v := a.(type) {
+		case float64:
+			f[i] = v
+		case int64:
+			f[i] = float64(v)
+		default:
+			return nil, fmt.Errorf("cannot pass %T to cusum, must be float64 or int64", a)
+		}
+	}
+	k, h, minSigma := f[0], f[1], f[2]
+	if minSigma < 0 {
+		return nil, errors.New("cusum minimum standard deviation must be >= 0")
+	}
+
+	var shift int64
+	if c.n >= 2 {
+		sigma := math.Max(math.Sqrt(c.m2/(c.n-1)), minSigma)
+		if sigma == 0 {
+			// The values have been constant, any deviation is a shift.
+			switch {
+			case x > c.mean:
+				shift = 1
+			case x < c.mean:
+				shift = -1
+			}
+		} else {
+			z := (x - c.mean) / sigma
+			c.high = math.Max(0, c.high+z-k)
+			c.low = math.Max(0, c.low-z-k)
+			switch {
+			case c.high > h:
+				shift = 1
+			case c.low > h:
+				shift = -1
+			}
+		}
+	}
+	if shift != 0 {
+		c.Reset()
+	}
+	c.n++
+	delta := x - c.mean
+	c.mean += delta / c.n
+	c.m2 += delta * (x - c.mean)
+	return shift, nil
+}
+
+var cusumFuncSignature = map[Domain]ast.ValueType{}
+
+// Initialize Cusum Function Signature
+func init() {
+	thresholds := []ast.ValueType{ast.TFloat, ast.TInt}
+	for _, k := range thresholds {
+		for _, h := range thresholds {
+			d := Domain{}
+			d[0] = ast.TFloat
+			d[1] = k
+			d[2] = h
+			cusumFuncSignature[d] = ast.TInt
+			for _, s := range thresholds {
+				d[3] = s
+				cusumFuncSignature[d] = ast.TInt
+			}
+		}
+	}
+}
+
+func (c *cusum) Signature() map[Domain]ast.ValueType {
+	return cusumFuncSignature
+}
+
+type slope struct {
+	values []float64
+	// Index of the oldest value once the window is full.
+	next int
+}
+
+func (s *slope) Reset() {
+	s.values = s.values[:0]
+	s.next = 0
+}
+
+// Computes the least squares slope of the last window values, per value.
+// Returns 0 until there are at least two values.
+func (s *slope) Call(args ...interface{}) (interface{}, error) {
+	if len(args) != 2 {
+		return 0, errors.New("slope expects exactly two arguments")
+	}
+	x, ok := args[0].(float64)
+	if !ok {
+		return nil, ErrNotFloat
+	}
+	window, ok := args[1].(int64)
+	if !ok {
+		return nil, fmt.Errorf("cannot pass %T as window to slope, must be int64", args[1])
+	}
+	if window < 2 {
+		return nil, fmt.Errorf("slope window must be at least 2, got %d", window)
+	}
+	if int64(len(s.values)) < window {
+		s.values = append(s.values, x)
+	} else {
+		s.values[s.next] = x
+		s.next = (s.next + 1) % len(s.values)
+	}
+	n := len(s.values)
+	if n < 2 {
+		return float64(0), nil
+	}
+	// Regress the values against their position in the window, oldest first.
+	var sumY, sumXY float64
+	for i := 0; i < n; i++ {
+		y := s.values[(s.next+i)%n]
+		sumY += y
+		sumXY += float64(i) * y
+	}
+	fn := float64(n)
+	sumX := fn * (fn - 1) / 2
+	sumXX := (fn - 1) * fn * (2*fn - 1) / 6
+	return (fn*sumXY - sumX*sumY) / (fn*sumXX - sumX*sumX), nil
+}
+
+var slopeFuncSignature = map[Domain]ast.ValueType{}
+
+// Initialize Slope Function Signature
+func init() {
+	d := Domain{}
+	d[0] = ast.TFloat
+	d[1] = ast.TInt
+	slopeFuncSignature[d] = ast.TFloat
+}
+
+func (s *slope) Signature() map[Domain]ast.ValueType {
+	return slopeFuncSignature
+}
+
 // Time function signatures
 var timeFuncSignature = map[Domain]ast.ValueType{}
 
@@ -913,3 +913,134 @@ func Test_StatelessFuncs(t *testing.T) {
 	}
 
 }
+
+func Test_StatefulFuncs(t *testing.T) {
+	t0 := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	type call struct {
+		args []interface{}
+		exp  interface{}
+	}
+	testCases := []struct {
+		name  string
+		calls []call
+		err   error
+	}{
+		{
+			name: "ewma",
+			calls: []call{
+				{args: []interface{}{10.0, 0.5}, exp: 10.0},
+				{args: []interface{}{20.0, 0.5}, exp: 15.0},
+				{args: []interface{}{0.0, 0.5}, exp: 7.5},
+			},
+		},
+		{
+			name: "ewma",
+			calls: []call{
+				{args: []interface{}{10.0, 1.5}},
+			},
+			err: errors.New("ewma smoothing factor must be in (0, 1], got 1.5"),
+		},
+		{
+			name: "rate",
+			calls: []call{
+				{args: []interface{}{10.0, t0}, exp: 0.0},
+				{args: []interface{}{20.0, t0.Add(2 * time.Second)}, exp: 5.0},
+				{args: []interface{}{30.0, t0.Add(2 * time.Second)}, exp: 0.0},
+				{args: []interface{}{int64(10), t0.Add(4 * time.Second)}, exp: -10.0},
+			},
+		},
+		{
+			name: "delta",
+			calls: []call{
+				{args: []interface{}{1.0}, exp: 0.0},
+				{args: []interface{}{4.0}, exp: 3.0},
+				{args: []interface{}{2.0}, exp: -2.0},
+			},
+		},
+		{
+			name: "delta",
+			calls: []call{
+				{args: []interface{}{int64(5)}, exp: int64(0)},
+				{args: []interface{}{int64(7)}, exp: int64(2)},
+			},
+		},
+		{
+			name: "changed",
+			calls: []call{
+				{args: []interface{}{"a"}, exp: false},
+				{args: []interface{}{"a"}, exp: false},
+				{args: []interface{}{"b"}, exp: true},
+				{args: []interface{}{"b"}, exp: false},
+			},
+		},
+		{
+			name: "cusum",
+			calls: []call{
+				{args: []interface{}{10.0, 0.5, 3.0}, exp: int64(0)},
+				{args: []interface{}{11.0, 0.5, 3.0}, exp: int64(0)},
+				{args: []interface{}{9.0, 0.5, 3.0}, exp: int64(0)},
+				{args: []interface{}{10.0, 0.5, 3.0}, exp: int64(0)},
+				{args: []interface{}{12.0, 0.5, 3.0}, exp: int64(0)},
+				{args: []interface{}{14.0, 0.5, int64(3)}, exp: int64(1)},
+				{args: []interface{}{14.0, 0.5, 3.0}, exp: int64(0)},
+			},
+		},
+		{
+			name: "cusum",
+			calls: []call{
+				{args: []interface{}{10.0, 0.5, 3.0}, exp: int64(0)},
+				{args: []interface{}{10.0, 0.5, 3.0}, exp: int64(0)},
+				{args: []interface{}{10.0, 0.5, 3.0}, exp: int64(0)},
+				{args: []interface{}{9.9, 0.5, 3.0}, exp: int64(-1)},
+			},
+		},
+		{
+			name: "cusum",
+			calls: []call{
+				{args: []interface{}{10.0, 0.5, 3.0, 1.0}, exp: int64(0)},
+				{args: []interface{}{10.0, 0.5, 3.0, 1.0}, exp: int64(0)},
+				{args: []interface{}{10.0, 0.5, 3.0, 1.0}, exp: int64(0)},
+				{args: []interface{}{11.0, 0.5, 3.0, 1.0}, exp: int64(0)},
+				{args: []interface{}{14.0, 0.5, 3.0, int64(1)}, exp: int64(1)},
+			},
+		},
+		{
+			name: "cusum",
+			calls: []call{
+				{args: []interface{}{10.0, 0.5, 3.0, -1.0}},
+			},
+			err: errors.New("cusum minimum standard deviation must be >= 0"),
+		},
+		{
+			name: "slope",
+			calls: []call{
+				{args: []interface{}{1.0, int64(3)}, exp: 0.0},
+				{args: []interface{}{3.0, int64(3)}, exp: 2.0},
+				{args: []interface{}{5.0, int64(3)}, exp: 2.0},
+				{args: []interface{}{5.0, int64(3)}, exp: 1.0},
+				{args: []interface{}{5.0, int64(3)}, exp: 0.0},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		f := NewFunctions()[tc.name]
+		for i, c := range tc.calls {
+			result, err := f.Call(c.args...)
+			if tc.err != nil && i == len(tc.calls)-1 {
+				if err == nil {
+					t.Errorf("%s: expected error got: nil exp: %s", tc.name, tc.err)
+				} else if got, exp := err.Error(), tc.err.Error(); got != exp {
+					t.Errorf("%s: unexpected error\ngot:\n%s\nexp:\n%s", tc.name, got, exp)
+				}
+				continue
+			} else if err != nil {
+				t.Errorf("%s: unexpected error: %s", tc.name, err)
+				break
+			}
+			if result != c.exp {
+				t.Errorf("%s: unexpected result of call %d\ngot: %+v\nexp: %+v", tc.name, i, result, c.exp)
+			}
+		}
+	}
+}
@@ -42,3 +42,102 @@ func Create(
 	}
 	return t, nil
 }
+
+// CreateServer creates a new tls.Config object for a server from the given cert, key and client CA files.
+// If a client CA is given, clients must present a certificate signed by the CA.
+// The cipher suites and the minimum and maximum TLS versions are optional, see ParseCiphers and ParseVersion.
+func CreateServer(
+	SSLCA, SSLCert, SSLKey string,
+	ciphers []string,
+	minVersion, maxVersion string,
+) (*tls.Config, error) {
+	if SSLCert == "" || SSLKey == "" {
+		return nil, errors.New("Must provide both key and cert files.")
+	}
+	cert, err := tls.LoadX509KeyPair(SSLCert, SSLKey)
+	if err != nil {
+		return nil, fmt.Errorf("Could not load TLS server key/certificate: %s", err)
+	}
+	t := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if SSLCA != "" {
+		caCert, err := ioutil.ReadFile(SSLCA)
+		if err != nil {
+			return nil, fmt.Errorf("Could not load TLS client CA: %s", err)
+		}
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("No certificates found in TLS client CA %s", SSLCA)
+		}
+		t.ClientCAs = caCertPool
+		t.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	if t.CipherSuites, err = ParseCiphers(ciphers); err != nil {
+		return nil, err
+	}
+	if t.MinVersion, err = ParseVersion(minVersion); err != nil {
+		return nil, err
+	}
+	if t.MaxVersion, err = ParseVersion(maxVersion); err != nil {
+		return nil, err
+	}
+	if t.MinVersion != 0 && t.MaxVersion != 0 && t.MinVersion > t.MaxVersion {
+		return nil, fmt.Errorf("TLS min version %s is greater than max version %s", minVersion, maxVersion)
+	}
+	return t, nil
+}
+
+// ciphers are the configurable cipher suites, the 3DES and RSA key exchange CBC suites are not supported.
+var ciphers = map[string]uint16{
+	"TLS_RSA_WITH_AES_128_GCM_SHA256":         tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_RSA_WITH_AES_256_GCM_SHA384":         tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA":    tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA":    tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA":      tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA":      tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":   tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256": tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":   tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384": tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+}
+
+// ParseCiphers returns the IDs of the named cipher suites, i.e. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256.
+// A nil slice is returned if no names are given, so that the default cipher suites are used.
+// The cipher suites of TLS 1.3 are not configurable.
+func ParseCiphers(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	ids := make([]uint16, len(names))
+	for i, name := range names {
+		id, ok := ciphers[name]
+		if !ok {
+			return nil, fmt.Errorf("Unsupported TLS cipher suite %q", name)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+var versions = map[string]uint16{
+	"tls1.0": tls.VersionTLS10,
+	"tls1.1": tls.VersionTLS11,
+	"tls1.2": tls.VersionTLS12,
+	"tls1.3": tls.VersionTLS13,
+}
+
+// ParseVersion returns the TLS version with the name tls1.0, tls1.1, tls1.2 or tls1.3.
+// Zero is returned for an empty name, so that the default version is used.
+func ParseVersion(name string) (uint16, error) {
+	if name == "" {
+		return 0, nil
+	}
+	v, ok := versions[name]
+	if !ok {
+		return 0, fmt.Errorf("Unsupported TLS version %q, must be one of tls1.0, tls1.1, tls1.2 or tls1.3", name)
+	}
+	return v, nil
+}
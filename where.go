@@ -7,7 +7,6 @@ import (
 
 	"github.com/influxdata/kapacitor/edge"
 	"github.com/influxdata/kapacitor/pipeline"
-	"github.com/influxdata/kapacitor/tick/ast"
 	"github.com/influxdata/kapacitor/tick/stateful"
 )
 
@@ -32,7 +31,7 @@ func newWhereNode(et *ExecutingTask, n *pipeline.WhereNode, l *log.Logger) (wn *
 		return nil, fmt.Errorf("Failed to compile expression in where clause: %v", err)
 	}
 	wn.expression = expr
-	wn.scopePool = stateful.NewScopePool(ast.FindReferenceVariables(n.Lambda.Expression))
+	wn.scopePool = stateful.NewScopePool(stateful.FindReferenceVariables(n.Lambda.Expression))
 
 	wn.runF = wn.runWhere
 	if n.Lambda == nil {
@@ -42,11 +41,7 @@ func newWhereNode(et *ExecutingTask, n *pipeline.WhereNode, l *log.Logger) (wn *
 }
 
 func (n *WhereNode) runWhere(snapshot []byte) error {
-	consumer := edge.NewGroupedConsumer(
-		n.ins[0],
-		n,
-	)
-	n.statMap.Set(statCardinalityGauge, consumer.CardinalityVar())
+	consumer := n.newGroupedConsumer(n)
 
 	return consumer.Consume()
 }
@@ -1,25 +1,80 @@
 package kapacitor
 
 import (
+	"bytes"
+	"encoding/gob"
 	"errors"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/influxdata/kapacitor/edge"
+	"github.com/influxdata/kapacitor/expvar"
 	"github.com/influxdata/kapacitor/models"
 	"github.com/influxdata/kapacitor/pipeline"
 )
 
+const (
+	statWindowLimitDrops = "window_limit_drops"
+)
+
+const (
+	sessionStartField = "session_start"
+	sessionEndField   = "session_end"
+	sessionCountField = "session_count"
+)
+
 type WindowNode struct {
 	node
 	w *pipeline.WindowNode
+
+	limitDrops *expvar.Int
+	late       *lateHandler
+
+	mu      sync.Mutex
+	windows map[models.GroupID]windowState
+	// State of groups restored from a snapshot that have not received data yet.
+	restored map[models.GroupID]windowGroupSnapshot
+	// Time of the stream when the sessions of all groups were last checked for closing.
+	lastSweep time.Time
+}
+
+// windowState is implemented by the windows of a group.
+type windowState interface {
+	edge.ForwardReceiver
+	snapshot() windowGroupSnapshot
+	restore(windowGroupSnapshot)
+}
+
+type windowSnapshot struct {
+	Groups map[models.GroupID]windowGroupSnapshot
+}
+
+type windowGroupSnapshot struct {
+	Points []windowPoint
+	// Next and last emit time of a window by time.
+	NextEmit time.Time
+	LastEmit time.Time
+	// Count and next emit count of a window by count.
+	Count         int
+	NextEmitCount int
+}
+
+type windowPoint struct {
+	Name            string
+	Database        string
+	RetentionPolicy string
+	Dimensions      models.Dimensions
+	Tags            models.Tags
+	Fields          models.Fields
+	Time            time.Time
 }
 
 // Create a new  WindowNode, which windows data for a period of time and emits the window.
 func newWindowNode(et *ExecutingTask, n *pipeline.WindowNode, l *log.Logger) (*WindowNode, error) {
-	if n.Period == 0 && n.PeriodCount == 0 {
-		return nil, errors.New("window node must have either a non zero period or non zero period count")
+	if n.Period == 0 && n.PeriodCount == 0 && n.SessionGap == 0 {
+		return nil, errors.New("window node must have either a non zero period, period count or session gap")
 	}
 	wn := &WindowNode{
 		w:    n,
@@ -29,20 +84,106 @@ func newWindowNode(et *ExecutingTask, n *pipeline.WindowNode, l *log.Logger) (*W
 	return wn, nil
 }
 
-func (n *WindowNode) runWindow([]byte) error {
-	consumer := edge.NewGroupedConsumer(n.ins[0], n)
-	n.statMap.Set(statCardinalityGauge, consumer.CardinalityVar())
+func (n *WindowNode) runWindow(snapshot []byte) error {
+	n.windows = make(map[models.GroupID]windowState)
+	n.restored = make(map[models.GroupID]windowGroupSnapshot)
+	if len(snapshot) > 0 {
+		if err := n.restore(snapshot); err != nil {
+			n.incrementErrorCount()
+			n.logger.Println("E! failed to restore window state, starting with empty windows:", err)
+		}
+	}
+
+	n.limitDrops = new(expvar.Int)
+	if n.et.Task.Limits.MaxWindowPoints > 0 {
+		n.statMap.Set(statWindowLimitDrops, n.limitDrops)
+	}
+	n.late = n.newLateHandler()
+
+	consumer := n.newGroupedConsumer(n)
 	return consumer.Consume()
 }
 
+// sweepSessions closes the sessions of all groups that are closed at the stream time t.
+// Sessions are checked at most once per gap, so that a group that stops receiving points
+// has its session emitted without checking every group for every point.
+func (n *WindowNode) sweepSessions(t time.Time) error {
+	n.mu.Lock()
+	if t.Sub(n.lastSweep) < n.w.SessionGap {
+		n.mu.Unlock()
+		return nil
+	}
+	n.lastSweep = t
+	n.mu.Unlock()
+	return n.expireSessions(t)
+}
+
+// expireSessions closes and emits the sessions that are closed at the stream time t.
+func (n *WindowNode) expireSessions(t time.Time) error {
+	n.mu.Lock()
+	sessions := make([]*windowBySession, 0, len(n.windows))
+	for _, w := range n.windows {
+		if s, ok := w.(*windowBySession); ok {
+			sessions = append(sessions, s)
+		}
+	}
+	n.mu.Unlock()
+	for _, s := range sessions {
+		if b, ok := s.expire(t); ok {
+			if err := n.emit(b); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// emit forwards a message of another group than the one the consumer is processing.
+func (n *WindowNode) emit(m edge.Message) error {
+	for _, out := range n.outs {
+		if err := out.Collect(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sessionSweeper closes the sessions of all groups as the time of the stream advances.
+type sessionSweeper struct {
+	edge.ForwardReceiver
+	n *WindowNode
+}
+
+func (s sessionSweeper) Point(p edge.PointMessage) (edge.Message, error) {
+	msg, err := s.ForwardReceiver.Point(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.n.sweepSessions(p.Time()); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
 func (n *WindowNode) NewGroup(group edge.GroupInfo, first edge.PointMeta) (edge.Receiver, error) {
 	r, err := n.newWindow(group, first)
 	if err != nil {
 		return nil, err
 	}
+	n.mu.Lock()
+	if s, ok := n.restored[group.ID]; ok {
+		delete(n.restored, group.ID)
+		r.restore(s)
+	}
+	n.windows[group.ID] = r
+	n.mu.Unlock()
+	var fr edge.ForwardReceiver = r
+	if _, ok := r.(*windowBySession); ok {
+		fr = sessionSweeper{ForwardReceiver: r, n: n}
+	}
 	return edge.NewReceiverFromForwardReceiverWithStats(
 		n.outs,
-		edge.NewTimedForwardReceiver(n.timer, r),
+		edge.NewTimedForwardReceiver(n.timer, newLateFilter(n.late, fr)),
 	), nil
 }
 
@@ -50,10 +191,50 @@ func (n *WindowNode) DeleteGroup(group models.GroupID) {
 	// Nothing to do
 }
 
-func (n *WindowNode) newWindow(group edge.GroupInfo, first edge.PointMeta) (edge.ForwardReceiver, error) {
+// EvictGroup releases the window of an evicted group.
+func (n *WindowNode) EvictGroup(group models.GroupID) {
+	n.mu.Lock()
+	delete(n.windows, group)
+	n.mu.Unlock()
+}
+
+func (n *WindowNode) snapshot() ([]byte, error) {
+	n.mu.Lock()
+	s := windowSnapshot{
+		Groups: make(map[models.GroupID]windowGroupSnapshot, len(n.windows)+len(n.restored)),
+	}
+	for id, gs := range n.restored {
+		s.Groups[id] = gs
+	}
+	for id, w := range n.windows {
+		s.Groups[id] = w.snapshot()
+	}
+	n.mu.Unlock()
+	if len(s.Groups) == 0 {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (n *WindowNode) restore(data []byte) error {
+	var s windowSnapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return err
+	}
+	n.mu.Lock()
+	n.restored = s.Groups
+	n.mu.Unlock()
+	return nil
+}
+
+func (n *WindowNode) newWindow(group edge.GroupInfo, first edge.PointMeta) (windowState, error) {
 	switch {
 	case n.w.Period != 0:
-		return newWindowByTime(
+		w := newWindowByTime(
 			first.Name(),
 			first.Time(),
 			group,
@@ -61,8 +242,13 @@ func (n *WindowNode) newWindow(group edge.GroupInfo, first edge.PointMeta) (edge
 			n.w.Every,
 			n.w.AlignFlag,
 			n.w.FillPeriodFlag,
+			n.et.Task.Limits.MaxWindowPoints,
+			n.limitDrops,
 			n.logger,
-		), nil
+		)
+		w.late = n.late
+		w.lateUpdate = n.w.LateUpdateFlag
+		return w, nil
 	case n.w.PeriodCount != 0:
 		return newWindowByCount(
 			first.Name(),
@@ -72,8 +258,20 @@ func (n *WindowNode) newWindow(group edge.GroupInfo, first edge.PointMeta) (edge
 			n.w.FillPeriodFlag,
 			n.logger,
 		), nil
+	case n.w.SessionGap != 0:
+		w := newWindowBySession(
+			first.Name(),
+			group,
+			n.w.SessionGap,
+			n.w.SessionMaxLength,
+			n.et.Task.Limits.MaxWindowPoints,
+			n.limitDrops,
+			n.logger,
+		)
+		w.emit = n.emit
+		return w, nil
 	default:
-		return nil, errors.New("unreachable code, window node should have a non-zero period or period count")
+		return nil, errors.New("unreachable code, window node should have a non-zero period, period count or session gap")
 	}
 }
 
@@ -82,6 +280,8 @@ type windowByTime struct {
 	group edge.GroupInfo
 
 	nextEmit time.Time
+	// Time of the last emitted window, zero if no window was emitted yet.
+	lastEmit time.Time
 
 	buf *windowTimeBuffer
 
@@ -91,6 +291,18 @@ type windowByTime struct {
 	period time.Duration
 	every  time.Duration
 
+	// Maximum number of buffered points, zero means no limit.
+	maxPoints  int
+	limitDrops *expvar.Int
+
+	// Handler for points arriving for a window already emitted, nil if late points are not detected.
+	late *lateHandler
+	// Whether late points update the last emitted window.
+	lateUpdate bool
+
+	// mu guards the window while it is being snapshot.
+	mu sync.Mutex
+
 	logger *log.Logger
 }
 
@@ -102,6 +314,8 @@ func newWindowByTime(
 	every time.Duration,
 	align,
 	fillPeriod bool,
+	maxPoints int,
+	limitDrops *expvar.Int,
 	logger *log.Logger,
 
 ) *windowByTime {
@@ -133,6 +347,8 @@ func newWindowByTime(
 		fillPeriod: fillPeriod,
 		period:     period,
 		every:      every,
+		maxPoints:  maxPoints,
+		limitDrops: limitDrops,
 		logger:     logger,
 	}
 }
@@ -155,9 +371,14 @@ func (w *windowByTime) DeleteGroup(d edge.DeleteGroupMessage) (edge.Message, err
 }
 
 func (w *windowByTime) Point(p edge.PointMessage) (msg edge.Message, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.late != nil && p.Time().Before(w.lastEmit) {
+		return w.latePoint(p)
+	}
 	if w.every == 0 {
 		// Insert point before.
-		w.buf.insert(p)
+		w.insert(p)
 		// Since we are emitting every point we can use a right aligned window (oldest, now]
 		if !p.Time().Before(w.nextEmit) {
 			// purge old points
@@ -169,6 +390,7 @@ func (w *windowByTime) Point(p edge.PointMessage) (msg edge.Message, err error)
 
 			// Next emit time is now
 			w.nextEmit = p.Time()
+			w.lastEmit = p.Time()
 		}
 	} else {
 		// Since more points can arrive with the same time we need to use a left aligned window [oldest, now).
@@ -179,6 +401,7 @@ func (w *windowByTime) Point(p edge.PointMessage) (msg edge.Message, err error)
 
 			// get current batch
 			msg = w.batch(w.nextEmit)
+			w.lastEmit = w.nextEmit
 
 			// Determine next emit time.
 			// This is dependent on the current time not the last time we emitted.
@@ -188,11 +411,117 @@ func (w *windowByTime) Point(p edge.PointMessage) (msg edge.Message, err error)
 			}
 		}
 		// Insert point after.
-		w.buf.insert(p)
+		w.insert(p)
 	}
 	return
 }
 
+// latePoint handles a point that arrived for a window already emitted.
+// The last window is emitted again including the point if late updates are enabled,
+// otherwise the point is late.
+func (w *windowByTime) latePoint(p edge.PointMessage) (edge.Message, error) {
+	oldest := w.lastEmit.Add(-1 * w.period)
+	if !w.lateUpdate || p.Time().Before(oldest) {
+		return nil, w.late.late(p)
+	}
+	w.insert(p)
+	// Points of the last window, right aligned (oldest, lastEmit] if every point emits, else left aligned [oldest, lastEmit).
+	var points []edge.BatchPointMessage
+	for _, bp := range w.buf.pointMessages() {
+		t := bp.Time()
+		if w.every == 0 {
+			if !t.After(oldest) || t.After(w.lastEmit) {
+				continue
+			}
+		} else if t.Before(oldest) || !t.Before(w.lastEmit) {
+			continue
+		}
+		points = append(points, edge.BatchPointFromPoint(bp))
+	}
+	return edge.NewBufferedBatchMessage(
+		edge.NewBeginBatchMessage(
+			w.name,
+			w.group.Tags,
+			w.group.Dimensions.ByName,
+			w.lastEmit,
+			len(points),
+		),
+		points,
+		edge.NewEndBatchMessage(),
+	), nil
+}
+
+// insert adds the point to the buffer unless the buffer is full.
+// Points of windows already emitted are purged before the buffer is considered full.
+func (w *windowByTime) insert(p edge.PointMessage) {
+	if w.maxPoints > 0 && w.buf.size >= w.maxPoints {
+		w.purgeEmitted(p.Time())
+		if w.buf.size >= w.maxPoints {
+			w.limitDrops.Add(1)
+			return
+		}
+	}
+	w.buf.insertSorted(p)
+}
+
+// purgeEmitted purges the points that are not part of the next window at time t.
+// The points of the last window are kept if late points update it.
+func (w *windowByTime) purgeEmitted(t time.Time) {
+	lateUpdate := w.late != nil && w.lateUpdate && !w.lastEmit.IsZero()
+	if w.every == 0 {
+		oldest := t.Add(-1 * w.period)
+		if lateUpdate {
+			oldest = w.lastEmit.Add(-1 * w.period)
+		}
+		w.buf.purge(oldest, false)
+		return
+	}
+	oldest := w.nextEmit.Add(-1 * w.period)
+	if lateUpdate {
+		oldest = w.lastEmit.Add(-1 * w.period)
+	}
+	w.buf.purge(oldest, true)
+}
+
+func (w *windowByTime) snapshot() windowGroupSnapshot {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	s := windowGroupSnapshot{
+		NextEmit: w.nextEmit,
+		LastEmit: w.lastEmit,
+	}
+	for _, p := range w.buf.pointMessages() {
+		s.Points = append(s.Points, windowPoint{
+			Name:            p.Name(),
+			Database:        p.Database(),
+			RetentionPolicy: p.RetentionPolicy(),
+			Dimensions:      p.Dimensions(),
+			Tags:            p.Tags(),
+			Fields:          p.Fields(),
+			Time:            p.Time(),
+		})
+	}
+	return s
+}
+
+func (w *windowByTime) restore(s windowGroupSnapshot) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.nextEmit = s.NextEmit
+	w.lastEmit = s.LastEmit
+	for _, p := range s.Points {
+		w.insert(edge.NewPointMessage(
+			p.Name,
+			p.Database,
+			p.RetentionPolicy,
+			p.Dimensions,
+			p.Fields,
+			p.Tags,
+			p.Time,
+		))
+	}
+}
+
 // batch returns the current window buffer as a batch message.
 // TODO(nathanielc): A possible optimization could be to not buffer the data at all if we know that we do not have overlapping windows.
 func (w *windowByTime) batch(tmax time.Time) edge.BufferedBatchMessage {
@@ -260,6 +589,37 @@ func (b *windowTimeBuffer) insert(p edge.PointMessage) {
 	b.stop++
 }
 
+// Insert a single point into the buffer keeping the points ordered by time.
+// Points arriving out of order are rare, so the buffer is rebuilt for them.
+func (b *windowTimeBuffer) insertSorted(p edge.PointMessage) {
+	if b.size == 0 {
+		b.insert(p)
+		return
+	}
+	last := b.stop - 1
+	if last < 0 {
+		last = len(b.window) - 1
+	}
+	if !p.Time().Before(b.window[last].Time()) {
+		b.insert(p)
+		return
+	}
+	points := b.pointMessages()
+	i := len(points)
+	for i > 0 && p.Time().Before(points[i-1].Time()) {
+		i--
+	}
+	b.window = b.window[:0]
+	b.start, b.stop, b.size = 0, 0, 0
+	for _, bp := range points[:i] {
+		b.insert(bp)
+	}
+	b.insert(p)
+	for _, bp := range points[i:] {
+		b.insert(bp)
+	}
+}
+
 // Purge expired data from the window.
 func (b *windowTimeBuffer) purge(oldest time.Time, inclusive bool) {
 	include := func(t time.Time) bool {
@@ -298,6 +658,21 @@ func (b *windowTimeBuffer) purge(oldest time.Time, inclusive bool) {
 	}
 }
 
+// Returns the buffered points in order.
+func (b *windowTimeBuffer) pointMessages() []edge.PointMessage {
+	if b.size == 0 {
+		return nil
+	}
+	points := make([]edge.PointMessage, 0, b.size)
+	if b.stop > b.start {
+		points = append(points, b.window[b.start:b.stop]...)
+	} else {
+		points = append(points, b.window[b.start:]...)
+		points = append(points, b.window[:b.stop]...)
+	}
+	return points
+}
+
 // Returns a copy of the current buffer.
 // TODO(nathanielc): Optimize this function use buffered vs unbuffered batch messages.
 func (b *windowTimeBuffer) points() []edge.BatchPointMessage {
@@ -339,6 +714,9 @@ type windowByCount struct {
 	size     int
 	count    int
 
+	// mu guards the window while it is being snapshot.
+	mu sync.Mutex
+
 	logger *log.Logger
 }
 
@@ -382,13 +760,9 @@ func (w *windowByCount) DeleteGroup(d edge.DeleteGroupMessage) (edge.Message, er
 }
 
 func (w *windowByCount) Point(p edge.PointMessage) (msg edge.Message, err error) {
-	w.buf[w.stop] = edge.BatchPointFromPoint(p)
-	w.stop = (w.stop + 1) % w.period
-	if w.size == w.period {
-		w.start = (w.start + 1) % w.period
-	} else {
-		w.size++
-	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.insert(edge.BatchPointFromPoint(p))
 	w.count++
 	//Check if its time to emit
 	if w.count == w.nextEmit {
@@ -398,6 +772,43 @@ func (w *windowByCount) Point(p edge.PointMessage) (msg edge.Message, err error)
 	return
 }
 
+func (w *windowByCount) insert(bp edge.BatchPointMessage) {
+	w.buf[w.stop] = bp
+	w.stop = (w.stop + 1) % w.period
+	if w.size == w.period {
+		w.start = (w.start + 1) % w.period
+	} else {
+		w.size++
+	}
+}
+
+func (w *windowByCount) snapshot() windowGroupSnapshot {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	s := windowGroupSnapshot{
+		Count:         w.count,
+		NextEmitCount: w.nextEmit,
+	}
+	for _, bp := range w.points() {
+		s.Points = append(s.Points, windowPoint{
+			Tags:   bp.Tags(),
+			Fields: bp.Fields(),
+			Time:   bp.Time(),
+		})
+	}
+	return s
+}
+
+func (w *windowByCount) restore(s windowGroupSnapshot) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, p := range s.Points {
+		w.insert(edge.NewBatchPointMessage(p.Fields, p.Tags, p.Time))
+	}
+	w.count = s.Count
+	w.nextEmit = s.NextEmitCount
+}
+
 func (w *windowByCount) batch() edge.BufferedBatchMessage {
 	points := w.points()
 	return edge.NewBufferedBatchMessage(
@@ -435,3 +846,177 @@ func (w *windowByCount) points() []edge.BatchPointMessage {
 	}
 	return points
 }
+
+// windowBySession collects the points of a group until the session is closed by a gap of inactivity.
+type windowBySession struct {
+	name  string
+	group edge.GroupInfo
+
+	gap       time.Duration
+	maxLength time.Duration
+
+	buf   []edge.BatchPointMessage
+	start time.Time
+	last  time.Time
+
+	// Maximum number of buffered points, zero means no limit.
+	maxPoints  int
+	limitDrops *expvar.Int
+
+	// emit forwards sessions closed outside of the returned messages.
+	emit func(edge.Message) error
+
+	// mu guards the window while it is being snapshot or expired.
+	mu sync.Mutex
+
+	logger *log.Logger
+}
+
+func newWindowBySession(
+	name string,
+	group edge.GroupInfo,
+	gap,
+	maxLength time.Duration,
+	maxPoints int,
+	limitDrops *expvar.Int,
+	logger *log.Logger,
+) *windowBySession {
+	return &windowBySession{
+		name:       name,
+		group:      group,
+		gap:        gap,
+		maxLength:  maxLength,
+		maxPoints:  maxPoints,
+		limitDrops: limitDrops,
+		logger:     logger,
+	}
+}
+
+func (w *windowBySession) BeginBatch(edge.BeginBatchMessage) (edge.Message, error) {
+	return nil, errors.New("window does not support batch data")
+}
+func (w *windowBySession) BatchPoint(edge.BatchPointMessage) (edge.Message, error) {
+	return nil, errors.New("window does not support batch data")
+}
+func (w *windowBySession) EndBatch(edge.EndBatchMessage) (edge.Message, error) {
+	return nil, errors.New("window does not support batch data")
+}
+
+// Barrier closes the session if the barrier time is past the gap.
+// The closed session is emitted before the barrier is forwarded.
+func (w *windowBySession) Barrier(b edge.BarrierMessage) (edge.Message, error) {
+	w.mu.Lock()
+	if !w.closed(b.Time()) {
+		w.mu.Unlock()
+		return b, nil
+	}
+	batch := w.close()
+	w.mu.Unlock()
+	if err := w.emit(batch); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+func (w *windowBySession) DeleteGroup(d edge.DeleteGroupMessage) (edge.Message, error) {
+	return d, nil
+}
+
+func (w *windowBySession) Point(p edge.PointMessage) (msg edge.Message, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed(p.Time()) {
+		msg = w.close()
+	}
+	w.insert(edge.BatchPointFromPoint(p))
+	return
+}
+
+// closed reports whether the current session is closed at time t.
+func (w *windowBySession) closed(t time.Time) bool {
+	if len(w.buf) == 0 {
+		return false
+	}
+	if t.Sub(w.last) > w.gap {
+		return true
+	}
+	return w.maxLength > 0 && !t.Before(w.start.Add(w.maxLength))
+}
+
+// expire closes the session if it is closed at the stream time t.
+func (w *windowBySession) expire(t time.Time) (edge.BufferedBatchMessage, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.closed(t) {
+		return nil, false
+	}
+	return w.close(), true
+}
+
+func (w *windowBySession) insert(bp edge.BatchPointMessage) {
+	if w.maxPoints > 0 && len(w.buf) >= w.maxPoints {
+		w.limitDrops.Add(1)
+		return
+	}
+	switch {
+	case len(w.buf) == 0:
+		w.start = bp.Time()
+		w.last = bp.Time()
+	case bp.Time().Before(w.start):
+		w.start = bp.Time()
+	case bp.Time().After(w.last):
+		w.last = bp.Time()
+	}
+	w.buf = append(w.buf, bp)
+}
+
+// close returns the current session as a batch and starts a new session.
+func (w *windowBySession) close() edge.BufferedBatchMessage {
+	start := w.start.UnixNano()
+	end := w.last.UnixNano()
+	count := int64(len(w.buf))
+	points := make([]edge.BatchPointMessage, len(w.buf))
+	for i, bp := range w.buf {
+		fields := bp.Fields().Copy()
+		fields[sessionStartField] = start
+		fields[sessionEndField] = end
+		fields[sessionCountField] = count
+		points[i] = edge.NewBatchPointMessage(fields, bp.Tags(), bp.Time())
+	}
+	b := edge.NewBufferedBatchMessage(
+		edge.NewBeginBatchMessage(
+			w.name,
+			w.group.Tags,
+			w.group.Dimensions.ByName,
+			w.last,
+			len(points),
+		),
+		points,
+		edge.NewEndBatchMessage(),
+	)
+	w.buf = nil
+	w.start = time.Time{}
+	w.last = time.Time{}
+	return b
+}
+
+func (w *windowBySession) snapshot() windowGroupSnapshot {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var s windowGroupSnapshot
+	for _, bp := range w.buf {
+		s.Points = append(s.Points, windowPoint{
+			Tags:   bp.Tags(),
+			Fields: bp.Fields(),
+			Time:   bp.Time(),
+		})
+	}
+	return s
+}
+
+func (w *windowBySession) restore(s windowGroupSnapshot) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, p := range s.Points {
+		w.insert(edge.NewBatchPointMessage(p.Fields, p.Tags, p.Time))
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/influxdata/kapacitor/edge"
+	"github.com/influxdata/kapacitor/expvar"
 	"github.com/influxdata/kapacitor/models"
 	"github.com/stretchr/testify/assert"
 )
@@ -199,3 +200,176 @@ func TestWindowBufferByCount(t *testing.T) {
 		}
 	}
 }
+
+func TestWindowByTime_MaxPoints(t *testing.T) {
+	limitDrops := new(expvar.Int)
+	w := newWindowByTime("name", time.Unix(0, 0), edge.GroupInfo{}, 10*time.Second, 10*time.Second, false, false, 5, limitDrops, logger)
+	var batch edge.BufferedBatchMessage
+	for i := 0; i < 11; i++ {
+		p := edge.NewPointMessage("name", "db", "rp", models.Dimensions{}, nil, nil, time.Unix(int64(i), 0))
+		msg, err := w.Point(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg != nil {
+			batch = msg.(edge.BufferedBatchMessage)
+		}
+	}
+	if batch == nil {
+		t.Fatal("expected window to emit a batch")
+	}
+	if got, exp := len(batch.Points()), 5; got != exp {
+		t.Errorf("unexpected number of points got %d exp %d", got, exp)
+	}
+	// The points 5-9 are dropped, the point at 10s is kept
+	// since the points of the emitted window are purged once the buffer is full.
+	if got, exp := limitDrops.IntValue(), int64(5); got != exp {
+		t.Errorf("unexpected limit drops got %d exp %d", got, exp)
+	}
+	msg, err := w.Point(edge.NewPointMessage("name", "db", "rp", models.Dimensions{}, nil, nil, time.Unix(20, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := len(msg.(edge.BufferedBatchMessage).Points()), 1; got != exp {
+		t.Errorf("unexpected number of points in the next window got %d exp %d", got, exp)
+	}
+}
+
+func TestWindowBySession(t *testing.T) {
+	w := newWindowBySession("name", edge.GroupInfo{}, 10*time.Second, time.Minute, 0, new(expvar.Int), logger)
+	var batches []edge.BufferedBatchMessage
+	w.emit = func(m edge.Message) error {
+		batches = append(batches, m.(edge.BufferedBatchMessage))
+		return nil
+	}
+	point := func(sec int64) {
+		p := edge.NewPointMessage("name", "db", "rp", models.Dimensions{}, models.Fields{"value": float64(sec)}, nil, time.Unix(sec, 0))
+		msg, err := w.Point(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg != nil {
+			batches = append(batches, msg.(edge.BufferedBatchMessage))
+		}
+	}
+	// First session, closed by the gap, with a point out of order.
+	point(5)
+	point(0)
+	point(15)
+	// Second session, closed by the max length.
+	for sec := int64(30); sec <= 90; sec += 5 {
+		point(sec)
+	}
+	// Third session, closed by a barrier.
+	msg, err := w.Barrier(edge.NewBarrierMessage(time.Unix(100, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := msg.(edge.BarrierMessage); !ok {
+		t.Fatalf("expected barrier within the gap to be forwarded, got %T", msg)
+	}
+	msg, err = w.Barrier(edge.NewBarrierMessage(time.Unix(101, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := msg.(edge.BarrierMessage); !ok {
+		t.Fatalf("expected barrier to be forwarded after the session, got %T", msg)
+	}
+	// Fourth session, closed once the stream of another group moves past the gap.
+	point(110)
+	if _, ok := w.expire(time.Unix(120, 0)); ok {
+		t.Fatal("expected session within the gap to stay open")
+	}
+	b, ok := w.expire(time.Unix(121, 0))
+	if !ok {
+		t.Fatal("expected idle session to be closed")
+	}
+	batches = append(batches, b)
+
+	exp := []struct {
+		start, end, count int64
+	}{
+		{start: 0, end: 15, count: 3},
+		{start: 30, end: 85, count: 12},
+		{start: 90, end: 90, count: 1},
+		{start: 110, end: 110, count: 1},
+	}
+	if got, exp := len(batches), len(exp); got != exp {
+		t.Fatalf("unexpected number of sessions got %d exp %d", got, exp)
+	}
+	for i, e := range exp {
+		b := batches[i]
+		if got, exp := b.Time(), time.Unix(e.end, 0); !got.Equal(exp) {
+			t.Errorf("%d unexpected batch time got %v exp %v", i, got, exp)
+		}
+		if got := int64(len(b.Points())); got != e.count {
+			t.Errorf("%d unexpected number of points got %d exp %d", i, got, e.count)
+		}
+		for _, p := range b.Points() {
+			f := p.Fields()
+			if got, exp := f[sessionStartField], time.Unix(e.start, 0).UnixNano(); got != exp {
+				t.Errorf("%d unexpected session start got %v exp %v", i, got, exp)
+			}
+			if got, exp := f[sessionEndField], time.Unix(e.end, 0).UnixNano(); got != exp {
+				t.Errorf("%d unexpected session end got %v exp %v", i, got, exp)
+			}
+			if got, exp := f[sessionCountField], e.count; got != exp {
+				t.Errorf("%d unexpected session count got %v exp %v", i, got, exp)
+			}
+		}
+	}
+}
+
+func TestWindowByTime_LateUpdate(t *testing.T) {
+	late := &lateHandler{latePoints: new(expvar.Int)}
+	w := newWindowByTime("name", time.Unix(0, 0), edge.GroupInfo{}, 10*time.Second, 10*time.Second, false, false, 0, new(expvar.Int), logger)
+	w.late = late
+	w.lateUpdate = true
+	point := func(sec int64) edge.Message {
+		p := edge.NewPointMessage("name", "db", "rp", models.Dimensions{}, nil, nil, time.Unix(sec, 0))
+		msg, err := w.Point(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+	for i := int64(0); i < 10; i++ {
+		point(i)
+	}
+	if msg := point(10); msg == nil {
+		t.Fatal("expected window to emit a batch")
+	}
+	// Late point for the emitted window [0s, 10s).
+	msg := point(5)
+	if msg == nil {
+		t.Fatal("expected late point to emit an updated window")
+	}
+	b := msg.(edge.BufferedBatchMessage)
+	if got, exp := len(b.Points()), 11; got != exp {
+		t.Errorf("unexpected number of points got %d exp %d", got, exp)
+	}
+	if got, exp := b.Time(), time.Unix(10, 0); !got.Equal(exp) {
+		t.Errorf("unexpected batch time got %v exp %v", got, exp)
+	}
+	for i, p := range b.Points() {
+		if i > 0 && p.Time().Before(b.Points()[i-1].Time()) {
+			t.Errorf("points out of order at %d", i)
+		}
+	}
+	// Point older than the emitted window.
+	if msg := point(-1); msg != nil {
+		t.Errorf("unexpected message for point older than the last window %v", msg)
+	}
+	if got, exp := late.latePoints.IntValue(), int64(1); got != exp {
+		t.Errorf("unexpected late points got %d exp %d", got, exp)
+	}
+	// The late point is not part of the next window.
+	msg = point(20)
+	if msg == nil {
+		t.Fatal("expected window to emit a batch")
+	}
+	b = msg.(edge.BufferedBatchMessage)
+	if got, exp := len(b.Points()), 1; got != exp {
+		t.Errorf("unexpected number of points in next window got %d exp %d", got, exp)
+	}
+}